@@ -25,9 +25,16 @@ import (
 	"github.com/raincious/trap/trap"
 	"github.com/raincious/trap/trap/core"
 
+	auditPkg "github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/blocklist"
 	"github.com/raincious/trap/trap/core/event"
+	"github.com/raincious/trap/trap/core/firewall"
 	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/policy"
+	"github.com/raincious/trap/trap/core/signature"
 	statusPkg "github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/password"
+	"github.com/raincious/trap/trap/core/syslog"
 	"github.com/raincious/trap/trap/core/types"
 
 	"github.com/raincious/trap/trap/protocol/tcp"
@@ -39,24 +46,37 @@ import (
 	logPrinter "github.com/raincious/trap/trap/logger"
 
 	"bufio"
+	"crypto/tls"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"runtime/pprof"
+	"strings"
 	"syscall"
 	"time"
 )
 
 var (
-	logFile    = ""
-	silentRun  = false
-	cfgFile    = ""
-	cpuPrfFile = ""
-	memPrfFile = ""
+	logFile       = ""
+	logFormat     = ""
+	logMaxSize    = int64(0)
+	logMaxAge     = time.Duration(0)
+	logMaxBackups = 0
+	logCompress   = false
+	logLevel      = ""
+	logSyslog     = ""
+	logFacility   = ""
+	siemWriters   = []*syslog.Writer{}
+	silentRun     = false
+	cfgFile       = ""
+	cpuPrfFile    = ""
+	memPrfFile    = ""
 )
 
 func init() {
@@ -67,6 +87,35 @@ func init() {
 		"Save log data to specified file, "+
 			"keep it default to disable file logger.")
 
+	flag.StringVar(&logFormat, "log-format", "text",
+		"Format of the log file, \"text\" or \"json\" (one JSON object "+
+			"per line).")
+
+	flag.Int64Var(&logMaxSize, "log-max-size", 0,
+		"Rotate the log file when it's larger than specified megabytes, "+
+			"keep it 0 to disable.")
+
+	flag.DurationVar(&logMaxAge, "log-max-age", 0,
+		"Rotate the log file when it's older than specified duration "+
+			"like \"24h\", keep it 0 to disable.")
+
+	flag.IntVar(&logMaxBackups, "log-max-backups", 0,
+		"Maximum rotated log files to keep, keep it 0 to keep all.")
+
+	flag.BoolVar(&logCompress, "log-compress", false,
+		"Compress rotated log files with gzip.")
+
+	flag.StringVar(&logSyslog, "log-syslog", "",
+		"Send log to a syslog server, like \"udp://127.0.0.1:514\", "+
+			"\"tcp://127.0.0.1:514\" or \"unix:///dev/log\".")
+
+	flag.StringVar(&logFacility, "log-syslog-facility", "daemon",
+		"Syslog facility of the log, like \"daemon\" or \"local0\".")
+
+	flag.StringVar(&logLevel, "log-level", "",
+		"Override log levels of the configuration, like "+
+			"\"debug\" or \"info,Sync=debug,Sync:Server=error\".")
+
 	flag.StringVar(&cpuPrfFile, "profiling-cpu", "",
 		"Dump CPU profile data to specified file, "+
 			"keep it blank to disable profiling.")
@@ -81,7 +130,65 @@ func init() {
 	flag.Parse()
 }
 
-func initConfig(server *trap.Server, sync *trap.Sync, status *trap.Status) {
+// Apply the levels of the configuration and the command line. This also
+// runs on reload, so levels changed at runtime will be replaced
+func initLogLevels(logging *logger.Logger, cfg *config.Config) {
+	settings := logger.LevelSettings{
+		Level:    cfg.LogLevel,
+		Contexts: map[types.String]types.String{},
+	}
+
+	for context, level := range cfg.LogLevels {
+		settings.Contexts[context] = level
+	}
+
+	// Settings from command line always take the priority
+	for _, item := range types.String(logLevel).ExplodeWith(",") {
+		if item.Trim() == "" {
+			continue
+		}
+
+		if !item.Contains("=") {
+			settings.Level = item
+
+			continue
+		}
+
+		context, level := item.SpiltWith("=")
+
+		settings.Contexts[context.Trim()] = level
+	}
+
+	levelErr := logging.Levels().Apply(settings)
+
+	if levelErr != nil {
+		panic(fmt.Errorf("Can't set log levels due to error: %s", levelErr))
+	}
+}
+
+func toggleDebugLog(logging *logger.Logger, lastLevel *int) {
+	levels := logging.Levels()
+
+	current, _ := logger.ParseLevel(levels.Export().Level)
+
+	if current == logger.LOG_TYPE_DEBUG {
+		levels.SetLevel(*lastLevel)
+
+		logging.Infof("Debug log has been disabled")
+
+		return
+	}
+
+	*lastLevel = current
+
+	levels.SetLevel(logger.LOG_TYPE_DEBUG)
+
+	logging.Infof("Debug log has been enabled")
+}
+
+func initConfig(logging *logger.Logger, server *trap.Server,
+	sync *trap.Sync, status *trap.Status, metrics *trap.Metrics,
+	audit *trap.Audit) {
 	if cfgFile == "" {
 		panic(fmt.Errorf("Configuration is not specified. "+
 			"Please use command `%s -help` for more information",
@@ -95,6 +202,10 @@ func initConfig(server *trap.Server, sync *trap.Sync, status *trap.Status) {
 			cfgFile, err))
 	}
 
+	initLogLevels(logging, cfg)
+
+	logging.SetBufferSize(int(cfg.LogBufferSize))
+
 	if cfg.AttemptTimeout > 0 {
 		server.SetTimeout(time.Duration(cfg.AttemptTimeout) * time.Second)
 	}
@@ -109,6 +220,56 @@ func initConfig(server *trap.Server, sync *trap.Sync, status *trap.Status) {
 			time.Duration(cfg.AttemptRestrict.Int64())*time.Second)
 	}
 
+	if cfg.AttemptScanPorts > 0 && cfg.AttemptScanWindow > 0 {
+		server.SetScanDetect(cfg.AttemptScanPorts,
+			time.Duration(cfg.AttemptScanWindow.Int64())*time.Second)
+	}
+
+	if cfg.ClientMaxCount > 0 || cfg.ClientMaxMemory > 0 {
+		server.SetClientLimit(cfg.ClientMaxCount, cfg.ClientMaxMemory)
+	}
+
+	if cfg.AggregateThershold > 0 {
+		server.SetAggregate(cfg.AggregateIPv4, cfg.AggregateIPv6,
+			cfg.AggregateThershold)
+	}
+
+	if cfg.SignatureRules != "" {
+		rules, rulesErr := signature.Load(cfg.SignatureRules.String())
+
+		if rulesErr != nil {
+			panic(fmt.Errorf("Can't load signature rules '%s' due to error: %s",
+				cfg.SignatureRules, rulesErr))
+		}
+
+		server.SetSignatures(rules)
+	}
+
+	if cfg.PolicyFile != "" {
+		pol, polErr := policy.Load(cfg.PolicyFile.String())
+
+		if polErr != nil {
+			panic(fmt.Errorf("Can't load policy '%s' due to error: %s",
+				cfg.PolicyFile, polErr))
+		}
+
+		server.SetPolicy(pol)
+	}
+
+	if cfg.FirewallBackend != "" {
+		backend, backendErr := firewall.New(cfg.FirewallBackend,
+			cfg.FirewallSet)
+
+		if backendErr != nil {
+			panic(fmt.Errorf("Can't initialize firewall backend '%s' due "+
+				"to error: %s", cfg.FirewallBackend, backendErr))
+		}
+
+		server.SetFirewall(backend)
+	}
+
+	server.SetEventQueue(cfg.EventWorkers, cfg.EventQueueSize)
+
 	server.SetConcurrentLimit(100)
 
 	// Init TCP Protocol
@@ -138,30 +299,126 @@ func initConfig(server *trap.Server, sync *trap.Sync, status *trap.Status) {
 	for eventName, eventCommands := range cfg.Commands {
 		for _, eventCommand := range eventCommands {
 			func(eName types.String, eCmd config.Command) {
-				server.Event().Register(eName,
-					func(p *event.Parameters) *types.Throw {
-						var params []string
+				server.Event().RegisterCommand(eName, event.Command{
+					Path: eCmd.Command,
+					Arguments: func(p *event.Parameters) []types.String {
+						var params []types.String
 
 						for _, cmdParam := range eCmd.Parameters {
 							params = append(params, p.Parse(cmdParam.Format,
-								cmdParam.Labels).String())
+								cmdParam.Labels))
 						}
 
-						cmd := exec.Command(eCmd.Command.String(),
-							params...)
+						return params
+					},
+					Timeout: eCmd.Timeout,
+					Retries: cfg.EventRetries,
+					Backoff: time.Duration(
+						cfg.EventBackoff.Int64()) * time.Second,
+					Condition: eCmd.Condition,
+				})
+			}(eventName, eventCommand)
+		}
+	}
 
-						err := cmd.Run()
+	// Register batched events
+	for eventName, eventBatch := range cfg.Batches {
+		func(eName types.String, eBatch config.Batch) {
+			server.Event().RegisterBatch(eName, event.Batch{
+				Command: event.Command{
+					Path: eBatch.Command.Command,
+					Arguments: func(p *event.Parameters) []types.String {
+						var params []types.String
 
-						if err != nil {
-							return types.ConvertError(err)
+						for _, cmdParam := range eBatch.Command.Parameters {
+							params = append(params, p.Parse(cmdParam.Format,
+								cmdParam.Labels))
 						}
 
-						return nil
-					})
-			}(eventName, eventCommand)
+						return params
+					},
+					Timeout: eBatch.Command.Timeout,
+					Retries: cfg.EventRetries,
+					Backoff: time.Duration(
+						cfg.EventBackoff.Int64()) * time.Second,
+				},
+				Interval: eBatch.Interval,
+				MaxItems: int(eBatch.MaxItems),
+				Line: func(p *event.Parameters) types.String {
+					return p.Parse(eBatch.Line.Format, eBatch.Line.Labels)
+				},
+				Stdin: eBatch.Stdin,
+			})
+		}(eventName, eventBatch)
+	}
+
+	// Register webhooks
+	for _, hook := range cfg.Webhooks {
+		server.Event().RegisterWebhook(event.Webhook{
+			URL:        hook.URL,
+			Secret:     hook.Secret,
+			Events:     hook.Events,
+			Timeout:    hook.Timeout,
+			Retries:    hook.Retries,
+			Backoff:    hook.Backoff,
+			QueueSize:  int(hook.QueueSize),
+			DeadLetter: hook.DeadLetter,
+		})
+	}
+
+	// Register SIEM outputs, writers of the last configuration are no
+	// longer used after a reload
+	for _, writer := range siemWriters {
+		writer.Close()
+	}
+
+	siemWriters = []*syslog.Writer{}
+
+	// Writers connect in background, so a SIEM which is down for now
+	// will not stop the server from starting or reloading
+	for _, siem := range cfg.SIEMs {
+		writer, writerErr := syslog.Dial(siem.Network, siem.Address,
+			"trap")
+
+		if writerErr != nil {
+			logging.Errorf("Can't use SIEM '%s' due to error: %s",
+				siem.Address, writerErr)
+
+			continue
+		}
+
+		writer.OnError(func(err error) {
+			logging.Errorf("%s", err)
+		})
+
+		siemWriters = append(siemWriters, writer)
+
+		siemErr := server.Event().RegisterSIEM(event.SIEM{
+			Format:   siem.Format,
+			Writer:   writer,
+			Facility: siem.Facility,
+			Events:   siem.Events,
+		})
+
+		if siemErr != nil {
+			panic(fmt.Errorf("Can't register SIEM '%s' due to error: %s",
+				siem.Address, siemErr))
 		}
 	}
 
+	// Register co-processes
+	for _, co := range cfg.Coprocesses {
+		server.Event().RegisterCoprocess(event.Coprocess{
+			Path:       co.Command,
+			Arguments:  co.Arguments,
+			Events:     co.Events,
+			Timeout:    co.Timeout,
+			Retries:    co.Retries,
+			Backoff:    co.Backoff,
+			BufferSize: int(co.BufferSize),
+		})
+	}
+
 	// Start `Sync` Server for status sync
 	if cfg.SyncPort > 0 {
 		sync.SetPort(cfg.SyncPort)
@@ -209,10 +466,47 @@ func initConfig(server *trap.Server, sync *trap.Sync, status *trap.Status) {
 		})
 	}
 
+	// Keep administrative actions in the audit log, and write them to the
+	// `audit_file` when it's set
+	audit.SetSize(int(cfg.AuditBufferSize))
+	audit.SetForward(cfg.AuditEvents)
+
+	auditFileErr := audit.SetFile(cfg.AuditFile)
+
+	if auditFileErr != nil {
+		panic(fmt.Errorf("Can't open audit file '%s' due to error: %s",
+			cfg.AuditFile, auditFileErr))
+	}
+
+	// Export metrics on the `Status` server, and on it's own address
+	// without authorization when `metrics_listen` is set
+	metrics.SetServer(server)
+	metrics.SetSync(nil)
+	metrics.SetStatus(nil)
+
+	if cfg.SyncPort > 0 {
+		metrics.SetSync(sync)
+	}
+
+	if cfg.StatusPort > 0 {
+		metrics.SetStatus(status)
+	}
+
+	if cfg.MetricsListen != "" {
+		metrics.Listen(cfg.MetricsListen)
+
+		server.OnUpDown(func() *types.Throw {
+			return metrics.Serv()
+		}, func() *types.Throw {
+			return metrics.Down()
+		})
+	}
+
 	// Start `Status` Server to display some of the status of the server
 	if cfg.StatusPort > 0 {
 		status.SetServer(server)
 		status.SetSync(sync)
+		status.SetMetrics(metrics)
 
 		status.Port(cfg.StatusPort)
 
@@ -224,6 +518,51 @@ func initConfig(server *trap.Server, sync *trap.Sync, status *trap.Status) {
 			status.LoadCert(cfg.StatusTLSCert, cfg.StatusTLSCertKey)
 		}
 
+		status.BlocklistPublic(cfg.BlocklistPublic)
+
+		for user, userCfg := range cfg.StatusUsers {
+			_, sUserErr := status.User(user, userCfg.Password,
+				userCfg.Permissions)
+
+			if sUserErr == nil {
+				continue
+			}
+
+			panic(fmt.Errorf("Error registering status user '%s' due to "+
+				"error: %s", user, sUserErr))
+		}
+
+		sTokenFileErr := status.SetTokenFile(cfg.StatusTokenFile)
+
+		if sTokenFileErr != nil {
+			panic(fmt.Errorf("Can't load status tokens due to error: %s",
+				sTokenFileErr))
+		}
+
+		for _, token := range cfg.StatusTokens {
+			_, sTokenErr := status.Token(statusPkg.Token{
+				Name:     token.Name,
+				Scopes:   token.Scopes,
+				Networks: token.Networks,
+				Expire:   token.Expire,
+				Creator:  "config",
+			}, token.Hash)
+
+			if sTokenErr == nil {
+				continue
+			}
+
+			if sTokenErr.Is(statusPkg.ErrTokenRevoked) {
+				logging.Warningf("Status token '%s' has been revoked, "+
+					"remove it from the configuration", token.Name)
+
+				continue
+			}
+
+			panic(fmt.Errorf("Error registering status token '%s' due to "+
+				"error: %s", token.Name, sTokenErr))
+		}
+
 		for account, permissions := range cfg.StatusAccounts {
 			_, sAccErr := status.Account(account, permissions)
 
@@ -235,6 +574,12 @@ func initConfig(server *trap.Server, sync *trap.Sync, status *trap.Status) {
 				account, sAccErr))
 		}
 
+		if len(cfg.StatusAccounts) > 0 {
+			logging.Warningf("`status_accounts` stores passwords in plain " +
+				"text, please move them to `status_users` with password " +
+				"hashes generated by `trap hash-password`")
+		}
+
 		server.OnUpDown(func() *types.Throw {
 			return status.Serv()
 		}, func() *types.Throw {
@@ -243,7 +588,185 @@ func initConfig(server *trap.Server, sync *trap.Sync, status *trap.Status) {
 	}
 }
 
+// Read a password from stdin and print it's bcrypt hash for the
+// `status_users` option
+func hashPassword(args []string) {
+	hashFlags := flag.NewFlagSet("hash-password", flag.ExitOnError)
+	cost := hashFlags.Int("cost", password.DEFAULT_COST,
+		"The bcrypt cost, higher is slower and harder to crack.")
+
+	hashFlags.Parse(args)
+
+	fmt.Fprint(os.Stderr, "Password: ")
+
+	input, inputErr := bufio.NewReader(os.Stdin).ReadString('\n')
+
+	if inputErr != nil && input == "" {
+		panic(fmt.Errorf("Can't read password due to error: %s", inputErr))
+	}
+
+	hash, hashErr := password.Hash(
+		types.String(strings.TrimRight(input, "\r\n")), *cost)
+
+	if hashErr != nil {
+		panic(fmt.Errorf("Can't hash password due to error: %s", hashErr))
+	}
+
+	fmt.Println(hash)
+}
+
+// Print a new API token and the hash of it for the `status_tokens` option
+func generateToken() {
+	token, hash, tokenErr := statusPkg.NewTokenSecret()
+
+	if tokenErr != nil {
+		panic(fmt.Errorf("Can't generate token due to error: %s", tokenErr))
+	}
+
+	fmt.Printf("Token: %s\nHash:  %s\n", token, hash)
+}
+
+// Fetch the blocklist from the `Status` server of a running instance,
+// then print it or save it to a file
+func exportBlocklist(args []string) {
+	exportFlags := flag.NewFlagSet("export-blocklist", flag.ExitOnError)
+	statusURL := exportFlags.String("server", "http://127.0.0.1:1793",
+		"URL of the Status server.")
+	token := exportFlags.String("token", os.Getenv("TRAP_TOKEN"),
+		"API token which has the \"blocklist:read\" permission, "+
+			"can also be set by the TRAP_TOKEN environment variable.")
+	format := exportFlags.String("format", blocklist.FORMAT_PLAIN,
+		"Format of the blocklist, one of: "+
+			types.Strings(blocklist.Formats).ImplodeWith(", ").String()+".")
+	name := exportFlags.String("name", blocklist.DEFAULT_NAME,
+		"Name of the set, table or chain.")
+	tags := exportFlags.String("tag", "",
+		"Only export clients which have any of the comma separated tags.")
+	ports := exportFlags.String("port", "",
+		"Only export clients which hit any of the comma separated ports.")
+	maxAge := exportFlags.Duration("max-age", 0,
+		"Only export clients which have been seen in the duration, "+
+			"like \"24h\".")
+	output := exportFlags.String("output", "",
+		"Save the blocklist to the file instead of printing it, the "+
+			"file is left untouched when the blocklist is not changed.")
+	insecure := exportFlags.Bool("insecure", false,
+		"Do not verify the TLS certificate of the Status server.")
+
+	exportFlags.Parse(args)
+
+	params := url.Values{}
+
+	params.Set("format", *format)
+	params.Set("name", *name)
+
+	if *tags != "" {
+		params.Set("tag", *tags)
+	}
+
+	if *ports != "" {
+		params.Set("port", *ports)
+	}
+
+	if *maxAge > 0 {
+		params.Set("max_age", maxAge.String())
+	}
+
+	req, reqErr := http.NewRequest("GET", strings.TrimRight(*statusURL,
+		"/")+"/api/blocklist?"+params.Encode(), nil)
+
+	if reqErr != nil {
+		panic(fmt.Errorf("Can't create request due to error: %s", reqErr))
+	}
+
+	if *token != "" {
+		req.Header.Set(statusPkg.STATUS_SERVER_AUTH_HEADER,
+			statusPkg.STATUS_SERVER_AUTH_BEARER+*token)
+	}
+
+	// Only download the blocklist when it's different from the saved one
+	if *output != "" {
+		saved, savedErr := ioutil.ReadFile(*output)
+
+		if savedErr == nil {
+			req.Header.Set("If-None-Match", blocklist.ETag(saved))
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.TLSClientConfig = &tls.Config{
+		InsecureSkipVerify: *insecure,
+	}
+
+	resp, respErr := (&http.Client{
+		Transport: transport,
+		Timeout:   30 * time.Second,
+	}).Do(req)
+
+	if respErr != nil {
+		panic(fmt.Errorf("Can't fetch blocklist due to error: %s", respErr))
+	}
+
+	defer resp.Body.Close()
+
+	body, bodyErr := ioutil.ReadAll(resp.Body)
+
+	if bodyErr != nil {
+		panic(fmt.Errorf("Can't read blocklist due to error: %s", bodyErr))
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		panic(fmt.Errorf("Can't fetch blocklist, server responded %d: %s",
+			resp.StatusCode, strings.TrimSpace(string(body))))
+	}
+
+	if *output == "" {
+		os.Stdout.Write(body)
+
+		return
+	}
+
+	// Replace the file at once, so readers never see a partial blocklist
+	tempFile := *output + ".tmp"
+
+	writeErr := ioutil.WriteFile(tempFile, body, 0644)
+
+	if writeErr == nil {
+		writeErr = os.Rename(tempFile, *output)
+	}
+
+	if writeErr != nil {
+		os.Remove(tempFile)
+
+		panic(fmt.Errorf("Can't save blocklist to '%s' due to error: %s",
+			*output, writeErr))
+	}
+}
+
 func main() {
+	// Run the helper commands rather than the server
+	switch flag.Arg(0) {
+	case "hash-password":
+		hashPassword(flag.Args()[1:])
+
+		return
+
+	case "generate-token":
+		generateToken()
+
+		return
+
+	case "export-blocklist":
+		exportBlocklist(flag.Args()[1:])
+
+		return
+	}
+
 	// Enable CPU profiling
 	if cpuPrfFile != "" {
 		cpuPrfFileFile, cpuPrfFileErr := os.OpenFile(cpuPrfFile,
@@ -292,38 +815,88 @@ func main() {
 
 	log.SetOutput(logging.NewContext("System"))
 
+	var logFileHander *logPrinter.RotatingFile = nil
+
 	// Register file logger if variable `logFile` is filled
 	if logFile != "" {
-		logFileHander, logFErr := os.OpenFile(logFile,
-			os.O_CREATE|os.O_TRUNC|os.O_APPEND|os.O_WRONLY,
-			0600)
+		reportLogErr := func(err error) {
+			fmt.Fprintf(os.Stderr, "Log file error: %s\n", err)
+		}
+
+		var logFErr *types.Throw = nil
+
+		logFileHander, logFErr = logPrinter.NewRotatingFile(
+			logPrinter.RotateConfig{
+				Path:       logFile,
+				MaxSize:    logMaxSize * 1024 * 1024,
+				MaxAge:     logMaxAge,
+				MaxBackups: logMaxBackups,
+				Compress:   logCompress,
+				OnError:    reportLogErr,
+			})
 
 		if logFErr != nil {
 			panic(fmt.Errorf("Can't open log file '%s' due to error: %s",
 				logFile, logFErr))
 		}
 
-		loggerBuffer := bufio.NewWriter(logFileHander)
+		defer logFileHander.Close()
 
-		defer func() {
-			loggerBuffer.Flush()
+		switch logFormat {
+		case "json":
+			logging.Register(logPrinter.NewJSONPrinter(logFileHander,
+				reportLogErr))
 
-			logFileHander.Close()
-		}()
+		case "text":
+			fileLogPrinter, fLPErr := logPrinter.NewFilePrinter(logFileHander)
 
-		fileLogPrinter, fLPErr := logPrinter.NewFilePrinter(loggerBuffer)
+			if fLPErr != nil {
+				panic(fmt.Errorf("Can't create File logger due to error: %s",
+					fLPErr))
+			}
 
-		if fLPErr != nil {
-			panic(fmt.Errorf("Can't create File logger due to error: %s",
-				fLPErr))
-		}
+			logging.Register(fileLogPrinter)
 
-		logging.Register(fileLogPrinter)
+		default:
+			panic(fmt.Errorf("Unknown log format '%s'", logFormat))
+		}
 	} else if !silentRun {
 		// Or, register screen logger instead
 		logging.Register(logPrinter.NewScreenPrinter())
 	}
 
+	// Register syslog logger if variable `logSyslog` is filled
+	if logSyslog != "" {
+		syslogURL := strings.SplitN(logSyslog, "://", 2)
+
+		if len(syslogURL) != 2 {
+			panic(fmt.Errorf("Invalid syslog address '%s'", logSyslog))
+		}
+
+		facility, facilityErr := syslog.ParseFacility(
+			types.String(logFacility))
+
+		if facilityErr != nil {
+			panic(fmt.Errorf("Can't use syslog facility '%s' due to "+
+				"error: %s", logFacility, facilityErr))
+		}
+
+		syslogWriter, syslogErr := syslog.Dial(types.String(syslogURL[0]),
+			types.String(syslogURL[1]), "trap")
+
+		if syslogErr != nil {
+			panic(fmt.Errorf("Can't use syslog '%s' due to error: %s",
+				logSyslog, syslogErr))
+		}
+
+		defer syslogWriter.Close()
+
+		logging.Register(logPrinter.NewSyslogPrinter(syslogWriter, facility,
+			func(err error) {
+				fmt.Fprintf(os.Stderr, "Syslog error: %s\n", err)
+			}))
+	}
+
 	// Start booting
 	server := trap.NewServer()
 
@@ -342,7 +915,23 @@ func main() {
 	sync.SetLogger(logging)
 	sync.SetServer(server)
 
-	initConfig(server, sync, status)
+	// Init Metrics server
+	metrics := trap.NewMetrics()
+
+	metrics.SetLogger(logging)
+
+	// Init Audit log
+	audit := trap.NewAudit()
+
+	defer audit.Close()
+
+	audit.SetLogger(logging)
+	audit.SetServer(server)
+
+	status.SetAudit(audit)
+	sync.SetAudit(audit)
+
+	initConfig(logging, server, sync, status, metrics, audit)
 
 	servErr := server.Serv()
 
@@ -355,10 +944,13 @@ func main() {
 
 	// Catch system signals
 	signalCall := make(chan os.Signal, 1)
+	lastLogLevel := logger.LOG_TYPE_INFO
 
 	// Register system signal handlers
 	signal.Notify(signalCall,
-		syscall.SIGHUP, // For Reload
+		syscall.SIGHUP,  // For Reload
+		syscall.SIGUSR1, // For reopening log file
+		syscall.SIGUSR2, // For toggling debug log
 		syscall.SIGINT,
 		syscall.SIGTERM) // Control + C
 
@@ -370,7 +962,7 @@ func main() {
 
 		switch {
 		case callSignal == syscall.SIGHUP:
-			server.Reload(func(s *trap.Server) *types.Throw {
+			reloadErr := server.Reload(func(s *trap.Server) *types.Throw {
 				statusErr := status.Reset()
 
 				if statusErr != nil &&
@@ -378,11 +970,49 @@ func main() {
 					return statusErr
 				}
 
-				initConfig(s, sync, status)
+				initConfig(logging, s, sync, status, metrics, audit)
 
 				return nil
 			})
 
+			reloadEntry := auditPkg.Entry{
+				Actor:  auditPkg.ACTOR_SYSTEM,
+				Action: auditPkg.ACTION_CONFIG_RELOAD,
+				Target: types.String(cfgFile),
+			}
+
+			if reloadErr != nil {
+				reloadEntry.Error = types.String(reloadErr.Error())
+			}
+
+			audit.Record(reloadEntry)
+
+		case callSignal == syscall.SIGUSR1:
+			auditReopenErr := audit.Reopen()
+
+			if auditReopenErr != nil {
+				logging.Errorf("Can't reopen audit file due to error: %s",
+					auditReopenErr)
+			}
+
+			if logFileHander == nil {
+				break
+			}
+
+			reopenErr := logFileHander.Reopen()
+
+			if reopenErr != nil {
+				fmt.Fprintf(os.Stderr, "Can't reopen log file '%s' due "+
+					"to error: %s\n", logFile, reopenErr)
+
+				break
+			}
+
+			logging.Infof("Log file has been reopened")
+
+		case callSignal == syscall.SIGUSR2:
+			toggleDebugLog(logging, &lastLogLevel)
+
 		case callSignal == syscall.SIGINT || callSignal == syscall.SIGTERM:
 			logging.Infof("Exit signal picked up")
 
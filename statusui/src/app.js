@@ -53,6 +53,14 @@
                             return vueObj.records.source.clients.loaded;
                         },
                         fetcher: function(vueObj, finished) {
+                            if (!vueObj.auth || !vueObj.auth.permissions['clients:read']) {
+                                vueObj.records.source.clients.loaded = true;
+
+                                finished();
+
+                                return;
+                            }
+
                             vueObj.requestJson('GET', '/api/clients', {}, function(data) {
                                 vueObj.clientList = data;
                                 vueObj.records.source.clients.loaded = true;
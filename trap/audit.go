@@ -0,0 +1,181 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trap
+
+import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/types"
+
+	"os"
+)
+
+// Audit records the actions which changed the state of the trap. The
+// entries are kept in memory, and appended to the audit file when it's
+// set. Nothing can remove an entry from the file
+type Audit struct {
+	log      *audit.Log
+	logger   *logger.Logger
+	server   *Server
+	file     *os.File
+	filePath types.String
+	forward  bool
+	fileLock types.Mutex
+}
+
+func NewAudit() *Audit {
+	newAudit := &Audit{
+		log:      audit.NewLog(),
+		fileLock: types.Mutex{},
+	}
+
+	newAudit.log.OnRecord(newAudit.forwardEntry)
+
+	return newAudit
+}
+
+func (this *Audit) SetLogger(l *logger.Logger) {
+	this.logger = l.NewContext("Audit")
+}
+
+func (this *Audit) SetServer(s *Server) {
+	this.server = s
+}
+
+// SetSize changes how many entries will be kept in memory
+func (this *Audit) SetSize(size int) {
+	this.log.SetSize(size)
+}
+
+// SetForward sets whether entries will be triggered as the `on.audit`
+// event, so they can be sent by the event handlers
+func (this *Audit) SetForward(forward bool) {
+	this.fileLock.Exec(func() {
+		this.forward = forward
+	})
+}
+
+func (this *Audit) openFile(path types.String) *types.Throw {
+	file, openErr := os.OpenFile(path.String(),
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+
+	if openErr != nil {
+		return types.ConvertError(openErr)
+	}
+
+	// Switch the writer before closing, so no entry goes to closed file
+	this.log.SetWriter(file)
+
+	if this.file != nil {
+		this.file.Close()
+	}
+
+	this.file = file
+	this.filePath = path
+
+	return nil
+}
+
+// SetFile opens the file which the entries will be appended to, an empty
+// path stops writing into the file
+func (this *Audit) SetFile(path types.String) *types.Throw {
+	var result *types.Throw = nil
+
+	this.fileLock.Exec(func() {
+		if path == this.filePath && this.file != nil {
+			return
+		}
+
+		if path != "" {
+			result = this.openFile(path)
+
+			return
+		}
+
+		this.log.SetWriter(nil)
+
+		if this.file != nil {
+			this.file.Close()
+		}
+
+		this.file = nil
+		this.filePath = ""
+	})
+
+	return result
+}
+
+// Reopen the audit file, so it can be rotated by other tools
+func (this *Audit) Reopen() *types.Throw {
+	var result *types.Throw = nil
+
+	this.fileLock.Exec(func() {
+		if this.filePath == "" {
+			return
+		}
+
+		result = this.openFile(this.filePath)
+	})
+
+	return result
+}
+
+func (this *Audit) Close() *types.Throw {
+	return this.SetFile("")
+}
+
+func (this *Audit) forwardEntry(entry audit.Entry) {
+	forward := false
+
+	this.fileLock.Exec(func() {
+		forward = this.forward
+	})
+
+	if !forward || this.server == nil {
+		return
+	}
+
+	this.server.Event().Trigger("on.audit", auditParameters(entry))
+}
+
+// Record the entry, failures of writing the audit file will be logged
+func (this *Audit) Record(entry audit.Entry) {
+	var filePath types.String = ""
+
+	recorded, recordErr := this.log.Record(entry)
+
+	if recordErr == nil || this.logger == nil {
+		return
+	}
+
+	this.fileLock.Exec(func() {
+		filePath = this.filePath
+	})
+
+	this.logger.Errorf("Audit entry '%d' (%s by '%s') can't be written "+
+		"into '%s': %s", recorded.ID, recorded.Action, recorded.Actor,
+		filePath, recordErr)
+}
+
+func (this *Audit) Query(q audit.Query) audit.QueryResult {
+	return this.log.Query(q)
+}
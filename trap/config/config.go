@@ -1,8 +1,10 @@
 package config
 
 import (
+	"github.com/raincious/trap/trap/core/event"
 	"github.com/raincious/trap/trap/core/types"
 
+	"net"
 	"time"
 )
 
@@ -19,10 +21,74 @@ type Parameter struct {
 type Command struct {
 	Command    types.String
 	Parameters []Parameter
+	Condition  *event.Condition
+	Timeout    time.Duration
 }
 
 type Commands map[types.String][]Command
 
+type Batch struct {
+	Interval time.Duration
+	MaxItems types.UInt32
+	Line     Parameter
+	Stdin    bool
+	Command  Command
+}
+
+type Batches map[types.String]Batch
+
+type Webhook struct {
+	URL        types.String
+	Secret     types.String
+	Events     []types.String
+	Timeout    time.Duration
+	Retries    types.UInt16
+	Backoff    time.Duration
+	QueueSize  types.UInt32
+	DeadLetter types.String
+}
+
+type Webhooks []Webhook
+
+type Coprocess struct {
+	Command    types.String
+	Arguments  []types.String
+	Events     []types.String
+	Timeout    time.Duration
+	Retries    types.UInt16
+	Backoff    time.Duration
+	BufferSize types.UInt32
+}
+
+type Coprocesses []Coprocess
+
+type SIEM struct {
+	Format   types.String
+	Network  types.String
+	Address  types.String
+	Facility int
+	Events   []types.String
+}
+
+type SIEMs []SIEM
+
+type StatusUser struct {
+	Password    types.String
+	Permissions []types.String
+}
+
+type StatusUsers map[types.String]StatusUser
+
+type StatusToken struct {
+	Name     types.String
+	Hash     types.String
+	Scopes   []types.String
+	Networks []*net.IPNet
+	Expire   time.Time
+}
+
+type StatusTokens []StatusToken
+
 type Server struct {
 	Address    types.IPAddress
 	Passphrase types.String
@@ -34,6 +100,10 @@ type Listens []Listen
 type Config struct {
 	Log types.String
 
+	LogLevel      types.String
+	LogLevels     map[types.String]types.String
+	LogBufferSize types.UInt32
+
 	Listens Listens
 
 	AttemptTimeout   types.UInt32
@@ -42,14 +112,51 @@ type Config struct {
 	AttemptExpire    types.UInt32
 	AttemptRestrict  types.UInt32
 
-	Commands Commands
+	AttemptScanPorts  types.UInt16
+	AttemptScanWindow types.UInt32
+
+	ClientMaxCount  types.UInt32
+	ClientMaxMemory types.UInt64
+
+	AggregateIPv4      types.UInt16
+	AggregateIPv6      types.UInt16
+	AggregateThershold types.UInt32
+
+	SignatureRules types.String
+	PolicyFile     types.String
+
+	FirewallBackend types.String
+	FirewallSet     types.String
+
+	EventWorkers   types.UInt16
+	EventQueueSize types.UInt32
+	EventTimeout   types.UInt32
+	EventRetries   types.UInt16
+	EventBackoff   types.UInt32
+
+	Commands    Commands
+	Batches     Batches
+	Webhooks    Webhooks
+	Coprocesses Coprocesses
+	SIEMs       SIEMs
 
 	StatusInterface  types.IP
 	StatusPort       types.UInt16
 	StatusAccounts   map[types.String][]types.String
+	StatusUsers      StatusUsers
+	StatusTokens     StatusTokens
+	StatusTokenFile  types.String
 	StatusTLSCert    types.String
 	StatusTLSCertKey types.String
 
+	MetricsListen types.String
+
+	AuditBufferSize types.UInt32
+	AuditFile       types.String
+	AuditEvents     bool
+
+	BlocklistPublic bool
+
 	SyncInterface    types.IP
 	SyncPort         types.UInt16
 	SyncReceiveLen   types.UInt16
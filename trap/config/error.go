@@ -8,4 +8,22 @@ var (
 	ErrParseInvalidItem *types.Error = types.NewError("Invalid value \"%s\" in option `%s`.")
 
 	ErrInvalidCmdItem *types.Error = types.NewError("The number '%d' option in `Command` set '%s' is invalid.")
+
+	ErrInvalidCmdCondition *types.Error = types.NewError("The number '%d' option in `Command` set '%s' has an invalid condition: %s")
+
+	ErrInvalidParameter *types.Error = types.NewError("Parameter \"%s\" is invalid: %s")
+
+	ErrInvalidBatchItem *types.Error = types.NewError("Batch for event '%s' has an invalid `%s` option.")
+
+	ErrInvalidCoprocessItem *types.Error = types.NewError("The number '%d' co-process has an invalid `%s` option.")
+
+	ErrInvalidSIEMItem *types.Error = types.NewError("The number '%d' SIEM output has an invalid `%s` option.")
+
+	ErrInvalidStatusUser *types.Error = types.NewError("Status user '%s' has an invalid `%s` option.")
+
+	ErrInvalidStatusToken *types.Error = types.NewError("The number '%d' status token has an invalid `%s` option.")
+
+	ErrUnknownStatusPermission *types.Error = types.NewError("Unknown permission \"%s\" in option `%s`.")
+
+	ErrInvalidWebhookItem *types.Error = types.NewError("The number '%d' webhook has an invalid `%s` option.")
 )
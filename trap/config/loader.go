@@ -1,33 +1,161 @@
 package config
 
 import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/event"
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/password"
+	"github.com/raincious/trap/trap/core/syslog"
 	"github.com/raincious/trap/trap/core/types"
 
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os/exec"
 	"regexp"
 	"time"
 )
 
-type rawCommandConfig [][]types.String
+type rawCommandConfig []rawCommandItem
+
+// A command item can be either an array of the command and it's
+// arguments, or an object with an `if` condition, a `timeout` and the
+// `command`
+type rawCommandItem struct {
+	If      types.String   `json:"if"`
+	Timeout *types.UInt32  `json:"timeout"`
+	Command []types.String `json:"command"`
+}
+
+func (r *rawCommandItem) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		r.If = ""
+
+		return json.Unmarshal(data, &r.Command)
+	}
+
+	item := struct {
+		If      types.String   `json:"if"`
+		Timeout *types.UInt32  `json:"timeout"`
+		Command []types.String `json:"command"`
+	}{}
+
+	err := json.Unmarshal(data, &item)
+
+	if err != nil {
+		return err
+	}
+
+	r.If = item.If
+	r.Timeout = item.Timeout
+	r.Command = item.Command
+
+	return nil
+}
 
 type rawServerKVMap map[types.String]types.String
 
+type rawBatchConfig struct {
+	Interval types.UInt32   `json:"interval"`
+	MaxItems types.UInt32   `json:"max_items"`
+	Line     types.String   `json:"line"`
+	Stdin    bool           `json:"stdin"`
+	Timeout  *types.UInt32  `json:"timeout"`
+	Command  []types.String `json:"command"`
+}
+
+type rawWebhookConfig struct {
+	URL        types.String   `json:"url"`
+	Secret     types.String   `json:"secret"`
+	Events     []types.String `json:"events"`
+	Timeout    types.UInt32   `json:"timeout"`
+	Retries    types.UInt16   `json:"retries"`
+	Backoff    types.UInt32   `json:"backoff"`
+	QueueSize  types.UInt32   `json:"queue_size"`
+	DeadLetter types.String   `json:"dead_letter"`
+}
+
+type rawSIEMConfig struct {
+	Format   types.String   `json:"format"`
+	Network  types.String   `json:"network"`
+	Address  types.String   `json:"address"`
+	Facility types.String   `json:"facility"`
+	Events   []types.String `json:"events"`
+}
+
+type rawStatusUserConfig struct {
+	Password    types.String   `json:"password"`
+	Permissions []types.String `json:"permissions"`
+}
+
+type rawStatusUsers map[types.String]rawStatusUserConfig
+
+type rawStatusTokenConfig struct {
+	Name     types.String   `json:"name"`
+	Hash     types.String   `json:"hash"`
+	Scopes   []types.String `json:"scopes"`
+	Networks []types.String `json:"networks"`
+	Expire   types.String   `json:"expire"`
+}
+
+type rawCoprocessConfig struct {
+	Command    []types.String `json:"command"`
+	Events     []types.String `json:"events"`
+	Timeout    types.UInt32   `json:"ack_timeout"`
+	Retries    types.UInt16   `json:"retries"`
+	Backoff    types.UInt32   `json:"backoff"`
+	BufferSize types.UInt32   `json:"buffer_size"`
+}
+
 type rawConfig struct {
 	Log                types.String                      `json:"log"`
+	LogLevel           types.String                      `json:"log_level"`
+	LogLevels          map[types.String]types.String     `json:"log_levels"`
+	LogBufferSize      types.UInt32                      `json:"log_buffer_size"`
 	Listens            []types.String                    `json:"listens"`
 	AttemptTimeout     types.UInt32                      `json:"attempt_timeout"`
 	AttemptMaxBytes    types.UInt32                      `json:"attempt_max_bytes"`
 	AttemptThershold   types.UInt32                      `json:"attempt_thershold"`
 	AttemptExpire      types.UInt32                      `json:"attempt_expire"`
 	AttemptRestrict    types.UInt32                      `json:"attempt_restrict"`
+	AttemptScanPorts   types.UInt16                      `json:"attempt_scan_ports"`
+	AttemptScanWindow  types.UInt32                      `json:"attempt_scan_window"`
+	ClientMaxCount     types.UInt32                      `json:"client_max_count"`
+	ClientMaxMemory    types.UInt64                      `json:"client_max_memory"`
+	AggregateIPv4      types.UInt16                      `json:"aggregate_ipv4_prefix"`
+	AggregateIPv6      types.UInt16                      `json:"aggregate_ipv6_prefix"`
+	AggregateThershold types.UInt32                      `json:"aggregate_thershold"`
+	SignatureRules     types.String                      `json:"signature_rules"`
+	PolicyFile         types.String                      `json:"policy_file"`
+	FirewallBackend    types.String                      `json:"firewall_backend"`
+	FirewallSet        types.String                      `json:"firewall_set"`
+	EventWorkers       types.UInt16                      `json:"event_workers"`
+	EventQueueSize     types.UInt32                      `json:"event_queue_size"`
+	EventTimeout       types.UInt32                      `json:"event_command_timeout"`
+	EventRetries       types.UInt16                      `json:"event_command_retries"`
+	EventBackoff       types.UInt32                      `json:"event_command_backoff"`
 	Commands           map[types.String]rawCommandConfig `json:"commands"`
+	Batches            map[types.String]rawBatchConfig   `json:"batches"`
+	Webhooks           []rawWebhookConfig                `json:"webhooks"`
+	Coprocesses        []rawCoprocessConfig              `json:"coprocesses"`
+	SIEMs              []rawSIEMConfig                   `json:"siem"`
 	StatusInterface    types.IP                          `json:"status_interface"`
 	StatusPort         types.UInt16                      `json:"status_port"`
 	StatusAccounts     map[types.String][]types.String   `json:"status_accounts"`
+	StatusUsers        rawStatusUsers                    `json:"status_users"`
+	StatusTokens       []rawStatusTokenConfig            `json:"status_tokens"`
+	StatusTokenFile    types.String                      `json:"status_token_file"`
 	StatusTLSCert      types.String                      `json:"status_tls_certificate"`
 	StatusTLSCertKey   types.String                      `json:"status_tls_certificate_key"`
+	MetricsListen      types.String                      `json:"metrics_listen"`
+	AuditBufferSize    types.UInt32                      `json:"audit_buffer_size"`
+	AuditFile          types.String                      `json:"audit_file"`
+	AuditEvents        bool                              `json:"audit_events"`
+	BlocklistPublic    bool                              `json:"blocklist_public"`
 	SyncInterface      types.String                      `json:"synchronize_interface"`
 	SyncPort           types.UInt16                      `json:"synchronize_port"`
 	SyncReceiveLen     types.UInt16                      `json:"synchronize_max_receive_length"`
@@ -52,7 +180,15 @@ func Load(filePath string) (*Config, *types.Throw) {
 
 func Parse(configStr []byte) (*Config, *types.Throw) {
 	config := &Config{}
-	rawConfig := &rawConfig{}
+
+	// Fields which can be set to 0 on purpose got their default values
+	// before parsing, so a missing field can be told from a 0
+	rawConfig := &rawConfig{
+		EventWorkers:   event.EVENT_DEFAULT_WORKERS,
+		EventQueueSize: event.EVENT_DEFAULT_QUEUE_SIZE,
+		EventTimeout: types.UInt32(
+			event.COMMAND_DEFAULT_TIMEOUT / time.Second),
+	}
 
 	reg, err := regexp.Compile("(\\/\\*\\*\\!(?msiU:.*)\\*\\/)")
 
@@ -71,6 +207,39 @@ func Parse(configStr []byte) (*Config, *types.Throw) {
 	// Parse `Log` Field
 	config.Log = rawConfig.Log
 
+	// Parse `LogLevel` Field
+	config.LogLevel = rawConfig.LogLevel.Trim().Lower()
+
+	if config.LogLevel != "" {
+		_, levelErr := logger.ParseLevel(config.LogLevel)
+
+		if levelErr != nil {
+			return nil, ErrParseInvalidItem.Throw(config.LogLevel,
+				"log_level")
+		}
+	}
+
+	// Parse `LogLevels` Field
+	config.LogLevels = map[types.String]types.String{}
+
+	for context, level := range rawConfig.LogLevels {
+		_, levelErr := logger.ParseLevel(level)
+
+		if levelErr != nil {
+			return nil, ErrParseInvalidItem.Throw(level,
+				"log_levels")
+		}
+
+		config.LogLevels[context.Trim()] = level.Trim().Lower()
+	}
+
+	// Parse `LogBufferSize` Field
+	config.LogBufferSize = rawConfig.LogBufferSize
+
+	if config.LogBufferSize <= 0 {
+		config.LogBufferSize = logger.LOG_BUFFER_SIZE
+	}
+
 	// Parse `Listens` Field
 	config.Listens = Listens{}
 
@@ -106,58 +275,303 @@ func Parse(configStr []byte) (*Config, *types.Throw) {
 		config.AttemptExpire = 0
 	}
 
+	// Parse `AttemptScanPorts` Field
+	config.AttemptScanPorts = rawConfig.AttemptScanPorts
+
+	// Parse `AttemptScanWindow` Field
+	config.AttemptScanWindow = rawConfig.AttemptScanWindow
+
+	// Parse `ClientMaxCount` Field
+	config.ClientMaxCount = rawConfig.ClientMaxCount
+
+	// Parse `ClientMaxMemory` Field
+	config.ClientMaxMemory = rawConfig.ClientMaxMemory
+
+	// Parse `AggregateIPv4` Field
+	config.AggregateIPv4 = rawConfig.AggregateIPv4
+
+	if config.AggregateIPv4 > 32 {
+		return nil, ErrParseInvalidItem.Throw(config.AggregateIPv4,
+			"aggregate_ipv4_prefix")
+	}
+
+	// Parse `AggregateIPv6` Field
+	config.AggregateIPv6 = rawConfig.AggregateIPv6
+
+	if config.AggregateIPv6 > 128 {
+		return nil, ErrParseInvalidItem.Throw(config.AggregateIPv6,
+			"aggregate_ipv6_prefix")
+	}
+
+	// Parse `AggregateThershold` Field
+	config.AggregateThershold = rawConfig.AggregateThershold
+
+	// Parse `SignatureRules` Field
+	config.SignatureRules = rawConfig.SignatureRules.Trim()
+
+	// Parse `PolicyFile` Field
+	config.PolicyFile = rawConfig.PolicyFile.Trim()
+
+	// Parse `FirewallBackend` Field
+	config.FirewallBackend = rawConfig.FirewallBackend.Trim().Lower()
+
+	switch config.FirewallBackend {
+	case "":
+	case "ipset":
+	case "nftables":
+
+	default:
+		return nil, ErrParseInvalidItem.Throw(config.FirewallBackend,
+			"firewall_backend")
+	}
+
+	// Parse `FirewallSet` Field
+	config.FirewallSet = rawConfig.FirewallSet.Trim()
+
+	if config.FirewallSet == "" {
+		config.FirewallSet = "trap"
+	}
+
+	// Parse `EventWorkers` Field
+	config.EventWorkers = rawConfig.EventWorkers
+
+	// Parse `EventQueueSize` Field
+	config.EventQueueSize = rawConfig.EventQueueSize
+
+	// Parse `EventTimeout` Field
+	config.EventTimeout = rawConfig.EventTimeout
+
+	// Parse `EventRetries` Field
+	config.EventRetries = rawConfig.EventRetries
+
+	// Parse `EventBackoff` Field
+	config.EventBackoff = rawConfig.EventBackoff
+
 	// Parse `Commands` Fields
 	config.Commands = Commands{}
 
-	paramReg, err := regexp.Compile("\\$\\(\\([[:word:]]+\\)\\)")
+	paramReg, err := regexp.Compile("\\$\\(\\([^()]+\\)\\)")
 
 	for cmdType, cmds := range rawConfig.Commands {
 		cType := cmdType.Trim().Lower()
 
-		for _, cmdParams := range cmds {
-			cmdLen := len(cmdParams)
+		for cmdIdx, cmdParams := range cmds {
+			if len(cmdParams.Command) < 1 {
+				return nil, ErrInvalidCmdItem.Throw(cmdIdx+1,
+					cmdType.String())
+			}
+
+			cmdItem, cmdErr := parseCommand(cmdParams.Command, paramReg)
 
-			if cmdLen < 1 {
-				return nil, ErrInvalidCmdItem.Throw(cmdType.String(),
-					"commands")
+			if cmdErr != nil {
+				return nil, cmdErr
 			}
 
-			cmdItem := Command{}
+			cmdItem.Timeout = parseCommandTimeout(cmdParams.Timeout,
+				config.EventTimeout)
+
+			if cmdParams.If.Trim() != "" {
+				condition, condErr := event.ParseCondition(cType,
+					cmdParams.If.Trim())
 
-			file, fErr := exec.LookPath(cmdParams[0].String())
+				if condErr != nil {
+					return nil, ErrInvalidCmdCondition.Throw(cmdIdx+1,
+						cmdType.String(), condErr)
+				}
 
-			if fErr != nil {
-				return nil, types.ConvertError(fErr)
+				cmdItem.Condition = condition
 			}
 
-			cmdItem.Command = types.String(file)
+			config.Commands[cType] = append(config.Commands[cType], cmdItem)
+		}
+	}
 
-			if cmdLen > 1 {
-				for cmdIdx := 1; cmdIdx < cmdLen; cmdIdx++ {
-					cmdString := cmdParams[cmdIdx].Trim()
-					cmdParamLables := []types.String{}
+	// Parse `Batches` Fields
+	config.Batches = Batches{}
 
-					for _, maths := range paramReg.FindAllStringSubmatch(
-						cmdString.String(), -1) {
-						cmdParamLables = append(cmdParamLables,
-							types.String(maths[0]))
-					}
+	for batchType, rawBatch := range rawConfig.Batches {
+		if len(rawBatch.Command) < 1 {
+			return nil, ErrInvalidBatchItem.Throw(batchType, "command")
+		}
 
-					cmdParam := Parameter{
-						Format: cmdParams[cmdIdx].Trim(),
-						Labels: cmdParamLables,
-					}
+		if rawBatch.Line.Trim() == "" {
+			return nil, ErrInvalidBatchItem.Throw(batchType, "line")
+		}
 
-					if cmdParam.Format == "" {
-						continue
-					}
+		batchCmd, cmdErr := parseCommand(rawBatch.Command, paramReg)
 
-					cmdItem.Parameters = append(cmdItem.Parameters, cmdParam)
-				}
-			}
+		if cmdErr != nil {
+			return nil, cmdErr
+		}
 
-			config.Commands[cType] = append(config.Commands[cType], cmdItem)
+		batchCmd.Timeout = parseCommandTimeout(rawBatch.Timeout,
+			config.EventTimeout)
+
+		batchLine, lineErr := parseParameter(rawBatch.Line, paramReg)
+
+		if lineErr != nil {
+			return nil, lineErr
+		}
+
+		batch := Batch{
+			Interval: time.Duration(rawBatch.Interval) * time.Millisecond,
+			MaxItems: rawBatch.MaxItems,
+			Line:     batchLine,
+			Stdin:    rawBatch.Stdin,
+			Command:  batchCmd,
+		}
+
+		// Don't let events wait forever, a partial batch must be
+		// flushed even if `max_items` is never reached
+		if batch.Interval <= 0 {
+			batch.Interval = 1 * time.Second
+		}
+
+		config.Batches[batchType.Trim().Lower()] = batch
+	}
+
+	// Parse `Coprocesses` Fields
+	config.Coprocesses = Coprocesses{}
+
+	for coIdx, rawCo := range rawConfig.Coprocesses {
+		if len(rawCo.Command) < 1 {
+			return nil, ErrInvalidCoprocessItem.Throw(coIdx+1, "command")
+		}
+
+		if len(rawCo.Events) < 1 {
+			return nil, ErrInvalidCoprocessItem.Throw(coIdx+1, "events")
+		}
+
+		file, fErr := exec.LookPath(rawCo.Command[0].String())
+
+		if fErr != nil {
+			return nil, types.ConvertError(fErr)
+		}
+
+		co := Coprocess{
+			Command:    types.String(file),
+			Arguments:  rawCo.Command[1:],
+			Events:     []types.String{},
+			Timeout:    time.Duration(rawCo.Timeout) * time.Second,
+			Retries:    rawCo.Retries,
+			Backoff:    time.Duration(rawCo.Backoff) * time.Second,
+			BufferSize: rawCo.BufferSize,
+		}
+
+		for _, eventName := range rawCo.Events {
+			co.Events = append(co.Events, eventName.Trim().Lower())
+		}
+
+		if co.Timeout <= 0 {
+			co.Timeout = 10 * time.Second
+		}
+
+		if co.Backoff <= 0 {
+			co.Backoff = 1 * time.Second
+		}
+
+		if co.BufferSize <= 0 {
+			co.BufferSize = event.COPROCESS_BUFFER_SIZE
+		}
+
+		config.Coprocesses = append(config.Coprocesses, co)
+	}
+
+	// Parse `Webhooks` Fields
+	config.Webhooks = Webhooks{}
+
+	for hookIdx, rawHook := range rawConfig.Webhooks {
+		hookURL, urlErr := url.Parse(rawHook.URL.Trim().String())
+
+		if urlErr != nil || hookURL.Host == "" ||
+			(hookURL.Scheme != "http" && hookURL.Scheme != "https") {
+			return nil, ErrInvalidWebhookItem.Throw(hookIdx+1, "url")
+		}
+
+		if rawHook.Secret == "" {
+			return nil, ErrInvalidWebhookItem.Throw(hookIdx+1, "secret")
+		}
+
+		hook := Webhook{
+			URL:        rawHook.URL.Trim(),
+			Secret:     rawHook.Secret,
+			Events:     []types.String{},
+			Timeout:    time.Duration(rawHook.Timeout) * time.Second,
+			Retries:    rawHook.Retries,
+			Backoff:    time.Duration(rawHook.Backoff) * time.Second,
+			QueueSize:  rawHook.QueueSize,
+			DeadLetter: rawHook.DeadLetter.Trim(),
+		}
+
+		for _, eventName := range rawHook.Events {
+			hook.Events = append(hook.Events, eventName.Trim().Lower())
+		}
+
+		if hook.Timeout <= 0 {
+			hook.Timeout = 10 * time.Second
+		}
+
+		if hook.Backoff <= 0 {
+			hook.Backoff = 1 * time.Second
+		}
+
+		if hook.QueueSize <= 0 {
+			hook.QueueSize = 1024
+		}
+
+		config.Webhooks = append(config.Webhooks, hook)
+	}
+
+	// Parse `SIEMs` Fields
+	config.SIEMs = SIEMs{}
+
+	for siemIdx, rawSIEM := range rawConfig.SIEMs {
+		siem := SIEM{
+			Format:  rawSIEM.Format.Trim().Lower(),
+			Network: rawSIEM.Network.Trim().Lower(),
+			Address: rawSIEM.Address.Trim(),
+			Events:  []types.String{},
+		}
+
+		switch siem.Format {
+		case event.SIEM_FORMAT_CEF:
+		case event.SIEM_FORMAT_LEEF:
+
+		default:
+			return nil, ErrInvalidSIEMItem.Throw(siemIdx+1, "format")
+		}
+
+		switch siem.Network {
+		case "udp", "tcp", "unix":
+
+		case "":
+			siem.Network = "udp"
+
+		default:
+			return nil, ErrInvalidSIEMItem.Throw(siemIdx+1, "network")
+		}
+
+		if siem.Address == "" {
+			return nil, ErrInvalidSIEMItem.Throw(siemIdx+1, "address")
+		}
+
+		if rawSIEM.Facility.Trim() == "" {
+			rawSIEM.Facility = "local0"
+		}
+
+		facility, facilityErr := syslog.ParseFacility(rawSIEM.Facility)
+
+		if facilityErr != nil {
+			return nil, ErrInvalidSIEMItem.Throw(siemIdx+1, "facility")
+		}
+
+		siem.Facility = facility
+
+		for _, eventName := range rawSIEM.Events {
+			siem.Events = append(siem.Events, eventName.Trim().Lower())
 		}
+
+		config.SIEMs = append(config.SIEMs, siem)
 	}
 
 	// Parse `StatusTLSCert` and `StatusTLSCertKey` Field
@@ -180,12 +594,129 @@ func Parse(configStr []byte) (*Config, *types.Throw) {
 		permissionList := []types.String{}
 
 		for _, permission := range permissions {
+			if status.ValidatePermission(permission) != nil {
+				return nil, ErrUnknownStatusPermission.Throw(permission,
+					"status_accounts")
+			}
+
 			permissionList = append(permissionList, permission)
 		}
 
 		config.StatusAccounts[pass] = permissionList
 	}
 
+	// Parse `StatusUsers` Field
+	config.StatusUsers = StatusUsers{}
+
+	for name, rawUser := range rawConfig.StatusUsers {
+		if name == "" || name.Contains(":") {
+			return nil, ErrInvalidStatusUser.Throw(name, "name")
+		}
+
+		if password.Check(rawUser.Password) != nil {
+			return nil, ErrInvalidStatusUser.Throw(name, "password")
+		}
+
+		for _, permission := range rawUser.Permissions {
+			if status.ValidatePermission(permission) != nil {
+				return nil, ErrUnknownStatusPermission.Throw(permission,
+					"status_users")
+			}
+		}
+
+		config.StatusUsers[name] = StatusUser{
+			Password:    rawUser.Password,
+			Permissions: append([]types.String{}, rawUser.Permissions...),
+		}
+	}
+
+	// Parse `StatusTokens` Field
+	config.StatusTokens = StatusTokens{}
+
+	for tokenIdx, rawToken := range rawConfig.StatusTokens {
+		token := StatusToken{
+			Name:     rawToken.Name.Trim(),
+			Hash:     rawToken.Hash.Trim().Lower(),
+			Scopes:   []types.String{},
+			Networks: []*net.IPNet{},
+		}
+
+		if token.Name == "" {
+			return nil, ErrInvalidStatusToken.Throw(tokenIdx+1, "name")
+		}
+
+		hashBytes, hashErr := hex.DecodeString(token.Hash.String())
+
+		if hashErr != nil || len(hashBytes) != sha256.Size {
+			return nil, ErrInvalidStatusToken.Throw(tokenIdx+1, "hash")
+		}
+
+		for _, scope := range rawToken.Scopes {
+			if status.ValidatePermission(scope) != nil {
+				return nil, ErrUnknownStatusPermission.Throw(scope,
+					"status_tokens")
+			}
+
+			token.Scopes = append(token.Scopes, scope)
+		}
+
+		for _, network := range rawToken.Networks {
+			ipNet, ipNetErr := status.ParseNetwork(network)
+
+			if ipNetErr != nil {
+				return nil, ErrInvalidStatusToken.Throw(tokenIdx+1,
+					"networks")
+			}
+
+			token.Networks = append(token.Networks, ipNet)
+		}
+
+		if rawToken.Expire != "" {
+			expire, expireErr := time.Parse(time.RFC3339,
+				rawToken.Expire.String())
+
+			if expireErr != nil {
+				return nil, ErrInvalidStatusToken.Throw(tokenIdx+1, "expire")
+			}
+
+			token.Expire = expire
+		}
+
+		config.StatusTokens = append(config.StatusTokens, token)
+	}
+
+	// Parse `StatusTokenFile` Field
+	config.StatusTokenFile = rawConfig.StatusTokenFile.Trim()
+
+	// Parse `MetricsListen` Field
+	config.MetricsListen = rawConfig.MetricsListen
+
+	if config.MetricsListen != "" {
+		_, _, metricsListenErr := net.SplitHostPort(
+			config.MetricsListen.String())
+
+		if metricsListenErr != nil {
+			return nil, ErrParseInvalidItem.Throw(config.MetricsListen,
+				"metrics_listen")
+		}
+	}
+
+	// Parse `AuditBufferSize` Field
+	config.AuditBufferSize = rawConfig.AuditBufferSize
+
+	if config.AuditBufferSize <= 0 {
+		config.AuditBufferSize = audit.AUDIT_BUFFER_SIZE
+	}
+
+	// Parse `AuditFile` Field
+	config.AuditFile = rawConfig.AuditFile.Trim()
+
+	// Parse `AuditEvents` Field
+	config.AuditEvents = rawConfig.AuditEvents
+
+	// Parse `BlocklistPublic` Field
+	config.BlocklistPublic = rawConfig.BlocklistPublic
+
 	// Parse `SyncInterface` Field
 	syncIfaceIP, syncIfaceIpErr := types.ConvertIPFromString(
 		rawConfig.SyncInterface)
@@ -253,3 +784,65 @@ func Parse(configStr []byte) (*Config, *types.Throw) {
 
 	return config, nil
 }
+
+func parseParameter(format types.String,
+	paramReg *regexp.Regexp) (Parameter, *types.Throw) {
+	cmdParamLables := []types.String{}
+
+	for _, maths := range paramReg.FindAllStringSubmatch(
+		format.Trim().String(), -1) {
+		labelErr := event.ValidateLabel(types.String(maths[0]))
+
+		if labelErr != nil {
+			return Parameter{}, ErrInvalidParameter.Throw(format.Trim(),
+				labelErr)
+		}
+
+		cmdParamLables = append(cmdParamLables, types.String(maths[0]))
+	}
+
+	return Parameter{
+		Format: format.Trim(),
+		Labels: cmdParamLables,
+	}, nil
+}
+
+// The `timeout` of a command overrides the `event_command_timeout`,
+// both in seconds and 0 means never kill
+func parseCommandTimeout(timeout *types.UInt32,
+	defaultTimeout types.UInt32) time.Duration {
+	if timeout != nil {
+		defaultTimeout = *timeout
+	}
+
+	return time.Duration(defaultTimeout.Int64()) * time.Second
+}
+
+func parseCommand(cmdParams []types.String,
+	paramReg *regexp.Regexp) (Command, *types.Throw) {
+	cmdItem := Command{}
+
+	file, fErr := exec.LookPath(cmdParams[0].String())
+
+	if fErr != nil {
+		return cmdItem, types.ConvertError(fErr)
+	}
+
+	cmdItem.Command = types.String(file)
+
+	for cmdIdx := 1; cmdIdx < len(cmdParams); cmdIdx++ {
+		cmdParam, paramErr := parseParameter(cmdParams[cmdIdx], paramReg)
+
+		if paramErr != nil {
+			return cmdItem, paramErr
+		}
+
+		if cmdParam.Format == "" {
+			continue
+		}
+
+		cmdItem.Parameters = append(cmdItem.Parameters, cmdParam)
+	}
+
+	return cmdItem, nil
+}
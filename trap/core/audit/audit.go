@@ -0,0 +1,204 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const (
+	AUDIT_BUFFER_SIZE = 1024
+
+	RESULT_SUCCESS = "success"
+	RESULT_FAILURE = "failure"
+
+	// Actor of actions which are started by Trap itself, like reloads
+	ACTOR_SYSTEM = "system"
+
+	// Actor of requests which failed before the user can be known
+	ACTOR_ANONYMOUS = "anonymous"
+
+	// Prefix of the actor of synchronizing nodes
+	ACTOR_SYNC_PREFIX = "sync:"
+
+	ACTION_CLIENT_MARK        = "client.mark"
+	ACTION_CLIENT_UNMARK      = "client.unmark"
+	ACTION_TOKEN_CREATE       = "token.create"
+	ACTION_TOKEN_REVOKE       = "token.revoke"
+	ACTION_TOKEN_USE          = "token.use"
+	ACTION_LOG_LEVEL          = "log.level"
+	ACTION_CONFIG_RELOAD      = "config.reload"
+	ACTION_SYNC_CLIENT_MARK   = "sync.client.mark"
+	ACTION_SYNC_CLIENT_UNMARK = "sync.client.unmark"
+	ACTION_SYNC_PREFIX_MARK   = "sync.prefix.mark"
+	ACTION_SYNC_PREFIX_UNMARK = "sync.prefix.unmark"
+)
+
+// Entry is a record of an action which changed the state of Trap
+type Entry struct {
+	ID        uint64
+	Time      time.Time
+	Actor     types.String // Who did it
+	IP        types.String // Where the actor came from, empty for local
+	Action    types.String
+	Target    types.String // What has been acted on
+	Result    types.String
+	Error     types.String // Why the action failed
+	RequestID types.String // Request ID of the status interface
+}
+
+func (e Entry) Failed() bool {
+	return e.Result == RESULT_FAILURE
+}
+
+// Log keeps the latest entries in memory for querying, and appends every
+// entry to the writer. Entries can't be changed once they're recorded
+type Log struct {
+	mutex    types.Mutex
+	entries  []Entry
+	size     int
+	lastID   uint64
+	writer   io.Writer
+	onRecord func(Entry)
+}
+
+func NewLog() *Log {
+	return &Log{
+		entries: []Entry{},
+		size:    AUDIT_BUFFER_SIZE,
+	}
+}
+
+// SetSize changes how many entries will be kept in memory
+func (l *Log) SetSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+
+	l.mutex.Exec(func() {
+		l.size = size
+
+		if len(l.entries) > size {
+			l.entries = append([]Entry{}, l.entries[len(l.entries)-size:]...)
+		}
+	})
+}
+
+// SetWriter sets where the entries will be written to, one JSON
+// document per line. Set to nil to stop writing
+func (l *Log) SetWriter(w io.Writer) {
+	l.mutex.Exec(func() {
+		l.writer = w
+	})
+}
+
+// OnRecord sets the function which will be called with every recorded
+// entry
+func (l *Log) OnRecord(f func(Entry)) {
+	l.mutex.Exec(func() {
+		l.onRecord = f
+	})
+}
+
+// Record the entry. The ID and the time will be filled, and the result
+// will be decided by the error when it's not set
+func (l *Log) Record(entry Entry) (Entry, *types.Throw) {
+	var writeErr *types.Throw = nil
+	var onRecord func(Entry) = nil
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	if entry.Result == "" {
+		entry.Result = RESULT_SUCCESS
+
+		if entry.Error != "" {
+			entry.Result = RESULT_FAILURE
+		}
+	}
+
+	l.mutex.Exec(func() {
+		l.lastID++
+
+		entry.ID = l.lastID
+		onRecord = l.onRecord
+
+		if l.size > 0 {
+			l.entries = append(l.entries, entry)
+
+			if len(l.entries) > l.size {
+				l.entries = l.entries[len(l.entries)-l.size:]
+			}
+		}
+
+		if l.writer == nil {
+			return
+		}
+
+		jsonData, jsonErr := json.Marshal(entry)
+
+		if jsonErr != nil {
+			writeErr = types.ConvertError(jsonErr)
+
+			return
+		}
+
+		_, wErr := l.writer.Write(append(jsonData, '\n'))
+
+		if wErr != nil {
+			writeErr = ErrWriteFailed.Throw(entry.ID, wErr)
+		}
+	})
+
+	if onRecord != nil {
+		onRecord(entry)
+	}
+
+	return entry, writeErr
+}
+
+// Query searches entries in memory, newest entries first
+func (l *Log) Query(q Query) QueryResult {
+	result := QueryResult{
+		Entries: []Entry{},
+		Cursor:  0,
+	}
+
+	l.mutex.Exec(func() {
+		entries := l.entries
+
+		result.Cursor = types.Page(len(entries), func(i int) uint64 {
+			return entries[i].ID
+		}, func(i int) bool {
+			return q.Match(entries[i])
+		}, func(i int) {
+			result.Entries = append(result.Entries, entries[i])
+		}, q.Cursor, q.Limit)
+	})
+
+	return result
+}
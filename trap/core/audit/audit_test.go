@@ -0,0 +1,190 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogRecord(t *testing.T) {
+	buf := bytes.Buffer{}
+	recorded := []Entry{}
+	log := NewLog()
+
+	log.SetWriter(&buf)
+	log.OnRecord(func(e Entry) {
+		recorded = append(recorded, e)
+	})
+
+	entry, err := log.Record(Entry{
+		Actor:  "alice",
+		IP:     "192.0.2.1",
+		Action: ACTION_CLIENT_UNMARK,
+		Target: "198.51.100.1",
+	})
+
+	if err != nil {
+		t.Errorf("Log.Record() failed due to error: %s", err)
+
+		return
+	}
+
+	if entry.ID != 1 || entry.Time.IsZero() ||
+		entry.Result != RESULT_SUCCESS {
+		t.Error("Log.Record() failed to fill the entry")
+
+		return
+	}
+
+	entry, _ = log.Record(Entry{
+		Actor:  "bob",
+		Action: ACTION_CLIENT_MARK,
+		Error:  "Client already existed",
+	})
+
+	if entry.ID != 2 || !entry.Failed() {
+		t.Errorf("Log.Record() failed to decide the result. "+
+			"Expecting '%s', got '%s'", RESULT_FAILURE, entry.Result)
+
+		return
+	}
+
+	if len(recorded) != 2 || recorded[1].ID != 2 {
+		t.Errorf("Log.OnRecord() failed to receive entries. "+
+			"Expecting '%d', got '%d'", 2, len(recorded))
+
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	if len(lines) != 2 {
+		t.Errorf("Log.Record() failed to write entries. "+
+			"Expecting '%d' lines, got '%d'", 2, len(lines))
+
+		return
+	}
+
+	written := Entry{}
+
+	json.Unmarshal([]byte(lines[0]), &written)
+
+	if written.Actor != "alice" || written.Target != "198.51.100.1" {
+		t.Error("Log.Record() written an invalid entry")
+
+		return
+	}
+}
+
+func TestLogQuery(t *testing.T) {
+	log := NewLog()
+
+	for _, entry := range []Entry{
+		{Actor: "alice", Action: ACTION_CLIENT_MARK, Target: "192.0.2.1"},
+		{Actor: "sync:192.0.2.9:4000", Action: ACTION_SYNC_CLIENT_MARK,
+			Target: "192.0.2.2"},
+		{Actor: "alice", Action: ACTION_CLIENT_UNMARK, Target: "192.0.2.1"},
+		{Actor: "sync:192.0.2.9:4000", Action: ACTION_SYNC_PREFIX_UNMARK,
+			Target: "192.0.2.0/24", Error: "Prefix not found"},
+	} {
+		log.Record(entry)
+	}
+
+	tests := []struct {
+		query    Query
+		expected []uint64
+	}{
+		{Query{}, []uint64{4, 3, 2, 1}},
+		{Query{Actor: "alice"}, []uint64{3, 1}},
+		{Query{Action: "sync."}, []uint64{4, 2}},
+		{Query{Action: "sync"}, []uint64{}},
+		{Query{Target: "192.0.2.1", Action: ACTION_CLIENT_UNMARK},
+			[]uint64{3}},
+		{Query{Result: RESULT_FAILURE}, []uint64{4}},
+		{Query{Limit: 3}, []uint64{4, 3, 2}},
+		{Query{Cursor: 2}, []uint64{1}},
+	}
+
+	for testIdx, test := range tests {
+		result := log.Query(test.query)
+		ids := []uint64{}
+
+		for _, entry := range result.Entries {
+			ids = append(ids, entry.ID)
+		}
+
+		if jsonString(ids) != jsonString(test.expected) {
+			t.Errorf("Log.Query() failed to query the number '%d' test. "+
+				"Expecting '%v', got '%v'", testIdx+1, test.expected, ids)
+
+			return
+		}
+	}
+
+	result := log.Query(Query{Limit: 3})
+
+	if result.Cursor != 2 {
+		t.Errorf("Log.Query() failed to set the cursor. "+
+			"Expecting '%d', got '%d'", 2, result.Cursor)
+
+		return
+	}
+}
+
+func jsonString(v interface{}) string {
+	data, _ := json.Marshal(v)
+
+	return string(data)
+}
+
+func TestLogSetSize(t *testing.T) {
+	log := NewLog()
+
+	log.SetSize(2)
+
+	for i := 0; i < 5; i++ {
+		log.Record(Entry{Actor: ACTOR_SYSTEM, Action: ACTION_CONFIG_RELOAD})
+	}
+
+	result := log.Query(Query{})
+
+	if len(result.Entries) != 2 || result.Entries[0].ID != 5 ||
+		result.Entries[1].ID != 4 {
+		t.Errorf("Log.SetSize() failed to limit the entries. "+
+			"Expecting '%d', got '%d'", 2, len(result.Entries))
+
+		return
+	}
+
+	log.SetSize(1)
+
+	result = log.Query(Query{})
+
+	if len(result.Entries) != 1 || result.Entries[0].ID != 5 {
+		t.Error("Log.SetSize() failed to shrink the entries")
+
+		return
+	}
+}
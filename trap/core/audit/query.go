@@ -0,0 +1,86 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"strings"
+	"time"
+)
+
+// Query selects entries from the Log. Zero values mean no restriction
+type Query struct {
+	Actor  types.String
+	IP     types.String
+	Action types.String // Action, or the prefix of actions like `sync.`
+	Target types.String
+	Result types.String
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+
+	// Only entries older than the one which has this ID will be
+	// returned, use `QueryResult.Cursor` to get the next page
+	Cursor uint64
+}
+
+type QueryResult struct {
+	Entries []Entry
+	Cursor  uint64 // 0 when there is no more entries
+}
+
+// Match tells whether the entry meets the conditions other than the
+// `Limit` and the `Cursor`
+func (q *Query) Match(entry Entry) bool {
+	if q.Actor != "" && entry.Actor != q.Actor {
+		return false
+	}
+
+	if q.IP != "" && entry.IP != q.IP {
+		return false
+	}
+
+	if q.Action != "" && entry.Action != q.Action &&
+		!(strings.HasSuffix(q.Action.String(), ".") &&
+			strings.HasPrefix(entry.Action.String(), q.Action.String())) {
+		return false
+	}
+
+	if q.Target != "" && entry.Target != q.Target {
+		return false
+	}
+
+	if q.Result != "" && entry.Result != q.Result {
+		return false
+	}
+
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && entry.Time.After(q.Until) {
+		return false
+	}
+
+	return true
+}
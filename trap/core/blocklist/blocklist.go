@@ -0,0 +1,272 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blocklist
+
+import (
+	"github.com/raincious/trap/trap/core/client"
+	"github.com/raincious/trap/trap/core/types"
+
+	"bytes"
+	"net"
+	"sort"
+	"time"
+)
+
+// Entry is a marked client or prefix in the blocklist
+type Entry struct {
+	Address   types.String // Address of a client, or CIDR of a prefix
+	Count     types.UInt32
+	Tags      types.Strings
+	Ports     []types.UInt16
+	FirstSeen time.Time
+	LastSeen  time.Time
+
+	network net.IPNet
+}
+
+// IPv4 returns true when the entry is an IPv4 address or network
+func (e Entry) IPv4() bool {
+	return e.network.IP.To4() != nil
+}
+
+// Host returns true when the entry is a single address
+func (e Entry) Host() bool {
+	ones, bits := e.network.Mask.Size()
+
+	return ones == bits
+}
+
+// Network returns the network of the entry, single addresses have a
+// full length mask
+func (e Entry) Network() net.IPNet {
+	return e.network
+}
+
+// Entries are sorted with IPv4 first, then by address and prefix length
+type Entries []Entry
+
+func (e Entries) Len() int {
+	return len(e)
+}
+
+func (e Entries) Less(i, j int) bool {
+	if e[i].IPv4() != e[j].IPv4() {
+		return e[i].IPv4()
+	}
+
+	compared := bytes.Compare(e[i].network.IP, e[j].network.IP)
+
+	if compared != 0 {
+		return compared < 0
+	}
+
+	iOnes, _ := e[i].network.Mask.Size()
+	jOnes, _ := e[j].network.Mask.Size()
+
+	return iOnes < jOnes
+}
+
+func (e Entries) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+// Filter selects marked clients for the blocklist, empty fields select
+// all of them
+type Filter struct {
+	// Clients which have any of the tags
+	Tags types.Strings
+
+	// Clients which hit any of the ports
+	Ports []types.UInt16
+
+	// Clients which have been seen in the duration
+	MaxAge time.Duration
+}
+
+func (f Filter) Match(c client.ClientExport, now time.Time) bool {
+	if f.MaxAge > 0 && now.Sub(c.LastSeen) > f.MaxAge {
+		return false
+	}
+
+	if len(f.Tags) > 0 && !hasAny(recordTags(c.Records), f.Tags) {
+		return false
+	}
+
+	if len(f.Ports) > 0 && !hasAnyPort(recordPorts(c.Records), f.Ports) {
+		return false
+	}
+
+	return true
+}
+
+func hasAny(strs types.Strings, wanted types.Strings) bool {
+	for _, str := range strs {
+		for _, want := range wanted {
+			if str == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func hasAnyPort(ports []types.UInt16, wanted []types.UInt16) bool {
+	for _, port := range ports {
+		for _, want := range wanted {
+			if port == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func recordTags(records []client.Record) types.Strings {
+	tags := types.Strings{}
+	known := map[types.String]bool{}
+
+	for _, record := range records {
+		for _, tag := range record.Tags {
+			if known[tag] {
+				continue
+			}
+
+			known[tag] = true
+
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+func recordPorts(records []client.Record) []types.UInt16 {
+	ports := []types.UInt16{}
+	known := map[types.UInt16]bool{}
+
+	for _, record := range records {
+		if known[record.Hitting.Port] {
+			continue
+		}
+
+		known[record.Hitting.Port] = true
+
+		ports = append(ports, record.Hitting.Port)
+	}
+
+	return ports
+}
+
+// Get the network of the client, or the network of the prefix
+func clientNetwork(c client.ClientExport) (net.IPNet, *types.Throw) {
+	if c.CIDR != "" {
+		_, network, parseErr := net.ParseCIDR(c.CIDR.String())
+
+		if parseErr != nil {
+			return net.IPNet{}, ErrInvalidAddress.Throw(c.CIDR)
+		}
+
+		return *network, nil
+	}
+
+	if ipv4 := c.Address.To4(); ipv4 != nil {
+		return net.IPNet{IP: ipv4, Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	if ipv6 := c.Address.To16(); ipv6 != nil {
+		return net.IPNet{IP: ipv6, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	return net.IPNet{}, ErrInvalidAddress.Throw(c.Address.String())
+}
+
+// Build the blocklist from marked clients which matched the filter.
+// Clients inside of a marked prefix are left out, as the prefix already
+// covered them
+func Build(clients []client.ClientExport, filter Filter,
+	now time.Time) Entries {
+	entries := Entries{}
+	prefixes := []net.IPNet{}
+
+	for _, c := range clients {
+		if !c.Marked || !filter.Match(c, now) {
+			continue
+		}
+
+		network, networkErr := clientNetwork(c)
+
+		if networkErr != nil {
+			continue
+		}
+
+		entry := Entry{
+			Address:   types.String(c.Address.String()),
+			Count:     c.Count,
+			Tags:      recordTags(c.Records),
+			Ports:     recordPorts(c.Records),
+			FirstSeen: c.FirstSeen,
+			LastSeen:  c.LastSeen,
+			network:   network,
+		}
+
+		if !entry.Host() {
+			entry.Address = types.String(network.String())
+
+			prefixes = append(prefixes, network)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	result := Entries{}
+
+	for _, entry := range entries {
+		if covered(entry, prefixes) {
+			continue
+		}
+
+		result = append(result, entry)
+	}
+
+	sort.Sort(result)
+
+	return result
+}
+
+// The entry is inside of another, larger prefix
+func covered(entry Entry, prefixes []net.IPNet) bool {
+	ones, _ := entry.network.Mask.Size()
+
+	for _, prefix := range prefixes {
+		prefixOnes, _ := prefix.Mask.Size()
+
+		if prefixOnes >= ones || !prefix.Contains(entry.network.IP) {
+			continue
+		}
+
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,202 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blocklist
+
+import (
+	"github.com/raincious/trap/trap/core/client"
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"testing"
+	"time"
+)
+
+func testClients(now time.Time) []client.ClientExport {
+	hit := func(port types.UInt16, tags ...types.String) client.Record {
+		return client.Record{
+			Hitting: client.Hitting{
+				IPAddress: types.IPAddress{Port: port},
+				Type:      "tcp",
+			},
+			Tags: types.Strings(tags),
+		}
+	}
+
+	return []client.ClientExport{
+		{
+			Address:  net.ParseIP("2001:db8::1"),
+			LastSeen: now,
+			Records:  []client.Record{hit(22, "ssh")},
+			Marked:   true,
+		},
+		{
+			Address:  net.ParseIP("192.0.2.9"),
+			LastSeen: now.Add(-2 * time.Hour),
+			Records:  []client.Record{hit(23, "mirai")},
+			Marked:   true,
+		},
+		{
+			Address:  net.ParseIP("192.0.2.1"),
+			LastSeen: now,
+			Records:  []client.Record{hit(22, "ssh"), hit(80)},
+			Marked:   true,
+		},
+		{
+			Address:  net.ParseIP("192.0.2.2"),
+			LastSeen: now,
+			Records:  []client.Record{hit(22, "ssh")},
+			Marked:   false,
+		},
+		{
+			Address:  net.ParseIP("198.51.100.0"),
+			CIDR:     "198.51.100.0/24",
+			LastSeen: now,
+			Records:  []client.Record{},
+			Marked:   true,
+		},
+		{
+			Address:  net.ParseIP("198.51.100.7"),
+			LastSeen: now,
+			Records:  []client.Record{hit(22)},
+			Marked:   true,
+		},
+	}
+}
+
+func addresses(entries Entries) types.String {
+	result := types.Strings{}
+
+	for _, entry := range entries {
+		result = append(result, entry.Address)
+	}
+
+	return result.ImplodeWith(",")
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		filter   Filter
+		expected types.String
+	}{
+		{Filter{},
+			"192.0.2.1,192.0.2.9,198.51.100.0/24,2001:db8::1"},
+		{Filter{Tags: types.Strings{"ssh"}}, "192.0.2.1,2001:db8::1"},
+		{Filter{Ports: []types.UInt16{23, 80}}, "192.0.2.1,192.0.2.9"},
+		{Filter{MaxAge: time.Hour},
+			"192.0.2.1,198.51.100.0/24,2001:db8::1"},
+		{Filter{Tags: types.Strings{"ssh"}, Ports: []types.UInt16{80}},
+			"192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		result := addresses(Build(testClients(now), test.filter, now))
+
+		if result != test.expected {
+			t.Errorf("Build() failed to build the blocklist. "+
+				"Expecting '%s', got '%s'", test.expected, result)
+
+			return
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	now := time.Now()
+	entries := Build(testClients(now), Filter{}, now)
+
+	tests := []struct {
+		format   types.String
+		expected string
+	}{
+		{FORMAT_PLAIN, "192.0.2.1\n192.0.2.9\n198.51.100.0/24\n" +
+			"2001:db8::1\n"},
+		{FORMAT_IPSET, "create trap hash:net family inet -exist\n" +
+			"flush trap\n" +
+			"add trap 192.0.2.1\n" +
+			"add trap 192.0.2.9\n" +
+			"add trap 198.51.100.0/24\n" +
+			"create trap6 hash:net family inet6 -exist\n" +
+			"flush trap6\n" +
+			"add trap6 2001:db8::1\n"},
+		{FORMAT_NFT, "add table inet trap\n" +
+			"add set inet trap clients4 { type ipv4_addr; " +
+			"flags interval; }\n" +
+			"flush set inet trap clients4\n" +
+			"add element inet trap clients4 " +
+			"{ 192.0.2.1, 192.0.2.9, 198.51.100.0/24 }\n" +
+			"add set inet trap clients6 { type ipv6_addr; " +
+			"flags interval; }\n" +
+			"flush set inet trap clients6\n" +
+			"add element inet trap clients6 { 2001:db8::1 }\n"},
+		{FORMAT_IPTABLES, "*filter\n:TRAP - [0:0]\n" +
+			"-A TRAP -s 192.0.2.1/32 -j DROP\n" +
+			"-A TRAP -s 192.0.2.9/32 -j DROP\n" +
+			"-A TRAP -s 198.51.100.0/24 -j DROP\n" +
+			"COMMIT\n"},
+		{FORMAT_IP6TABLES, "*filter\n:TRAP - [0:0]\n" +
+			"-A TRAP -s 2001:db8::1/128 -j DROP\n" +
+			"COMMIT\n"},
+		{FORMAT_HOSTS_DENY, "ALL: 192.0.2.1\nALL: 192.0.2.9\n" +
+			"ALL: 198.51.100.0/255.255.255.0\nALL: [2001:db8::1]\n"},
+		{FORMAT_NGINX, "deny 192.0.2.1;\ndeny 192.0.2.9;\n" +
+			"deny 198.51.100.0/24;\ndeny 2001:db8::1;\n"},
+	}
+
+	for _, test := range tests {
+		result, err := Render(test.format, "trap", entries)
+
+		if err != nil {
+			t.Errorf("Render() failed to render '%s' due to error: %s",
+				test.format, err)
+
+			return
+		}
+
+		if string(result) != test.expected {
+			t.Errorf("Render() failed to render '%s'. "+
+				"Expecting '%s', got '%s'", test.format, test.expected,
+				result)
+
+			return
+		}
+	}
+}
+
+func TestRenderInvalid(t *testing.T) {
+	_, err := Render("pf", "trap", Entries{})
+
+	if err == nil || !err.Is(ErrUnknownFormat) {
+		t.Error("Render() failed to reject unknown format")
+
+		return
+	}
+
+	_, err = Render(FORMAT_IPSET, "trap; rm", Entries{})
+
+	if err == nil || !err.Is(ErrInvalidName) {
+		t.Error("Render() failed to reject invalid name")
+
+		return
+	}
+}
@@ -0,0 +1,265 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package blocklist
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+)
+
+const (
+	FORMAT_PLAIN      = "plain"
+	FORMAT_IPSET      = "ipset"
+	FORMAT_NFT        = "nft"
+	FORMAT_IPTABLES   = "iptables"
+	FORMAT_IP6TABLES  = "ip6tables"
+	FORMAT_HOSTS_DENY = "hosts.deny"
+	FORMAT_NGINX      = "nginx"
+	FORMAT_JSON       = "json"
+
+	// Name of the set, table or chain when it's not specified
+	DEFAULT_NAME = "trap_blocklist"
+)
+
+var (
+	Formats = []types.String{
+		FORMAT_PLAIN,
+		FORMAT_IPSET,
+		FORMAT_NFT,
+		FORMAT_IPTABLES,
+		FORMAT_IP6TABLES,
+		FORMAT_HOSTS_DENY,
+		FORMAT_NGINX,
+		FORMAT_JSON,
+	}
+
+	nameReg = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_]{0,23}$")
+)
+
+// CheckName checks the name of the set, table or chain, it must be
+// usable by all of the firewalls
+func CheckName(name types.String) *types.Throw {
+	if !nameReg.MatchString(name.String()) {
+		return ErrInvalidName.Throw(name)
+	}
+
+	return nil
+}
+
+// ContentType returns the `Content-Type` of the rendered format
+func ContentType(format types.String) string {
+	if format == FORMAT_JSON {
+		return "application/json; charset=UTF-8"
+	}
+
+	return "text/plain; charset=UTF-8"
+}
+
+// ETag returns the entity tag of the rendered blocklist, a blocklist
+// will always be rendered the same way when nothing changed
+func ETag(data []byte) string {
+	sum := sha256.Sum256(data)
+
+	return "\"" + hex.EncodeToString(sum[:16]) + "\""
+}
+
+// Render the entries in the format. The name is used as the name of the
+// set, table or chain by `ipset`, `nft`, `iptables` and `ip6tables`
+func Render(format types.String, name types.String,
+	entries Entries) ([]byte, *types.Throw) {
+	nameErr := CheckName(name)
+
+	if nameErr != nil {
+		return nil, nameErr
+	}
+
+	buf := bytes.Buffer{}
+
+	switch format {
+	case FORMAT_PLAIN:
+		renderPlain(&buf, entries)
+
+	case FORMAT_IPSET:
+		renderIPSet(&buf, name, entries)
+
+	case FORMAT_NFT:
+		renderNFT(&buf, name, entries)
+
+	case FORMAT_IPTABLES:
+		renderIPTables(&buf, name, entries, true)
+
+	case FORMAT_IP6TABLES:
+		renderIPTables(&buf, name, entries, false)
+
+	case FORMAT_HOSTS_DENY:
+		renderHostsDeny(&buf, entries)
+
+	case FORMAT_NGINX:
+		renderNginx(&buf, entries)
+
+	case FORMAT_JSON:
+		jsonData, jsonErr := json.Marshal(entries)
+
+		if jsonErr != nil {
+			return nil, types.ConvertError(jsonErr)
+		}
+
+		buf.Write(jsonData)
+		buf.WriteString("\n")
+
+	default:
+		return nil, ErrUnknownFormat.Throw(format)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Entries of one address family
+func family(entries Entries, ipv4 bool) Entries {
+	result := Entries{}
+
+	for _, entry := range entries {
+		if entry.IPv4() != ipv4 {
+			continue
+		}
+
+		result = append(result, entry)
+	}
+
+	return result
+}
+
+// One address or CIDR per line, for URL tables and address lists
+func renderPlain(buf *bytes.Buffer, entries Entries) {
+	for _, entry := range entries {
+		fmt.Fprintf(buf, "%s\n", entry.Address)
+	}
+}
+
+// Input of `ipset restore -exist`, IPv6 entries go to the set which
+// has a "6" suffix like the `ipset` firewall backend
+func renderIPSet(buf *bytes.Buffer, name types.String, entries Entries) {
+	for _, ipv4 := range []bool{true, false} {
+		setName, setFamily := name.String(), "inet"
+
+		if !ipv4 {
+			setName, setFamily = setName+"6", "inet6"
+		}
+
+		fmt.Fprintf(buf, "create %s hash:net family %s -exist\n",
+			setName, setFamily)
+		fmt.Fprintf(buf, "flush %s\n", setName)
+
+		for _, entry := range family(entries, ipv4) {
+			fmt.Fprintf(buf, "add %s %s\n", setName, entry.Address)
+		}
+	}
+}
+
+// Script of `nft -f`, which (re)defines the `clients4` and `clients6`
+// sets in the table without touching it's chains
+func renderNFT(buf *bytes.Buffer, name types.String, entries Entries) {
+	fmt.Fprintf(buf, "add table inet %s\n", name)
+
+	for _, ipv4 := range []bool{true, false} {
+		setName, setType := "clients4", "ipv4_addr"
+
+		if !ipv4 {
+			setName, setType = "clients6", "ipv6_addr"
+		}
+
+		fmt.Fprintf(buf, "add set inet %s %s { type %s; flags interval; }\n",
+			name, setName, setType)
+		fmt.Fprintf(buf, "flush set inet %s %s\n", name, setName)
+
+		familyEntries := family(entries, ipv4)
+
+		// An empty element list is a syntax error
+		if len(familyEntries) <= 0 {
+			continue
+		}
+
+		elements := types.Strings{}
+
+		for _, entry := range familyEntries {
+			elements = append(elements, entry.Address)
+		}
+
+		fmt.Fprintf(buf, "add element inet %s %s { %s }\n",
+			name, setName, elements.ImplodeWith(", "))
+	}
+}
+
+// Input of `iptables-restore --noflush`, which (re)creates a chain that
+// drops the entries. The chain must be jumped to from other chains
+func renderIPTables(buf *bytes.Buffer, name types.String,
+	entries Entries, ipv4 bool) {
+	chain := name.Upper()
+
+	fmt.Fprintf(buf, "*filter\n:%s - [0:0]\n", chain)
+
+	for _, entry := range family(entries, ipv4) {
+		network := entry.Network()
+
+		fmt.Fprintf(buf, "-A %s -s %s -j DROP\n", chain, network.String())
+	}
+
+	buf.WriteString("COMMIT\n")
+}
+
+// Rules of `/etc/hosts.deny`, IPv4 networks are written with net/mask
+// as older versions of TCP Wrappers don't know prefix lengths
+func renderHostsDeny(buf *bytes.Buffer, entries Entries) {
+	for _, entry := range entries {
+		network := entry.Network()
+		ones, _ := network.Mask.Size()
+
+		switch {
+		case entry.IPv4() && entry.Host():
+			fmt.Fprintf(buf, "ALL: %s\n", network.IP)
+
+		case entry.IPv4():
+			fmt.Fprintf(buf, "ALL: %s/%s\n", network.IP,
+				net.IP(network.Mask))
+
+		case entry.Host():
+			fmt.Fprintf(buf, "ALL: [%s]\n", network.IP)
+
+		default:
+			fmt.Fprintf(buf, "ALL: [%s]/%d\n", network.IP, ones)
+		}
+	}
+}
+
+// `deny` directives of the nginx access module
+func renderNginx(buf *bytes.Buffer, entries Entries) {
+	for _, entry := range entries {
+		fmt.Fprintf(buf, "deny %s;\n", entry.Address)
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"sync/atomic"
+)
+
+// Budget keeps the amount of clients and the memory they used, it can be
+// shared by many `Clients` so the limits are applied to all of them as a
+// whole
+type budget struct {
+	clients    int64
+	memory     int64
+	maxClients int64
+	maxMemory  int64
+}
+
+func newBudget(config Config) *budget {
+	return &budget{
+		clients:    0,
+		memory:     0,
+		maxClients: int64(config.MaxClients),
+		maxMemory:  int64(config.MaxMemory.UInt64()),
+	}
+}
+
+func (b *budget) add(clients int64, memory int64) {
+	if clients != 0 {
+		atomic.AddInt64(&b.clients, clients)
+	}
+
+	if memory != 0 {
+		atomic.AddInt64(&b.memory, memory)
+	}
+}
+
+func (b *budget) resize(oldSize types.UInt64, newSize types.UInt64) {
+	b.add(0, int64(newSize.UInt64())-int64(oldSize.UInt64()))
+}
+
+func (b *budget) over() bool {
+	if b.maxClients > 0 && atomic.LoadInt64(&b.clients) > b.maxClients {
+		return true
+	}
+
+	if b.maxMemory > 0 && atomic.LoadInt64(&b.memory) > b.maxMemory {
+		return true
+	}
+
+	return false
+}
@@ -24,6 +24,7 @@ package client
 import (
 	"github.com/raincious/trap/trap/core/types"
 
+	"container/list"
 	"net"
 	"time"
 )
@@ -34,17 +35,32 @@ const (
 	CLIENT_EXPIRED_RESTRICTED
 )
 
+// Estimated memory cost of a `Client`, each of it's `Record` without
+// the inbound and outbound data, and each port it recently hit
+const (
+	CLIENT_MEMORY_BASE = types.UInt64(512)
+	RECORD_MEMORY_BASE = types.UInt64(128)
+	PORT_MEMORY_BASE   = types.UInt64(48)
+)
+
 type Client struct {
 	address        net.IP
 	firstSeen      time.Time
 	lastSeen       time.Time
 	count          types.UInt32
+	score          types.UInt32
 	records        []Record
 	lastRecord     *Record
+	ports          Ports
 	marked         bool
+	size           types.UInt64
+	stored         bool
+	seenElement    *list.Element
 	onMark         func(*Client, MarkType)
 	onUnmark       func(*Client, UnmarkType)
 	onRecord       func(*Client, Record)
+	onResize       func(*Client, types.UInt64, types.UInt64)
+	onSeen         func(*Client)
 	tolerateCount  types.UInt32
 	tolerateExpire time.Duration
 	restrictExpire time.Duration
@@ -66,6 +82,23 @@ func (c *Client) Count() types.UInt32 {
 	return c.count
 }
 
+func (c *Client) Score() types.UInt32 {
+	return c.score
+}
+
+// Add signature score to the client, returns the new total score
+func (c *Client) AddScore(score types.UInt32) types.UInt32 {
+	if c.score+score < c.score {
+		c.score = types.UINT32_MAX_UINT32
+
+		return c.score
+	}
+
+	c.score += score
+
+	return c.score
+}
+
 func (c *Client) Marked() bool {
 	return c.marked
 }
@@ -81,17 +114,82 @@ func (c *Client) Record(record Record, maxLen types.UInt16) {
 
 	c.lastRecord = &c.records[len(c.records)-1]
 
+	c.resize()
+
 	c.onRecord(c, record)
 }
 
+func (c *Client) resize() {
+	oldSize := c.size
+
+	c.size = CLIENT_MEMORY_BASE
+
+	for _, record := range c.records {
+		c.size += RECORD_MEMORY_BASE +
+			types.Int64(len(record.Inbound)+len(record.Outbound)).UInt64()
+	}
+
+	c.size += PORT_MEMORY_BASE * types.Int64(len(c.ports)).UInt64()
+
+	if c.onResize != nil && oldSize != c.size {
+		c.onResize(c, oldSize, c.size)
+	}
+}
+
+func (c *Client) Size() types.UInt64 {
+	return c.size
+}
+
+func (c *Client) seen() {
+	c.lastSeen = time.Now()
+
+	if c.onSeen != nil {
+		c.onSeen(c)
+	}
+}
+
 func (c *Client) Records() []Record {
 	return c.records
 }
 
+// Count the records which was recorded after the specified time, the
+// result is limited by the max amount of records a client can hold
+func (c *Client) HitsWithin(since time.Time) types.UInt32 {
+	hits := types.UInt32(0)
+
+	for _, record := range c.records {
+		if record.Time.Before(since) {
+			continue
+		}
+
+		hits += 1
+	}
+
+	return hits
+}
+
 func (c *Client) LastRecord() *Record {
 	return c.lastRecord
 }
 
+func (c *Client) HitPort(port types.UInt16, now time.Time,
+	window time.Duration) Ports {
+	if c.ports == nil {
+		c.ports = Ports{}
+	}
+
+	c.ports.Expire(now.Add(-window))
+	c.ports.Hit(port, now)
+
+	c.resize()
+
+	return c.ports
+}
+
+func (c *Client) Ports() Ports {
+	return c.ports
+}
+
 func (c *Client) Mark(ty MarkType) {
 	oldMarkStatus := c.marked
 
@@ -113,7 +211,7 @@ func (c *Client) Unmark(ty UnmarkType) {
 }
 
 func (c *Client) Bump() {
-	c.lastSeen = time.Now()
+	c.seen()
 
 	if c.count+1 > types.UINT32_MAX_UINT32 {
 		return
@@ -124,7 +222,9 @@ func (c *Client) Bump() {
 
 func (c *Client) Rebump() {
 	c.count = 1
-	c.lastSeen = time.Now()
+	c.score = 0
+
+	c.seen()
 }
 
 func (c *Client) Tolerate(count types.UInt32, expire time.Duration,
@@ -149,3 +249,26 @@ func (c *Client) Expired(now time.Time) int {
 
 	return CLIENT_EXPIRED_YES
 }
+
+// Time when the client will be removed if it not come again
+func (c *Client) RestrictedUntil() time.Time {
+	expireTime := c.lastSeen.Add(c.tolerateExpire)
+
+	if c.count < c.tolerateCount {
+		return expireTime
+	}
+
+	return expireTime.Add(c.restrictExpire)
+}
+
+func (c *Client) Export() ClientExport {
+	return ClientExport{
+		Address:   c.Address(),
+		FirstSeen: c.FirstSeen(),
+		LastSeen:  c.LastSeen(),
+		Count:     c.Count(),
+		Score:     c.Score(),
+		Records:   c.Records(),
+		Marked:    c.Marked(),
+	}
+}
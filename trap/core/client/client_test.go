@@ -294,6 +294,80 @@ func TestClientBumpRebump(t *testing.T) {
 	}
 }
 
+func TestClientScore(t *testing.T) {
+	client := Client{
+		address:  net.ParseIP("127.0.0.1"),
+		records:  []Record{},
+		onMark:   func(c *Client, ty MarkType) {},
+		onUnmark: func(c *Client, ty UnmarkType) {},
+		onRecord: func(c *Client, d Record) {},
+	}
+
+	if client.AddScore(3) != 3 {
+		t.Errorf("Client.AddScore() failed to add score. "+
+			"Expecting '%d', got '%d'", 3, client.Score())
+
+		return
+	}
+
+	if client.AddScore(4) != 7 {
+		t.Errorf("Client.AddScore() failed to add score. "+
+			"Expecting '%d', got '%d'", 7, client.Score())
+
+		return
+	}
+
+	if client.AddScore(types.UINT32_MAX_UINT32) != types.UINT32_MAX_UINT32 {
+		t.Errorf("Client.AddScore() failed to cap the score. "+
+			"Expecting '%d', got '%d'", types.UINT32_MAX_UINT32,
+			client.Score())
+
+		return
+	}
+
+	client.Rebump()
+
+	if client.Score() != 0 {
+		t.Errorf("Client.Rebump() failed to reset score. "+
+			"Expecting '%d', got '%d'", 0, client.Score())
+
+		return
+	}
+}
+
+func TestClientHitsWithin(t *testing.T) {
+	now := time.Now()
+	client := Client{
+		address:  net.ParseIP("127.0.0.1"),
+		records:  []Record{},
+		onMark:   func(c *Client, ty MarkType) {},
+		onUnmark: func(c *Client, ty UnmarkType) {},
+		onRecord: func(c *Client, d Record) {},
+	}
+
+	for _, ago := range []time.Duration{30, 20, 10, 5, 0} {
+		client.Record(Record{
+			Time: now.Add(-ago * time.Minute),
+		}, 16)
+	}
+
+	if client.HitsWithin(now.Add(-10*time.Minute)) != 3 {
+		t.Errorf("Client.HitsWithin() failed to count hits. "+
+			"Expecting '%d', got '%d'", 3,
+			client.HitsWithin(now.Add(-10*time.Minute)))
+
+		return
+	}
+
+	if client.HitsWithin(now.Add(time.Minute)) != 0 {
+		t.Errorf("Client.HitsWithin() failed to count hits. "+
+			"Expecting '%d', got '%d'", 0,
+			client.HitsWithin(now.Add(time.Minute)))
+
+		return
+	}
+}
+
 func TestClientTolerateExpired(t *testing.T) {
 	now := time.Now()
 	client := Client{
@@ -343,4 +417,22 @@ func TestClientTolerateExpired(t *testing.T) {
 
 		return
 	}
+
+	if client.RestrictedUntil() != client.LastSeen().Add(6*time.Second) {
+		t.Errorf("Client.RestrictedUntil() failed to include restrict "+
+			"period. Expecting '%s', got '%s'",
+			client.LastSeen().Add(6*time.Second), client.RestrictedUntil())
+
+		return
+	}
+
+	client.Rebump()
+
+	if client.RestrictedUntil() != client.LastSeen().Add(3*time.Second) {
+		t.Errorf("Client.RestrictedUntil() failed to exclude restrict "+
+			"period. Expecting '%s', got '%s'",
+			client.LastSeen().Add(3*time.Second), client.RestrictedUntil())
+
+		return
+	}
 }
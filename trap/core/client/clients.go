@@ -24,6 +24,7 @@ package client
 import (
 	"github.com/raincious/trap/trap/core/types"
 
+	"container/list"
 	"time"
 )
 
@@ -34,27 +35,157 @@ const (
 	CLIENT_MARK_MANUAL MarkType = iota
 	CLIENT_MARK_PICK
 	CLIENT_MARK_OTHER
+	CLIENT_MARK_SCAN
+	CLIENT_MARK_SIGNATURE
+	CLIENT_MARK_POLICY
 )
 
 const (
 	CLIENT_UNMARK_MANUAL UnmarkType = iota
 	CLIENT_UNMARK_EXPIRE
+	CLIENT_UNMARK_SYNC    // Requested by another node
+	CLIENT_UNMARK_RELEASE // Released by it's members
 )
 
+func (m MarkType) String() types.String {
+	switch m {
+	case CLIENT_MARK_MANUAL:
+		return "manual"
+
+	case CLIENT_MARK_PICK:
+		return "pick"
+
+	case CLIENT_MARK_OTHER:
+		return "other"
+
+	case CLIENT_MARK_SCAN:
+		return "scan"
+
+	case CLIENT_MARK_SIGNATURE:
+		return "signature"
+
+	case CLIENT_MARK_POLICY:
+		return "policy"
+	}
+
+	return "unknown"
+}
+
+func (u UnmarkType) String() types.String {
+	switch u {
+	case CLIENT_UNMARK_MANUAL:
+		return "manual"
+
+	case CLIENT_UNMARK_EXPIRE:
+		return "expire"
+
+	case CLIENT_UNMARK_SYNC:
+		return "sync"
+
+	case CLIENT_UNMARK_RELEASE:
+		return "release"
+	}
+
+	return "unknown"
+}
+
 type Clients struct {
-	clients  map[types.IP]*Client
-	onMark   func(*Client, MarkType)
-	onUnmark func(*Client, UnmarkType)
-	onRecord func(*Client, Record)
+	clients    map[types.IP]*Client
+	seen       *list.List
+	memory     types.UInt64
+	evicted    types.UInt64
+	budget     *budget
+	localEvict bool
+	onMark     func(*Client, MarkType)
+	onUnmark   func(*Client, UnmarkType)
+	onRecord   func(*Client, Record)
+	onEvict    func(*Client)
 }
 
 func NewClients(config Config) *Clients {
+	return newClients(config, newBudget(config), true)
+}
+
+// Create a `Clients` which counts it's usage into the given budget. When
+// `localEvict` is false, the owner of the budget is responsible to evict
+// clients once the budget ran out
+func newClients(config Config, b *budget, localEvict bool) *Clients {
 	return &Clients{
-		clients:  map[types.IP]*Client{},
-		onMark:   config.OnMark,
-		onUnmark: config.OnUnmark,
-		onRecord: config.OnRecord,
+		clients:    map[types.IP]*Client{},
+		seen:       list.New(),
+		memory:     0,
+		evicted:    0,
+		budget:     b,
+		localEvict: localEvict,
+		onMark:     config.OnMark,
+		onUnmark:   config.OnUnmark,
+		onRecord:   config.OnRecord,
+		onEvict:    config.OnEvict,
+	}
+}
+
+func (c *Clients) overLimit() bool {
+	return c.budget.over()
+}
+
+// Evict least recently seen clients until we are back under the limit.
+// Marked clients are not in the `seen` list so they will be kept, and so
+// will the client that currently in use
+func (c *Clients) evict(keep *Client) {
+	for c.overLimit() && c.evictOne(keep) {
+	}
+}
+
+// The least recently seen client which can be evicted, or nil when there
+// is none
+func (c *Clients) oldest(keep *Client) *Client {
+	element := c.seen.Back()
+
+	if element != nil && element.Value.(*Client) == keep {
+		element = element.Prev()
+	}
+
+	if element == nil {
+		return nil
+	}
+
+	return element.Value.(*Client)
+}
+
+func (c *Clients) evictOne(keep *Client) bool {
+	evicting := c.oldest(keep)
+
+	if evicting == nil {
+		return false
+	}
+
+	c.remove(types.ConvertIP(evicting.address))
+
+	c.evicted += 1
+
+	if c.onEvict != nil {
+		c.onEvict(evicting)
+	}
+
+	return true
+}
+
+func (c *Clients) remove(ip types.IP) {
+	client := c.clients[ip]
+
+	if client.seenElement != nil {
+		c.seen.Remove(client.seenElement)
+
+		client.seenElement = nil
 	}
+
+	client.stored = false
+
+	c.memory -= client.size
+
+	c.budget.add(-1, -int64(client.size.UInt64()))
+
+	delete(c.clients, ip)
 }
 
 func (c *Clients) Get(ip types.IP) (*Client, bool) {
@@ -68,21 +199,80 @@ func (c *Clients) Get(ip types.IP) (*Client, bool) {
 			count:          0,
 			records:        []Record{},
 			lastRecord:     nil,
+			ports:          Ports{},
 			marked:         false,
-			onMark:         c.onMark,
-			onUnmark:       c.onUnmark,
+			onMark:         c.marked,
+			onUnmark:       c.unmarked,
 			onRecord:       c.onRecord,
+			onResize:       c.resized,
+			onSeen:         c.bumped,
 			tolerateCount:  0,
 			tolerateExpire: time.Duration(0),
 			restrictExpire: time.Duration(0),
 		}
 
+		c.clients[ip].stored = true
+		c.clients[ip].seenElement = c.seen.PushFront(c.clients[ip])
+
+		c.budget.add(1, 0)
+
+		c.clients[ip].resize()
+
 		isNew = true
 	}
 
 	return c.clients[ip], isNew
 }
 
+// Marked clients can't be evicted, take them out of the `seen` list so
+// the eviction will never walk through them
+func (c *Clients) marked(client *Client, ty MarkType) {
+	if client.stored && client.seenElement != nil {
+		c.seen.Remove(client.seenElement)
+
+		client.seenElement = nil
+	}
+
+	if c.onMark != nil {
+		c.onMark(client, ty)
+	}
+}
+
+func (c *Clients) unmarked(client *Client, ty UnmarkType) {
+	if client.stored && client.seenElement == nil {
+		client.seenElement = c.seen.PushFront(client)
+	}
+
+	if c.onUnmark != nil {
+		c.onUnmark(client, ty)
+	}
+}
+
+func (c *Clients) resized(client *Client, oldSize types.UInt64,
+	newSize types.UInt64) {
+	if !client.stored {
+		return
+	}
+
+	c.memory = c.memory - oldSize + newSize
+
+	c.budget.resize(oldSize, newSize)
+
+	if !c.localEvict {
+		return
+	}
+
+	c.evict(client)
+}
+
+func (c *Clients) bumped(client *Client) {
+	if client.seenElement == nil {
+		return
+	}
+
+	c.seen.MoveToFront(client.seenElement)
+}
+
 func (c *Clients) Has(ip types.IP) bool {
 	if _, ok := c.clients[ip]; !ok {
 		return false
@@ -104,11 +294,19 @@ func (c *Clients) Delete(ip types.IP, ty UnmarkType) *types.Throw {
 		c.clients[ip].Unmark(ty)
 	}
 
-	delete(c.clients, ip)
+	c.remove(ip)
 
 	return nil
 }
 
+func (c *Clients) Memory() types.UInt64 {
+	return c.memory
+}
+
+func (c *Clients) Evicted() types.UInt64 {
+	return c.evicted
+}
+
 func (c *Clients) Scan(
 	callback func(types.IP, *Client) *types.Throw) *types.Throw {
 	var err *types.Throw = nil
@@ -146,14 +344,7 @@ func (c *Clients) Export() []ClientExport {
 	clients := []ClientExport{}
 
 	for _, clientInfo := range c.clients {
-		clients = append(clients, ClientExport{
-			Address:   clientInfo.Address(),
-			FirstSeen: clientInfo.FirstSeen(),
-			LastSeen:  clientInfo.LastSeen(),
-			Count:     clientInfo.Count(),
-			Records:   clientInfo.Records(),
-			Marked:    clientInfo.Marked(),
-		})
+		clients = append(clients, clientInfo.Export())
 	}
 
 	return clients
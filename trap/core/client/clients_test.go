@@ -26,7 +26,7 @@ import (
 
 	"testing"
 	//"net"
-	//"time"
+	"time"
 )
 
 var (
@@ -353,3 +353,193 @@ func TestClientsExport(t *testing.T) {
 		return
 	}
 }
+
+func TestClientsEvictByCount(t *testing.T) {
+	evicted := []string{}
+
+	clients := NewClients(Config{
+		MaxClients: 2,
+		OnMark:     func(*Client, MarkType) {},
+		OnUnmark:   func(*Client, UnmarkType) {},
+		OnRecord:   func(*Client, Record) {},
+		OnEvict: func(c *Client) {
+			evicted = append(evicted, c.Address().String())
+		},
+	})
+
+	ip1, _ := types.ConvertIPFromString("127.0.0.1")
+	ip2, _ := types.ConvertIPFromString("127.0.0.2")
+	ip3, _ := types.ConvertIPFromString("127.0.0.3")
+	ip4, _ := types.ConvertIPFromString("127.0.0.4")
+
+	client1, _ := clients.Get(ip1)
+	clients.Get(ip2)
+
+	// Mark the oldest one, it should never be evicted
+	client1.Mark(CLIENT_MARK_MANUAL)
+
+	clients.Get(ip3)
+
+	if clients.Len() != 2 || !clients.Has(ip1) || clients.Has(ip2) {
+		t.Error("Clients.Get() failed to evict the least recently seen " +
+			"unmarked client")
+
+		return
+	}
+
+	// Bump the 3rd client so it's more recently seen than the 1st one
+	client3, _ := clients.Get(ip3)
+	client3.Bump()
+
+	clients.Get(ip4)
+
+	if clients.Len() != 2 || !clients.Has(ip1) || !clients.Has(ip4) {
+		t.Error("Clients.Get() evicted an unexpected client")
+
+		return
+	}
+
+	if clients.Evicted() != 2 || len(evicted) != 2 ||
+		evicted[0] != "127.0.0.2" || evicted[1] != "127.0.0.3" {
+		t.Errorf("Clients.Get() evicted unexpected clients: %v", evicted)
+
+		return
+	}
+}
+
+func TestClientsEvictByMemory(t *testing.T) {
+	clients := NewClients(Config{
+		MaxMemory: CLIENT_MEMORY_BASE*2 + RECORD_MEMORY_BASE + 64,
+		OnMark:    func(*Client, MarkType) {},
+		OnUnmark:  func(*Client, UnmarkType) {},
+		OnRecord:  func(*Client, Record) {},
+		OnEvict:   func(*Client) {},
+	})
+
+	ip1, _ := types.ConvertIPFromString("127.0.0.1")
+	ip2, _ := types.ConvertIPFromString("127.0.0.2")
+
+	client1, _ := clients.Get(ip1)
+	client2, _ := clients.Get(ip2)
+
+	if clients.Memory() != CLIENT_MEMORY_BASE*2 {
+		t.Errorf("Clients.Memory() reported unexpected memory usage. "+
+			"Expecting '%d', got '%d'", CLIENT_MEMORY_BASE*2,
+			clients.Memory())
+
+		return
+	}
+
+	client1.Record(Record{
+		Inbound:  make([]byte, 32),
+		Outbound: make([]byte, 32),
+	}, 16)
+
+	if clients.Len() != 2 {
+		t.Error("Clients evicted client before reaching the memory limit")
+
+		return
+	}
+
+	// Record on the 2nd client will exceed the limit, so the 1st one
+	// which is least recently seen must be evicted
+	client2.Record(Record{
+		Inbound:  make([]byte, 1),
+		Outbound: []byte{},
+	}, 16)
+
+	if clients.Len() != 1 || clients.Has(ip1) || !clients.Has(ip2) {
+		t.Error("Clients failed to evict client after reaching the " +
+			"memory limit")
+
+		return
+	}
+
+	expectedMemory := CLIENT_MEMORY_BASE + RECORD_MEMORY_BASE + 1
+
+	if clients.Memory() != expectedMemory {
+		t.Errorf("Clients.Memory() reported unexpected memory usage. "+
+			"Expecting '%d', got '%d'", expectedMemory, clients.Memory())
+
+		return
+	}
+
+	clients.Delete(ip2, CLIENT_UNMARK_MANUAL)
+
+	if clients.Memory() != 0 {
+		t.Errorf("Clients.Delete() failed to release memory usage. "+
+			"Expecting '%d', got '%d'", 0, clients.Memory())
+
+		return
+	}
+}
+
+func TestClientsEvictSkipsMarked(t *testing.T) {
+	clients := NewClients(Config{
+		MaxClients: 2,
+		OnMark:     func(*Client, MarkType) {},
+		OnUnmark:   func(*Client, UnmarkType) {},
+		OnRecord:   func(*Client, Record) {},
+		OnEvict:    func(*Client) {},
+	})
+
+	ip1, _ := types.ConvertIPFromString("127.0.0.1")
+	ip2, _ := types.ConvertIPFromString("127.0.0.2")
+	ip3, _ := types.ConvertIPFromString("127.0.0.3")
+
+	client1, _ := clients.Get(ip1)
+	client1.Mark(CLIENT_MARK_MANUAL)
+
+	if clients.seen.Len() != 0 {
+		t.Errorf("Client.Mark() failed to take the client out of the "+
+			"eviction list. Expecting '%d', got '%d'", 0, clients.seen.Len())
+
+		return
+	}
+
+	client1.Unmark(CLIENT_UNMARK_MANUAL)
+
+	if clients.seen.Len() != 1 {
+		t.Errorf("Client.Unmark() failed to put the client back to the "+
+			"eviction list. Expecting '%d', got '%d'", 1, clients.seen.Len())
+
+		return
+	}
+
+	client1.Mark(CLIENT_MARK_MANUAL)
+
+	clients.Get(ip2)
+	clients.Get(ip3)
+
+	if clients.Len() != 2 || !clients.Has(ip1) || !clients.Has(ip3) {
+		t.Error("Clients.Get() evicted an unexpected client")
+
+		return
+	}
+}
+
+func TestClientsPortsMemory(t *testing.T) {
+	clients := NewClients(Config{
+		OnMark:   func(*Client, MarkType) {},
+		OnUnmark: func(*Client, UnmarkType) {},
+		OnRecord: func(*Client, Record) {},
+		OnEvict:  func(*Client) {},
+	})
+
+	ip, _ := types.ConvertIPFromString("127.0.0.1")
+
+	client, _ := clients.Get(ip)
+
+	before := clients.Memory()
+
+	client.HitPort(22, time.Now(), time.Minute)
+	client.HitPort(23, time.Now(), time.Minute)
+
+	if clients.Memory() != before+2*PORT_MEMORY_BASE {
+		t.Errorf("Client.HitPort() failed to count the port memory. "+
+			"Expecting '%d', got '%d'",
+			before+2*PORT_MEMORY_BASE, clients.Memory())
+
+		return
+	}
+}
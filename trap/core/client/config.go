@@ -21,8 +21,30 @@
 
 package client
 
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"time"
+)
+
 type Config struct {
-	OnMark   func(*Client, MarkType)
-	OnUnmark func(*Client, UnmarkType)
-	OnRecord func(*Client, Record)
+	MaxClients types.UInt32
+	MaxMemory  types.UInt64
+	OnMark     func(*Client, MarkType)
+	OnUnmark   func(*Client, UnmarkType)
+	OnRecord   func(*Client, Record)
+	OnEvict    func(*Client)
+}
+
+type PrefixConfig struct {
+	IPv4Bits  types.UInt16
+	IPv6Bits  types.UInt16
+	Thershold types.UInt32
+	OnMark    func(*Prefix, MarkType)
+	OnUnmark  func(*Prefix, UnmarkType)
+
+	// How long a prefix which is not marked by it's members will be kept
+	// after it was last marked, PREFIX_DEFAULT_EXPIRE will be used when
+	// it's 0
+	Expire time.Duration
 }
@@ -35,17 +35,21 @@ type Hitting struct {
 }
 
 type Record struct {
-	Inbound  []byte
-	Outbound []byte
-	Hitting  Hitting
-	Time     time.Time
+	Inbound    []byte
+	Outbound   []byte
+	Hitting    Hitting
+	Signatures types.Strings
+	Tags       types.Strings
+	Time       time.Time
 }
 
 type ClientExport struct {
 	Address   net.IP
+	CIDR      types.String
 	FirstSeen time.Time
 	LastSeen  time.Time
 	Count     types.UInt32
+	Score     types.UInt32
 	Records   []Record
 	Marked    bool
 }
@@ -28,4 +28,10 @@ import (
 var (
 	ErrClientNotFound *types.Error = types.NewError(
 		"Client '%s' is not found")
+
+	ErrPrefixNotFound *types.Error = types.NewError(
+		"Prefix '%s' is not found")
+
+	ErrPrefixAlreadyMarked *types.Error = types.NewError(
+		"Prefix '%s' is already marked")
 )
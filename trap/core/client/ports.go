@@ -0,0 +1,75 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"sort"
+	"time"
+)
+
+type Ports map[types.UInt16]time.Time
+
+func (p Ports) Hit(port types.UInt16, now time.Time) {
+	p[port] = now
+}
+
+func (p Ports) Expire(before time.Time) {
+	for port, lastHit := range p {
+		if !lastHit.Before(before) {
+			continue
+		}
+
+		delete(p, port)
+	}
+}
+
+func (p Ports) Len() int {
+	return len(p)
+}
+
+func (p Ports) Ports() []types.UInt16 {
+	ports := types.UInt16Slice{}
+
+	for port, _ := range p {
+		ports = append(ports, port)
+	}
+
+	sort.Sort(ports)
+
+	return ports
+}
+
+func (p Ports) String() types.String {
+	result := types.String("")
+
+	for idx, port := range p.Ports() {
+		if idx > 0 {
+			result = result.Join(",")
+		}
+
+		result = result.Join(port.String())
+	}
+
+	return result
+}
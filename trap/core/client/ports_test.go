@@ -0,0 +1,117 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPortsHitNExpire(t *testing.T) {
+	nw := time.Now()
+	ports := Ports{}
+
+	ports.Hit(22, nw.Add(-10*time.Minute))
+	ports.Hit(23, nw.Add(-1*time.Minute))
+	ports.Hit(80, nw)
+
+	if ports.Len() != 3 {
+		t.Errorf("Ports.Hit() failed to record ports. Expecting '%d' "+
+			"ports, got '%d'", 3, ports.Len())
+
+		return
+	}
+
+	// Hit the same port again, shouldn't add any new port
+	ports.Hit(80, nw)
+
+	if ports.Len() != 3 {
+		t.Errorf("Ports.Hit() recorded a duplicated port. Expecting '%d' "+
+			"ports, got '%d'", 3, ports.Len())
+
+		return
+	}
+
+	ports.Expire(nw.Add(-5 * time.Minute))
+
+	if ports.Len() != 2 {
+		t.Errorf("Ports.Expire() failed to expire ports. Expecting '%d' "+
+			"ports, got '%d'", 2, ports.Len())
+
+		return
+	}
+
+	if ports.String() != "23,80" {
+		t.Errorf("Ports.String() exports an unexpected result. "+
+			"Expecting '%s', got '%s'", "23,80", ports.String())
+
+		return
+	}
+}
+
+func TestPortsPorts(t *testing.T) {
+	nw := time.Now()
+	ports := Ports{}
+
+	if len(ports.Ports()) != 0 || ports.String() != "" {
+		t.Error("Ports.Ports() exports an unexpected result for empty ports")
+
+		return
+	}
+
+	ports.Hit(8080, nw)
+	ports.Hit(21, nw)
+	ports.Hit(443, nw)
+
+	exported := ports.Ports()
+
+	if len(exported) != 3 || exported[0] != 21 || exported[1] != 443 ||
+		exported[2] != 8080 {
+		t.Errorf("Ports.Ports() exports an unexpected result: %v", exported)
+
+		return
+	}
+}
+
+func TestClientHitPort(t *testing.T) {
+	nw := time.Now()
+	client := Client{}
+
+	client.HitPort(22, nw.Add(-2*time.Minute), time.Minute)
+	client.HitPort(23, nw.Add(-30*time.Second), time.Minute)
+
+	ports := client.HitPort(80, nw, time.Minute)
+
+	if ports.Len() != 2 {
+		t.Errorf("Client.HitPort() failed to track ports within the "+
+			"window. Expecting '%d' ports, got '%d'", 2, ports.Len())
+
+		return
+	}
+
+	if client.Ports().String() != "23,80" {
+		t.Errorf("Client.Ports() exports an unexpected result. "+
+			"Expecting '%s', got '%s'", "23,80", client.Ports().String())
+
+		return
+	}
+}
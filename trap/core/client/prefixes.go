@@ -0,0 +1,300 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"time"
+)
+
+const (
+	// Used when no expire time is configured, so the prefixes marked
+	// by others will be released eventually
+	PREFIX_DEFAULT_EXPIRE = 2 * time.Hour
+)
+
+type Prefix struct {
+	network   net.IPNet
+	members   map[types.IP]time.Time
+	firstSeen time.Time
+	lastSeen  time.Time
+	marked    bool
+	markType  MarkType
+}
+
+func (p *Prefix) Network() net.IPNet {
+	return p.network
+}
+
+func (p *Prefix) CIDR() types.String {
+	return types.String(p.network.String())
+}
+
+func (p *Prefix) Members() types.UInt32 {
+	return types.Int32(len(p.members)).UInt32()
+}
+
+func (p *Prefix) FirstSeen() time.Time {
+	return p.firstSeen
+}
+
+func (p *Prefix) LastSeen() time.Time {
+	return p.lastSeen
+}
+
+func (p *Prefix) Marked() bool {
+	return p.marked
+}
+
+type Prefixes struct {
+	ipv4Bits  types.UInt16
+	ipv6Bits  types.UInt16
+	thershold types.UInt32
+	expire    time.Duration
+	prefixes  map[types.String]*Prefix
+	onMark    func(*Prefix, MarkType)
+	onUnmark  func(*Prefix, UnmarkType)
+}
+
+func NewPrefixes(config PrefixConfig) *Prefixes {
+	if config.Expire <= 0 {
+		config.Expire = PREFIX_DEFAULT_EXPIRE
+	}
+
+	return &Prefixes{
+		ipv4Bits:  config.IPv4Bits,
+		ipv6Bits:  config.IPv6Bits,
+		thershold: config.Thershold,
+		expire:    config.Expire,
+		prefixes:  map[types.String]*Prefix{},
+		onMark:    config.OnMark,
+		onUnmark:  config.OnUnmark,
+	}
+}
+
+func (p *Prefixes) Network(ip types.IP) (net.IPNet, bool) {
+	addr := ip.IP()
+
+	if addr4 := addr.To4(); addr4 != nil {
+		if p.ipv4Bits == 0 {
+			return net.IPNet{}, false
+		}
+
+		mask := net.CIDRMask(int(p.ipv4Bits), net.IPv4len*8)
+
+		return net.IPNet{IP: addr4.Mask(mask), Mask: mask}, true
+	}
+
+	if p.ipv6Bits == 0 || len(addr) != net.IPv6len {
+		return net.IPNet{}, false
+	}
+
+	mask := net.CIDRMask(int(p.ipv6Bits), net.IPv6len*8)
+
+	return net.IPNet{IP: addr.Mask(mask), Mask: mask}, true
+}
+
+func (p *Prefixes) get(network net.IPNet) (*Prefix, bool) {
+	key := types.String(network.String())
+
+	if _, ok := p.prefixes[key]; ok {
+		return p.prefixes[key], false
+	}
+
+	p.prefixes[key] = &Prefix{
+		network:   network,
+		members:   map[types.IP]time.Time{},
+		firstSeen: time.Now(),
+		lastSeen:  time.Now(),
+		marked:    false,
+		markType:  CLIENT_MARK_PICK,
+	}
+
+	return p.prefixes[key], true
+}
+
+func (p *Prefixes) mark(prefix *Prefix, ty MarkType) {
+	if prefix.marked {
+		return
+	}
+
+	prefix.marked = true
+	prefix.markType = ty
+
+	p.onMark(prefix, ty)
+}
+
+func (p *Prefixes) Join(ip types.IP) *Prefix {
+	if p.thershold == 0 {
+		return nil
+	}
+
+	network, ok := p.Network(ip)
+
+	if !ok {
+		return nil
+	}
+
+	prefix, _ := p.get(network)
+
+	prefix.members[ip] = time.Now()
+	prefix.lastSeen = time.Now()
+
+	if prefix.Members() >= p.thershold {
+		p.mark(prefix, CLIENT_MARK_PICK)
+	}
+
+	return prefix
+}
+
+func (p *Prefixes) Leave(ip types.IP) {
+	network, ok := p.Network(ip)
+
+	if !ok {
+		return
+	}
+
+	key := types.String(network.String())
+
+	if _, ok := p.prefixes[key]; !ok {
+		return
+	}
+
+	prefix := p.prefixes[key]
+
+	delete(prefix.members, ip)
+
+	// Only the prefix we picked can be released by it's members
+	if prefix.marked && prefix.markType == CLIENT_MARK_PICK &&
+		prefix.Members() < p.thershold {
+		prefix.marked = false
+
+		p.onUnmark(prefix, CLIENT_UNMARK_RELEASE)
+	}
+
+	if !prefix.marked && len(prefix.members) == 0 {
+		delete(p.prefixes, key)
+	}
+}
+
+func (p *Prefixes) Has(network net.IPNet) bool {
+	if _, ok := p.prefixes[types.String(network.String())]; !ok {
+		return false
+	}
+
+	return true
+}
+
+// Mark the prefix, ErrPrefixAlreadyMarked will be returned when it's
+// already been marked
+func (p *Prefixes) Mark(network net.IPNet,
+	ty MarkType) (*Prefix, *types.Throw) {
+	prefix, _ := p.get(network)
+
+	if prefix.marked {
+		return prefix, ErrPrefixAlreadyMarked.Throw(prefix.CIDR())
+	}
+
+	prefix.lastSeen = time.Now()
+
+	p.mark(prefix, ty)
+
+	return prefix, nil
+}
+
+// Expire unmarks prefixes which are not released by their members (the
+// ones marked by others) after they have been kept for the expire time
+func (p *Prefixes) Expire(now time.Time) {
+	for _, prefix := range p.prefixes {
+		if !prefix.marked || prefix.markType == CLIENT_MARK_PICK {
+			continue
+		}
+
+		if !now.After(prefix.lastSeen.Add(p.expire)) {
+			continue
+		}
+
+		p.Unmark(prefix.network, CLIENT_UNMARK_EXPIRE)
+	}
+}
+
+func (p *Prefixes) Unmark(network net.IPNet, ty UnmarkType) *types.Throw {
+	key := types.String(network.String())
+
+	if _, ok := p.prefixes[key]; !ok {
+		return ErrPrefixNotFound.Throw(key)
+	}
+
+	prefix := p.prefixes[key]
+
+	delete(p.prefixes, key)
+
+	if prefix.marked {
+		prefix.marked = false
+
+		p.onUnmark(prefix, ty)
+	}
+
+	return nil
+}
+
+func (p *Prefixes) Len() int {
+	return len(p.prefixes)
+}
+
+func (p *Prefixes) Clear() *types.Throw {
+	var err *types.Throw = nil
+
+	for _, prefix := range p.prefixes {
+		unmarkErr := p.Unmark(prefix.network, CLIENT_UNMARK_EXPIRE)
+
+		if unmarkErr != nil {
+			err = unmarkErr
+		}
+	}
+
+	return err
+}
+
+func (p *Prefixes) Export() []ClientExport {
+	prefixes := []ClientExport{}
+
+	for _, prefix := range p.prefixes {
+		if !prefix.marked {
+			continue
+		}
+
+		prefixes = append(prefixes, ClientExport{
+			Address:   prefix.network.IP,
+			CIDR:      prefix.CIDR(),
+			FirstSeen: prefix.FirstSeen(),
+			LastSeen:  prefix.LastSeen(),
+			Count:     prefix.Members(),
+			Records:   []Record{},
+			Marked:    prefix.Marked(),
+		})
+	}
+
+	return prefixes
+}
@@ -0,0 +1,268 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"testing"
+	"time"
+)
+
+func getTestPrefixes(marked *int, unmarked *int) *Prefixes {
+	return NewPrefixes(PrefixConfig{
+		IPv4Bits:  24,
+		IPv6Bits:  64,
+		Thershold: 2,
+		OnMark: func(p *Prefix, ty MarkType) {
+			*marked += 1
+		},
+		OnUnmark: func(p *Prefix, ty UnmarkType) {
+			*unmarked += 1
+		},
+	})
+}
+
+func TestPrefixesNetwork(t *testing.T) {
+	marked, unmarked := 0, 0
+	prefixes := getTestPrefixes(&marked, &unmarked)
+
+	ip4, _ := types.ConvertIPFromString("10.0.0.77")
+	ip6, _ := types.ConvertIPFromString("2001:db8::1:2:3:4")
+
+	network4, ok4 := prefixes.Network(ip4)
+
+	if !ok4 || network4.String() != "10.0.0.0/24" {
+		t.Errorf("Prefixes.Network() failed to aggregate IPv4 address. "+
+			"Expecting '%s', got '%s'", "10.0.0.0/24", network4.String())
+
+		return
+	}
+
+	network6, ok6 := prefixes.Network(ip6)
+
+	if !ok6 || network6.String() != "2001:db8::/64" {
+		t.Errorf("Prefixes.Network() failed to aggregate IPv6 address. "+
+			"Expecting '%s', got '%s'", "2001:db8::/64", network6.String())
+
+		return
+	}
+
+	disabled := NewPrefixes(PrefixConfig{
+		IPv4Bits:  0,
+		IPv6Bits:  64,
+		Thershold: 2,
+	})
+
+	if _, ok := disabled.Network(ip4); ok {
+		t.Error("Prefixes.Network() aggregated a disabled address family")
+
+		return
+	}
+}
+
+func TestPrefixesJoinNLeave(t *testing.T) {
+	marked, unmarked := 0, 0
+	prefixes := getTestPrefixes(&marked, &unmarked)
+
+	ip1, _ := types.ConvertIPFromString("10.0.0.1")
+	ip2, _ := types.ConvertIPFromString("10.0.0.2")
+	ip3, _ := types.ConvertIPFromString("10.0.1.1")
+
+	prefixes.Join(ip1)
+	prefixes.Join(ip3)
+
+	if marked != 0 || len(prefixes.Export()) != 0 {
+		t.Error("Prefixes.Join() marked a prefix before it reaches the " +
+			"thershold")
+
+		return
+	}
+
+	prefix := prefixes.Join(ip2)
+
+	if marked != 1 || !prefix.Marked() || prefix.Members() != 2 {
+		t.Error("Prefixes.Join() failed to mark a prefix after it reaches " +
+			"the thershold")
+
+		return
+	}
+
+	exported := prefixes.Export()
+
+	if len(exported) != 1 || exported[0].CIDR != "10.0.0.0/24" {
+		t.Errorf("Prefixes.Export() exports unexpected result: %v", exported)
+
+		return
+	}
+
+	prefixes.Leave(ip1)
+
+	if unmarked != 1 || prefix.Marked() {
+		t.Error("Prefixes.Leave() failed to release a prefix after its " +
+			"members drops below the thershold")
+
+		return
+	}
+
+	prefixes.Leave(ip2)
+	prefixes.Leave(ip3)
+
+	if prefixes.Len() != 0 {
+		t.Errorf("Prefixes.Leave() failed to remove empty prefixes. "+
+			"Expecting '%d' prefixes, got '%d'", 0, prefixes.Len())
+
+		return
+	}
+}
+
+func TestPrefixesMarkNUnmark(t *testing.T) {
+	marked, unmarked := 0, 0
+	prefixes := getTestPrefixes(&marked, &unmarked)
+
+	_, network, _ := net.ParseCIDR("172.16.3.0/24")
+
+	ip, _ := types.ConvertIPFromString("172.16.3.1")
+
+	_, markErr := prefixes.Mark(*network, CLIENT_MARK_OTHER)
+
+	if markErr != nil || marked != 1 || !prefixes.Has(*network) {
+		t.Error("Prefixes.Mark() failed to mark a prefix")
+
+		return
+	}
+
+	_, markErr = prefixes.Mark(*network, CLIENT_MARK_OTHER)
+
+	if markErr == nil || !markErr.Is(ErrPrefixAlreadyMarked) || marked != 1 {
+		t.Error("Prefixes.Mark() failed to report an already marked prefix")
+
+		return
+	}
+
+	// Member leaving should not release a prefix marked by others
+	prefixes.Join(ip)
+	prefixes.Leave(ip)
+
+	if unmarked != 0 {
+		t.Error("Prefixes.Leave() released a prefix marked by others")
+
+		return
+	}
+
+	if prefixes.Unmark(*network, CLIENT_UNMARK_MANUAL) != nil ||
+		unmarked != 1 || prefixes.Has(*network) {
+		t.Error("Prefixes.Unmark() failed to unmark a prefix")
+
+		return
+	}
+
+	unmarkErr := prefixes.Unmark(*network, CLIENT_UNMARK_MANUAL)
+
+	if unmarkErr == nil || !unmarkErr.Is(ErrPrefixNotFound) {
+		t.Error("Prefixes.Unmark() failed to report a non-existed prefix")
+
+		return
+	}
+}
+
+func TestPrefixesExpire(t *testing.T) {
+	marked, unmarked := 0, 0
+	prefixes := NewPrefixes(PrefixConfig{
+		IPv4Bits:  24,
+		IPv6Bits:  64,
+		Thershold: 1,
+		OnMark: func(p *Prefix, ty MarkType) {
+			marked += 1
+		},
+		OnUnmark: func(p *Prefix, ty UnmarkType) {
+			unmarked += 1
+		},
+		Expire: time.Hour,
+	})
+
+	_, imported, _ := net.ParseCIDR("172.16.3.0/24")
+
+	prefixes.Mark(*imported, CLIENT_MARK_OTHER)
+
+	// Prefix picked by it's members is released by them instead
+	ip, _ := types.ConvertIPFromString("172.16.4.1")
+
+	prefixes.Join(ip)
+
+	prefixes.Expire(time.Now().Add(30 * time.Minute))
+
+	if unmarked != 0 || prefixes.Len() != 2 {
+		t.Error("Prefixes.Expire() released a prefix before it expires")
+
+		return
+	}
+
+	prefixes.Expire(time.Now().Add(2 * time.Hour))
+
+	if unmarked != 1 || prefixes.Has(*imported) || prefixes.Len() != 1 {
+		t.Errorf("Prefixes.Expire() failed to release expired prefix. "+
+			"Expecting '%d' prefix, got '%d'", 1, prefixes.Len())
+
+		return
+	}
+}
+
+func TestPrefixesReleaseNDefaultExpire(t *testing.T) {
+	unmarkTypes := []UnmarkType{}
+	prefixes := NewPrefixes(PrefixConfig{
+		IPv4Bits:  24,
+		IPv6Bits:  64,
+		Thershold: 1,
+		OnMark:    func(p *Prefix, ty MarkType) {},
+		OnUnmark: func(p *Prefix, ty UnmarkType) {
+			unmarkTypes = append(unmarkTypes, ty)
+		},
+	})
+
+	ip, _ := types.ConvertIPFromString("172.16.4.1")
+
+	prefixes.Join(ip)
+	prefixes.Leave(ip)
+
+	if len(unmarkTypes) != 1 || unmarkTypes[0] != CLIENT_UNMARK_RELEASE {
+		t.Errorf("Prefixes.Leave() failed to release the prefix. "+
+			"Got '%v'", unmarkTypes)
+
+		return
+	}
+
+	_, imported, _ := net.ParseCIDR("172.16.3.0/24")
+
+	prefixes.Mark(*imported, CLIENT_MARK_OTHER)
+
+	prefixes.Expire(time.Now().Add(PREFIX_DEFAULT_EXPIRE + time.Minute))
+
+	if len(unmarkTypes) != 2 || unmarkTypes[1] != CLIENT_UNMARK_EXPIRE ||
+		prefixes.Has(*imported) {
+		t.Error("Prefixes.Expire() failed to release imported prefix " +
+			"without a configured expire time")
+
+		return
+	}
+}
@@ -0,0 +1,234 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"hash/fnv"
+	"time"
+)
+
+const (
+	CLIENT_STORE_SHARDS = 64
+)
+
+type storeShard struct {
+	lock    types.Mutex
+	clients *Clients
+}
+
+// Store spreads clients into shards by their address, so only the hits
+// from clients in the same shard will wait for each other
+type Store struct {
+	shards []*storeShard
+	budget *budget
+}
+
+func NewStore(shards int, config Config) *Store {
+	if shards < 1 {
+		shards = 1
+	}
+
+	store := &Store{
+		shards: make([]*storeShard, shards),
+		budget: newBudget(config),
+	}
+
+	// Every shard counts into the same budget, so the limits are applied
+	// to the whole store and the Store evicts across shards
+	for idx := range store.shards {
+		store.shards[idx] = &storeShard{
+			lock:    types.Mutex{},
+			clients: newClients(config, store.budget, false),
+		}
+	}
+
+	return store
+}
+
+func (s *Store) shard(ip types.IP) *storeShard {
+	hash := fnv.New32a()
+
+	hash.Write(ip[:])
+
+	return s.shards[hash.Sum32()%uint32(len(s.shards))]
+}
+
+// Run the callback with the shard which the address belongs to locked
+func (s *Store) Exec(ip types.IP, callback func(*Clients)) {
+	shard := s.shard(ip)
+
+	shard.lock.Exec(func() {
+		callback(shard.clients)
+	})
+
+	if s.budget.over() {
+		s.evict(ip)
+	}
+}
+
+// Evict the least recently seen clients of all shards until the store is
+// back under the limit. Marked clients and the client of the given address
+// will be kept
+func (s *Store) evict(keep types.IP) {
+	for s.budget.over() {
+		var oldestShard *storeShard = nil
+		var oldestSeen time.Time
+
+		for _, shard := range s.shards {
+			shard.lock.Exec(func() {
+				oldest := shard.clients.oldest(shard.clients.clients[keep])
+
+				if oldest == nil {
+					return
+				}
+
+				if oldestShard != nil && !oldest.lastSeen.Before(oldestSeen) {
+					return
+				}
+
+				oldestShard = shard
+				oldestSeen = oldest.lastSeen
+			})
+		}
+
+		if oldestShard == nil {
+			return
+		}
+
+		evicted := false
+
+		oldestShard.lock.Exec(func() {
+			evicted = oldestShard.clients.evictOne(
+				oldestShard.clients.clients[keep])
+		})
+
+		if !evicted {
+			return
+		}
+	}
+}
+
+// Run the callback on every shard, only one shard will be locked at the
+// same time
+func (s *Store) Each(callback func(*Clients) *types.Throw) *types.Throw {
+	var err *types.Throw = nil
+
+	for _, shard := range s.shards {
+		shard.lock.Exec(func() {
+			err = callback(shard.clients)
+		})
+
+		if err != nil {
+			break
+		}
+	}
+
+	return err
+}
+
+func (s *Store) Scan(
+	callback func(types.IP, *Client) *types.Throw) *types.Throw {
+	return s.Each(func(clients *Clients) *types.Throw {
+		return clients.Scan(callback)
+	})
+}
+
+func (s *Store) Export() []ClientExport {
+	clients := []ClientExport{}
+
+	s.Each(func(shardClients *Clients) *types.Throw {
+		clients = append(clients, shardClients.Export()...)
+
+		return nil
+	})
+
+	return clients
+}
+
+func (s *Store) Clear() *types.Throw {
+	var err *types.Throw = nil
+
+	s.Each(func(clients *Clients) *types.Throw {
+		clearErr := clients.Clear()
+
+		if clearErr != nil {
+			err = clearErr
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+func (s *Store) Len() int {
+	total := 0
+
+	s.Each(func(clients *Clients) *types.Throw {
+		total += clients.Len()
+
+		return nil
+	})
+
+	return total
+}
+
+// Count the clients which currently been marked
+func (s *Store) Marked() int {
+	total := 0
+
+	s.Scan(func(ip types.IP, client *Client) *types.Throw {
+		if client.Marked() {
+			total++
+		}
+
+		return nil
+	})
+
+	return total
+}
+
+func (s *Store) Memory() types.UInt64 {
+	total := types.UInt64(0)
+
+	s.Each(func(clients *Clients) *types.Throw {
+		total += clients.Memory()
+
+		return nil
+	})
+
+	return total
+}
+
+func (s *Store) Evicted() types.UInt64 {
+	total := types.UInt64(0)
+
+	s.Each(func(clients *Clients) *types.Throw {
+		total += clients.Evicted()
+
+		return nil
+	})
+
+	return total
+}
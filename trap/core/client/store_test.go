@@ -0,0 +1,300 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package client
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+)
+
+func getTestStore(shards int, maxClients types.UInt32) *Store {
+	return NewStore(shards, Config{
+		MaxClients: maxClients,
+		OnMark:     func(*Client, MarkType) {},
+		OnUnmark:   func(*Client, UnmarkType) {},
+		OnRecord:   func(*Client, Record) {},
+		OnEvict:    func(*Client) {},
+	})
+}
+
+func getTestStoreIP(idx uint32) types.IP {
+	ip := types.IP{}
+
+	// IPv4-mapped address inside 10.0.0.0/8
+	ip[10] = 255
+	ip[11] = 255
+
+	binary.BigEndian.PutUint32(ip[12:], 0x0a000000|(idx&0x00ffffff))
+
+	return ip
+}
+
+func TestStoreExec(t *testing.T) {
+	store := getTestStore(8, 0)
+	wait := sync.WaitGroup{}
+
+	for routine := uint32(0); routine < 16; routine++ {
+		wait.Add(1)
+
+		go func(routine uint32) {
+			defer wait.Done()
+
+			for idx := uint32(0); idx < 256; idx++ {
+				ip := getTestStoreIP(routine*256 + idx)
+
+				store.Exec(ip, func(clients *Clients) {
+					client, _ := clients.Get(ip)
+
+					client.Bump()
+				})
+			}
+		}(routine)
+	}
+
+	wait.Wait()
+
+	if store.Len() != 16*256 {
+		t.Errorf("Store.Exec() failed to add clients. Expecting '%d' "+
+			"clients, got '%d'", 16*256, store.Len())
+
+		return
+	}
+
+	if len(store.Export()) != 16*256 {
+		t.Errorf("Store.Export() exports unexpected amount of clients. "+
+			"Expecting '%d', got '%d'", 16*256, len(store.Export()))
+
+		return
+	}
+
+	if store.Memory() != CLIENT_MEMORY_BASE*16*256 {
+		t.Errorf("Store.Memory() reported unexpected memory usage. "+
+			"Expecting '%d', got '%d'", CLIENT_MEMORY_BASE*16*256,
+			store.Memory())
+
+		return
+	}
+
+	scanned := 0
+
+	store.Scan(func(ip types.IP, client *Client) *types.Throw {
+		scanned += 1
+
+		return nil
+	})
+
+	if scanned != 16*256 {
+		t.Errorf("Store.Scan() scanned unexpected amount of clients. "+
+			"Expecting '%d', got '%d'", 16*256, scanned)
+
+		return
+	}
+
+	store.Clear()
+
+	if store.Len() != 0 || store.Memory() != 0 {
+		t.Error("Store.Clear() failed to clear all clients")
+
+		return
+	}
+}
+
+func TestStoreLimit(t *testing.T) {
+	store := getTestStore(4, 64)
+
+	for idx := uint32(0); idx < 1024; idx++ {
+		ip := getTestStoreIP(idx)
+
+		store.Exec(ip, func(clients *Clients) {
+			clients.Get(ip)
+		})
+	}
+
+	if store.Len() > 64 {
+		t.Errorf("Store failed to limit the amount of clients. Expecting "+
+			"no more than '%d' clients, got '%d'", 64, store.Len())
+
+		return
+	}
+
+	if store.Evicted() != types.UInt64(1024-store.Len()) {
+		t.Errorf("Store.Evicted() reported unexpected eviction count. "+
+			"Expecting '%d', got '%d'", 1024-store.Len(), store.Evicted())
+
+		return
+	}
+}
+
+func TestStoreLimitAcrossShards(t *testing.T) {
+	store := getTestStore(CLIENT_STORE_SHARDS, 10)
+
+	for idx := uint32(0); idx < 1024; idx++ {
+		ip := getTestStoreIP(idx)
+
+		store.Exec(ip, func(clients *Clients) {
+			clients.Get(ip)
+		})
+	}
+
+	if store.Len() != 10 {
+		t.Errorf("Store failed to apply the limit to all shards as a "+
+			"whole. Expecting '%d' clients, got '%d'", 10, store.Len())
+
+		return
+	}
+
+	// The most recently seen clients must be the ones that are kept
+	for idx := uint32(1024 - 10); idx < 1024; idx++ {
+		ip := getTestStoreIP(idx)
+		found := false
+
+		store.Exec(ip, func(clients *Clients) {
+			found = clients.Has(ip)
+		})
+
+		if !found {
+			t.Errorf("Store evicted a recently seen client '%s'", ip.IP())
+
+			return
+		}
+	}
+}
+
+func benchmarkStoreHits(b *testing.B, shards int, sources uint32) {
+	store := getTestStore(shards, 0)
+	sample := make([]byte, 64)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		idx := uint32(0)
+
+		for pb.Next() {
+			ip := getTestStoreIP(idx % sources)
+
+			idx += 7919
+
+			store.Exec(ip, func(clients *Clients) {
+				client, _ := clients.Get(ip)
+
+				client.Record(Record{
+					Inbound:  sample,
+					Outbound: []byte{},
+					Time:     time.Now(),
+				}, 16)
+
+				client.Bump()
+			})
+		}
+	})
+}
+
+func BenchmarkStoreHits1Shard1MSources(b *testing.B) {
+	benchmarkStoreHits(b, 1, 1<<20)
+}
+
+func BenchmarkStoreHits64Shards1MSources(b *testing.B) {
+	benchmarkStoreHits(b, CLIENT_STORE_SHARDS, 1<<20)
+}
+
+func BenchmarkStoreHits64Shards64KSources(b *testing.B) {
+	benchmarkStoreHits(b, CLIENT_STORE_SHARDS, 1<<16)
+}
+
+func BenchmarkStoreExportWhileHitting(b *testing.B) {
+	store := getTestStore(CLIENT_STORE_SHARDS, 0)
+	exitChan := make(chan bool)
+	wait := sync.WaitGroup{}
+
+	for idx := uint32(0); idx < 1<<16; idx++ {
+		ip := getTestStoreIP(idx)
+
+		store.Exec(ip, func(clients *Clients) {
+			clients.Get(ip)
+		})
+	}
+
+	for routine := uint32(0); routine < 8; routine++ {
+		wait.Add(1)
+
+		go func(routine uint32) {
+			defer wait.Done()
+
+			for idx := routine; ; idx += 8 {
+				select {
+				case <-exitChan:
+					return
+
+				default:
+				}
+
+				ip := getTestStoreIP(idx % (1 << 16))
+
+				store.Exec(ip, func(clients *Clients) {
+					client, _ := clients.Get(ip)
+
+					client.Bump()
+				})
+			}
+		}(routine)
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		store.Export()
+	}
+
+	b.StopTimer()
+
+	close(exitChan)
+
+	wait.Wait()
+}
+
+func TestStoreMarked(t *testing.T) {
+	store := getTestStore(4, 0)
+
+	for idx := uint32(0); idx < 16; idx++ {
+		ip := getTestStoreIP(idx)
+
+		store.Exec(ip, func(clients *Clients) {
+			client, _ := clients.Get(ip)
+
+			if idx%4 == 0 {
+				client.Mark(CLIENT_MARK_MANUAL)
+			}
+		})
+	}
+
+	if store.Marked() != 4 {
+		t.Errorf("Store.Marked() failed to count marked clients. "+
+			"Expecting '%d', got '%d'", 4, store.Marked())
+
+		return
+	}
+}
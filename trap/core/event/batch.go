@@ -0,0 +1,177 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// Batch collects the triggered events and runs the command once for all
+// of them. Every event will be rendered into one line of the batch file,
+// which can be accessed through `$((BatchFile))` or the stdin
+type Batch struct {
+	Command  Command
+	Interval time.Duration
+	MaxItems int
+	Line     func(*Parameters) types.String
+	Stdin    bool
+}
+
+type batcher struct {
+	name  types.String
+	batch Batch
+	stats *Stats
+
+	lines    []types.String
+	timer    *time.Timer
+	lock     sync.Mutex
+	execLock sync.Mutex
+}
+
+func (this *Event) RegisterBatch(name types.String, batch Batch) {
+	batch.Command.logger = this.logger.NewContext(batch.Command.Path)
+	batch.Command.stats = &this.stats
+	batch.Command.metrics = this.commandMetrics(name, batch.Command.Path)
+
+	b := &batcher{
+		name:  name,
+		batch: batch,
+		stats: &this.stats,
+		lines: []types.String{},
+	}
+
+	this.batchers = append(this.batchers, b)
+
+	this.Register(name, b.add)
+}
+
+func (b *batcher) add(params *Parameters) *types.Throw {
+	b.lock.Lock()
+
+	b.lines = append(b.lines, b.batch.Line(params))
+
+	full := b.batch.MaxItems > 0 && len(b.lines) >= b.batch.MaxItems
+
+	if !full && len(b.lines) == 1 && b.batch.Interval > 0 {
+		b.timer = time.AfterFunc(b.batch.Interval, func() {
+			b.flush()
+		})
+	}
+
+	b.lock.Unlock()
+
+	if !full {
+		return nil
+	}
+
+	return b.flush()
+}
+
+// Must be called with `lock` locked
+func (b *batcher) take() []types.String {
+	lines := b.lines
+
+	b.lines = []types.String{}
+
+	if b.timer != nil {
+		b.timer.Stop()
+
+		b.timer = nil
+	}
+
+	return lines
+}
+
+func (b *batcher) flush() *types.Throw {
+	// Lines are taken with the `execLock` locked, so batches will be
+	// executed in the order they are collected
+	b.execLock.Lock()
+
+	defer b.execLock.Unlock()
+
+	b.lock.Lock()
+
+	lines := b.take()
+
+	b.lock.Unlock()
+
+	return b.exec(lines)
+}
+
+// Must be called with `execLock` locked
+func (b *batcher) exec(lines []types.String) *types.Throw {
+	if len(lines) <= 0 {
+		return nil
+	}
+
+	content := types.Strings(lines).ImplodeWith("\n").Join("\n").Bytes()
+
+	file, fileErr := ioutil.TempFile("", "trap-batch-")
+
+	if fileErr != nil {
+		return b.failed(types.ConvertError(fileErr))
+	}
+
+	defer os.Remove(file.Name())
+
+	_, writeErr := file.Write(content)
+
+	closeErr := file.Close()
+
+	if writeErr != nil {
+		return b.failed(types.ConvertError(writeErr))
+	}
+
+	if closeErr != nil {
+		return b.failed(types.ConvertError(closeErr))
+	}
+
+	params := Parameters{}.
+		AddString("BatchFile", types.String(file.Name())).
+		AddUInt32("BatchCount", types.Int32(len(lines)).UInt32())
+
+	var input []byte = nil
+
+	if b.batch.Stdin {
+		input = content
+	}
+
+	return b.failed(b.batch.Command.RunWithInput(&params, input))
+}
+
+func (b *batcher) failed(err *types.Throw) *types.Throw {
+	if err == nil {
+		return nil
+	}
+
+	b.stats.Failed.AtomicAdd(1)
+
+	b.batch.Command.logger.Errorf("Failed to run batch for event '%s' "+
+		"due to error: %s", b.name, err)
+
+	return err
+}
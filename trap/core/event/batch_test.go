@@ -0,0 +1,183 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func getTestBatch(dir string, interval time.Duration,
+	maxItems int, stdin bool) *Event {
+	e := getEmptyEvent()
+
+	script := "cat \"$0\" >> \"$2/lines\"; echo \"$1\" >> \"$2/counts\""
+
+	if stdin {
+		script = "cat >> \"$2/lines\"; echo \"$1\" >> \"$2/counts\""
+	}
+
+	e.RegisterBatch("test.batch", Batch{
+		Command: Command{
+			Path: "sh",
+			Arguments: func(p *Parameters) []types.String {
+				return []types.String{"-c", types.String(script),
+					p.Parse("$((BatchFile))",
+						[]types.String{"$((BatchFile))"}),
+					p.Parse("$((BatchCount))",
+						[]types.String{"$((BatchCount))"}),
+					types.String(dir)}
+			},
+			Timeout: 5 * time.Second,
+		},
+		Interval: interval,
+		MaxItems: maxItems,
+		Line: func(p *Parameters) types.String {
+			return p.Parse("add $((ClientIP))",
+				[]types.String{"$((ClientIP))"})
+		},
+		Stdin: stdin,
+	})
+
+	return e
+}
+
+func readTestBatch(dir string, name string) string {
+	content, _ := ioutil.ReadFile(filepath.Join(dir, name))
+
+	return string(content)
+}
+
+func TestBatchMaxItems(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-batch-test-")
+
+	if dirErr != nil {
+		t.Errorf("Can't create test directory due to error: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	e := getTestBatch(dir, 0, 3, false)
+
+	for _, ip := range []types.String{"1", "2", "3", "4", "5", "6", "7"} {
+		tErr := e.Trigger("test.batch", Parameters{}.AddString("ClientIP", ip))
+
+		if tErr != nil {
+			t.Errorf("Can't trigger test event due to error: %s", tErr)
+
+			return
+		}
+	}
+
+	if readTestBatch(dir, "counts") != "3\n3\n" {
+		t.Errorf("Batch failed to run when `MaxItems` is reached, "+
+			"got counts '%s'", readTestBatch(dir, "counts"))
+
+		return
+	}
+
+	e.Flush()
+
+	if readTestBatch(dir, "counts") != "3\n3\n1\n" {
+		t.Errorf("Event.Flush() failed to run pending batch, "+
+			"got counts '%s'", readTestBatch(dir, "counts"))
+
+		return
+	}
+
+	if readTestBatch(dir, "lines") != "add 1\nadd 2\nadd 3\nadd 4\n"+
+		"add 5\nadd 6\nadd 7\n" {
+		t.Errorf("Batch generated unexpected batch file: '%s'",
+			readTestBatch(dir, "lines"))
+
+		return
+	}
+}
+
+func TestBatchInterval(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-batch-test-")
+
+	if dirErr != nil {
+		t.Errorf("Can't create test directory due to error: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	e := getTestBatch(dir, 50*time.Millisecond, 0, true)
+
+	for _, ip := range []types.String{"1", "2", "3"} {
+		e.Trigger("test.batch", Parameters{}.AddString("ClientIP", ip))
+	}
+
+	for i := 0; i < 100 && readTestBatch(dir, "counts") == ""; i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if readTestBatch(dir, "counts") != "3\n" {
+		t.Errorf("Batch failed to run after `Interval`, got counts '%s'",
+			readTestBatch(dir, "counts"))
+
+		return
+	}
+
+	if readTestBatch(dir, "lines") != "add 1\nadd 2\nadd 3\n" {
+		t.Errorf("Batch failed to write stdin: '%s'",
+			readTestBatch(dir, "lines"))
+
+		return
+	}
+}
+
+func TestBatchStats(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-batch-test-")
+
+	if dirErr != nil {
+		t.Errorf("Can't create test directory due to error: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	e := getTestBatch(dir, 0, 3, false)
+
+	for _, ip := range []types.String{"1", "2", "3"} {
+		e.Trigger("test.batch", Parameters{}.AddString("ClientIP", ip))
+	}
+
+	// Only the events are counted, not the batch
+	if e.Stats().Handled != 3 {
+		t.Errorf("Batch failed to count handled events. "+
+			"Expecting '%d', got '%d'", 3, e.Stats().Handled)
+
+		return
+	}
+}
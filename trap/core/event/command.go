@@ -0,0 +1,243 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/metrics"
+	"github.com/raincious/trap/trap/core/types"
+
+	"bufio"
+	"bytes"
+	"context"
+	"os/exec"
+	"time"
+)
+
+// How many bytes of the stdout and stderr of a command will be kept for
+// logging, the rest will be discarded
+const (
+	COMMAND_OUTPUT_LIMIT = 64 * 1024
+)
+
+// How long a command can run before it get killed when no timeout is
+// configured
+const (
+	COMMAND_DEFAULT_TIMEOUT = 10 * time.Second
+)
+
+// Command runs an external program as an event handler
+type Command struct {
+	Path      types.String
+	Arguments func(*Parameters) []types.String
+	Timeout   time.Duration
+	Retries   types.UInt16
+	Backoff   time.Duration
+	Condition *Condition
+
+	logger  *logger.Logger
+	stats   *Stats
+	metrics *commandMetrics
+}
+
+// CommandStats records the executions of a command registered to an event
+type CommandStats struct {
+	Event    types.String
+	Path     types.String
+	Runs     types.UInt64
+	Failures types.UInt64
+	Duration metrics.HistogramSnapshot
+}
+
+type commandMetrics struct {
+	event    types.String
+	path     types.String
+	runs     types.UInt64
+	failures types.UInt64
+	duration *metrics.Histogram
+}
+
+func newCommandMetrics(event types.String,
+	path types.String) *commandMetrics {
+	return &commandMetrics{
+		event:    event,
+		path:     path,
+		duration: metrics.NewHistogram(metrics.DefaultBuckets),
+	}
+}
+
+func (m *commandMetrics) export() CommandStats {
+	return CommandStats{
+		Event:    m.event,
+		Path:     m.path,
+		Runs:     m.runs.AtomicLoad(),
+		Failures: m.failures.AtomicLoad(),
+		Duration: m.duration.Snapshot(),
+	}
+}
+
+func (c Command) Run(params *Parameters) *types.Throw {
+	return c.RunWithInput(params, nil)
+}
+
+// Run the command with the input been written into it's stdin. The
+// command must be registered first so it's output can be logged
+func (c Command) RunWithInput(params *Parameters, input []byte) *types.Throw {
+	var err *types.Throw = nil
+
+	if c.logger == nil {
+		return ErrCommandUnregistered.Throw(c.Path)
+	}
+
+	args := []string{}
+
+	if c.Arguments != nil {
+		for _, arg := range c.Arguments(params) {
+			args = append(args, arg.String())
+		}
+	}
+
+	backoff := c.Backoff
+
+	for attempt := types.UInt16(0); attempt <= c.Retries; attempt++ {
+		if attempt > 0 {
+			if c.stats != nil {
+				c.stats.Retried.AtomicAdd(1)
+			}
+
+			c.logger.Warningf("Retrying in '%s' as the last attempt failed "+
+				"due to error: %s", backoff, err)
+
+			time.Sleep(backoff)
+
+			backoff *= 2
+		}
+
+		err = c.measure(args, input)
+
+		if err == nil {
+			return nil
+		}
+	}
+
+	if c.metrics != nil {
+		c.metrics.failures.AtomicAdd(1)
+	}
+
+	return err
+}
+
+// Execute the command once and record how long it took
+func (c Command) measure(args []string, input []byte) *types.Throw {
+	if c.metrics == nil {
+		return c.exec(args, input)
+	}
+
+	started := time.Now()
+
+	err := c.exec(args, input)
+
+	c.metrics.runs.AtomicAdd(1)
+	c.metrics.duration.ObserveDuration(time.Since(started))
+
+	return err
+}
+
+func (c Command) exec(args []string, input []byte) *types.Throw {
+	ctx := context.Background()
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+
+		defer cancel()
+	}
+
+	stdout := limitedBuffer{limit: COMMAND_OUTPUT_LIMIT}
+	stderr := limitedBuffer{limit: COMMAND_OUTPUT_LIMIT}
+
+	cmd := exec.CommandContext(ctx, c.Path.String(), args...)
+
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+
+	runErr := cmd.Run()
+
+	c.output(&stdout, c.logger.Infof)
+	c.output(&stderr, c.logger.Warningf)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrCommandTimeout.Throw(c.Path, c.Timeout)
+	}
+
+	if runErr != nil {
+		return types.ConvertError(runErr)
+	}
+
+	return nil
+}
+
+func (c Command) output(buf *limitedBuffer,
+	printer func(string, ...interface{})) {
+	scanner := bufio.NewScanner(&buf.buffer)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) <= 0 {
+			continue
+		}
+
+		printer("%s", scanner.Text())
+	}
+
+	if buf.truncated {
+		printer("Output has been truncated after '%d' bytes", buf.limit)
+	}
+}
+
+// Buffer which keeps no more than `limit` bytes and silently discards the
+// rest, so a noisy command will not be killed by a broken pipe
+type limitedBuffer struct {
+	buffer    bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	room := b.limit - b.buffer.Len()
+
+	if room >= len(p) {
+		return b.buffer.Write(p)
+	}
+
+	if room > 0 {
+		b.buffer.Write(p[:room])
+	}
+
+	b.truncated = true
+
+	return len(p), nil
+}
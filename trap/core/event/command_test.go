@@ -0,0 +1,194 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+	"time"
+)
+
+func TestCommandRun(t *testing.T) {
+	e := getEmptyEvent()
+
+	e.RegisterCommand("test.command", Command{
+		Path: "sh",
+		Arguments: func(p *Parameters) []types.String {
+			return []types.String{"-c", p.Parse("test \"$((VALUE))\" = "+
+				"\"expected\"", []types.String{"$((VALUE))"})}
+		},
+		Timeout: 5 * time.Second,
+	})
+
+	tErr := e.Trigger("test.command", Parameters{}.
+		AddString("VALUE", "expected"))
+
+	if tErr != nil {
+		t.Errorf("Command.Run() failed due to error: %s", tErr)
+
+		return
+	}
+
+	tErr = e.Trigger("test.command", Parameters{}.
+		AddString("VALUE", "unexpected"))
+
+	if tErr == nil {
+		t.Error("Command.Run() failed to report the failure")
+
+		return
+	}
+}
+
+func TestCommandTimeout(t *testing.T) {
+	e := getEmptyEvent()
+
+	e.RegisterCommand("test.command", Command{
+		Path: "sleep",
+		Arguments: func(p *Parameters) []types.String {
+			return []types.String{"10"}
+		},
+		Timeout: 100 * time.Millisecond,
+	})
+
+	startTime := time.Now()
+
+	tErr := e.Trigger("test.command", Parameters{})
+
+	if tErr == nil || !tErr.Is(ErrCommandTimeout) {
+		t.Errorf("Command.Run() failed to kill the command: %s", tErr)
+
+		return
+	}
+
+	if time.Now().Sub(startTime) > 5*time.Second {
+		t.Error("Command.Run() failed to kill the command in time")
+
+		return
+	}
+}
+
+func TestCommandUnregistered(t *testing.T) {
+	cmd := Command{
+		Path: "true",
+	}
+
+	tErr := cmd.Run(&Parameters{})
+
+	if tErr == nil || !tErr.Is(ErrCommandUnregistered) {
+		t.Errorf("Command.Run() failed to refuse an unregistered "+
+			"command: %s", tErr)
+
+		return
+	}
+}
+
+func TestCommandRetries(t *testing.T) {
+	e := getEmptyEvent()
+
+	e.RegisterCommand("test.command", Command{
+		Path:    "false",
+		Retries: 2,
+		Backoff: 10 * time.Millisecond,
+	})
+
+	tErr := e.Trigger("test.command", Parameters{})
+
+	if tErr == nil {
+		t.Error("Command.Run() failed to report the failure")
+
+		return
+	}
+
+	stats := e.Stats()
+
+	if stats.Retried != 2 || stats.Failed != 1 {
+		t.Errorf("Command.Run() failed to retry. Expecting '%d' retries, "+
+			"got '%d'", 2, stats.Retried)
+
+		return
+	}
+}
+
+func TestCommandStats(t *testing.T) {
+	e := getEmptyEvent()
+
+	e.RegisterCommand("test.command", Command{
+		Path:    "false",
+		Retries: 1,
+	})
+
+	e.Trigger("test.command", Parameters{})
+
+	stats := e.CommandStats()
+
+	if len(stats) != 1 {
+		t.Errorf("Event.CommandStats() failed to list the commands. "+
+			"Expecting '%d', got '%d'", 1, len(stats))
+
+		return
+	}
+
+	if stats[0].Event != "test.command" || stats[0].Path != "false" {
+		t.Errorf("Event.CommandStats() failed to record the command. "+
+			"Got '%s' '%s'", stats[0].Event, stats[0].Path)
+
+		return
+	}
+
+	if stats[0].Runs != 2 || stats[0].Failures != 1 ||
+		stats[0].Duration.Count != 2 {
+		t.Errorf("Event.CommandStats() failed to count the runs. "+
+			"Expecting '%d', got '%d'", 2, stats[0].Runs)
+
+		return
+	}
+}
+
+func TestCommandOutputLimit(t *testing.T) {
+	buf := limitedBuffer{limit: 8}
+
+	written, err := buf.Write([]byte("12345"))
+
+	if written != 5 || err != nil || buf.truncated {
+		t.Errorf("limitedBuffer.Write() failed to write. Expecting '%d', "+
+			"got '%d'", 5, written)
+
+		return
+	}
+
+	written, err = buf.Write([]byte("67890"))
+
+	if written != 5 || err != nil {
+		t.Errorf("limitedBuffer.Write() must pretend to write everything. "+
+			"Expecting '%d', got '%d'", 5, written)
+
+		return
+	}
+
+	if buf.buffer.String() != "12345678" || !buf.truncated {
+		t.Errorf("limitedBuffer.Write() failed to limit the output. "+
+			"Expecting '%s', got '%s'", "12345678", buf.buffer.String())
+
+		return
+	}
+}
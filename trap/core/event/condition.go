@@ -0,0 +1,312 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/policy"
+	"github.com/raincious/trap/trap/core/types"
+
+	"strconv"
+)
+
+// Condition decides whether a handler should run for the triggered
+// event. It's written in the expression language of the policy, and
+// compares the event parameters with literals. For example:
+//
+//	ServerPort == 22 and Type == "udp"
+//	ClientIP in "10.0.0.0/8" or Count >= 5
+//	not (ServerPort in [80, 443])
+//
+// A comparison with a missing parameter never matches
+type Condition struct {
+	expression *policy.Expression
+}
+
+// Parameters of each event and their kinds, conditions can only compare
+// the parameters of the event they are registered to. Keep it in sync with
+// the parameters the events are triggered with, the tests of the `trap`
+// package will fail otherwise
+var conditionParameters = map[types.String]map[string]policy.FieldKind{
+	"on.server.up":   {},
+	"on.server.down": {},
+	"on.port.registered": {
+		"IP":       policy.FIELD_IP,
+		"Port":     policy.FIELD_NUMBER,
+		"Protocol": policy.FIELD_STRING,
+	},
+	"on.port.unregistered": {
+		"IP":       policy.FIELD_IP,
+		"Port":     policy.FIELD_NUMBER,
+		"Protocol": policy.FIELD_STRING,
+	},
+	"on.port.failed": {
+		"Setting":  policy.FIELD_STRING,
+		"Protocol": policy.FIELD_STRING,
+		"Error":    policy.FIELD_STRING,
+	},
+	"on.port.broken": {
+		"IP":       policy.FIELD_IP,
+		"Port":     policy.FIELD_NUMBER,
+		"Protocol": policy.FIELD_STRING,
+		"Error":    policy.FIELD_STRING,
+	},
+	"on.client.hitting": {
+		"ClientIP":       policy.FIELD_IP,
+		"ServerIP":       policy.FIELD_IP,
+		"ServerPort":     policy.FIELD_NUMBER,
+		"Type":           policy.FIELD_STRING,
+		"ReceivedSample": policy.FIELD_STRING,
+		"RespondedData":  policy.FIELD_STRING,
+		"Signatures":     policy.FIELD_STRING,
+		"Tags":           policy.FIELD_STRING,
+	},
+	"on.client.marked": {
+		"ClientIP": policy.FIELD_IP,
+		"Count":    policy.FIELD_NUMBER,
+		"Tags":     policy.FIELD_STRING,
+		"MarkType": policy.FIELD_STRING,
+	},
+	"on.client.marked.out": {
+		"ClientIP": policy.FIELD_IP,
+		"Count":    policy.FIELD_NUMBER,
+		"MarkType": policy.FIELD_STRING,
+	},
+	"on.client.scanning": {
+		"ClientIP":  policy.FIELD_IP,
+		"Ports":     policy.FIELD_STRING,
+		"PortCount": policy.FIELD_NUMBER,
+		"Count":     policy.FIELD_NUMBER,
+	},
+	"on.client.signature": {
+		"ClientIP":   policy.FIELD_IP,
+		"Signatures": policy.FIELD_STRING,
+		"Tags":       policy.FIELD_STRING,
+		"Severity":   policy.FIELD_STRING,
+		"Score":      policy.FIELD_NUMBER,
+		"Count":      policy.FIELD_NUMBER,
+	},
+	"on.client.evicted": {
+		"ClientIP": policy.FIELD_IP,
+		"Count":    policy.FIELD_NUMBER,
+		"Marked":   policy.FIELD_STRING,
+	},
+	"on.client.imported": {
+		"ClientIP":   policy.FIELD_IP,
+		"ServerIP":   policy.FIELD_IP,
+		"ServerPort": policy.FIELD_NUMBER,
+		"Type":       policy.FIELD_STRING,
+	},
+	"on.prefix.marked": {
+		"CIDR":     policy.FIELD_STRING,
+		"Count":    policy.FIELD_NUMBER,
+		"MarkType": policy.FIELD_STRING,
+	},
+	"on.prefix.marked.out": {
+		"CIDR":     policy.FIELD_STRING,
+		"Count":    policy.FIELD_NUMBER,
+		"MarkType": policy.FIELD_STRING,
+	},
+	"on.sync.node.connected": {
+		"NodeIP":   policy.FIELD_IP,
+		"NodePort": policy.FIELD_NUMBER,
+		"Error":    policy.FIELD_STRING,
+	},
+	"on.sync.node.authed": {
+		"NodeIP":   policy.FIELD_IP,
+		"NodePort": policy.FIELD_NUMBER,
+		"Error":    policy.FIELD_STRING,
+	},
+	"on.sync.node.disconnected": {
+		"NodeIP":   policy.FIELD_IP,
+		"NodePort": policy.FIELD_NUMBER,
+		"Error":    policy.FIELD_STRING,
+	},
+	"on.sync.node.conflicted": {
+		"NodeIP":   policy.FIELD_IP,
+		"NodePort": policy.FIELD_NUMBER,
+		"Error":    policy.FIELD_STRING,
+	},
+	"on.status.login": {
+		"ClientIP": policy.FIELD_IP,
+		"User":     policy.FIELD_STRING,
+	},
+	"on.status.login.failed": {
+		"ClientIP": policy.FIELD_IP,
+		"User":     policy.FIELD_STRING,
+		"Error":    policy.FIELD_STRING,
+	},
+	"on.status.token.used": {
+		"ClientIP":  policy.FIELD_IP,
+		"TokenID":   policy.FIELD_STRING,
+		"TokenName": policy.FIELD_STRING,
+	},
+	"on.status.token.failed": {
+		"ClientIP": policy.FIELD_IP,
+		"Error":    policy.FIELD_STRING,
+	},
+	"on.status.token.created": {
+		"ClientIP":  policy.FIELD_IP,
+		"User":      policy.FIELD_STRING,
+		"TokenID":   policy.FIELD_STRING,
+		"TokenName": policy.FIELD_STRING,
+	},
+	"on.status.token.revoked": {
+		"ClientIP":  policy.FIELD_IP,
+		"User":      policy.FIELD_STRING,
+		"TokenID":   policy.FIELD_STRING,
+		"TokenName": policy.FIELD_STRING,
+	},
+	"on.audit": {
+		"Actor":     policy.FIELD_STRING,
+		"ClientIP":  policy.FIELD_IP,
+		"Action":    policy.FIELD_STRING,
+		"Target":    policy.FIELD_STRING,
+		"Result":    policy.FIELD_STRING,
+		"Error":     policy.FIELD_STRING,
+		"RequestID": policy.FIELD_STRING,
+	},
+}
+
+// ConditionParameters returns the names of the parameters of each event
+// which conditions can compare, built-in parameters are not included
+func ConditionParameters() map[types.String][]types.String {
+	result := make(map[types.String][]types.String,
+		len(conditionParameters))
+
+	for name, params := range conditionParameters {
+		names := make([]types.String, 0, len(params))
+
+		for param := range params {
+			names = append(names, types.String(param))
+		}
+
+		result[name] = names
+	}
+
+	return result
+}
+
+// Parse the condition of a handler of the event, referencing a parameter
+// which the event doesn't have is an error
+func ParseCondition(event types.String,
+	source types.String) (*Condition, *types.Throw) {
+	params, found := conditionParameters[event.Lower()]
+
+	if !found {
+		return nil, ErrConditionUnknownEvent.Throw(event)
+	}
+
+	// Built-in parameters are available for every event
+	kinds := map[string]policy.FieldKind{
+		"Timestamp": policy.FIELD_NUMBER,
+		"Hostname":  policy.FIELD_STRING,
+	}
+
+	for name, kind := range params {
+		kinds[name] = kind
+	}
+
+	expression, err := policy.ParseExpression(source.String(), kinds)
+
+	if err != nil {
+		return nil, ErrConditionSyntax.Throw(source, err)
+	}
+
+	return &Condition{
+		expression: expression,
+	}, nil
+}
+
+func (c *Condition) String() types.String {
+	return types.String(c.expression.String())
+}
+
+func (c *Condition) Match(params *Parameters) bool {
+	return c.expression.Match(conditionValues{params: params})
+}
+
+// Look up the parameters for the expression of a condition
+type conditionValues struct {
+	params *Parameters
+}
+
+func (v conditionValues) Number(name string) (int64, bool) {
+	param, found := (*v.params)[types.String("$(("+name+"))")]
+
+	if !found {
+		return 0, false
+	}
+
+	switch val := param.Value().(type) {
+	case types.Int16:
+		return int64(val), true
+
+	case types.Int32:
+		return int64(val), true
+
+	case types.Int64:
+		return int64(val), true
+
+	case types.UInt16:
+		return int64(val), true
+
+	case types.UInt32:
+		return int64(val), true
+
+	case types.UInt64:
+		return int64(val), true
+	}
+
+	number, err := strconv.ParseInt(param.String().String(), 10, 64)
+
+	if err != nil {
+		return 0, false
+	}
+
+	return number, true
+}
+
+func (v conditionValues) String(name string) (string, bool) {
+	param, found := (*v.params)[types.String("$(("+name+"))")]
+
+	if !found {
+		return "", false
+	}
+
+	return param.String().String(), true
+}
+
+func (v conditionValues) IP(name string) (types.IP, bool) {
+	param, found := (*v.params)[types.String("$(("+name+"))")]
+
+	if !found {
+		return types.IP{}, false
+	}
+
+	ip, err := types.ConvertIPFromString(param.String().Trim())
+
+	if err != nil {
+		return types.IP{}, false
+	}
+
+	return ip, true
+}
@@ -0,0 +1,128 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/policy"
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+)
+
+func init() {
+	conditionParameters["test.condition"] = map[string]policy.FieldKind{
+		"ClientIP":   policy.FIELD_IP,
+		"ServerIP":   policy.FIELD_IP,
+		"Type":       policy.FIELD_STRING,
+		"ServerPort": policy.FIELD_NUMBER,
+		"Count":      policy.FIELD_NUMBER,
+		"Tags":       policy.FIELD_STRING,
+	}
+}
+
+func TestConditionMatch(t *testing.T) {
+	params := Parameters{}.
+		AddString("ClientIP", "10.1.2.3").
+		AddString("Type", "udp").
+		AddUInt16("ServerPort", 22).
+		AddUInt32("Count", 5).
+		AddString("Tags", "mirai,iot")
+
+	tests := []struct {
+		Condition types.String
+		Expected  bool
+	}{
+		{"ServerPort == 22", true},
+		{"ServerPort != 22", false},
+		{"Count >= 5 and Count < 6", true},
+		{"Count > 5", false},
+		{"Type == \"udp\"", true},
+		{"Type == \"tcp\" or ServerPort <= 21", false},
+		{"ClientIP in \"10.0.0.0/8\"", true},
+		{"ClientIP not in \"10.0.0.0/8\"", false},
+		{"ClientIP == \"10.1.2.3\"", true},
+		{"ClientIP in [\"192.168.0.0/16\", \"10.1.2.3\"]", true},
+		{"ServerPort in [80, 443]", false},
+		{"not (ServerPort in [80, 443])", true},
+		{"Tags contains \"mirai\"", true},
+		{"Tags matches \"^iot\"", false},
+		{"Type in [\"tcp\", \"udp\"]", true},
+		{"ServerIP != \"10.0.0.1\"", false},
+		{"not ServerIP == \"10.0.0.1\"", true},
+		{"(Type == \"tcp\" or Type == \"udp\") and Count == 5", true},
+	}
+
+	for _, test := range tests {
+		cond, err := ParseCondition("test.condition", test.Condition)
+
+		if err != nil {
+			t.Errorf("ParseCondition() failed to parse '%s' due to "+
+				"error: %s", test.Condition, err)
+
+			return
+		}
+
+		if cond.Match(&params) != test.Expected {
+			t.Errorf("Condition.Match() failed on '%s'. Expecting "+
+				"'%t', got '%t'", test.Condition, test.Expected,
+				!test.Expected)
+
+			return
+		}
+	}
+}
+
+func TestConditionInvalid(t *testing.T) {
+	for _, condition := range []types.String{
+		"",
+		"ServerPort",
+		"ServerPort = 22",
+		"ServerPort == ",
+		"Type < \"udp\"",
+		"Type matches \"(\"",
+		"ClientIP == \"10.0.0.0/8\"",
+		"ClientIP in [\"10.0.0.0/8\"",
+		"ClientIP in 10.0.0.0/8",
+		"Port == 22",
+		"(ServerPort == 22",
+		"ServerPort == 22 Count == 1",
+		"Type == \"udp",
+		"ServerPort == 22 and",
+		"22 == ServerPort",
+	} {
+		_, err := ParseCondition("test.condition", condition)
+
+		if err == nil || !err.Is(ErrConditionSyntax) {
+			t.Errorf("ParseCondition() failed to reject '%s'", condition)
+
+			return
+		}
+	}
+
+	_, err := ParseCondition("on.nothing", "Count == 1")
+
+	if err == nil || !err.Is(ErrConditionUnknownEvent) {
+		t.Error("ParseCondition() failed to reject the unknown event")
+
+		return
+	}
+}
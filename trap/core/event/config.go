@@ -26,9 +26,33 @@ import (
 	"github.com/raincious/trap/trap/core/types"
 )
 
+// Default amount of event workers and the size of their queue
+const (
+	EVENT_DEFAULT_WORKERS    = 4
+	EVENT_DEFAULT_QUEUE_SIZE = 1024
+)
+
 type Config struct {
-	Logger *logger.Logger
+	Logger    *logger.Logger
+	Workers   int
+	QueueSize int
+}
+
+type Stats struct {
+	Depth    types.UInt64
+	Capacity types.UInt64
+	Handled  types.UInt64
+	Failed   types.UInt64
+	Retried  types.UInt64
+	Dropped  types.UInt64
 }
 
 type Callback func(*Parameters) *types.Throw
-type Callbacks map[types.String][]Callback
+
+// Handler will be skipped when it's Condition not match the parameters
+type Handler struct {
+	Callback  Callback
+	Condition *Condition
+}
+
+type Callbacks map[types.String][]Handler
@@ -0,0 +1,556 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/types"
+
+	"bufio"
+	"encoding/json"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	COPROCESS_MAX_BACKOFF = 1 * time.Minute
+	COPROCESS_STOP_WAIT   = 5 * time.Second
+	COPROCESS_BUFFER_SIZE = 1024
+
+	// How long to wait for the buffered events before stop, events left
+	// in the buffer after that will be dropped
+	COPROCESS_DRAIN_WAIT = 30 * time.Second
+)
+
+// Coprocess is a helper program which will be started once and kept
+// running. Every event will be written into it's stdin as one JSON
+// Payload per line, and the helper must acknowledge it by writing
+//
+//	{"id": "<ID of the payload>", "ok": true}
+//
+// or `{"id": "...", "error": "reason"}` into the stdout. Other stdout
+// lines will be logged. The helper will be restarted when it exits or
+// not acknowledge in Timeout, events will be buffered in the meantime
+type Coprocess struct {
+	Path       types.String
+	Arguments  []types.String
+	Events     []types.String
+	Timeout    time.Duration
+	Retries    types.UInt16
+	Backoff    time.Duration
+	BufferSize int
+}
+
+type coprocessAck struct {
+	ID    types.String `json:"id"`
+	OK    bool         `json:"ok"`
+	Error types.String `json:"error"`
+}
+
+type coprocessInstance struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	acks   chan coprocessAck
+	exited chan struct{}
+}
+
+type coprocess struct {
+	co     Coprocess
+	logger *logger.Logger
+	stats  *Stats
+
+	queue     chan Payload
+	pending   int // Guarded by the lock
+	idle      *sync.Cond
+	drainWait time.Duration
+	lock      sync.Mutex
+	started   bool
+	stopped   bool
+	stopCh    chan struct{}
+	done      chan struct{}
+
+	instance *coprocessInstance
+	backoff  time.Duration
+}
+
+func (this *Event) RegisterCoprocess(co Coprocess) {
+	if co.BufferSize <= 0 {
+		co.BufferSize = COPROCESS_BUFFER_SIZE
+	}
+
+	if co.Backoff <= 0 {
+		co.Backoff = 1 * time.Second
+	}
+
+	c := &coprocess{
+		co:        co,
+		logger:    this.logger.NewContext(co.Path),
+		stats:     &this.stats,
+		queue:     make(chan Payload, co.BufferSize),
+		drainWait: COPROCESS_DRAIN_WAIT,
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+		backoff:   co.Backoff,
+	}
+
+	c.idle = sync.NewCond(&c.lock)
+
+	this.coprocesses = append(this.coprocesses, c)
+
+	for _, name := range co.Events {
+		func(eventName types.String) {
+			this.Register(eventName, func(p *Parameters) *types.Throw {
+				return c.add(eventName, p)
+			})
+		}(name)
+	}
+}
+
+// Events will be buffered until the co-process has been started
+func (c *coprocess) add(name types.String, params *Parameters) *types.Throw {
+	c.lock.Lock()
+
+	defer c.lock.Unlock()
+
+	if c.stopped {
+		return ErrCoprocessStopped.Throw(c.co.Path)
+	}
+
+	select {
+	case c.queue <- NewPayload(name, params):
+		c.pending++
+
+		return nil
+
+	default:
+		c.stats.Dropped.AtomicAdd(1)
+
+		c.logger.Errorf("Event '%s' has been dropped as the co-process "+
+			"buffer is full", name)
+
+		return ErrQueueFull.Throw(name)
+	}
+}
+
+func (c *coprocess) start() {
+	c.lock.Lock()
+
+	defer c.lock.Unlock()
+
+	if c.started || c.stopped {
+		return
+	}
+
+	c.started = true
+
+	go c.run()
+}
+
+// Wait until all buffered events has been handled, or the drain wait
+// has passed. Returns false when there are still events in the buffer
+func (c *coprocess) drain() bool {
+	deadline := time.Now().Add(c.drainWait)
+
+	// Wake up the waiting below when the time is up
+	timer := time.AfterFunc(c.drainWait, func() {
+		c.lock.Lock()
+
+		defer c.lock.Unlock()
+
+		c.idle.Broadcast()
+	})
+
+	defer timer.Stop()
+
+	c.lock.Lock()
+
+	defer c.lock.Unlock()
+
+	for c.pending > 0 {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+
+		c.idle.Wait()
+	}
+
+	return true
+}
+
+// Mark a buffered event as handled
+func (c *coprocess) handled() {
+	c.lock.Lock()
+
+	defer c.lock.Unlock()
+
+	c.pending--
+
+	if c.pending <= 0 {
+		c.idle.Broadcast()
+	}
+}
+
+// Wait until all buffered events has been handled. It gives up after
+// the drain wait, so a dead helper can't hold up the caller
+func (c *coprocess) flush() {
+	c.lock.Lock()
+
+	started := c.started
+
+	c.lock.Unlock()
+
+	if !started {
+		return
+	}
+
+	if !c.drain() {
+		c.logger.Warningf("Co-process failed to handle '%d' buffered "+
+			"events in '%s'", len(c.queue), c.drainWait)
+	}
+}
+
+// Handle buffered events then stop the helper, new events will be
+// rejected after that. Events which are not handled in the drain wait
+// will be dropped
+func (c *coprocess) stop() {
+	c.lock.Lock()
+
+	if c.stopped {
+		c.lock.Unlock()
+
+		return
+	}
+
+	started := c.started
+
+	c.stopped = true
+
+	c.lock.Unlock()
+
+	if started {
+		c.drain()
+	}
+
+	// Interrupts the delivering and the restart wait as well
+	close(c.stopCh)
+
+	if started {
+		<-c.done
+	}
+
+	dropped := 0
+
+	for {
+		select {
+		case <-c.queue:
+			dropped++
+
+			c.stats.Dropped.AtomicAdd(1)
+
+			c.handled()
+
+			continue
+
+		default:
+		}
+
+		break
+	}
+
+	if dropped > 0 {
+		c.logger.Errorf("'%d' buffered events has been dropped as the "+
+			"co-process is stopped", dropped)
+	}
+}
+
+func (c *coprocess) run() {
+	defer close(c.done)
+
+	defer c.terminate()
+
+	for {
+		// Stop even when there are buffered events, they will be dropped
+		select {
+		case <-c.stopCh:
+			return
+
+		default:
+		}
+
+		select {
+		case <-c.stopCh:
+			return
+
+		case payload := <-c.queue:
+			// It's been counted as handled when it's added
+			err := c.deliver(payload)
+
+			if err != nil {
+				c.stats.Failed.AtomicAdd(1)
+
+				c.logger.Errorf("Failed to deliver event '%s' (%s) due to "+
+					"error: %s", payload.Event, payload.ID, err)
+			}
+
+			c.handled()
+		}
+	}
+}
+
+func (c *coprocess) deliver(payload Payload) *types.Throw {
+	var err *types.Throw = nil
+
+	line, jsonErr := json.Marshal(payload)
+
+	if jsonErr != nil {
+		return types.ConvertError(jsonErr)
+	}
+
+	for attempt := types.UInt16(0); attempt <= c.co.Retries; attempt++ {
+		if attempt > 0 {
+			c.stats.Retried.AtomicAdd(1)
+		}
+
+		if c.instance == nil {
+			err = c.spawn()
+
+			if err != nil {
+				c.logger.Errorf("Can't start co-process due to error: %s",
+					err)
+
+				if !c.wait() {
+					return err
+				}
+
+				continue
+			}
+		}
+
+		var retry bool
+
+		retry, err = c.send(payload, line)
+
+		if !retry {
+			return err
+		}
+
+		c.logger.Warningf("Co-process failed to handle event '%s' (%s) "+
+			"due to error: %s", payload.Event, payload.ID, err)
+
+		c.terminate()
+
+		if !c.wait() {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Write the payload and wait for the acknowledgement. Returns true when
+// the helper is broken and the payload should be sent again
+func (c *coprocess) send(payload Payload, line []byte) (bool, *types.Throw) {
+	_, writeErr := c.instance.stdin.Write(append(line, '\n'))
+
+	if writeErr != nil {
+		return true, types.ConvertError(writeErr)
+	}
+
+	var timeout <-chan time.Time = nil
+
+	if c.co.Timeout > 0 {
+		timer := time.NewTimer(c.co.Timeout)
+
+		defer timer.Stop()
+
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case ack := <-c.instance.acks:
+			if ack.ID != payload.ID {
+				c.logger.Warningf("Ignored an acknowledgement of unknown "+
+					"event '%s'", ack.ID)
+
+				continue
+			}
+
+			// The helper is working, so reset the restart wait
+			c.backoff = c.co.Backoff
+
+			if !ack.OK || ack.Error != "" {
+				return false, ErrCoprocessRejected.Throw(ack.Error)
+			}
+
+			return false, nil
+
+		case <-c.instance.exited:
+			return true, ErrCoprocessExited.Throw(c.co.Path)
+
+		case <-timeout:
+			return true, ErrCoprocessTimeout.Throw(c.co.Timeout)
+
+		case <-c.stopCh:
+			return false, ErrCoprocessStopped.Throw(c.co.Path)
+		}
+	}
+}
+
+// Wait before restart the helper. Returns false when stopped
+func (c *coprocess) wait() bool {
+	wait := c.backoff
+
+	c.backoff *= 2
+
+	if c.backoff > COPROCESS_MAX_BACKOFF {
+		c.backoff = COPROCESS_MAX_BACKOFF
+	}
+
+	c.logger.Warningf("Restarting co-process in '%s'", wait)
+
+	select {
+	case <-c.stopCh:
+		return false
+
+	case <-time.After(wait):
+		return true
+	}
+}
+
+func (c *coprocess) spawn() *types.Throw {
+	args := []string{}
+
+	for _, arg := range c.co.Arguments {
+		args = append(args, arg.String())
+	}
+
+	cmd := exec.Command(c.co.Path.String(), args...)
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return types.ConvertError(err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return types.ConvertError(err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+
+	if err != nil {
+		return types.ConvertError(err)
+	}
+
+	err = cmd.Start()
+
+	if err != nil {
+		return types.ConvertError(err)
+	}
+
+	instance := &coprocessInstance{
+		cmd:    cmd,
+		stdin:  stdin,
+		acks:   make(chan coprocessAck, 16),
+		exited: make(chan struct{}),
+	}
+
+	stderrDone := make(chan struct{})
+
+	go func() {
+		defer close(stderrDone)
+
+		scanner := bufio.NewScanner(stderr)
+
+		for scanner.Scan() {
+			c.logger.Warningf("%s", scanner.Text())
+		}
+	}()
+
+	go func() {
+		defer close(instance.exited)
+
+		scanner := bufio.NewScanner(stdout)
+
+		for scanner.Scan() {
+			text := strings.TrimSpace(scanner.Text())
+			ack := coprocessAck{}
+
+			if json.Unmarshal([]byte(text), &ack) != nil || ack.ID == "" {
+				c.logger.Infof("%s", text)
+
+				continue
+			}
+
+			select {
+			case instance.acks <- ack:
+			default:
+				c.logger.Warningf("Too many unread acknowledgements, "+
+					"'%s' has been ignored", ack.ID)
+			}
+		}
+
+		<-stderrDone
+
+		waitErr := cmd.Wait()
+
+		if waitErr != nil {
+			c.logger.Warningf("Co-process exited due to error: %s", waitErr)
+		}
+	}()
+
+	c.instance = instance
+
+	c.logger.Debugf("Co-process has been started")
+
+	return nil
+}
+
+// Close the stdin to ask the helper to exit, kill it if it won't
+func (c *coprocess) terminate() {
+	if c.instance == nil {
+		return
+	}
+
+	instance := c.instance
+
+	c.instance = nil
+
+	instance.stdin.Close()
+
+	select {
+	case <-instance.exited:
+		return
+
+	case <-time.After(COPROCESS_STOP_WAIT):
+	}
+
+	instance.cmd.Process.Kill()
+
+	<-instance.exited
+}
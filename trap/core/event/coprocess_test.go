@@ -0,0 +1,229 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Acknowledge every line with it's ID, and keep it in the `lines` file.
+// The first instance crashes without acknowledge when `crash` is set
+const testCoprocessScript = `
+if [ "$2" = "crash" ] && [ ! -f "$1/crashed" ]; then
+	touch "$1/crashed"
+	read line
+	exit 1
+fi
+
+echo "started" >> "$1/starts"
+
+while read line; do
+	id=$(echo "$line" | sed 's/^{"id":"\([^"]*\)".*/\1/')
+
+	case "$line" in
+		*reject*) echo "{\"id\":\"$id\",\"error\":\"rejected\"}" ;;
+		*) echo "$line" >> "$1/lines"; echo "{\"id\":\"$id\",\"ok\":true}" ;;
+	esac
+done
+`
+
+func getTestCoprocess(t *testing.T, mode string) (*Event, string) {
+	dir, dirErr := ioutil.TempDir("", "trap-coprocess-test")
+
+	if dirErr != nil {
+		t.Fatalf("Can't create temp dir: %s", dirErr)
+	}
+
+	e := getEmptyEvent()
+
+	e.RegisterCoprocess(Coprocess{
+		Path: "sh",
+		Arguments: []types.String{"-c", testCoprocessScript, "sh",
+			types.String(dir), types.String(mode)},
+		Events:     []types.String{"test.coprocess"},
+		Timeout:    5 * time.Second,
+		Retries:    2,
+		Backoff:    10 * time.Millisecond,
+		BufferSize: 16,
+	})
+
+	return e, dir
+}
+
+func readTestLines(dir string, name string) []string {
+	content, _ := ioutil.ReadFile(filepath.Join(dir, name))
+
+	if len(content) <= 0 {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestCoprocessDeliver(t *testing.T) {
+	e, dir := getTestCoprocess(t, "")
+
+	defer os.RemoveAll(dir)
+
+	// Events triggered before start will be buffered
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "A"))
+
+	e.Start()
+
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "B"))
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "reject"))
+
+	e.Flush()
+
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "C"))
+
+	e.Stop()
+
+	lines := readTestLines(dir, "lines")
+
+	if len(lines) != 3 {
+		t.Errorf("Coprocess failed to deliver events. Expecting '%d' "+
+			"lines, got '%d'", 3, len(lines))
+
+		return
+	}
+
+	for idx, expected := range []string{"A", "B", "C"} {
+		payload := struct {
+			Event      string `json:"event"`
+			Parameters struct {
+				ClientIP string `json:"ClientIP"`
+			} `json:"parameters"`
+		}{}
+
+		json.Unmarshal([]byte(lines[idx]), &payload)
+
+		if payload.Event != "test.coprocess" ||
+			payload.Parameters.ClientIP != expected {
+			t.Errorf("Coprocess delivered an unexpected payload: %s",
+				lines[idx])
+
+			return
+		}
+	}
+
+	if len(readTestLines(dir, "starts")) != 1 {
+		t.Error("Coprocess should only be started once")
+
+		return
+	}
+
+	stats := e.Stats()
+
+	if stats.Handled != 4 || stats.Failed != 1 {
+		t.Errorf("Unexpected event stats: %+v", stats)
+
+		return
+	}
+
+	tErr := e.Trigger("test.coprocess", Parameters{})
+
+	if tErr == nil || !tErr.Is(ErrCoprocessStopped) {
+		t.Errorf("Coprocess failed to reject events after stopped: %s",
+			tErr)
+
+		return
+	}
+}
+
+func TestCoprocessRestart(t *testing.T) {
+	e, dir := getTestCoprocess(t, "crash")
+
+	defer os.RemoveAll(dir)
+
+	e.Start()
+
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "A"))
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "B"))
+
+	e.Flush()
+	e.Stop()
+
+	lines := readTestLines(dir, "lines")
+
+	if len(lines) != 2 || !strings.Contains(lines[0], "\"A\"") {
+		t.Errorf("Coprocess failed to deliver events after restart. "+
+			"Got '%v'", lines)
+
+		return
+	}
+
+	if e.Stats().Retried != 1 || e.Stats().Failed != 0 {
+		t.Errorf("Unexpected event stats: %+v", e.Stats())
+
+		return
+	}
+}
+
+func TestCoprocessStopDeadline(t *testing.T) {
+	e := getEmptyEvent()
+
+	// The helper can't be started, and won't be retried for hours
+	e.RegisterCoprocess(Coprocess{
+		Path:       "/nonexistent/trap-coprocess",
+		Events:     []types.String{"test.coprocess"},
+		Retries:    2,
+		Backoff:    time.Hour,
+		BufferSize: 16,
+	})
+
+	e.coprocesses[0].drainWait = 100 * time.Millisecond
+
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "A"))
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "B"))
+	e.Trigger("test.coprocess", Parameters{}.AddString("ClientIP", "C"))
+
+	e.Start()
+
+	started := time.Now()
+
+	e.Flush()
+	e.Stop()
+
+	if elapsed := time.Now().Sub(started); elapsed > 5*time.Second {
+		t.Errorf("Coprocess failed to stop in time. Took '%s'", elapsed)
+
+		return
+	}
+
+	// The one been delivered failed, the others are dropped
+	stats := e.Stats()
+
+	if stats.Failed != 1 || stats.Dropped != 2 {
+		t.Errorf("Unexpected event stats: %+v", stats)
+
+		return
+	}
+}
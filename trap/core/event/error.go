@@ -28,4 +28,46 @@ import (
 var (
 	ErrNoEvent *types.Error = types.NewError(
 		"There is no any handler for event '%s'")
+
+	ErrQueueFull *types.Error = types.NewError(
+		"Event queue is full, event '%s' has been dropped")
+
+	ErrCommandTimeout *types.Error = types.NewError(
+		"Command '%s' has been killed as it runs longer than '%s'")
+
+	ErrCommandUnregistered *types.Error = types.NewError(
+		"Command '%s' must be registered before it can run")
+
+	ErrConditionSyntax *types.Error = types.NewError(
+		"Invalid condition '%s': %s")
+
+	ErrConditionUnknownEvent *types.Error = types.NewError(
+		"Condition can't be used on unknown event '%s'")
+
+	ErrInvalidLabel *types.Error = types.NewError(
+		"Invalid parameter label '%s'")
+
+	ErrUnknownFilter *types.Error = types.NewError(
+		"Unknown filter '%s' in label '%s'")
+
+	ErrInvalidFilterArgument *types.Error = types.NewError(
+		"Filter '%s' has an invalid argument '%s'")
+
+	ErrCoprocessStopped *types.Error = types.NewError(
+		"Co-process '%s' has been stopped")
+
+	ErrCoprocessExited *types.Error = types.NewError(
+		"Co-process '%s' exited unexpectedly")
+
+	ErrCoprocessTimeout *types.Error = types.NewError(
+		"Co-process did not acknowledge the event within '%s'")
+
+	ErrCoprocessRejected *types.Error = types.NewError(
+		"Co-process rejected the event: %s")
+
+	ErrWebhookStatus *types.Error = types.NewError(
+		"Webhook responded with unexpected status '%s'")
+
+	ErrUnknownSIEMFormat *types.Error = types.NewError(
+		"Unknown SIEM format '%s'")
 )
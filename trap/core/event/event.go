@@ -24,47 +24,256 @@ package event
 import (
 	"github.com/raincious/trap/trap/core/logger"
 	"github.com/raincious/trap/trap/core/types"
+
+	"hash/fnv"
+	"os"
+	"sync"
+	"time"
 )
 
+type job struct {
+	name     types.String
+	params   Parameters
+	handlers []Handler
+}
+
 type Event struct {
-	error *types.Throw
+	logger   *logger.Logger
+	events   Callbacks
+	hostname types.String
+
+	workers   int
+	queueSize int
+	queues    []chan job
+	queueLock sync.RWMutex
+	queueWait sync.WaitGroup
+	batchers  []*batcher
+	webhooks  []*webhook
 
-	logger *logger.Logger
-	events Callbacks
+	coprocesses []*coprocess
+
+	commands    []*commandMetrics
+	commandLock sync.RWMutex
+
+	stats Stats
 }
 
 func (this *Event) Init(cfg *Config) {
 	this.logger = cfg.Logger.NewContext("Event")
 
 	this.events = Callbacks{}
+
+	hostname, hostnameErr := os.Hostname()
+
+	if hostnameErr == nil {
+		this.hostname = types.String(hostname)
+	}
+
+	this.workers = cfg.Workers
+	this.queueSize = cfg.QueueSize
+
+	if this.queueSize < this.workers {
+		this.queueSize = this.workers
+	}
 }
 
 func (this *Event) Register(name types.String,
 	callback Callback) {
-	this.events[name] = append(this.events[name], callback)
+	this.RegisterIf(name, nil, callback)
+}
+
+// Register a handler which only runs when the condition matches, a nil
+// condition always matches
+func (this *Event) RegisterIf(name types.String, condition *Condition,
+	callback Callback) {
+	this.events[name] = append(this.events[name], Handler{
+		Callback:  callback,
+		Condition: condition,
+	})
 
 	this.logger.Debugf("New `Event` handler has been registered to '%s' event",
 		name)
 }
 
-func (this *Event) Trigger(name types.String,
-	params Parameters) *types.Throw {
-	var e *types.Throw = nil
+func (this *Event) RegisterCommand(name types.String, cmd Command) {
+	cmd.logger = this.logger.NewContext(cmd.Path)
+	cmd.stats = &this.stats
+	cmd.metrics = this.commandMetrics(name, cmd.Path)
 
-	if _, ok := this.events[name]; !ok {
-		this.error = ErrNoEvent.Throw(name)
+	this.RegisterIf(name, cmd.Condition, cmd.Run)
+}
+
+func (this *Event) commandMetrics(name types.String,
+	path types.String) *commandMetrics {
+	m := newCommandMetrics(name, path)
+
+	this.commandLock.Lock()
+	defer this.commandLock.Unlock()
 
-		this.logger.Warningf("Can't trigger event due to error: %s", this.error)
+	this.commands = append(this.commands, m)
 
-		return this.error
+	return m
+}
+
+// CommandStats returns the execution records of all registered commands
+func (this *Event) CommandStats() []CommandStats {
+	this.commandLock.RLock()
+	defer this.commandLock.RUnlock()
+
+	result := make([]CommandStats, 0, len(this.commands))
+
+	for _, m := range this.commands {
+		result = append(result, m.export())
 	}
 
-	this.logger.Debugf("The event '%s' has been triggered", name)
+	return result
+}
+
+// Start the workers, events will be queued and handled by the workers
+// after that. Only webhooks will be started if no worker is configured
+func (this *Event) Start() {
+	for _, w := range this.webhooks {
+		w.start()
+	}
+
+	for _, c := range this.coprocesses {
+		c.start()
+	}
+
+	this.queueLock.Lock()
+
+	defer this.queueLock.Unlock()
+
+	if this.workers <= 0 || this.queues != nil {
+		return
+	}
+
+	this.queues = make([]chan job, this.workers)
+
+	for idx := range this.queues {
+		this.queues[idx] = make(chan job, this.queueSize/this.workers)
+
+		this.queueWait.Add(1)
+
+		go this.work(this.queues[idx])
+	}
+
+	this.logger.Debugf("'%d' event workers has been started with a queue "+
+		"of '%d' events", this.workers, this.queueSize)
+}
+
+// Wait for all queued events to be handled and stop the workers, then
+// run all pending batches. Events triggered after that will be handled
+// right away
+func (this *Event) Flush() {
+	this.queueLock.Lock()
 
-	for _, eventHandler := range this.events[name] {
-		handleErr := eventHandler(&params)
+	queues := this.queues
+
+	this.queues = nil
+
+	for _, queue := range queues {
+		close(queue)
+	}
+
+	this.queueLock.Unlock()
+
+	this.queueWait.Wait()
+
+	if queues != nil {
+		this.logger.Debugf("Event queue has been flushed")
+	}
+
+	for _, b := range this.batchers {
+		b.flush()
+	}
+
+	for _, w := range this.webhooks {
+		w.flush()
+	}
+
+	for _, c := range this.coprocesses {
+		c.flush()
+	}
+}
+
+// Stop the co-processes, call it after the last event is triggered
+func (this *Event) Stop() {
+	for _, c := range this.coprocesses {
+		c.stop()
+	}
+}
+
+func (this *Event) Stats() Stats {
+	stats := Stats{
+		Handled: this.stats.Handled.AtomicLoad(),
+		Failed:  this.stats.Failed.AtomicLoad(),
+		Retried: this.stats.Retried.AtomicLoad(),
+		Dropped: this.stats.Dropped.AtomicLoad(),
+	}
+
+	this.queueLock.RLock()
+
+	defer this.queueLock.RUnlock()
+
+	for _, queue := range this.queues {
+		stats.Depth += types.UInt64(len(queue))
+		stats.Capacity += types.UInt64(cap(queue))
+	}
+
+	return stats
+}
+
+func (this *Event) work(queue chan job) {
+	defer this.queueWait.Done()
+
+	for j := range queue {
+		this.handle(j.name, j.handlers, &j.params)
+	}
+}
+
+// Events about the same client always go to the same worker, so they
+// will be handled in the order they are triggered. Events which are not
+// about any client all go to the first worker for the same reason
+func (this *Event) queue(name types.String, params Parameters) chan job {
+	var key types.String
+
+	if client, found := params["$((ClientIP))"]; found {
+		key = client.String()
+	} else if cidr, found := params["$((CIDR))"]; found {
+		key = cidr.String()
+	} else {
+		return this.queues[0]
+	}
+
+	hash := fnv.New32a()
+
+	hash.Write(key.Bytes())
+
+	return this.queues[hash.Sum32()%uint32(len(this.queues))]
+}
+
+func (this *Event) handle(name types.String, handlers []Handler,
+	params *Parameters) *types.Throw {
+	var e *types.Throw = nil
+
+	for _, eventHandler := range handlers {
+		if eventHandler.Condition != nil &&
+			!eventHandler.Condition.Match(params) {
+			this.logger.Debugf("Skipping a handler of event '%s' as "+
+				"condition '%s' not match", name,
+				eventHandler.Condition.String())
+
+			continue
+		}
+
+		handleErr := eventHandler.Callback(params)
+
+		this.stats.Handled.AtomicAdd(1)
 
 		if handleErr != nil {
+			this.stats.Failed.AtomicAdd(1)
+
 			this.logger.Errorf("An error happed when "+
 				"run handler for event '%s': %s", name, handleErr)
 
@@ -74,3 +283,60 @@ func (this *Event) Trigger(name types.String,
 
 	return e
 }
+
+// Add built-in parameters which every event has
+func (this *Event) builtin(params Parameters) Parameters {
+	if params == nil {
+		params = Parameters{}
+	}
+
+	if _, found := params["$((Timestamp))"]; !found {
+		params.AddInt64("Timestamp", types.Int64(time.Now().Unix()))
+	}
+
+	if _, found := params["$((Hostname))"]; !found {
+		params.AddString("Hostname", this.hostname)
+	}
+
+	return params
+}
+
+func (this *Event) Trigger(name types.String,
+	params Parameters) *types.Throw {
+	if _, ok := this.events[name]; !ok {
+		// Having no handler for an event is normal as most events are
+		// optional, so don't make any noise about it
+		return ErrNoEvent.Throw(name)
+	}
+
+	this.logger.Debugf("The event '%s' has been triggered", name)
+
+	params = this.builtin(params)
+
+	this.queueLock.RLock()
+
+	if this.queues == nil {
+		this.queueLock.RUnlock()
+
+		return this.handle(name, this.events[name], &params)
+	}
+
+	defer this.queueLock.RUnlock()
+
+	select {
+	case this.queue(name, params) <- job{
+		name:     name,
+		params:   params,
+		handlers: this.events[name],
+	}:
+		return nil
+
+	default:
+		this.stats.Dropped.AtomicAdd(1)
+
+		this.logger.Errorf("Event '%s' has been dropped as the event "+
+			"queue is full", name)
+
+		return ErrQueueFull.Throw(name)
+	}
+}
@@ -25,7 +25,9 @@ import (
 	"github.com/raincious/trap/trap/core/logger"
 	"github.com/raincious/trap/trap/core/types"
 
+	"sync"
 	"testing"
+	"time"
 )
 
 var (
@@ -55,12 +57,6 @@ func TestEventInit(t *testing.T) {
 		Logger: log,
 	})
 
-	if e.error != nil {
-		t.Error("`Event` error is impossible to be un-nil")
-
-		return
-	}
-
 	if e.events == nil {
 		t.Error("`Event` forgot init it's events map")
 
@@ -144,6 +140,37 @@ func TestEventRegisterNTrigger(t *testing.T) {
 	}
 }
 
+func TestEventRegisterIf(t *testing.T) {
+	e := getEmptyEvent()
+
+	results := []types.String{}
+
+	sshOnly, _ := ParseCondition("on.client.hitting", "ServerPort == 22")
+
+	e.RegisterIf("test.callback", sshOnly,
+		func(params *Parameters) *types.Throw {
+			results = append(results, "ssh")
+
+			return nil
+		})
+
+	e.Register("test.callback", func(params *Parameters) *types.Throw {
+		results = append(results, "all")
+
+		return nil
+	})
+
+	e.Trigger("test.callback", Parameters{}.AddUInt16("ServerPort", 22))
+	e.Trigger("test.callback", Parameters{}.AddUInt16("ServerPort", 80))
+
+	if len(results) != 3 || results[0] != "ssh" || results[2] != "all" {
+		t.Errorf("Event.RegisterIf() failed to skip the handler. "+
+			"Expecting '[ssh all all]', got '%v'", results)
+
+		return
+	}
+}
+
 func TestEventRegisterNTriggerMultiCallbacks(t *testing.T) {
 	filledParam := Parameters{}.
 		AddString("STRING", "String")
@@ -208,3 +235,213 @@ func TestEventTriggerNonExisted(t *testing.T) {
 		return
 	}
 }
+
+func TestEventWorkers(t *testing.T) {
+	e := Event{}
+
+	e.Init(&Config{
+		Logger:    getEmptyLogger(),
+		Workers:   4,
+		QueueSize: 1024,
+	})
+
+	e.Start()
+
+	resultLock := sync.Mutex{}
+	results := map[types.String][]types.Int32{}
+
+	e.Register("test.callback", func(params *Parameters) *types.Throw {
+		client := (*params)["$((ClientIP))"]
+		idx := (*params)["$((Index))"]
+
+		resultLock.Lock()
+
+		defer resultLock.Unlock()
+
+		results[client.GetStr()] = append(results[client.GetStr()],
+			idx.GetInt32())
+
+		return nil
+	})
+
+	e.Register("test.failed", func(params *Parameters) *types.Throw {
+		return ErrFakeFail.Throw()
+	})
+
+	for idx := types.Int32(0); idx < 100; idx++ {
+		for _, client := range []types.String{"A", "B", "C"} {
+			tErr := e.Trigger("test.callback", Parameters{}.
+				AddString("ClientIP", client).AddInt32("Index", idx))
+
+			if tErr != nil {
+				t.Errorf("Can't trigger test event due to error: %s", tErr)
+
+				return
+			}
+		}
+	}
+
+	e.Trigger("test.failed", Parameters{})
+
+	e.Flush()
+
+	// Events of the same client must be handled in order
+	for client, indexes := range results {
+		if len(indexes) != 100 {
+			t.Errorf("Excepting '100' results for '%s', got '%d'",
+				client, len(indexes))
+
+			return
+		}
+
+		for idx, index := range indexes {
+			if index == types.Int32(idx) {
+				continue
+			}
+
+			t.Errorf("Events of '%s' has been handled out of order", client)
+
+			return
+		}
+	}
+
+	stats := e.Stats()
+
+	if stats.Handled != 301 || stats.Failed != 1 || stats.Depth != 0 {
+		t.Errorf("Unexpected event stats: %+v", stats)
+
+		return
+	}
+
+	// Event will be handled synchronously after flushed
+	tErr := e.Trigger("test.failed", Parameters{})
+
+	if tErr == nil || !tErr.Is(ErrFakeFail) {
+		t.Errorf("Unexpected error: %s", tErr)
+
+		return
+	}
+}
+
+func TestEventQueueFull(t *testing.T) {
+	e := Event{}
+
+	e.Init(&Config{
+		Logger:    getEmptyLogger(),
+		Workers:   1,
+		QueueSize: 1,
+	})
+
+	e.Start()
+
+	blocker := make(chan bool)
+
+	e.Register("test.callback", func(params *Parameters) *types.Throw {
+		<-blocker
+
+		return nil
+	})
+
+	// First one is taken by the worker, second one fills the queue
+	e.Trigger("test.callback", Parameters{})
+
+	for e.Stats().Depth != 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	e.Trigger("test.callback", Parameters{})
+
+	tErr := e.Trigger("test.callback", Parameters{})
+
+	if tErr == nil || !tErr.Is(ErrQueueFull) {
+		t.Errorf("Unexpected error: %s", tErr)
+
+		return
+	}
+
+	if e.Stats().Dropped != 1 {
+		t.Errorf("Excepting '1' dropped event, got '%d'", e.Stats().Dropped)
+
+		return
+	}
+
+	close(blocker)
+
+	e.Flush()
+}
+
+func TestEventTriggerNoEventConcurrently(t *testing.T) {
+	e := getEmptyEvent()
+
+	wait := sync.WaitGroup{}
+
+	for i := 0; i < 16; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			err := e.Trigger("on.nothing", Parameters{})
+
+			if err == nil || !err.Is(ErrNoEvent) {
+				t.Error("Event.Trigger() failed to report the unknown event")
+			}
+		}()
+	}
+
+	wait.Wait()
+}
+
+func TestEventWorkersWithoutClient(t *testing.T) {
+	e := Event{}
+
+	e.Init(&Config{
+		Logger:    getEmptyLogger(),
+		Workers:   4,
+		QueueSize: 1024,
+	})
+
+	e.Start()
+
+	resultLock := sync.Mutex{}
+	results := []types.Int32{}
+
+	callback := func(params *Parameters) *types.Throw {
+		idx := (*params)["$((Index))"]
+
+		resultLock.Lock()
+
+		defer resultLock.Unlock()
+
+		results = append(results, idx.GetInt32())
+
+		return nil
+	}
+
+	e.Register("test.up", callback)
+	e.Register("test.down", callback)
+
+	for idx := types.Int32(0); idx < 100; idx += 2 {
+		e.Trigger("test.up", Parameters{}.AddInt32("Index", idx))
+		e.Trigger("test.down", Parameters{}.AddInt32("Index", idx+1))
+	}
+
+	e.Flush()
+
+	if len(results) != 100 {
+		t.Errorf("Excepting '100' results, got '%d'", len(results))
+
+		return
+	}
+
+	// Events without a client must be handled in order as well
+	for idx, index := range results {
+		if index == types.Int32(idx) {
+			continue
+		}
+
+		t.Error("Events without a client has been handled out of order")
+
+		return
+	}
+}
@@ -0,0 +1,218 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filters transform the parameter value before it been substituted into
+// the format, like `$((ReceivedSample|hex))` or
+// `$((ReceivedSample|trunc:64|shellquote))`. Filters are applied from
+// left to right
+type filter func(val []byte) []byte
+
+type filterBuilder func(arg string, hasArg bool) (filter, *types.Throw)
+
+var filterNameReg = regexp.MustCompile("^[[:word:]]+$")
+
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"DateTime":    "2006-01-02 15:04:05",
+	"Date":        "2006-01-02",
+}
+
+var filters = map[string]filterBuilder{
+	"hex": func(arg string, hasArg bool) (filter, *types.Throw) {
+		return func(val []byte) []byte {
+			return []byte(hex.EncodeToString(val))
+		}, nil
+	},
+	"base64": func(arg string, hasArg bool) (filter, *types.Throw) {
+		return func(val []byte) []byte {
+			return []byte(base64.StdEncoding.EncodeToString(val))
+		}, nil
+	},
+	"json": func(arg string, hasArg bool) (filter, *types.Throw) {
+		return func(val []byte) []byte {
+			result, _ := json.Marshal(string(val))
+
+			return result
+		}, nil
+	},
+	"shellquote": func(arg string, hasArg bool) (filter, *types.Throw) {
+		return func(val []byte) []byte {
+			return []byte("'" + strings.Replace(string(val), "'",
+				"'\\''", -1) + "'")
+		}, nil
+	},
+	"trunc": func(arg string, hasArg bool) (filter, *types.Throw) {
+		length, err := strconv.ParseUint(arg, 10, 31)
+
+		if !hasArg || err != nil {
+			return nil, ErrInvalidFilterArgument.Throw("trunc", arg)
+		}
+
+		return func(val []byte) []byte {
+			if uint64(len(val)) <= length {
+				return val
+			}
+
+			return val[:length]
+		}, nil
+	},
+	"cidr": func(arg string, hasArg bool) (filter, *types.Throw) {
+		bits, err := strconv.ParseUint(arg, 10, 8)
+
+		if !hasArg || err != nil || bits > 128 {
+			return nil, ErrInvalidFilterArgument.Throw("cidr", arg)
+		}
+
+		return func(val []byte) []byte {
+			ip := net.ParseIP(strings.TrimSpace(string(val)))
+
+			if ip == nil {
+				return val
+			}
+
+			size := 128
+
+			if ip.To4() != nil {
+				ip = ip.To4()
+				size = 32
+			}
+
+			prefix := int(bits)
+
+			if prefix > size {
+				prefix = size
+			}
+
+			network := net.IPNet{
+				IP:   ip.Mask(net.CIDRMask(prefix, size)),
+				Mask: net.CIDRMask(prefix, size),
+			}
+
+			return []byte(network.String())
+		}, nil
+	},
+	"time": func(arg string, hasArg bool) (filter, *types.Throw) {
+		layout, found := timeLayouts[arg]
+
+		if !hasArg {
+			layout = time.RFC3339
+		} else if !found {
+			// Allow Go layouts like `2006/01/02`
+			if time.Unix(0, 0).Format(arg) == arg {
+				return nil, ErrInvalidFilterArgument.Throw("time", arg)
+			}
+
+			layout = arg
+		}
+
+		// The value is an Unix timestamp in seconds
+		return func(val []byte) []byte {
+			seconds, err := strconv.ParseInt(
+				strings.TrimSpace(string(val)), 10, 64)
+
+			if err != nil {
+				return val
+			}
+
+			return []byte(time.Unix(seconds, 0).Format(layout))
+		}, nil
+	},
+}
+
+// Split a label like `$((Name|filter:arg))` into the label of the
+// parameter and it's filters
+func parseLabel(label types.String) (types.String, []filter,
+	*types.Throw) {
+	content := label.String()
+
+	if strings.HasPrefix(content, "$((") && strings.HasSuffix(content, "))") {
+		content = content[3 : len(content)-2]
+	}
+
+	segments := strings.Split(content, "|")
+
+	if !filterNameReg.MatchString(segments[0]) {
+		return "", nil, ErrInvalidLabel.Throw(label)
+	}
+
+	result := []filter{}
+
+	for _, segment := range segments[1:] {
+		name := segment
+		arg := ""
+		hasArg := false
+
+		if colon := strings.Index(segment, ":"); colon >= 0 {
+			name = segment[:colon]
+			arg = segment[colon+1:]
+			hasArg = true
+		}
+
+		builder, found := filters[name]
+
+		if !found {
+			return "", nil, ErrUnknownFilter.Throw(name, label)
+		}
+
+		f, err := builder(arg, hasArg)
+
+		if err != nil {
+			return "", nil, err
+		}
+
+		result = append(result, f)
+	}
+
+	return types.String("$((" + segments[0] + "))"), result, nil
+}
+
+// Check the label, so invalid filters can be found before the label is
+// been used
+func ValidateLabel(label types.String) *types.Throw {
+	_, _, err := parseLabel(label)
+
+	return err
+}
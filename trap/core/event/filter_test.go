@@ -0,0 +1,128 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+	"time"
+)
+
+func TestParametersParseFilters(t *testing.T) {
+	stamp := time.Date(2016, 1, 2, 3, 4, 5, 0, time.Local)
+
+	params := Parameters{}.
+		AddBytes("ReceivedSample", []byte("\x00it's\n")).
+		AddString("ClientIP", "192.0.2.77").
+		AddString("ClientIPv6", "2001:db8::1").
+		AddInt64("Timestamp", types.Int64(stamp.Unix()))
+
+	tests := []struct {
+		Label    types.String
+		Expected types.String
+	}{
+		{"$((ReceivedSample|hex))", "00697427730a"},
+		{"$((ReceivedSample|base64))", "AGl0J3MK"},
+		{"$((ReceivedSample|json))", "\"\\u0000it's\\n\""},
+		{"$((ReceivedSample|shellquote))", "'\x00it'\\''s\n'"},
+		{"$((ReceivedSample|trunc:3|hex))", "006974"},
+		{"$((ClientIP|cidr:24))", "192.0.2.0/24"},
+		{"$((ClientIP|cidr:64))", "192.0.2.77/32"},
+		{"$((ClientIPv6|cidr:32))", "2001:db8::/32"},
+		{"$((Timestamp|time:RFC3339))",
+			types.String(stamp.Format(time.RFC3339))},
+		{"$((Timestamp|time:2006/01/02))", "2016/01/02"},
+		{"$((Timestamp|time))", types.String(stamp.Format(time.RFC3339))},
+		{"$((Missing|hex))", ""},
+	}
+
+	for _, test := range tests {
+		result := params.Parse("<"+test.Label+">",
+			[]types.String{test.Label})
+
+		if result != "<"+test.Expected+">" {
+			t.Errorf("Parameters.Parse() failed to apply filters of '%s'. "+
+				"Expecting '%s', got '%s'", test.Label, test.Expected,
+				result)
+
+			return
+		}
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+	for _, label := range []types.String{
+		"$((ClientIP))",
+		"$((ClientIP|cidr:24|shellquote))",
+		"$((Timestamp|time:Kitchen))",
+	} {
+		err := ValidateLabel(label)
+
+		if err != nil {
+			t.Errorf("ValidateLabel() failed to accept '%s': %s", label, err)
+
+			return
+		}
+	}
+
+	for _, label := range []types.String{
+		"$((ClientIP|upper))",
+		"$((ClientIP|))",
+		"$((ClientIP|trunc))",
+		"$((ClientIP|trunc:-1))",
+		"$((ClientIP|cidr:129))",
+		"$((Timestamp|time:noon))",
+		"$((Client IP))",
+	} {
+		err := ValidateLabel(label)
+
+		if err == nil {
+			t.Errorf("ValidateLabel() failed to reject '%s'", label)
+
+			return
+		}
+	}
+}
+
+func TestEventBuiltinParameters(t *testing.T) {
+	e := getEmptyEvent()
+
+	var timestamp types.Int64
+
+	e.Register("test.builtin", func(p *Parameters) *types.Throw {
+		param := (*p)["$((Timestamp))"]
+
+		timestamp = param.GetInt64()
+
+		return nil
+	})
+
+	e.Trigger("test.builtin", Parameters{})
+
+	if timestamp < types.Int64(time.Now().Add(-time.Minute).Unix()) {
+		t.Errorf("Event.Trigger() failed to add `Timestamp`. Got '%d'",
+			timestamp)
+
+		return
+	}
+}
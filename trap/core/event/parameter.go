@@ -174,6 +174,36 @@ func (p *Parameter) GetBytes() []byte {
 }
 
 // Exporter
+func (p *Parameter) Value() interface{} {
+	switch p.dataType {
+	case DATA_TYPE_INT16:
+		return p.int16Data
+
+	case DATA_TYPE_INT32:
+		return p.int32Data
+
+	case DATA_TYPE_INT64:
+		return p.int64Data
+
+	case DATA_TYPE_UINT16:
+		return p.uint16Data
+
+	case DATA_TYPE_UINT32:
+		return p.uint32Data
+
+	case DATA_TYPE_UINT64:
+		return p.uint64Data
+
+	case DATA_TYPE_STRING:
+		return p.strData
+
+	case DATA_TYPE_BYTES:
+		return p.bytesData
+	}
+
+	return nil
+}
+
 func (p *Parameter) String() types.String {
 	switch p.dataType {
 	case DATA_TYPE_INT16:
@@ -286,18 +316,65 @@ func (p Parameters) AddBytes(key types.String, val []byte) Parameters {
 	return p
 }
 
+// Export parameters with their original names and types, bytes will be
+// base64 encoded when marshal into JSON
+func (p Parameters) Export() map[types.String]interface{} {
+	result := map[types.String]interface{}{}
+
+	for label, val := range p {
+		name := label
+
+		if len(name) > 4 && name[:3] == "$((" && name[len(name)-2:] == "))" {
+			name = name[3 : len(name)-2]
+		}
+
+		result[name] = val.Value()
+	}
+
+	return result
+}
+
+// Raw bytes of the value, for filters
+func (p *Parameter) Bytes() []byte {
+	if p.dataType == DATA_TYPE_BYTES {
+		return p.bytesData
+	}
+
+	return p.String().Bytes()
+}
+
 func (p Parameters) Parse(format types.String,
 	labels []types.String) types.String {
 	for _, label := range labels {
-		if _, ok := p[label]; !ok {
+		name, filters, err := parseLabel(label)
+
+		if err != nil {
 			format = format.Replace(label, "")
 
 			continue
 		}
 
-		val := p[label]
+		val, ok := p[name]
+
+		if !ok {
+			format = format.Replace(label, "")
+
+			continue
+		}
+
+		if len(filters) <= 0 {
+			format = format.Replace(label, val.String())
+
+			continue
+		}
+
+		result := val.Bytes()
+
+		for _, f := range filters {
+			result = f(result)
+		}
 
-		format = format.Replace(label, val.String())
+		format = format.Replace(label, types.String(result))
 	}
 
 	return format
@@ -0,0 +1,52 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Payload is the JSON document of a triggered event which been sent to
+// webhooks and co-processes
+type Payload struct {
+	ID         types.String                 `json:"id"`
+	Event      types.String                 `json:"event"`
+	Time       time.Time                    `json:"time"`
+	Parameters map[types.String]interface{} `json:"parameters"`
+}
+
+func NewPayload(name types.String, params *Parameters) Payload {
+	id := make([]byte, 16)
+
+	rand.Read(id)
+
+	return Payload{
+		ID:         types.String(hex.EncodeToString(id)),
+		Event:      name,
+		Time:       time.Now(),
+		Parameters: params.Export(),
+	}
+}
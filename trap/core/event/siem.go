@@ -0,0 +1,261 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core"
+	"github.com/raincious/trap/trap/core/syslog"
+	"github.com/raincious/trap/trap/core/types"
+
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	SIEM_FORMAT_CEF  = "cef"
+	SIEM_FORMAT_LEEF = "leef"
+)
+
+// Events will be sent to the SIEM when no event is specified
+var SIEMDefaultEvents = []types.String{
+	"on.client.marked",
+	"on.client.marked.out",
+	"on.client.hitting",
+}
+
+type siemEvent struct {
+	ID       types.String
+	Name     types.String
+	Severity int // 0 to 10
+}
+
+var siemEvents = map[types.String]siemEvent{
+	"on.client.marked": {
+		ID:       "client-marked",
+		Name:     "Client marked",
+		Severity: 7,
+	},
+	"on.client.marked.out": {
+		ID:       "client-unmarked",
+		Name:     "Client unmarked",
+		Severity: 3,
+	},
+	"on.client.hitting": {
+		ID:       "client-hit",
+		Name:     "Client hit a trap",
+		Severity: 5,
+	},
+}
+
+// Map event parameters to the field names of CEF and LEEF
+type siemField struct {
+	Parameter types.String
+	CEF       types.String
+	CEFLabel  types.String // For custom strings like `cs1`
+	LEEF      types.String
+}
+
+var siemFields = []siemField{
+	{Parameter: "ClientIP", CEF: "src", LEEF: "src"},
+	{Parameter: "ServerIP", CEF: "dst", LEEF: "dst"},
+	{Parameter: "ServerPort", CEF: "dpt", LEEF: "dstPort"},
+	{Parameter: "Type", CEF: "proto", LEEF: "proto"},
+	{Parameter: "Count", CEF: "cnt", LEEF: "count"},
+	{Parameter: "MarkType", CEF: "cs1", CEFLabel: "MarkType", LEEF: "markType"},
+	{Parameter: "Tags", CEF: "cs2", CEFLabel: "Tags", LEEF: "tags"},
+	{Parameter: "Signatures", CEF: "cs3", CEFLabel: "Signatures", LEEF: "signatures"},
+}
+
+// SIEM sends events to a syslog server in ArcSight CEF or QRadar LEEF
+// format
+type SIEM struct {
+	Format   types.String
+	Writer   *syslog.Writer
+	Facility int
+	Events   []types.String
+}
+
+func (this *Event) RegisterSIEM(s SIEM) *types.Throw {
+	var format func(types.String, *Parameters) types.String
+
+	switch s.Format {
+	case SIEM_FORMAT_CEF:
+		format = FormatCEF
+
+	case SIEM_FORMAT_LEEF:
+		format = FormatLEEF
+
+	default:
+		return ErrUnknownSIEMFormat.Throw(s.Format)
+	}
+
+	if len(s.Events) <= 0 {
+		s.Events = SIEMDefaultEvents
+	}
+
+	for _, name := range s.Events {
+		func(eventName types.String) {
+			this.Register(eventName, func(p *Parameters) *types.Throw {
+				err := s.Writer.Write(s.Facility, siemSeverity(eventName),
+					time.Now(), "", format(eventName, p))
+
+				if err != nil && err.Is(syslog.ErrQueueFull) {
+					this.stats.Dropped.AtomicAdd(1)
+				}
+
+				return err
+			})
+		}(name)
+	}
+
+	return nil
+}
+
+func getSIEMEvent(name types.String) siemEvent {
+	if e, ok := siemEvents[name]; ok {
+		return e
+	}
+
+	return siemEvent{
+		ID:       name,
+		Name:     name,
+		Severity: 3,
+	}
+}
+
+func siemSeverity(name types.String) int {
+	if getSIEMEvent(name).Severity >= 7 {
+		return syslog.SEVERITY_WARNING
+	}
+
+	return syslog.SEVERITY_NOTICE
+}
+
+func siemParameter(params *Parameters, name types.String) (types.String, bool) {
+	param, ok := (*params)["$(("+name+"))"]
+
+	if !ok {
+		return "", false
+	}
+
+	return param.String(), true
+}
+
+func siemSampleHash(params *Parameters) (types.String, bool) {
+	param, ok := (*params)["$((ReceivedSample))"]
+
+	if !ok {
+		return "", false
+	}
+
+	hash := sha256.Sum256(param.Bytes())
+
+	return types.String(hex.EncodeToString(hash[:])), true
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`,
+		"\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+	leefHeaderEscaper = strings.NewReplacer(`|`, `\|`)
+	leefValueEscaper  = strings.NewReplacer("\t", " ", "\r", " ",
+		"\n", " ")
+)
+
+// FormatCEF formats the event into an ArcSight Common Event Format line
+func FormatCEF(name types.String, params *Parameters) types.String {
+	e := getSIEMEvent(name)
+	extensions := []string{
+		fmt.Sprintf("rt=%d", time.Now().UnixNano()/int64(time.Millisecond)),
+	}
+
+	for _, field := range siemFields {
+		value, ok := siemParameter(params, field.Parameter)
+
+		if !ok {
+			continue
+		}
+
+		if field.Parameter == "Type" {
+			value = value.Upper()
+		}
+
+		extensions = append(extensions, fmt.Sprintf("%s=%s",
+			field.CEF, cefValueEscaper.Replace(value.String())))
+
+		if field.CEFLabel != "" {
+			extensions = append(extensions, fmt.Sprintf("%sLabel=%s",
+				field.CEF, field.CEFLabel))
+		}
+	}
+
+	if hash, ok := siemSampleHash(params); ok {
+		extensions = append(extensions, "fileHash="+hash.String())
+	}
+
+	return types.String(fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(core.TRAP_NAME),
+		cefHeaderEscaper.Replace(core.TRAP_NAME),
+		cefHeaderEscaper.Replace(core.TRAP_VERSION),
+		cefHeaderEscaper.Replace(e.ID.String()),
+		cefHeaderEscaper.Replace(e.Name.String()),
+		e.Severity, strings.Join(extensions, " ")))
+}
+
+// FormatLEEF formats the event into a QRadar Log Event Extended Format
+// 2.0 line, attributes are separated by tabs as declared by the `x09`
+// delimiter field of the header
+func FormatLEEF(name types.String, params *Parameters) types.String {
+	e := getSIEMEvent(name)
+	attributes := []string{
+		fmt.Sprintf("sev=%d", e.Severity),
+	}
+
+	for _, field := range siemFields {
+		value, ok := siemParameter(params, field.Parameter)
+
+		if !ok {
+			continue
+		}
+
+		if field.Parameter == "Type" {
+			value = value.Upper()
+		}
+
+		attributes = append(attributes, fmt.Sprintf("%s=%s",
+			field.LEEF, leefValueEscaper.Replace(value.String())))
+	}
+
+	if hash, ok := siemSampleHash(params); ok {
+		attributes = append(attributes, "sampleHash="+hash.String())
+	}
+
+	return types.String(fmt.Sprintf("LEEF:2.0|%s|%s|%s|%s|x09|%s",
+		leefHeaderEscaper.Replace(core.TRAP_NAME),
+		leefHeaderEscaper.Replace(core.TRAP_NAME),
+		leefHeaderEscaper.Replace(core.TRAP_VERSION),
+		leefHeaderEscaper.Replace(e.ID.String()),
+		strings.Join(attributes, "\t")))
+}
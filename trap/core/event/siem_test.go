@@ -0,0 +1,191 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core"
+	"github.com/raincious/trap/trap/core/syslog"
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func getSIEMTestParameters() Parameters {
+	return Parameters{}.
+		AddString("ClientIP", "192.0.2.1").
+		AddString("ServerIP", "198.51.100.1").
+		AddUInt16("ServerPort", 22).
+		AddString("Type", "tcp").
+		AddBytes("ReceivedSample", []byte("SSH-2.0")).
+		AddString("Tags", "ssh|scan=yes")
+}
+
+func TestFormatCEF(t *testing.T) {
+	params := getSIEMTestParameters()
+
+	result := FormatCEF("on.client.hitting", &params).String()
+
+	expectedParts := []string{
+		"CEF:0|Trap|Trap|",
+		"|client-hit|Client hit a trap|5|",
+		" src=192.0.2.1 ",
+		" dst=198.51.100.1 ",
+		" dpt=22 ",
+		" proto=TCP ",
+		" cs2=ssh|scan\\=yes cs2Label=Tags ",
+		" fileHash=" +
+			"60dfb07a3e8d692c41f44def5569022a09450e78bedb53d760f42b9b3d159e25",
+	}
+
+	for _, part := range expectedParts {
+		if !strings.Contains(result+" ", part) {
+			t.Errorf("FormatCEF() failed to format the event. "+
+				"Expecting '%s' in '%s'", part, result)
+
+			return
+		}
+	}
+}
+
+func TestFormatLEEF(t *testing.T) {
+	params := getSIEMTestParameters()
+
+	result := FormatLEEF("on.client.hitting", &params).String()
+
+	header := strings.SplitN(result, "|", 7)
+
+	if len(header) != 7 || header[0] != "LEEF:2.0" || header[1] != "Trap" ||
+		header[2] != "Trap" || header[3] != core.TRAP_VERSION ||
+		header[4] != "client-hit" || header[5] != "x09" {
+		t.Errorf("FormatLEEF() failed to format the header. Got '%s'",
+			result)
+
+		return
+	}
+
+	attributes := strings.Split(header[6], "\t")
+
+	found := map[string]bool{}
+
+	for _, attribute := range attributes {
+		found[attribute] = true
+	}
+
+	for _, expected := range []string{"src=192.0.2.1", "dstPort=22",
+		"proto=TCP", "sev=5", "tags=ssh|scan=yes"} {
+		if found[expected] {
+			continue
+		}
+
+		t.Errorf("FormatLEEF() failed to format the event. "+
+			"Expecting '%s' in '%s'", expected, result)
+
+		return
+	}
+}
+
+func TestEventRegisterSIEM(t *testing.T) {
+	listener, listenErr := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Errorf("Can't listen due to error: %s", listenErr)
+
+		return
+	}
+
+	defer listener.Close()
+
+	writer, dialErr := syslog.Dial("udp",
+		types.String(listener.LocalAddr().String()), "trap")
+
+	if dialErr != nil {
+		t.Errorf("Can't dial syslog due to error: %s", dialErr)
+
+		return
+	}
+
+	defer writer.Close()
+
+	e := getEmptyEvent()
+
+	regErr := e.RegisterSIEM(SIEM{
+		Format:   "xml",
+		Writer:   writer,
+		Facility: 16,
+	})
+
+	if regErr == nil || !regErr.Is(ErrUnknownSIEMFormat) {
+		t.Errorf("Event.RegisterSIEM() failed to reject unknown format. "+
+			"Got '%s'", regErr)
+
+		return
+	}
+
+	regErr = e.RegisterSIEM(SIEM{
+		Format:   SIEM_FORMAT_CEF,
+		Writer:   writer,
+		Facility: 16,
+	})
+
+	if regErr != nil {
+		t.Errorf("Event.RegisterSIEM() failed due to error: %s", regErr)
+
+		return
+	}
+
+	tErr := e.Trigger("on.client.marked", Parameters{}.
+		AddString("ClientIP", "192.0.2.1").
+		AddString("MarkType", "scan"))
+
+	if tErr != nil {
+		t.Errorf("Can't trigger event due to error: %s", tErr)
+
+		return
+	}
+
+	buf := make([]byte, 2048)
+
+	listener.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	n, _, readErr := listener.ReadFrom(buf)
+
+	if readErr != nil {
+		t.Errorf("Can't read message due to error: %s", readErr)
+
+		return
+	}
+
+	received := string(buf[:n])
+
+	// local0 * 8 + warning
+	if !strings.HasPrefix(received, "<132>1 ") ||
+		!strings.Contains(received, "|client-marked|Client marked|7|") ||
+		!strings.Contains(received, "cs1=scan cs1Label=MarkType") {
+		t.Errorf("Event.RegisterSIEM() sent unexpected message '%s'",
+			received)
+
+		return
+	}
+}
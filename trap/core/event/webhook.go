@@ -0,0 +1,313 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/types"
+
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	WEBHOOK_SIGNATURE_HEADER = "X-Trap-Signature"
+	WEBHOOK_EVENT_HEADER     = "X-Trap-Event"
+	WEBHOOK_DELIVERY_HEADER  = "X-Trap-Delivery"
+)
+
+// Events will be sent to the webhook when no event is specified
+var WebhookDefaultEvents = []types.String{
+	"on.server.up",
+	"on.server.down",
+	"on.client.marked",
+	"on.client.marked.out",
+	"on.client.hitting",
+}
+
+// Webhook posts triggered events to an HTTP endpoint as JSON documents.
+// The document is signed with HMAC-SHA256 of the Secret, and the
+// deliveries which still failed after all retries will be appended into
+// the DeadLetter file
+type Webhook struct {
+	URL        types.String
+	Secret     types.String
+	Events     []types.String
+	Timeout    time.Duration
+	Retries    types.UInt16
+	Backoff    time.Duration
+	QueueSize  int
+	DeadLetter types.String
+}
+
+type webhookDelivery struct {
+	event   types.String
+	payload Payload
+}
+
+type webhookDeadLetter struct {
+	URL     types.String    `json:"url"`
+	Error   types.String    `json:"error"`
+	Time    time.Time       `json:"time"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+type webhook struct {
+	hook   Webhook
+	client *http.Client
+	logger *logger.Logger
+	stats  *Stats
+
+	queue     chan webhookDelivery
+	queueLock sync.RWMutex
+	queueWait sync.WaitGroup
+	deadLock  sync.Mutex
+}
+
+func (this *Event) RegisterWebhook(hook Webhook) {
+	if len(hook.Events) <= 0 {
+		hook.Events = WebhookDefaultEvents
+	}
+
+	w := &webhook{
+		hook: hook,
+		client: &http.Client{
+			Timeout: hook.Timeout,
+		},
+		logger: this.logger.NewContext(hook.URL),
+		stats:  &this.stats,
+	}
+
+	this.webhooks = append(this.webhooks, w)
+
+	for _, name := range hook.Events {
+		func(eventName types.String) {
+			this.Register(eventName, func(p *Parameters) *types.Throw {
+				return w.add(eventName, p)
+			})
+		}(name)
+	}
+}
+
+// Deliveries will be sent from it's own goroutine after started, so a
+// slow endpoint will not block the event workers
+func (w *webhook) start() {
+	w.queueLock.Lock()
+
+	defer w.queueLock.Unlock()
+
+	if w.queue != nil || w.hook.QueueSize <= 0 {
+		return
+	}
+
+	w.queue = make(chan webhookDelivery, w.hook.QueueSize)
+
+	w.queueWait.Add(1)
+
+	go w.work(w.queue)
+}
+
+func (w *webhook) flush() {
+	w.queueLock.Lock()
+
+	queue := w.queue
+
+	w.queue = nil
+
+	if queue != nil {
+		close(queue)
+	}
+
+	w.queueLock.Unlock()
+
+	w.queueWait.Wait()
+}
+
+func (w *webhook) work(queue chan webhookDelivery) {
+	defer w.queueWait.Done()
+
+	// Deliveries has been counted as handled when they are added
+	for d := range queue {
+		err := w.deliver(d)
+
+		if err != nil {
+			w.stats.Failed.AtomicAdd(1)
+		}
+	}
+}
+
+func (w *webhook) add(name types.String, params *Parameters) *types.Throw {
+	d := webhookDelivery{
+		event:   name,
+		payload: NewPayload(name, params),
+	}
+
+	w.queueLock.RLock()
+
+	if w.queue == nil {
+		w.queueLock.RUnlock()
+
+		return w.deliver(d)
+	}
+
+	defer w.queueLock.RUnlock()
+
+	select {
+	case w.queue <- d:
+		return nil
+
+	default:
+		err := ErrQueueFull.Throw(name)
+
+		w.stats.Dropped.AtomicAdd(1)
+
+		w.dead(d, err)
+
+		return err
+	}
+}
+
+func (w *webhook) deliver(d webhookDelivery) *types.Throw {
+	var err *types.Throw = nil
+
+	body, jsonErr := json.Marshal(d.payload)
+
+	if jsonErr != nil {
+		err = types.ConvertError(jsonErr)
+
+		w.dead(d, err)
+
+		return err
+	}
+
+	backoff := w.hook.Backoff
+
+	for attempt := types.UInt16(0); attempt <= w.hook.Retries; attempt++ {
+		if attempt > 0 {
+			w.stats.Retried.AtomicAdd(1)
+
+			w.logger.Warningf("Retrying in '%s' as the last attempt failed "+
+				"due to error: %s", backoff, err)
+
+			time.Sleep(backoff)
+
+			backoff *= 2
+		}
+
+		err = w.post(d, body)
+
+		if err == nil {
+			return nil
+		}
+	}
+
+	w.dead(d, err)
+
+	return err
+}
+
+func (w *webhook) post(d webhookDelivery, body []byte) *types.Throw {
+	req, reqErr := http.NewRequest("POST", w.hook.URL.String(),
+		bytes.NewReader(body))
+
+	if reqErr != nil {
+		return types.ConvertError(reqErr)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WEBHOOK_EVENT_HEADER, d.event.String())
+	req.Header.Set(WEBHOOK_DELIVERY_HEADER, d.payload.ID.String())
+	req.Header.Set(WEBHOOK_SIGNATURE_HEADER,
+		WebhookSignature(w.hook.Secret, body).String())
+
+	resp, respErr := w.client.Do(req)
+
+	if respErr != nil {
+		return types.ConvertError(respErr)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return ErrWebhookStatus.Throw(resp.Status)
+	}
+
+	return nil
+}
+
+// Save the failed delivery, so it can be sent again manually
+func (w *webhook) dead(d webhookDelivery, err *types.Throw) {
+	w.logger.Errorf("Failed to deliver event '%s' (%s) due to error: %s",
+		d.event, d.payload.ID, err)
+
+	if w.hook.DeadLetter == "" {
+		return
+	}
+
+	payload, _ := json.Marshal(d.payload)
+
+	line, _ := json.Marshal(webhookDeadLetter{
+		URL:     w.hook.URL,
+		Error:   types.String(err.Error()),
+		Time:    time.Now(),
+		Payload: payload,
+	})
+
+	w.deadLock.Lock()
+
+	defer w.deadLock.Unlock()
+
+	file, fileErr := os.OpenFile(w.hook.DeadLetter.String(),
+		os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+
+	if fileErr != nil {
+		w.logger.Errorf("Can't open dead letter file '%s' due to error: %s",
+			w.hook.DeadLetter, fileErr)
+
+		return
+	}
+
+	defer file.Close()
+
+	_, writeErr := file.Write(append(line, '\n'))
+
+	if writeErr != nil {
+		w.logger.Errorf("Can't write dead letter file '%s' due to error: %s",
+			w.hook.DeadLetter, writeErr)
+	}
+}
+
+// Signature of the payload, in format of `sha256=<hex of HMAC-SHA256>`
+func WebhookSignature(secret types.String, body []byte) types.String {
+	mac := hmac.New(sha256.New, secret.Bytes())
+
+	mac.Write(body)
+
+	return types.String("sha256=" + hex.EncodeToString(mac.Sum(nil)))
+}
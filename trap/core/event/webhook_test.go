@@ -0,0 +1,245 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package event
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testReceiver struct {
+	lock     sync.Mutex
+	fails    int
+	bodies   [][]byte
+	headers  []http.Header
+	requests int
+}
+
+func (r *testReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.requests += 1
+
+	if r.fails > 0 {
+		r.fails -= 1
+
+		w.WriteHeader(503)
+
+		return
+	}
+
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header)
+
+	w.WriteHeader(204)
+}
+
+func TestWebhookDeliver(t *testing.T) {
+	receiver := &testReceiver{fails: 1}
+	server := httptest.NewServer(receiver)
+
+	defer server.Close()
+
+	e := getEmptyEvent()
+
+	e.RegisterWebhook(Webhook{
+		URL:     types.String(server.URL),
+		Secret:  "secret",
+		Events:  []types.String{"on.client.hitting"},
+		Timeout: 5 * time.Second,
+		Retries: 2,
+		Backoff: 10 * time.Millisecond,
+	})
+
+	tErr := e.Trigger("on.client.hitting", Parameters{}.
+		AddString("ClientIP", "192.0.2.1").
+		AddUInt16("ServerPort", 22).
+		AddBytes("ReceivedSample", []byte{0, 1, 2, 'S', 'S', 'H'}))
+
+	if tErr != nil {
+		t.Errorf("Webhook failed to deliver the event due to error: %s",
+			tErr)
+
+		return
+	}
+
+	if receiver.requests != 2 || len(receiver.bodies) != 1 {
+		t.Errorf("Webhook failed to retry. Expecting '%d' requests, "+
+			"got '%d'", 2, receiver.requests)
+
+		return
+	}
+
+	body := receiver.bodies[0]
+	header := receiver.headers[0]
+
+	if header.Get(WEBHOOK_SIGNATURE_HEADER) !=
+		WebhookSignature("secret", body).String() {
+		t.Errorf("Webhook failed to sign the payload. Got '%s'",
+			header.Get(WEBHOOK_SIGNATURE_HEADER))
+
+		return
+	}
+
+	if header.Get(WEBHOOK_EVENT_HEADER) != "on.client.hitting" {
+		t.Errorf("Webhook failed to send event header. Got '%s'",
+			header.Get(WEBHOOK_EVENT_HEADER))
+
+		return
+	}
+
+	payload := struct {
+		ID         string `json:"id"`
+		Event      string `json:"event"`
+		Parameters struct {
+			ClientIP       string `json:"ClientIP"`
+			ServerPort     int    `json:"ServerPort"`
+			ReceivedSample string `json:"ReceivedSample"`
+		} `json:"parameters"`
+	}{}
+
+	jsonErr := json.Unmarshal(body, &payload)
+
+	if jsonErr != nil {
+		t.Errorf("Webhook sent an invalid payload: %s", jsonErr)
+
+		return
+	}
+
+	if payload.ID != header.Get(WEBHOOK_DELIVERY_HEADER) ||
+		payload.Event != "on.client.hitting" ||
+		payload.Parameters.ClientIP != "192.0.2.1" ||
+		payload.Parameters.ServerPort != 22 {
+		t.Errorf("Webhook sent an unexpected payload: %s", body)
+
+		return
+	}
+
+	sample, _ := base64.StdEncoding.DecodeString(
+		payload.Parameters.ReceivedSample)
+
+	if string(sample) != "\x00\x01\x02SSH" {
+		t.Errorf("Webhook failed to encode bytes. Got '%s'",
+			payload.Parameters.ReceivedSample)
+
+		return
+	}
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	receiver := &testReceiver{fails: 100}
+	server := httptest.NewServer(receiver)
+
+	defer server.Close()
+
+	dir, dirErr := ioutil.TempDir("", "trap-webhook-test")
+
+	if dirErr != nil {
+		t.Errorf("Can't create temp dir: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	deadLetter := filepath.Join(dir, "dead.log")
+
+	e := getEmptyEvent()
+
+	e.RegisterWebhook(Webhook{
+		URL:        types.String(server.URL),
+		Secret:     "secret",
+		Timeout:    5 * time.Second,
+		Retries:    1,
+		Backoff:    10 * time.Millisecond,
+		QueueSize:  8,
+		DeadLetter: types.String(deadLetter),
+	})
+
+	e.Start()
+
+	for _, ip := range []types.String{"192.0.2.1", "192.0.2.2"} {
+		tErr := e.Trigger("on.client.marked", Parameters{}.
+			AddString("ClientIP", ip))
+
+		if tErr != nil {
+			t.Errorf("Webhook failed to queue the event due to error: %s",
+				tErr)
+
+			return
+		}
+	}
+
+	e.Flush()
+
+	if receiver.requests != 4 {
+		t.Errorf("Webhook failed to retry. Expecting '%d' requests, "+
+			"got '%d'", 4, receiver.requests)
+
+		return
+	}
+
+	content, readErr := ioutil.ReadFile(deadLetter)
+
+	if readErr != nil {
+		t.Errorf("Can't read dead letter file: %s", readErr)
+
+		return
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+
+	if len(lines) != 2 {
+		t.Errorf("Webhook failed to write dead letters. Expecting '%d' "+
+			"lines, got '%d'", 2, len(lines))
+
+		return
+	}
+
+	if !strings.Contains(lines[1], "192.0.2.2") ||
+		!strings.Contains(lines[1], "503") {
+		t.Errorf("Webhook wrote an unexpected dead letter: %s", lines[1])
+
+		return
+	}
+
+	if e.Stats().Failed != 2 || e.Stats().Retried != 2 {
+		t.Errorf("Webhook failed to count the failures. Expecting "+
+			"'2, 2', got '%d, %d'", e.Stats().Failed, e.Stats().Retried)
+
+		return
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"bytes"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Backend manages a dedicated set of blocked addresses in the firewall
+type Backend interface {
+	// Create the set and the rules which drop traffics from the set
+	Up() *types.Throw
+
+	// Remove the rules and the set
+	Down() *types.Throw
+
+	// Add or refresh an address, it will be removed by the firewall
+	// automatically after the ttl. A ttl of 0 means never
+	Add(ip types.IP, ttl time.Duration) *types.Throw
+
+	Remove(ip types.IP) *types.Throw
+
+	// List all addresses currently in the set
+	List() ([]types.IP, *types.Throw)
+}
+
+// Create a Backend by it's name, `ipset` or `nftables`
+func New(backend types.String, set types.String) (Backend, *types.Throw) {
+	switch backend {
+	case "ipset":
+		return NewIPSet(set)
+
+	case "nftables":
+		return NewNFTables(set)
+	}
+
+	return nil, ErrUnknownBackend.Throw(backend)
+}
+
+// Runner runs an external command, input will be written into stdin
+type Runner func(input []byte, name string,
+	args ...string) ([]byte, *types.Throw)
+
+var setNameReg = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_]{0,23}$")
+
+func checkSetName(name types.String) *types.Throw {
+	if !setNameReg.MatchString(name.String()) {
+		return ErrInvalidSetName.Throw(name)
+	}
+
+	return nil
+}
+
+func execRunner(input []byte, name string,
+	args ...string) ([]byte, *types.Throw) {
+	output := bytes.Buffer{}
+
+	cmd := exec.Command(name, args...)
+
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	}
+
+	err := cmd.Run()
+
+	if err != nil {
+		return output.Bytes(), ErrCommandFailed.Throw(
+			name+" "+strings.Join(args, " "), err,
+			strings.TrimSpace(output.String()))
+	}
+
+	return output.Bytes(), nil
+}
+
+func isIPv4(ip types.IP) bool {
+	return ip.IP().To4() != nil
+}
+
+func seconds(ttl time.Duration) types.String {
+	secs := types.Int64(ttl / time.Second)
+
+	if ttl > 0 && secs < 1 {
+		secs = 1
+	}
+
+	return secs.String()
+}
+
+// Make the set the same as the desired addresses, returns how many
+// addresses has been added and removed
+func Reconcile(backend Backend,
+	desired map[types.IP]time.Duration) (int, int, *types.Throw) {
+	added := 0
+	removed := 0
+
+	current, err := backend.List()
+
+	if err != nil {
+		return added, removed, err
+	}
+
+	existed := map[types.IP]bool{}
+
+	for _, ip := range current {
+		existed[ip] = true
+
+		if _, keep := desired[ip]; keep {
+			continue
+		}
+
+		err = backend.Remove(ip)
+
+		if err != nil {
+			return added, removed, err
+		}
+
+		removed += 1
+	}
+
+	for ip, ttl := range desired {
+		if existed[ip] {
+			continue
+		}
+
+		err = backend.Add(ip, ttl)
+
+		if err != nil {
+			return added, removed, err
+		}
+
+		added += 1
+	}
+
+	return added, removed, nil
+}
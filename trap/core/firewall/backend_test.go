@@ -0,0 +1,215 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+	"time"
+)
+
+func mustIP(t *testing.T, addr string) types.IP {
+	ip, err := types.ConvertIPFromString(types.String(addr))
+
+	if err != nil {
+		t.Fatalf("Can't convert IP '%s': %s", addr, err)
+	}
+
+	return ip
+}
+
+func TestReconcile(t *testing.T) {
+	r := NewRecorder()
+
+	r.Add(mustIP(t, "192.0.2.1"), time.Hour)
+	r.Add(mustIP(t, "192.0.2.2"), time.Hour)
+
+	added, removed, err := Reconcile(r, map[types.IP]time.Duration{
+		mustIP(t, "192.0.2.2"):   time.Hour,
+		mustIP(t, "2001:db8::1"): time.Minute,
+	})
+
+	if err != nil {
+		t.Errorf("Reconcile() failed due to error: %s", err)
+
+		return
+	}
+
+	if added != 1 || removed != 1 {
+		t.Errorf("Reconcile() failed to calculate changes. "+
+			"Expecting '1, 1', got '%d, %d'", added, removed)
+
+		return
+	}
+
+	entries := r.Entries()
+
+	if len(entries) != 2 {
+		t.Errorf("Reconcile() failed to update the set. "+
+			"Expecting '%d' entries, got '%d'", 2, len(entries))
+
+		return
+	}
+
+	if entries[mustIP(t, "2001:db8::1")] != time.Minute {
+		t.Error("Reconcile() failed to add missing address with it's ttl")
+
+		return
+	}
+
+	if _, ok := entries[mustIP(t, "192.0.2.1")]; ok {
+		t.Error("Reconcile() failed to remove unwanted address")
+
+		return
+	}
+}
+
+func TestManager(t *testing.T) {
+	r := NewRecorder()
+	m := NewManager(r, logger.NewLogger())
+
+	m.Add(mustIP(t, "192.0.2.1"), time.Hour)
+
+	if len(r.Calls()) != 0 {
+		t.Error("Manager.Add() failed to ignore changes before it's up")
+
+		return
+	}
+
+	err := m.Up()
+
+	if err != nil {
+		t.Errorf("Manager.Up() failed due to error: %s", err)
+
+		return
+	}
+
+	if !r.IsUp() {
+		t.Error("Manager.Up() failed to bring the backend up")
+
+		return
+	}
+
+	m.Add(mustIP(t, "192.0.2.1"), time.Hour)
+	m.Add(mustIP(t, "192.0.2.2"), time.Hour)
+	m.Remove(mustIP(t, "192.0.2.1"))
+	m.Reconcile(map[types.IP]time.Duration{
+		mustIP(t, "192.0.2.2"): time.Hour,
+		mustIP(t, "192.0.2.3"): time.Hour,
+	})
+
+	m.Sync()
+
+	expected := []types.String{
+		"up",
+		"add 192.0.2.1",
+		"add 192.0.2.2",
+		"remove 192.0.2.1",
+		"add 192.0.2.3",
+	}
+
+	calls := r.Calls()
+
+	if len(calls) != len(expected) {
+		t.Errorf("Manager failed to apply changes in order. "+
+			"Expecting '%v', got '%v'", expected, calls)
+
+		return
+	}
+
+	for i := range expected {
+		if calls[i] != expected[i] {
+			t.Errorf("Manager failed to apply changes in order. "+
+				"Expecting '%v', got '%v'", expected, calls)
+
+			return
+		}
+	}
+
+	err = m.Down()
+
+	if err != nil {
+		t.Errorf("Manager.Down() failed due to error: %s", err)
+
+		return
+	}
+
+	if r.IsUp() {
+		t.Error("Manager.Down() failed to bring the backend down")
+
+		return
+	}
+}
+
+func TestManagerRefresh(t *testing.T) {
+	r := NewRecorder()
+	m := NewManager(r, logger.NewLogger())
+
+	err := m.Up()
+
+	if err != nil {
+		t.Errorf("Manager.Up() failed due to error: %s", err)
+
+		return
+	}
+
+	m.Refresh(mustIP(t, "192.0.2.1"), time.Hour)
+	m.Refresh(mustIP(t, "192.0.2.1"), time.Hour)
+	m.Refresh(mustIP(t, "192.0.2.1"), time.Hour+time.Minute)
+
+	// Entry which is about to expire must be refreshed
+	m.Add(mustIP(t, "192.0.2.2"), time.Minute)
+	m.Refresh(mustIP(t, "192.0.2.2"), time.Hour)
+
+	m.Sync()
+
+	expected := []types.String{
+		"up",
+		"add 192.0.2.1",
+		"add 192.0.2.2",
+		"add 192.0.2.2",
+	}
+
+	calls := r.Calls()
+
+	if len(calls) != len(expected) {
+		t.Errorf("Manager.Refresh() failed to skip unnecessary changes. "+
+			"Expecting '%v', got '%v'", expected, calls)
+
+		return
+	}
+
+	for idx := range expected {
+		if calls[idx] == expected[idx] {
+			continue
+		}
+
+		t.Errorf("Manager.Refresh() failed to skip unnecessary changes. "+
+			"Expecting '%v', got '%v'", expected, calls)
+
+		return
+	}
+
+	m.Down()
+}
@@ -0,0 +1,205 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"strings"
+	"time"
+)
+
+// IPSet blocks addresses with two ipsets (IPv4 and IPv6) and a dedicated
+// iptables chain which drops traffics come from those sets
+type IPSet struct {
+	name  types.String
+	chain types.String
+	run   Runner
+}
+
+func NewIPSet(name types.String) (*IPSet, *types.Throw) {
+	return NewIPSetWithRunner(name, execRunner)
+}
+
+func NewIPSetWithRunner(name types.String,
+	runner Runner) (*IPSet, *types.Throw) {
+	err := checkSetName(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &IPSet{
+		name:  name,
+		chain: name.Upper(),
+		run:   runner,
+	}, nil
+}
+
+func (i *IPSet) setName(ipv4 bool) string {
+	if ipv4 {
+		return i.name.String()
+	}
+
+	return i.name.String() + "6"
+}
+
+func (i *IPSet) tables(ipv4 bool) string {
+	if ipv4 {
+		return "iptables"
+	}
+
+	return "ip6tables"
+}
+
+func (i *IPSet) family(ipv4 bool) string {
+	if ipv4 {
+		return "inet"
+	}
+
+	return "inet6"
+}
+
+func (i *IPSet) Up() *types.Throw {
+	chain := i.chain.String()
+
+	for _, ipv4 := range []bool{true, false} {
+		_, err := i.run(nil, "ipset", "create", i.setName(ipv4), "hash:ip",
+			"family", i.family(ipv4), "timeout", "0", "-exist")
+
+		if err != nil {
+			return err
+		}
+
+		// Set may left over by a crashed instance, start from empty
+		_, err = i.run(nil, "ipset", "flush", i.setName(ipv4))
+
+		if err != nil {
+			return err
+		}
+
+		tables := i.tables(ipv4)
+
+		// Chain may left over by a crashed instance, reuse it
+		_, err = i.run(nil, tables, "-N", chain)
+
+		if err != nil {
+			_, err = i.run(nil, tables, "-F", chain)
+
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = i.run(nil, tables, "-A", chain, "-m", "set",
+			"--match-set", i.setName(ipv4), "src", "-j", "DROP")
+
+		if err != nil {
+			return err
+		}
+
+		_, err = i.run(nil, tables, "-C", "INPUT", "-j", chain)
+
+		if err == nil {
+			continue
+		}
+
+		_, err = i.run(nil, tables, "-I", "INPUT", "-j", chain)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *IPSet) Down() *types.Throw {
+	var lastErr *types.Throw
+
+	chain := i.chain.String()
+
+	for _, ipv4 := range []bool{true, false} {
+		tables := i.tables(ipv4)
+
+		// Try to remove everything even some of them failed
+		for _, args := range [][]string{
+			{tables, "-D", "INPUT", "-j", chain},
+			{tables, "-F", chain},
+			{tables, "-X", chain},
+			{"ipset", "destroy", i.setName(ipv4)},
+		} {
+			_, err := i.run(nil, args[0], args[1:]...)
+
+			if err != nil {
+				lastErr = err
+			}
+		}
+	}
+
+	return lastErr
+}
+
+func (i *IPSet) Add(ip types.IP, ttl time.Duration) *types.Throw {
+	_, err := i.run(nil, "ipset", "add", i.setName(isIPv4(ip)),
+		ip.IP().String(), "timeout", seconds(ttl).String(), "-exist")
+
+	return err
+}
+
+func (i *IPSet) Remove(ip types.IP) *types.Throw {
+	_, err := i.run(nil, "ipset", "del", i.setName(isIPv4(ip)),
+		ip.IP().String(), "-exist")
+
+	return err
+}
+
+func (i *IPSet) List() ([]types.IP, *types.Throw) {
+	result := []types.IP{}
+
+	for _, ipv4 := range []bool{true, false} {
+		output, err := i.run(nil, "ipset", "save", i.setName(ipv4))
+
+		if err != nil {
+			return nil, err
+		}
+
+		// Lines like: add trap 192.0.2.1 timeout 3600
+		for _, line := range strings.Split(string(output), "\n") {
+			fields := strings.Fields(line)
+
+			if len(fields) < 3 || fields[0] != "add" {
+				continue
+			}
+
+			ip, ipErr := types.ConvertIPFromString(types.String(fields[2]))
+
+			if ipErr != nil {
+				continue
+			}
+
+			result = append(result, ip)
+		}
+	}
+
+	return result, nil
+}
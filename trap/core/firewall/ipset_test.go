@@ -0,0 +1,169 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRunner struct {
+	commands []string
+	inputs   []string
+	outputs  map[string]string
+	fails    map[string]bool
+}
+
+func (f *fakeRunner) run(input []byte, name string,
+	args ...string) ([]byte, *types.Throw) {
+	command := name + " " + strings.Join(args, " ")
+
+	f.commands = append(f.commands, command)
+	f.inputs = append(f.inputs, string(input))
+
+	if f.fails[command] {
+		return nil, ErrCommandFailed.Throw(command, "exit status 1", "")
+	}
+
+	return []byte(f.outputs[command]), nil
+}
+
+func TestIPSetUp(t *testing.T) {
+	f := &fakeRunner{
+		fails: map[string]bool{
+			"iptables -C INPUT -j TRAP":  true,
+			"ip6tables -N TRAP":          false,
+			"ip6tables -C INPUT -j TRAP": false,
+		},
+	}
+
+	i, err := NewIPSetWithRunner("trap", f.run)
+
+	if err != nil {
+		t.Errorf("NewIPSet() failed due to error: %s", err)
+
+		return
+	}
+
+	err = i.Up()
+
+	if err != nil {
+		t.Errorf("IPSet.Up() failed due to error: %s", err)
+
+		return
+	}
+
+	expected := []string{
+		"ipset create trap hash:ip family inet timeout 0 -exist",
+		"ipset flush trap",
+		"iptables -N TRAP",
+		"iptables -A TRAP -m set --match-set trap src -j DROP",
+		"iptables -C INPUT -j TRAP",
+		"iptables -I INPUT -j TRAP",
+		"ipset create trap6 hash:ip family inet6 timeout 0 -exist",
+		"ipset flush trap6",
+		"ip6tables -N TRAP",
+		"ip6tables -A TRAP -m set --match-set trap6 src -j DROP",
+		"ip6tables -C INPUT -j TRAP",
+	}
+
+	if strings.Join(f.commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("IPSet.Up() failed to run expected commands. "+
+			"Expecting '%v', got '%v'", expected, f.commands)
+
+		return
+	}
+}
+
+func TestIPSetAddRemove(t *testing.T) {
+	f := &fakeRunner{}
+
+	i, _ := NewIPSetWithRunner("trap", f.run)
+
+	i.Add(mustIP(t, "192.0.2.1"), 90*time.Second)
+	i.Add(mustIP(t, "2001:db8::1"), 0)
+	i.Remove(mustIP(t, "192.0.2.1"))
+
+	expected := []string{
+		"ipset add trap 192.0.2.1 timeout 90 -exist",
+		"ipset add trap6 2001:db8::1 timeout 0 -exist",
+		"ipset del trap 192.0.2.1 -exist",
+	}
+
+	if strings.Join(f.commands, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("IPSet failed to run expected commands. "+
+			"Expecting '%v', got '%v'", expected, f.commands)
+
+		return
+	}
+}
+
+func TestIPSetList(t *testing.T) {
+	f := &fakeRunner{
+		outputs: map[string]string{
+			"ipset save trap": "create trap hash:ip family inet " +
+				"hashsize 1024 maxelem 65536 timeout 0\n" +
+				"add trap 192.0.2.1 timeout 3500\n" +
+				"add trap 192.0.2.2 timeout 10\n",
+			"ipset save trap6": "create trap6 hash:ip family inet6 " +
+				"hashsize 1024 maxelem 65536 timeout 0\n" +
+				"add trap6 2001:db8::1 timeout 0\n",
+		},
+	}
+
+	i, _ := NewIPSetWithRunner("trap", f.run)
+
+	ips, err := i.List()
+
+	if err != nil {
+		t.Errorf("IPSet.List() failed due to error: %s", err)
+
+		return
+	}
+
+	if len(ips) != 3 {
+		t.Errorf("IPSet.List() failed to parse the set. "+
+			"Expecting '%d' addresses, got '%d'", 3, len(ips))
+
+		return
+	}
+
+	if ips[2] != mustIP(t, "2001:db8::1") {
+		t.Errorf("IPSet.List() failed to parse IPv6 address. "+
+			"Got '%s'", ips[2].IP().String())
+
+		return
+	}
+}
+
+func TestIPSetInvalidName(t *testing.T) {
+	_, err := NewIPSetWithRunner("trap; rm -rf", (&fakeRunner{}).run)
+
+	if err == nil || !err.Is(ErrInvalidSetName) {
+		t.Error("NewIPSet() failed to reject invalid set name")
+
+		return
+	}
+}
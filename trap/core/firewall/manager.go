@@ -0,0 +1,279 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/types"
+
+	"sync"
+	"time"
+)
+
+const (
+	FIREWALL_QUEUE_SIZE = 4096
+)
+
+type operation struct {
+	ip        types.IP
+	ttl       time.Duration
+	remove    bool
+	reconcile map[types.IP]time.Duration
+	done      chan struct{}
+}
+
+// Manager applies changes to the Backend in order from it's own
+// goroutine, so slow firewall commands will not block the caller
+type Manager struct {
+	backend Backend
+	logger  *logger.Logger
+
+	lock      sync.RWMutex
+	queue     chan operation
+	queueWait sync.WaitGroup
+
+	// When the entries we added will expire, so they will only be
+	// refreshed when needed
+	expires    map[types.IP]time.Time
+	expireLock sync.Mutex
+}
+
+func NewManager(backend Backend, log *logger.Logger) *Manager {
+	return &Manager{
+		backend: backend,
+		logger:  log.NewContext("Firewall"),
+		expires: map[types.IP]time.Time{},
+	}
+}
+
+func (m *Manager) Backend() Backend {
+	return m.backend
+}
+
+func (m *Manager) Up() *types.Throw {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.queue != nil {
+		return nil
+	}
+
+	err := m.backend.Up()
+
+	if err != nil {
+		return err
+	}
+
+	m.queue = make(chan operation, FIREWALL_QUEUE_SIZE)
+
+	m.queueWait.Add(1)
+
+	go m.work(m.queue)
+
+	m.logger.Debugf("Firewall backend is up")
+
+	return nil
+}
+
+// Apply all queued changes, then remove the set from the firewall
+func (m *Manager) Down() *types.Throw {
+	m.lock.Lock()
+
+	if m.queue == nil {
+		m.lock.Unlock()
+
+		return nil
+	}
+
+	close(m.queue)
+
+	m.queue = nil
+
+	m.lock.Unlock()
+
+	m.expireLock.Lock()
+	m.expires = map[types.IP]time.Time{}
+	m.expireLock.Unlock()
+
+	m.queueWait.Wait()
+
+	err := m.backend.Down()
+
+	if err != nil {
+		return err
+	}
+
+	m.logger.Debugf("Firewall backend is down")
+
+	return nil
+}
+
+func (m *Manager) work(queue chan operation) {
+	defer m.queueWait.Done()
+
+	for op := range queue {
+		if op.done != nil {
+			close(op.done)
+
+			continue
+		}
+
+		m.apply(op)
+	}
+}
+
+func (m *Manager) apply(op operation) {
+	if op.reconcile != nil {
+		added, removed, err := Reconcile(m.backend, op.reconcile)
+
+		if err != nil {
+			m.logger.Errorf("Can't reconcile firewall set due to error: %s",
+				err)
+
+			return
+		}
+
+		if added > 0 || removed > 0 {
+			m.logger.Infof("Firewall set has been reconciled, '%d' "+
+				"address added, '%d' removed", added, removed)
+		}
+
+		return
+	}
+
+	if op.remove {
+		err := m.backend.Remove(op.ip)
+
+		if err != nil {
+			m.logger.Errorf("Can't remove '%s' from firewall set due "+
+				"to error: %s", op.ip.IP().String(), err)
+		}
+
+		return
+	}
+
+	err := m.backend.Add(op.ip, op.ttl)
+
+	if err != nil {
+		m.logger.Errorf("Can't add '%s' to firewall set due to error: %s",
+			op.ip.IP().String(), err)
+	}
+}
+
+func (m *Manager) enqueue(op operation) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	if m.queue == nil {
+		return false
+	}
+
+	select {
+	case m.queue <- op:
+		return true
+
+	default:
+		// Dropped changes will be corrected by the next reconcile
+		m.logger.Warningf("%s", ErrQueueFull.Throw(op.ip.IP().String()))
+
+		return false
+	}
+}
+
+func (m *Manager) Add(ip types.IP, ttl time.Duration) {
+	m.expireLock.Lock()
+	defer m.expireLock.Unlock()
+
+	m.add(ip, ttl)
+}
+
+func (m *Manager) add(ip types.IP, ttl time.Duration) {
+	if !m.enqueue(operation{
+		ip:  ip,
+		ttl: ttl,
+	}) {
+		delete(m.expires, ip)
+
+		return
+	}
+
+	m.expires[ip] = time.Now().Add(ttl)
+}
+
+// Extend the entry to the given TTL, but only when it's already past half
+// of that, so frequently refreshed entries will not flood the queue
+func (m *Manager) Refresh(ip types.IP, ttl time.Duration) {
+	m.expireLock.Lock()
+	defer m.expireLock.Unlock()
+
+	expire, found := m.expires[ip]
+
+	if found && expire.Sub(time.Now()) >= ttl/2 {
+		return
+	}
+
+	m.add(ip, ttl)
+}
+
+func (m *Manager) Remove(ip types.IP) {
+	m.expireLock.Lock()
+	defer m.expireLock.Unlock()
+
+	delete(m.expires, ip)
+
+	m.enqueue(operation{
+		ip:     ip,
+		remove: true,
+	})
+}
+
+// Make the firewall set the same as desired after all queued changes
+// has been applied
+func (m *Manager) Reconcile(desired map[types.IP]time.Duration) {
+	m.expireLock.Lock()
+	defer m.expireLock.Unlock()
+
+	// Entries which already existed will keep their TTL, so only forget
+	// the ones no longer desired and let the next refresh add the rest
+	for ip := range m.expires {
+		if _, keep := desired[ip]; keep {
+			continue
+		}
+
+		delete(m.expires, ip)
+	}
+
+	m.enqueue(operation{
+		reconcile: desired,
+	})
+}
+
+// Wait until all queued changes has been applied
+func (m *Manager) Sync() {
+	done := make(chan struct{})
+
+	if !m.enqueue(operation{done: done}) {
+		return
+	}
+
+	<-done
+}
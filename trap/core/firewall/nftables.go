@@ -0,0 +1,193 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"fmt"
+	"strings"
+	"time"
+)
+
+// NFTables blocks addresses with a dedicated nftables table, which
+// contains two timeout sets and an input chain that drops traffics
+// come from those sets
+type NFTables struct {
+	table types.String
+	run   Runner
+}
+
+func NewNFTables(table types.String) (*NFTables, *types.Throw) {
+	return NewNFTablesWithRunner(table, execRunner)
+}
+
+func NewNFTablesWithRunner(table types.String,
+	runner Runner) (*NFTables, *types.Throw) {
+	err := checkSetName(table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &NFTables{
+		table: table,
+		run:   runner,
+	}, nil
+}
+
+func (n *NFTables) setName(ipv4 bool) string {
+	if ipv4 {
+		return "clients4"
+	}
+
+	return "clients6"
+}
+
+func (n *NFTables) script(lines ...string) []byte {
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func (n *NFTables) element(ip types.IP, ttl time.Duration) string {
+	if ttl <= 0 {
+		return ip.IP().String()
+	}
+
+	return ip.IP().String() + " timeout " + seconds(ttl).String() + "s"
+}
+
+func (n *NFTables) Up() *types.Throw {
+	table := n.table.String()
+
+	// Recreate the table in one transaction, so anything left over by
+	// a crashed instance will be cleaned up
+	_, err := n.run(n.script(
+		"add table inet "+table,
+		"delete table inet "+table,
+		"table inet "+table+" {",
+		"\tset clients4 {",
+		"\t\ttype ipv4_addr",
+		"\t\tflags timeout",
+		"\t}",
+		"\tset clients6 {",
+		"\t\ttype ipv6_addr",
+		"\t\tflags timeout",
+		"\t}",
+		"\tchain input {",
+		"\t\ttype filter hook input priority -10; policy accept;",
+		"\t\tip saddr @clients4 drop",
+		"\t\tip6 saddr @clients6 drop",
+		"\t}",
+		"}",
+	), "nft", "-f", "-")
+
+	return err
+}
+
+func (n *NFTables) Down() *types.Throw {
+	_, err := n.run(nil, "nft", "delete", "table", "inet", n.table.String())
+
+	return err
+}
+
+func (n *NFTables) Add(ip types.IP, ttl time.Duration) *types.Throw {
+	target := fmt.Sprintf("element inet %s %s { %%s }",
+		n.table, n.setName(isIPv4(ip)))
+
+	// Adding an existing element will not refresh it's timeout, so
+	// remove it first in the same transaction
+	_, err := n.run(n.script(
+		"add "+fmt.Sprintf(target, ip.IP().String()),
+		"delete "+fmt.Sprintf(target, ip.IP().String()),
+		"add "+fmt.Sprintf(target, n.element(ip, ttl)),
+	), "nft", "-f", "-")
+
+	return err
+}
+
+func (n *NFTables) Remove(ip types.IP) *types.Throw {
+	target := fmt.Sprintf("element inet %s %s { %s }",
+		n.table, n.setName(isIPv4(ip)), ip.IP().String())
+
+	// Add before delete, so removing an element which not exist will
+	// not fail
+	_, err := n.run(n.script(
+		"add "+target,
+		"delete "+target,
+	), "nft", "-f", "-")
+
+	return err
+}
+
+func (n *NFTables) List() ([]types.IP, *types.Throw) {
+	result := []types.IP{}
+
+	for _, ipv4 := range []bool{true, false} {
+		output, err := n.run(nil, "nft", "list", "set", "inet",
+			n.table.String(), n.setName(ipv4))
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, parseNFTElements(string(output))...)
+	}
+
+	return result, nil
+}
+
+// Parse `elements = { 192.0.2.1 timeout 1h expires 59m, ... }`
+func parseNFTElements(output string) []types.IP {
+	result := []types.IP{}
+
+	start := strings.Index(output, "elements = {")
+
+	if start < 0 {
+		return result
+	}
+
+	output = output[start+len("elements = {"):]
+
+	end := strings.Index(output, "}")
+
+	if end >= 0 {
+		output = output[:end]
+	}
+
+	for _, element := range strings.Split(output, ",") {
+		fields := strings.Fields(element)
+
+		if len(fields) < 1 {
+			continue
+		}
+
+		ip, ipErr := types.ConvertIPFromString(types.String(fields[0]))
+
+		if ipErr != nil {
+			continue
+		}
+
+		result = append(result, ip)
+	}
+
+	return result
+}
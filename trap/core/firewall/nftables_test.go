@@ -0,0 +1,145 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNFTablesUp(t *testing.T) {
+	f := &fakeRunner{}
+
+	n, err := NewNFTablesWithRunner("trap", f.run)
+
+	if err != nil {
+		t.Errorf("NewNFTables() failed due to error: %s", err)
+
+		return
+	}
+
+	err = n.Up()
+
+	if err != nil {
+		t.Errorf("NFTables.Up() failed due to error: %s", err)
+
+		return
+	}
+
+	if len(f.commands) != 1 || f.commands[0] != "nft -f -" {
+		t.Errorf("NFTables.Up() failed to run expected command. "+
+			"Got '%v'", f.commands)
+
+		return
+	}
+
+	for _, expected := range []string{
+		"delete table inet trap\n",
+		"ip saddr @clients4 drop",
+		"ip6 saddr @clients6 drop",
+		"flags timeout",
+	} {
+		if !strings.Contains(f.inputs[0], expected) {
+			t.Errorf("NFTables.Up() failed to build the script. "+
+				"Expecting '%s' in '%s'", expected, f.inputs[0])
+
+			return
+		}
+	}
+}
+
+func TestNFTablesAdd(t *testing.T) {
+	f := &fakeRunner{}
+
+	n, _ := NewNFTablesWithRunner("trap", f.run)
+
+	n.Add(mustIP(t, "192.0.2.1"), 2*time.Minute)
+	n.Remove(mustIP(t, "2001:db8::1"))
+
+	expected := "add element inet trap clients4 { 192.0.2.1 }\n" +
+		"delete element inet trap clients4 { 192.0.2.1 }\n" +
+		"add element inet trap clients4 { 192.0.2.1 timeout 120s }\n"
+
+	if f.inputs[0] != expected {
+		t.Errorf("NFTables.Add() failed to build the script. "+
+			"Expecting '%s', got '%s'", expected, f.inputs[0])
+
+		return
+	}
+
+	expected = "add element inet trap clients6 { 2001:db8::1 }\n" +
+		"delete element inet trap clients6 { 2001:db8::1 }\n"
+
+	if f.inputs[1] != expected {
+		t.Errorf("NFTables.Remove() failed to build the script. "+
+			"Expecting '%s', got '%s'", expected, f.inputs[1])
+
+		return
+	}
+}
+
+func TestNFTablesList(t *testing.T) {
+	f := &fakeRunner{
+		outputs: map[string]string{
+			"nft list set inet trap clients4": "table inet trap {\n" +
+				"\tset clients4 {\n" +
+				"\t\ttype ipv4_addr\n" +
+				"\t\tflags timeout\n" +
+				"\t\telements = { 192.0.2.1 timeout 1h expires 59m58s," +
+				" 192.0.2.2 timeout 2m expires 1m,\n" +
+				"\t\t\t     192.0.2.3 }\n" +
+				"\t}\n" +
+				"}\n",
+			"nft list set inet trap clients6": "table inet trap {\n" +
+				"\tset clients6 {\n" +
+				"\t\ttype ipv6_addr\n" +
+				"\t\tflags timeout\n" +
+				"\t}\n" +
+				"}\n",
+		},
+	}
+
+	n, _ := NewNFTablesWithRunner("trap", f.run)
+
+	ips, err := n.List()
+
+	if err != nil {
+		t.Errorf("NFTables.List() failed due to error: %s", err)
+
+		return
+	}
+
+	if len(ips) != 3 {
+		t.Errorf("NFTables.List() failed to parse the set. "+
+			"Expecting '%d' addresses, got '%d'", 3, len(ips))
+
+		return
+	}
+
+	if ips[2] != mustIP(t, "192.0.2.3") {
+		t.Errorf("NFTables.List() failed to parse the last address. "+
+			"Got '%s'", ips[2].IP().String())
+
+		return
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package firewall
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"sync"
+	"time"
+)
+
+// Recorder is a Backend which only records what has been asked to do
+type Recorder struct {
+	lock    sync.Mutex
+	up      bool
+	entries map[types.IP]time.Duration
+	calls   []types.String
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		entries: map[types.IP]time.Duration{},
+		calls:   []types.String{},
+	}
+}
+
+func (r *Recorder) Up() *types.Throw {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.up = true
+	r.calls = append(r.calls, "up")
+
+	return nil
+}
+
+func (r *Recorder) Down() *types.Throw {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.up = false
+	r.entries = map[types.IP]time.Duration{}
+	r.calls = append(r.calls, "down")
+
+	return nil
+}
+
+func (r *Recorder) Add(ip types.IP, ttl time.Duration) *types.Throw {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.entries[ip] = ttl
+	r.calls = append(r.calls, types.String("add "+ip.IP().String()))
+
+	return nil
+}
+
+func (r *Recorder) Remove(ip types.IP) *types.Throw {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.entries, ip)
+	r.calls = append(r.calls, types.String("remove "+ip.IP().String()))
+
+	return nil
+}
+
+func (r *Recorder) List() ([]types.IP, *types.Throw) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	result := []types.IP{}
+
+	for ip, _ := range r.entries {
+		result = append(result, ip)
+	}
+
+	return result, nil
+}
+
+func (r *Recorder) IsUp() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.up
+}
+
+func (r *Recorder) Entries() map[types.IP]time.Duration {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	result := map[types.IP]time.Duration{}
+
+	for ip, ttl := range r.entries {
+		result[ip] = ttl
+	}
+
+	return result
+}
+
+func (r *Recorder) Calls() []types.String {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return append([]types.String{}, r.calls...)
+}
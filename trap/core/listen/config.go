@@ -40,8 +40,10 @@ type Config struct {
 	OnError func(ConnectionInfo, *types.Throw)
 	OnPick  func(ConnectionInfo, RespondedResult)
 
-	OnListened   func(*ListeningInfo)
-	OnUnListened func(*ListeningInfo)
+	OnListened     func(*ListeningInfo)
+	OnUnListened   func(*ListeningInfo)
+	OnListenFailed func(*ListenerSetting, *types.Throw)
+	OnBroken       func(*ListeningInfo, *types.Throw)
 
 	MaxBytes types.UInt32
 
@@ -55,8 +57,9 @@ type ListenerConfig struct {
 	Logger     *logger.Logger
 	Concurrent types.UInt16
 
-	OnError func(ConnectionInfo, *types.Throw)
-	OnPick  func(ConnectionInfo, RespondedResult)
+	OnError  func(ConnectionInfo, *types.Throw)
+	OnPick   func(ConnectionInfo, RespondedResult)
+	OnBroken func(*ListeningInfo, *types.Throw)
 
 	MaxBytes types.UInt32
 
@@ -72,8 +75,9 @@ type ProtocolConfig struct {
 	Logger     *logger.Logger
 	Concurrent types.UInt16
 
-	OnError func(ConnectionInfo, *types.Throw)
-	OnPick  func(ConnectionInfo, RespondedResult)
+	OnError  func(ConnectionInfo, *types.Throw)
+	OnPick   func(ConnectionInfo, RespondedResult)
+	OnBroken func(*ListeningInfo, *types.Throw)
 
 	MaxBytes types.UInt32
 
@@ -82,6 +86,11 @@ type ProtocolConfig struct {
 	TotalTimeout time.Duration
 }
 
+type ListenerSetting struct {
+	Protocol types.String
+	Setting  types.String
+}
+
 type ListeningInfo struct {
 	Port     int
 	IP       net.IP
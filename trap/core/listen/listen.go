@@ -44,6 +44,7 @@ type Listen struct {
 	logger  *logger.Logger
 
 	listeners   []Listener
+	settings    []ListenerSetting
 	randomPorts types.UInt16
 
 	maxBytes types.UInt32
@@ -53,8 +54,10 @@ type Listen struct {
 	onError func(ConnectionInfo, *types.Throw)
 	onPick  func(ConnectionInfo, RespondedResult)
 
-	onListened   func(*ListeningInfo)
-	onUnListened func(*ListeningInfo)
+	onListened     func(*ListeningInfo)
+	onUnListened   func(*ListeningInfo)
+	onListenFailed func(*ListenerSetting, *types.Throw)
+	onBroken       func(*ListeningInfo, *types.Throw)
 
 	concurrent types.UInt16
 }
@@ -76,6 +79,8 @@ func (this *Listen) Init(cfg *Config) {
 
 	this.onListened = cfg.OnListened
 	this.onUnListened = cfg.OnUnListened
+	this.onListenFailed = cfg.OnListenFailed
+	this.onBroken = cfg.OnBroken
 
 	this.maxBytes = cfg.MaxBytes
 
@@ -93,8 +98,9 @@ func (this *Listen) Register(pType types.String, protocol Protocol) *types.Throw
 	}
 
 	initErr := protocol.Init(&ProtocolConfig{
-		OnError: this.onError,
-		OnPick:  this.onPick,
+		OnError:  this.onError,
+		OnPick:   this.onPick,
+		OnBroken: this.onBroken,
 
 		MaxBytes: this.maxBytes,
 
@@ -136,6 +142,10 @@ func (this *Listen) Add(pType types.String, setting types.String) *types.Throw {
 	}
 
 	this.listeners = append(this.listeners, listener)
+	this.settings = append(this.settings, ListenerSetting{
+		Protocol: pType,
+		Setting:  setting,
+	})
 
 	this.logger.Debugf("New `Listener` '%d' has been added at '%s'",
 		len(this.listeners)-1, setting)
@@ -155,6 +165,10 @@ func (this *Listen) Serv() *types.Throw {
 			this.logger.Debugf("Can't bring up `Listener` '%d' due "+
 				"to error: %s", idx, upErr)
 
+			if this.onListenFailed != nil {
+				this.onListenFailed(&this.settings[idx], upErr)
+			}
+
 			continue
 		}
 
@@ -0,0 +1,215 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	levelNames = map[int]types.String{
+		LOG_TYPE_DEBUG:   "debug",
+		LOG_TYPE_INFO:    "info",
+		LOG_TYPE_WARNING: "warning",
+		LOG_TYPE_ERROR:   "error",
+	}
+)
+
+// LevelSettings is the exportable form of the log levels
+type LevelSettings struct {
+	Level    types.String
+	Contexts map[types.String]types.String
+}
+
+// Levels decides which log will be recorded. It's shared by a `Logger`
+// and all it's contexts
+type Levels struct {
+	generation uint64 // Keep it on top for the alignment of atomic ops
+
+	lock     sync.RWMutex
+	root     types.String
+	level    int
+	contexts map[types.String]int
+}
+
+func newLevels(root types.String) *Levels {
+	return &Levels{
+		generation: 1,
+		root:       root,
+		level:      LOG_TYPE_INFO,
+		contexts:   map[types.String]int{},
+	}
+}
+
+// ParseLevel converts the level name into it's level
+func ParseLevel(name types.String) (int, *types.Throw) {
+	lowerName := name.Trim().Lower()
+
+	switch lowerName {
+	case "warn":
+		return LOG_TYPE_WARNING, nil
+
+	case "information":
+		return LOG_TYPE_INFO, nil
+	}
+
+	for level, levelName := range levelNames {
+		if levelName == lowerName {
+			return level, nil
+		}
+	}
+
+	return 0, ErrInvalidLevel.Throw(name)
+}
+
+// LevelName returns the name of the level
+func LevelName(level int) types.String {
+	name, ok := levelNames[level]
+
+	if !ok {
+		return "default"
+	}
+
+	return name
+}
+
+// Full context name begins with the root context, but it can be
+// omitted when setting levels: `Sync:Server` means `Trap:Sync:Server`
+func (l *Levels) contextName(context types.String) types.String {
+	context = context.Trim()
+
+	if context == l.root ||
+		strings.HasPrefix(context.String(), l.root.String()+":") {
+		return context
+	}
+
+	return l.root + ":" + context
+}
+
+func (l *Levels) update(f func()) {
+	l.lock.Lock()
+
+	defer l.lock.Unlock()
+
+	f()
+
+	atomic.AddUint64(&l.generation, 1)
+}
+
+func (l *Levels) resolve(context types.String) int {
+	l.lock.RLock()
+
+	defer l.lock.RUnlock()
+
+	level := l.level
+	matchedLen := 0
+
+	for ctx, ctxLevel := range l.contexts {
+		if ctx.Len() <= matchedLen {
+			continue
+		}
+
+		if context != ctx &&
+			!strings.HasPrefix(context.String(), ctx.String()+":") {
+			continue
+		}
+
+		level = ctxLevel
+		matchedLen = ctx.Len()
+	}
+
+	return level
+}
+
+func (l *Levels) SetLevel(level int) {
+	l.update(func() {
+		l.level = level
+	})
+}
+
+// SetContextLevel overrides the level of a context and it's sub contexts
+func (l *Levels) SetContextLevel(context types.String, level int) {
+	l.update(func() {
+		l.contexts[l.contextName(context)] = level
+	})
+}
+
+// ClearContextLevel makes the context follow the level of it's parent
+func (l *Levels) ClearContextLevel(context types.String) {
+	l.update(func() {
+		delete(l.contexts, l.contextName(context))
+	})
+}
+
+// Apply replaces all current levels with the settings
+func (l *Levels) Apply(settings LevelSettings) *types.Throw {
+	level := LOG_TYPE_INFO
+	contexts := map[types.String]int{}
+
+	if settings.Level != "" {
+		parsedLevel, parseErr := ParseLevel(settings.Level)
+
+		if parseErr != nil {
+			return parseErr
+		}
+
+		level = parsedLevel
+	}
+
+	for context, name := range settings.Contexts {
+		ctxLevel, parseErr := ParseLevel(name)
+
+		if parseErr != nil {
+			return parseErr
+		}
+
+		contexts[l.contextName(context)] = ctxLevel
+	}
+
+	l.update(func() {
+		l.level = level
+		l.contexts = contexts
+	})
+
+	return nil
+}
+
+func (l *Levels) Export() LevelSettings {
+	l.lock.RLock()
+
+	defer l.lock.RUnlock()
+
+	settings := LevelSettings{
+		Level:    LevelName(l.level),
+		Contexts: map[types.String]types.String{},
+	}
+
+	for context, level := range l.contexts {
+		settings.Contexts[context] = LevelName(level)
+	}
+
+	return settings
+}
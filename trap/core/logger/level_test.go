@@ -0,0 +1,170 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := map[types.String]int{
+		"debug":   LOG_TYPE_DEBUG,
+		"Info":    LOG_TYPE_INFO,
+		" warn ":  LOG_TYPE_WARNING,
+		"WARNING": LOG_TYPE_WARNING,
+		"error":   LOG_TYPE_ERROR,
+	}
+
+	for name, expected := range tests {
+		level, err := ParseLevel(name)
+
+		if err != nil {
+			t.Errorf("ParseLevel() failed to parse '%s' due to error: %s",
+				name, err)
+
+			return
+		}
+
+		if level != expected {
+			t.Errorf("ParseLevel() failed to parse '%s'. "+
+				"Expecting '%d', got '%d'", name, expected, level)
+
+			return
+		}
+	}
+
+	_, err := ParseLevel("verbose")
+
+	if err == nil || !err.Is(ErrInvalidLevel) {
+		t.Errorf("ParseLevel() failed to reject invalid level. "+
+			"Got '%s'", err)
+
+		return
+	}
+}
+
+func TestLoggerLevels(t *testing.T) {
+	logger := NewLogger()
+	syncLogger := logger.NewContext("Sync")
+	serverLogger := syncLogger.NewContext("Server")
+	listenLogger := logger.NewContext("Listen")
+
+	if logger.Enabled(LOG_TYPE_DEBUG) || !logger.Enabled(LOG_TYPE_INFO) {
+		t.Error("Logger.Enabled() failed to follow the default level")
+
+		return
+	}
+
+	logger.Levels().SetContextLevel("Sync", LOG_TYPE_DEBUG)
+
+	if !serverLogger.Enabled(LOG_TYPE_DEBUG) ||
+		!syncLogger.Enabled(LOG_TYPE_DEBUG) {
+		t.Error("Logger.Enabled() failed to follow the context level")
+
+		return
+	}
+
+	if listenLogger.Enabled(LOG_TYPE_DEBUG) {
+		t.Error("Logger.Enabled() applied context level to other context")
+
+		return
+	}
+
+	logger.Levels().SetContextLevel("Trap:Sync:Server", LOG_TYPE_ERROR)
+
+	if serverLogger.Enabled(LOG_TYPE_WARNING) ||
+		!syncLogger.Enabled(LOG_TYPE_DEBUG) {
+		t.Error("Logger.Enabled() failed to pick the closest context level")
+
+		return
+	}
+
+	serverLogger.Debugf("Not recorded")
+	syncLogger.Debugf("Recorded")
+	listenLogger.Debugf("Not recorded")
+
+	if len(logger.Dump()) != 1 {
+		t.Errorf("Logger.Debugf() failed to filter logs. "+
+			"Expecting '%d', got '%d'", 1, len(logger.Dump()))
+
+		return
+	}
+
+	logger.Levels().ClearContextLevel("Sync")
+	logger.Levels().SetLevel(LOG_TYPE_WARNING)
+
+	if syncLogger.Enabled(LOG_TYPE_INFO) ||
+		!listenLogger.Enabled(LOG_TYPE_WARNING) {
+		t.Error("Logger.Enabled() failed to follow the changed level")
+
+		return
+	}
+}
+
+func TestLevelsApplyNExport(t *testing.T) {
+	logger := NewLogger()
+
+	applyErr := logger.Levels().Apply(LevelSettings{
+		Level: "warning",
+		Contexts: map[types.String]types.String{
+			"Sync": "debug",
+		},
+	})
+
+	if applyErr != nil {
+		t.Errorf("Levels.Apply() failed due to error: %s", applyErr)
+
+		return
+	}
+
+	exported := logger.Levels().Export()
+
+	if exported.Level != "warning" ||
+		exported.Contexts["Trap:Sync"] != "debug" {
+		t.Errorf("Levels.Export() failed to export levels. "+
+			"Got '%v'", exported)
+
+		return
+	}
+
+	applyErr = logger.Levels().Apply(LevelSettings{
+		Contexts: map[types.String]types.String{
+			"Sync": "loud",
+		},
+	})
+
+	if applyErr == nil || !applyErr.Is(ErrInvalidLevel) {
+		t.Errorf("Levels.Apply() failed to reject invalid level. "+
+			"Got '%s'", applyErr)
+
+		return
+	}
+
+	// Failed apply must keep the old settings
+	if logger.Levels().Export().Level != "warning" {
+		t.Error("Levels.Apply() changed levels when failed")
+
+		return
+	}
+}
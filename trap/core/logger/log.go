@@ -28,6 +28,7 @@ import (
 )
 
 type Log struct {
+	ID      uint64
 	Time    time.Time
 	Type    int
 	Context types.String
@@ -35,6 +36,7 @@ type Log struct {
 }
 
 type LogExport struct {
+	ID      uint64
 	Time    time.Time
 	Type    types.String
 	Context types.String
@@ -55,34 +57,39 @@ func (l *Logs) Append(log Log, maxLen int) {
 	*l = oldLogs
 }
 
-func (l *Logs) Export() []LogExport {
-	exported := []LogExport{}
+func (l Log) Export() LogExport {
+	lType := types.String("Default")
 
-	oldLogs := *l
+	switch l.Type {
+	case LOG_TYPE_DEBUG:
+		lType = "Debug"
 
-	for i := len(oldLogs) - 1; i >= 0; i-- {
-		lType := types.String("Default")
+	case LOG_TYPE_INFO:
+		lType = "Information"
+
+	case LOG_TYPE_WARNING:
+		lType = "Warning"
 
-		switch oldLogs[i].Type {
-		case LOG_TYPE_DEBUG:
-			lType = "Debug"
+	case LOG_TYPE_ERROR:
+		lType = "Error"
+	}
 
-		case LOG_TYPE_INFO:
-			lType = "Information"
+	return LogExport{
+		ID:      l.ID,
+		Time:    l.Time,
+		Type:    lType,
+		Context: l.Context,
+		Message: l.Message,
+	}
+}
 
-		case LOG_TYPE_WARNING:
-			lType = "Warning"
+func (l *Logs) Export() []LogExport {
+	exported := []LogExport{}
 
-		case LOG_TYPE_ERROR:
-			lType = "Error"
-		}
+	oldLogs := *l
 
-		exported = append(exported, LogExport{
-			Time:    oldLogs[i].Time,
-			Type:    lType,
-			Context: oldLogs[i].Context,
-			Message: oldLogs[i].Message,
-		})
+	for i := len(oldLogs) - 1; i >= 0; i-- {
+		exported = append(exported, oldLogs[i].Export())
 	}
 
 	return exported
@@ -25,13 +25,20 @@ import (
 	"github.com/raincious/trap/trap/core/types"
 
 	"fmt"
+	"sync/atomic"
 	"time"
 )
 
 type Logger struct {
+	// Generation of the levels and the resolved level of current
+	// context, packed together so it can be loaded at once
+	level uint64
+
 	printers *Printers
 	logs     *Logs
 	mutex    *types.Mutex
+	levels   *Levels
+	history  *history
 
 	context types.String
 }
@@ -41,6 +48,8 @@ func NewLogger() *Logger {
 		printers: &Printers{},
 		logs:     &Logs{},
 		mutex:    &types.Mutex{},
+		levels:   newLevels("Trap"),
+		history:  newHistory(),
 		context:  "Trap",
 	}
 
@@ -56,13 +65,40 @@ func (l *Logger) NewContext(s types.String) *Logger {
 		printers: l.printers,
 		logs:     l.logs,
 		mutex:    l.mutex,
+		levels:   l.levels,
+		history:  l.history,
 		context:  l.context + ":" + s,
 	}
 }
 
+// Levels returns the log levels shared by all contexts of the Logger
+func (l *Logger) Levels() *Levels {
+	return l.levels
+}
+
+// Enabled tells whether logs of the level will be recorded in
+// current context
+func (l *Logger) Enabled(level int) bool {
+	generation := atomic.LoadUint64(&l.levels.generation)
+	resolved := atomic.LoadUint64(&l.level)
+
+	if resolved>>8 != generation {
+		resolved = generation<<8 | uint64(l.levels.resolve(l.context))
+
+		atomic.StoreUint64(&l.level, resolved)
+	}
+
+	return level >= int(resolved&0xff)
+}
+
 func (l *Logger) append(newLog Log) {
 	l.mutex.Exec(func() {
-		l.logs.Append(newLog, 128)
+		l.history.lastID++
+
+		newLog.ID = l.history.lastID
+
+		l.logs.Append(newLog, l.history.size)
+		l.history.publish(newLog)
 
 		switch newLog.Type {
 		case LOG_TYPE_DEBUG:
@@ -84,16 +120,23 @@ func (l *Logger) append(newLog Log) {
 }
 
 func (l *Logger) Debugf(s string, v ...interface{}) {
-	/* Disable debug totally after finish develpment
+	if !l.Enabled(LOG_TYPE_DEBUG) {
+		return
+	}
+
 	l.append(Log{
 		Time:    time.Now(),
 		Type:    LOG_TYPE_DEBUG,
 		Context: l.context,
 		Message: types.String(fmt.Sprintf(s, v...)),
-	})*/
+	})
 }
 
 func (l *Logger) Infof(s string, v ...interface{}) {
+	if !l.Enabled(LOG_TYPE_INFO) {
+		return
+	}
+
 	l.append(Log{
 		Time:    time.Now(),
 		Type:    LOG_TYPE_INFO,
@@ -103,6 +146,10 @@ func (l *Logger) Infof(s string, v ...interface{}) {
 }
 
 func (l *Logger) Warningf(s string, v ...interface{}) {
+	if !l.Enabled(LOG_TYPE_WARNING) {
+		return
+	}
+
 	l.append(Log{
 		Time:    time.Now(),
 		Type:    LOG_TYPE_WARNING,
@@ -112,6 +159,10 @@ func (l *Logger) Warningf(s string, v ...interface{}) {
 }
 
 func (l *Logger) Errorf(s string, v ...interface{}) {
+	if !l.Enabled(LOG_TYPE_ERROR) {
+		return
+	}
+
 	l.append(Log{
 		Time:    time.Now(),
 		Type:    LOG_TYPE_ERROR,
@@ -140,3 +191,59 @@ func (l *Logger) Dump() []LogExport {
 
 	return logs
 }
+
+// SetBufferSize changes how many logs will be kept in memory
+func (l *Logger) SetBufferSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+
+	l.mutex.Exec(func() {
+		l.history.size = size
+
+		if len(*l.logs) > size {
+			*l.logs = append(Logs{}, (*l.logs)[len(*l.logs)-size:]...)
+		}
+	})
+}
+
+// Query searches logs in the buffer, newest logs first
+func (l *Logger) Query(q Query) QueryResult {
+	result := QueryResult{
+		Logs:   []LogExport{},
+		Cursor: 0,
+	}
+
+	l.mutex.Exec(func() {
+		logs := *l.logs
+
+		result.Cursor = types.Page(len(logs), func(i int) uint64 {
+			return logs[i].ID
+		}, func(i int) bool {
+			return q.Match(logs[i])
+		}, func(i int) {
+			result.Logs = append(result.Logs, logs[i].Export())
+		}, q.Cursor, q.Limit)
+	})
+
+	return result
+}
+
+// Subscribe starts receiving new logs which matched the query, the
+// `Limit` and `Cursor` of the query will be ignored
+func (l *Logger) Subscribe(q Query, bufferSize int) *Subscription {
+	channel := make(chan LogExport, bufferSize)
+
+	subscription := &Subscription{
+		C:       channel,
+		logger:  l,
+		query:   q,
+		channel: channel,
+	}
+
+	l.mutex.Exec(func() {
+		l.history.subscribers[subscription] = true
+	})
+
+	return subscription
+}
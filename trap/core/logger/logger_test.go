@@ -90,7 +90,8 @@ func TestLoggerNewContext(t *testing.T) {
 
 	if newCtx.logs != logger.logs ||
 		newCtx.printers != logger.printers ||
-		newCtx.mutex != logger.mutex {
+		newCtx.mutex != logger.mutex ||
+		newCtx.levels != logger.levels {
 		t.Error("The new context is not using inherited properties")
 
 		return
@@ -112,8 +113,8 @@ func testLoggerAppend(t *testing.T, logger *Logger, baseN int) {
 
 	dumpped := logger.Dump()
 
-	// Notice the `Debugf` should be commented out when not in development
-	// So it wouldn't be count
+	// Notice the `Debugf` is disabled by the default level, so it
+	// wouldn't be count
 	if len(dumpped) != 4*baseN {
 		t.Error("Unexpected amount of log items")
 
@@ -0,0 +1,159 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"strings"
+	"time"
+)
+
+const (
+	LOG_BUFFER_SIZE = 128
+)
+
+// Query selects logs from the buffer. Zero values mean no restriction
+type Query struct {
+	Level   int          // Minimum level
+	Context types.String // Context prefix
+	Since   time.Time
+	Until   time.Time
+	Search  types.String // Case insensitive text in the message
+	After   uint64       // Only logs newer than the one has this ID
+	Limit   int
+
+	// Only logs older than the one which has this ID will be returned,
+	// use `QueryResult.Cursor` to get the next page
+	Cursor uint64
+}
+
+type QueryResult struct {
+	Logs   []LogExport
+	Cursor uint64 // 0 when there is no more logs
+}
+
+func (q *Query) contextName() types.String {
+	if q.Context == "" || q.Context == "Trap" ||
+		strings.HasPrefix(q.Context.String(), "Trap:") {
+		return q.Context
+	}
+
+	return "Trap:" + q.Context
+}
+
+// Match tells whether the log meets the conditions other than the
+// `Limit` and the `Cursor`
+func (q *Query) Match(log Log) bool {
+	if q.After > 0 && log.ID <= q.After {
+		return false
+	}
+
+	if q.Level > 0 && log.Type != LOG_TYPE_DEFAULT && log.Type < q.Level {
+		return false
+	}
+
+	if context := q.contextName(); context != "" &&
+		log.Context != context &&
+		!strings.HasPrefix(log.Context.String(), context.String()+":") {
+		return false
+	}
+
+	if !q.Since.IsZero() && log.Time.Before(q.Since) {
+		return false
+	}
+
+	if !q.Until.IsZero() && log.Time.After(q.Until) {
+		return false
+	}
+
+	if q.Search != "" && !strings.Contains(
+		strings.ToLower(log.Message.String()),
+		strings.ToLower(q.Search.String())) {
+		return false
+	}
+
+	return true
+}
+
+// Subscription receives new logs as they are recorded. Logs will be
+// dropped instead of blocking the Logger when the receiver is too slow
+type Subscription struct {
+	C <-chan LogExport
+
+	logger  *Logger
+	query   Query
+	channel chan LogExport
+	dropped uint64
+}
+
+func (s *Subscription) Dropped() uint64 {
+	var dropped uint64 = 0
+
+	s.logger.mutex.Exec(func() {
+		dropped = s.dropped
+	})
+
+	return dropped
+}
+
+func (s *Subscription) Close() {
+	s.logger.mutex.Exec(func() {
+		if _, ok := s.logger.history.subscribers[s]; !ok {
+			return
+		}
+
+		delete(s.logger.history.subscribers, s)
+
+		close(s.channel)
+	})
+}
+
+// Shared by the Logger and all it's contexts, protected by the mutex
+type history struct {
+	size        int
+	lastID      uint64
+	subscribers map[*Subscription]bool
+}
+
+func newHistory() *history {
+	return &history{
+		size:        LOG_BUFFER_SIZE,
+		lastID:      0,
+		subscribers: map[*Subscription]bool{},
+	}
+}
+
+func (h *history) publish(log Log) {
+	for subscriber := range h.subscribers {
+		if !subscriber.query.Match(log) {
+			continue
+		}
+
+		select {
+		case subscriber.channel <- log.Export():
+
+		default:
+			subscriber.dropped++
+		}
+	}
+}
@@ -0,0 +1,171 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoggerSetBufferSize(t *testing.T) {
+	logger := NewLogger()
+
+	for i := 0; i < 10; i++ {
+		logger.Infof("Log %d", i)
+	}
+
+	logger.SetBufferSize(4)
+
+	dumpped := logger.Dump()
+
+	if len(dumpped) != 4 {
+		t.Errorf("Logger.SetBufferSize() failed to shrink the buffer. "+
+			"Expecting '%d', got '%d'", 4, len(dumpped))
+
+		return
+	}
+
+	if dumpped[0].Message != "Log 9" || dumpped[3].Message != "Log 6" {
+		t.Error("Logger.SetBufferSize() removed wrong logs")
+
+		return
+	}
+
+	for i := 0; i < 10; i++ {
+		logger.Infof("Log %d", i)
+	}
+
+	if len(logger.Dump()) != 4 {
+		t.Errorf("Logger.SetBufferSize() failed to limit the buffer. "+
+			"Expecting '%d', got '%d'", 4, len(logger.Dump()))
+
+		return
+	}
+}
+
+func TestLoggerQuery(t *testing.T) {
+	logger := NewLogger()
+	syncLogger := logger.NewContext("Sync")
+
+	startTime := time.Now()
+
+	logger.Infof("Server is up")
+	syncLogger.Warningf("Node 'A' is dropped")
+	syncLogger.NewContext("Server").Errorf("Node 'B' is dropped")
+	logger.NewContext("Synchronous").Errorf("Not a sync log")
+	syncLogger.Infof("Node 'C' is connected")
+
+	result := logger.Query(Query{
+		Context: "Sync",
+		Level:   LOG_TYPE_WARNING,
+	})
+
+	if len(result.Logs) != 2 ||
+		result.Logs[0].Message != "Node 'B' is dropped" ||
+		result.Logs[1].Message != "Node 'A' is dropped" {
+		t.Errorf("Logger.Query() failed to filter logs. Got '%v'",
+			result.Logs)
+
+		return
+	}
+
+	result = logger.Query(Query{
+		Search: "DROPPED",
+		Limit:  1,
+	})
+
+	if len(result.Logs) != 1 || result.Cursor == 0 {
+		t.Errorf("Logger.Query() failed to limit logs. Got '%v'",
+			result)
+
+		return
+	}
+
+	result = logger.Query(Query{
+		Search: "dropped",
+		Limit:  1,
+		Cursor: result.Cursor,
+	})
+
+	if len(result.Logs) != 1 ||
+		result.Logs[0].Message != "Node 'A' is dropped" ||
+		result.Cursor != 0 {
+		t.Errorf("Logger.Query() failed to get the next page. Got '%v'",
+			result)
+
+		return
+	}
+
+	result = logger.Query(Query{
+		Until: startTime,
+	})
+
+	if len(result.Logs) != 0 {
+		t.Errorf("Logger.Query() failed to filter logs by time. "+
+			"Expecting '%d', got '%d'", 0, len(result.Logs))
+
+		return
+	}
+}
+
+func TestLoggerSubscribe(t *testing.T) {
+	logger := NewLogger()
+
+	subscription := logger.Subscribe(Query{
+		Level: LOG_TYPE_WARNING,
+	}, 1)
+
+	logger.Infof("Not received")
+	logger.Warningf("Received")
+	logger.Errorf("Dropped")
+
+	select {
+	case log := <-subscription.C:
+		if log.Message != "Received" || log.ID != 2 {
+			t.Errorf("Logger.Subscribe() received unexpected log '%v'", log)
+
+			return
+		}
+
+	default:
+		t.Error("Logger.Subscribe() failed to receive log")
+
+		return
+	}
+
+	if subscription.Dropped() != 1 {
+		t.Errorf("Subscription.Dropped() failed to count dropped logs. "+
+			"Expecting '%d', got '%d'", 1, subscription.Dropped())
+
+		return
+	}
+
+	subscription.Close()
+
+	logger.Errorf("After closed")
+
+	if _, ok := <-subscription.C; ok {
+		t.Error("Subscription.Close() failed to close the channel")
+
+		return
+	}
+}
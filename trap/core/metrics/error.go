@@ -0,0 +1,37 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+)
+
+var (
+	ErrServerNotSet *types.Error = types.NewError(
+		"Trap `Server` must be set before start a `Metrics` server")
+
+	ErrServerAlreadyUp *types.Error = types.NewError(
+		"`Metrics` server is already up")
+
+	ErrServerNotDownable *types.Error = types.NewError(
+		"`Metrics` can't be down at this moment")
+)
@@ -0,0 +1,175 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	COUNTER   = "counter"
+	GAUGE     = "gauge"
+	HISTOGRAM = "histogram"
+
+	CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Labels keeps the order in which the labels have been added, so the
+// exported series stay stable between the scrapes
+type Labels []Label
+
+func (l Labels) Add(name string, value string) Labels {
+	return append(l, Label{
+		Name:  name,
+		Value: value,
+	})
+}
+
+var labelValueEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\"", "\\\"",
+	"\n", "\\n",
+)
+
+var helpEscaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"\n", "\\n",
+)
+
+// Writer writes metrics in the Prometheus text exposition format
+type Writer struct {
+	writer *bufio.Writer
+	err    error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{
+		writer: bufio.NewWriter(w),
+	}
+}
+
+func (m *Writer) write(s ...string) {
+	if m.err != nil {
+		return
+	}
+
+	for _, part := range s {
+		_, m.err = m.writer.WriteString(part)
+
+		if m.err != nil {
+			return
+		}
+	}
+}
+
+func (m *Writer) labels(labels Labels, extra ...Label) string {
+	if len(labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	result := []string{}
+
+	for _, label := range append(append(Labels{}, labels...), extra...) {
+		result = append(result, label.Name+"=\""+
+			labelValueEscaper.Replace(label.Value)+"\"")
+	}
+
+	return "{" + strings.Join(result, ",") + "}"
+}
+
+func FormatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+
+	case math.IsInf(value, -1):
+		return "-Inf"
+
+	case math.IsNaN(value):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Family writes the `HELP` and `TYPE` lines of a metric. It must be
+// called once before the samples of that metric
+func (m *Writer) Family(name string, metricType string, help string) {
+	m.write("# HELP ", name, " ", helpEscaper.Replace(help), "\n")
+	m.write("# TYPE ", name, " ", metricType, "\n")
+}
+
+func (m *Writer) Sample(name string, labels Labels, value float64) {
+	m.write(name, m.labels(labels), " ", FormatValue(value), "\n")
+}
+
+// Histogram writes the `_bucket`, `_sum` and `_count` samples of a
+// histogram snapshot
+func (m *Writer) Histogram(name string, labels Labels,
+	snapshot HistogramSnapshot) {
+	cumulative := uint64(0)
+
+	for idx, bound := range snapshot.Bounds {
+		cumulative += snapshot.Counts[idx]
+
+		m.write(name, "_bucket", m.labels(labels, Label{
+			Name:  "le",
+			Value: FormatValue(bound),
+		}), " ", strconv.FormatUint(cumulative, 10), "\n")
+	}
+
+	m.write(name, "_bucket", m.labels(labels, Label{
+		Name:  "le",
+		Value: "+Inf",
+	}), " ", strconv.FormatUint(snapshot.Count, 10), "\n")
+
+	m.write(name, "_sum", m.labels(labels), " ",
+		FormatValue(snapshot.Sum), "\n")
+
+	m.write(name, "_count", m.labels(labels), " ",
+		strconv.FormatUint(snapshot.Count, 10), "\n")
+}
+
+// Flush writes buffered data to the underlaying writer and returns the
+// first error that happened during the writing
+func (m *Writer) Flush() *types.Throw {
+	if m.err == nil {
+		m.err = m.writer.Flush()
+	}
+
+	if m.err != nil {
+		return types.ConvertError(m.err)
+	}
+
+	return nil
+}
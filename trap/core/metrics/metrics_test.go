@@ -0,0 +1,92 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriterSample(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := NewWriter(&buf)
+
+	w.Family("trap_port_hits_total", COUNTER, "Hits\nof ports")
+	w.Sample("trap_port_hits_total",
+		Labels{}.Add("port", "22").Add("protocol", "tcp"), 3)
+	w.Sample("trap_port_hits_total",
+		Labels{}.Add("node", "a\"b\\c\n"), 0.5)
+	w.Sample("trap_clients", nil, 10)
+
+	flushErr := w.Flush()
+
+	if flushErr != nil {
+		t.Errorf("Writer.Flush() failed due to error: %s", flushErr)
+
+		return
+	}
+
+	expected := "# HELP trap_port_hits_total Hits\\nof ports\n" +
+		"# TYPE trap_port_hits_total counter\n" +
+		"trap_port_hits_total{port=\"22\",protocol=\"tcp\"} 3\n" +
+		"trap_port_hits_total{node=\"a\\\"b\\\\c\\n\"} 0.5\n" +
+		"trap_clients 10\n"
+
+	if buf.String() != expected {
+		t.Errorf("Writer.Sample() failed to write expected data. "+
+			"Expecting '%s', got '%s'", expected, buf.String())
+
+		return
+	}
+}
+
+func TestWriterHistogram(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := NewWriter(&buf)
+	h := NewHistogram([]float64{1, 0.1})
+
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	w.Histogram("trap_event_command_duration_seconds",
+		Labels{}.Add("event", "on.hit"), h.Snapshot())
+
+	w.Flush()
+
+	expected := "trap_event_command_duration_seconds_bucket" +
+		"{event=\"on.hit\",le=\"0.1\"} 2\n" +
+		"trap_event_command_duration_seconds_bucket" +
+		"{event=\"on.hit\",le=\"1\"} 3\n" +
+		"trap_event_command_duration_seconds_bucket" +
+		"{event=\"on.hit\",le=\"+Inf\"} 4\n" +
+		"trap_event_command_duration_seconds_sum{event=\"on.hit\"} 3.65\n" +
+		"trap_event_command_duration_seconds_count{event=\"on.hit\"} 4\n"
+
+	if buf.String() != expected {
+		t.Errorf("Writer.Histogram() failed to write expected data. "+
+			"Expecting '%s', got '%s'", expected, buf.String())
+
+		return
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"time"
+)
+
+// Context carries the fields of a hit which the policy can reference
+type Context struct {
+	Source     types.IP
+	Server     types.IP
+	Port       types.UInt16
+	Protocol   types.String
+	Count      types.UInt32
+	Score      types.UInt32
+	Marked     bool
+	Sample     []byte
+	Response   []byte
+	Tags       types.Strings
+	Signatures types.Strings
+
+	// Returns how many times the client hit us within the duration
+	HitsWithin func(time.Duration) types.UInt32
+}
+
+type scope struct {
+	context *Context
+	values  Values
+	within  time.Duration
+}
+
+type FieldKind int
+
+const (
+	FIELD_NUMBER FieldKind = iota
+	FIELD_STRING
+	FIELD_IP
+	FIELD_SET
+	FIELD_BOOL
+)
+
+var fieldKindNames = map[FieldKind]string{
+	FIELD_NUMBER: "number",
+	FIELD_STRING: "string",
+	FIELD_IP:     "address",
+	FIELD_SET:    "tag set",
+	FIELD_BOOL:   "boolean",
+}
+
+type field struct {
+	kind FieldKind
+
+	// Whether the field has a value, comparisons of a field without
+	// value never match. Fields of a hit always have their values
+	present func(*scope) bool
+
+	number  func(*scope) int64
+	str     func(*scope) string
+	ip      func(*scope) types.IP
+	set     func(*scope) types.Strings
+	boolean func(*scope) bool
+}
+
+var fields = map[string]field{
+	"source": {
+		kind: FIELD_IP,
+		ip: func(s *scope) types.IP {
+			return s.context.Source
+		},
+	},
+	"server": {
+		kind: FIELD_IP,
+		ip: func(s *scope) types.IP {
+			return s.context.Server
+		},
+	},
+	"port": {
+		kind: FIELD_NUMBER,
+		number: func(s *scope) int64 {
+			return int64(s.context.Port)
+		},
+	},
+	"count": {
+		kind: FIELD_NUMBER,
+		number: func(s *scope) int64 {
+			if s.within > 0 && s.context.HitsWithin != nil {
+				return int64(s.context.HitsWithin(s.within))
+			}
+
+			return int64(s.context.Count)
+		},
+	},
+	"score": {
+		kind: FIELD_NUMBER,
+		number: func(s *scope) int64 {
+			return int64(s.context.Score)
+		},
+	},
+	"size": {
+		kind: FIELD_NUMBER,
+		number: func(s *scope) int64 {
+			return int64(len(s.context.Sample))
+		},
+	},
+	"protocol": {
+		kind: FIELD_STRING,
+		str: func(s *scope) string {
+			return s.context.Protocol.Lower().String()
+		},
+	},
+	"sample": {
+		kind: FIELD_STRING,
+		str: func(s *scope) string {
+			return string(s.context.Sample)
+		},
+	},
+	"response": {
+		kind: FIELD_STRING,
+		str: func(s *scope) string {
+			return string(s.context.Response)
+		},
+	},
+	"tag": {
+		kind: FIELD_SET,
+		set: func(s *scope) types.Strings {
+			return s.context.Tags
+		},
+	},
+	"signature": {
+		kind: FIELD_SET,
+		set: func(s *scope) types.Strings {
+			return s.context.Signatures
+		},
+	},
+	"marked": {
+		kind: FIELD_BOOL,
+		boolean: func(s *scope) bool {
+			return s.context.Marked
+		},
+	},
+}
@@ -0,0 +1,152 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+)
+
+// Values provides the fields of an Expression by their names. The second
+// return value reports whether the field has a value of the expected kind
+type Values interface {
+	Number(name string) (int64, bool)
+	String(name string) (string, bool)
+	IP(name string) (types.IP, bool)
+}
+
+// Expression is a single condition of the policy language which is
+// evaluated against the given Values instead of a hit, like the
+// parameters of an event. A comparison on a field without value never
+// matches
+type Expression struct {
+	source    string
+	condition condition
+}
+
+// Parse the expression, only the given fields of the given kinds can be
+// referenced. Numbers, strings and addresses are supported
+func ParseExpression(source string,
+	kinds map[string]FieldKind) (*Expression, *types.Throw) {
+	exprFields := map[string]field{}
+
+	for name, kind := range kinds {
+		f, err := valueField(name, kind)
+
+		if err != nil {
+			return nil, err
+		}
+
+		exprFields[name] = f
+	}
+
+	tokens, err := newLexer(source).tokens()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens:  tokens,
+		current: 0,
+		fields:  exprFields,
+		defines: map[string]literal{},
+	}
+
+	cond, err := p.or()
+
+	if err != nil {
+		return nil, err
+	}
+
+	end := p.next()
+
+	if end.kind != TOKEN_EOF {
+		return nil, syntaxError(end.pos, "expecting end of condition, "+
+			"got %s", describe(end))
+	}
+
+	return &Expression{
+		source:    source,
+		condition: cond,
+	}, nil
+}
+
+func valueField(name string, kind FieldKind) (field, *types.Throw) {
+	switch kind {
+	case FIELD_NUMBER:
+		return field{
+			kind: kind,
+			present: func(s *scope) bool {
+				_, found := s.values.Number(name)
+
+				return found
+			},
+			number: func(s *scope) int64 {
+				number, _ := s.values.Number(name)
+
+				return number
+			},
+		}, nil
+
+	case FIELD_STRING:
+		return field{
+			kind: kind,
+			present: func(s *scope) bool {
+				_, found := s.values.String(name)
+
+				return found
+			},
+			str: func(s *scope) string {
+				str, _ := s.values.String(name)
+
+				return str
+			},
+		}, nil
+
+	case FIELD_IP:
+		return field{
+			kind: kind,
+			present: func(s *scope) bool {
+				_, found := s.values.IP(name)
+
+				return found
+			},
+			ip: func(s *scope) types.IP {
+				ip, _ := s.values.IP(name)
+
+				return ip
+			},
+		}, nil
+	}
+
+	return field{}, ErrUnsupportedFieldKind.Throw(fieldKindNames[kind], name)
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+func (e *Expression) Match(values Values) bool {
+	return e.condition(&scope{
+		values: values,
+	})
+}
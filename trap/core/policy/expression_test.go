@@ -0,0 +1,119 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+)
+
+type testValues map[string]string
+
+func (v testValues) Number(name string) (int64, bool) {
+	switch v[name] {
+	case "22":
+		return 22, true
+	}
+
+	return 0, false
+}
+
+func (v testValues) String(name string) (string, bool) {
+	str, found := v[name]
+
+	return str, found
+}
+
+func (v testValues) IP(name string) (types.IP, bool) {
+	ip, err := types.ConvertIPFromString(types.String(v[name]))
+
+	return ip, err == nil
+}
+
+func TestExpression(t *testing.T) {
+	kinds := map[string]FieldKind{
+		"Port":   FIELD_NUMBER,
+		"Type":   FIELD_STRING,
+		"Client": FIELD_IP,
+	}
+
+	values := testValues{
+		"Port":   "22",
+		"Client": "10.0.0.1",
+	}
+
+	tests := []struct {
+		Expression string
+		Expected   bool
+	}{
+		{"Port == 22", true},
+		{"Port != 22", false},
+		{"Client in \"10.0.0.0/8\" and Port in [22, 23]", true},
+		{"Type == \"tcp\"", false},
+		{"Type != \"tcp\"", false},
+		{"not Type == \"tcp\"", true},
+	}
+
+	for _, test := range tests {
+		expression, err := ParseExpression(test.Expression, kinds)
+
+		if err != nil {
+			t.Errorf("ParseExpression() failed to parse '%s' due to "+
+				"error: %s", test.Expression, err)
+
+			return
+		}
+
+		if expression.Match(values) != test.Expected {
+			t.Errorf("Expression.Match() failed on '%s'. Expecting '%t', "+
+				"got '%t'", test.Expression, test.Expected, !test.Expected)
+
+			return
+		}
+	}
+
+	for _, invalid := range []string{
+		"port == 22",
+		"Port == 22; Port == 23",
+		"Port == 22 within 10m",
+		"Port == \"22\"",
+	} {
+		_, err := ParseExpression(invalid, kinds)
+
+		if err == nil || !err.Is(ErrSyntax) {
+			t.Errorf("ParseExpression() failed to reject '%s'", invalid)
+
+			return
+		}
+	}
+
+	_, err := ParseExpression("Marked", map[string]FieldKind{
+		"Marked": FIELD_BOOL,
+	})
+
+	if err == nil || !err.Is(ErrUnsupportedFieldKind) {
+		t.Error("ParseExpression() failed to reject unsupported field kind")
+
+		return
+	}
+}
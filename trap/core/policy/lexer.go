@@ -0,0 +1,299 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"fmt"
+	"strconv"
+	"time"
+	"unicode"
+)
+
+type tokenType int
+
+const (
+	TOKEN_EOF tokenType = iota
+	TOKEN_NEWLINE
+	TOKEN_IDENT
+	TOKEN_NUMBER
+	TOKEN_DURATION
+	TOKEN_STRING
+	TOKEN_OPERATOR
+	TOKEN_LPAREN
+	TOKEN_RPAREN
+	TOKEN_LBRACKET
+	TOKEN_RBRACKET
+	TOKEN_COMMA
+)
+
+var durationUnits = map[rune]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+type Position struct {
+	Line   int
+	Column int
+}
+
+type token struct {
+	kind     tokenType
+	text     string
+	number   int64
+	duration time.Duration
+	pos      Position
+}
+
+type lexer struct {
+	source []rune
+	offset int
+	pos    Position
+}
+
+func newLexer(source string) *lexer {
+	return &lexer{
+		source: []rune(source),
+		offset: 0,
+		pos: Position{
+			Line:   1,
+			Column: 1,
+		},
+	}
+}
+
+func syntaxError(pos Position, format string, a ...interface{}) *types.Throw {
+	return ErrSyntax.Throw(pos.Line, pos.Column, fmt.Sprintf(format, a...))
+}
+
+func (l *lexer) peek(ahead int) rune {
+	if l.offset+ahead >= len(l.source) {
+		return 0
+	}
+
+	return l.source[l.offset+ahead]
+}
+
+func (l *lexer) next() rune {
+	r := l.source[l.offset]
+
+	l.offset += 1
+
+	if r == '\n' {
+		l.pos.Line += 1
+		l.pos.Column = 1
+	} else {
+		l.pos.Column += 1
+	}
+
+	return r
+}
+
+func isIdent(r rune, first bool) bool {
+	if r == '_' || unicode.IsLetter(r) {
+		return true
+	}
+
+	return !first && unicode.IsDigit(r)
+}
+
+// Read all tokens from the source
+func (l *lexer) tokens() ([]token, *types.Throw) {
+	tokens := []token{}
+
+	for {
+		tok, err := l.token()
+
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, tok)
+
+		if tok.kind == TOKEN_EOF {
+			return tokens, nil
+		}
+	}
+}
+
+func (l *lexer) token() (token, *types.Throw) {
+	// Skip spaces and comments
+	for l.offset < len(l.source) {
+		r := l.peek(0)
+
+		if r == '#' {
+			for l.offset < len(l.source) && l.peek(0) != '\n' {
+				l.next()
+			}
+
+			continue
+		}
+
+		if r == '\n' || !unicode.IsSpace(r) {
+			break
+		}
+
+		l.next()
+	}
+
+	pos := l.pos
+
+	if l.offset >= len(l.source) {
+		return token{kind: TOKEN_EOF, pos: pos}, nil
+	}
+
+	r := l.peek(0)
+
+	switch {
+	case r == '\n' || r == ';':
+		l.next()
+
+		return token{kind: TOKEN_NEWLINE, text: string(r), pos: pos}, nil
+
+	case r == '(':
+		l.next()
+
+		return token{kind: TOKEN_LPAREN, text: "(", pos: pos}, nil
+
+	case r == ')':
+		l.next()
+
+		return token{kind: TOKEN_RPAREN, text: ")", pos: pos}, nil
+
+	case r == '[':
+		l.next()
+
+		return token{kind: TOKEN_LBRACKET, text: "[", pos: pos}, nil
+
+	case r == ']':
+		l.next()
+
+		return token{kind: TOKEN_RBRACKET, text: "]", pos: pos}, nil
+
+	case r == ',':
+		l.next()
+
+		return token{kind: TOKEN_COMMA, text: ",", pos: pos}, nil
+
+	case r == '=' || r == '!' || r == '<' || r == '>':
+		l.next()
+
+		if l.peek(0) == '=' {
+			l.next()
+
+			return token{kind: TOKEN_OPERATOR, text: string(r) + "=",
+				pos: pos}, nil
+		}
+
+		if r == '!' {
+			return token{}, syntaxError(pos, "unexpected character '!'")
+		}
+
+		return token{kind: TOKEN_OPERATOR, text: string(r), pos: pos}, nil
+
+	case r == '"':
+		return l.str(pos)
+
+	case unicode.IsDigit(r):
+		return l.number(pos)
+
+	case isIdent(r, true):
+		ident := []rune{}
+
+		for l.offset < len(l.source) && isIdent(l.peek(0), false) {
+			ident = append(ident, l.next())
+		}
+
+		return token{kind: TOKEN_IDENT, text: string(ident), pos: pos}, nil
+	}
+
+	return token{}, syntaxError(pos, "unexpected character '%c'", r)
+}
+
+func (l *lexer) str(pos Position) (token, *types.Throw) {
+	literal := []rune{l.next()}
+
+	for {
+		if l.offset >= len(l.source) || l.peek(0) == '\n' {
+			return token{}, syntaxError(pos, "unterminated string")
+		}
+
+		r := l.next()
+
+		literal = append(literal, r)
+
+		if r == '\\' && l.offset < len(l.source) {
+			literal = append(literal, l.next())
+
+			continue
+		}
+
+		if r == '"' {
+			break
+		}
+	}
+
+	text, err := strconv.Unquote(string(literal))
+
+	if err != nil {
+		return token{}, syntaxError(pos, "invalid string %s", string(literal))
+	}
+
+	return token{kind: TOKEN_STRING, text: text, pos: pos}, nil
+}
+
+func (l *lexer) number(pos Position) (token, *types.Throw) {
+	digits := []rune{}
+
+	for l.offset < len(l.source) && unicode.IsDigit(l.peek(0)) {
+		digits = append(digits, l.next())
+	}
+
+	number, err := strconv.ParseInt(string(digits), 10, 64)
+
+	if err != nil {
+		return token{}, syntaxError(pos, "invalid number '%s'",
+			string(digits))
+	}
+
+	// A number directly followed by a unit is a duration, like `10m`
+	if unit, found := durationUnits[l.peek(0)]; found &&
+		!isIdent(l.peek(1), false) {
+		l.next()
+
+		return token{kind: TOKEN_DURATION, text: string(digits) +
+			string(l.source[l.offset-1]), number: number,
+			duration: time.Duration(number) * unit, pos: pos}, nil
+	}
+
+	if isIdent(l.peek(0), false) {
+		return token{}, syntaxError(pos, "invalid number or duration "+
+			"'%s%c'", string(digits), l.peek(0))
+	}
+
+	return token{kind: TOKEN_NUMBER, text: string(digits), number: number,
+		pos: pos}, nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLexerTokens(t *testing.T) {
+	tokens, err := newLexer("mark if port in [22, 23] # comment\n" +
+		"restrict 7d if tag == \"mi\\\"rai\"").tokens()
+
+	if err != nil {
+		t.Errorf("lexer.tokens() failed due to error: %s", err)
+
+		return
+	}
+
+	expected := []struct {
+		Kind tokenType
+		Text string
+	}{
+		{TOKEN_IDENT, "mark"}, {TOKEN_IDENT, "if"}, {TOKEN_IDENT, "port"},
+		{TOKEN_IDENT, "in"}, {TOKEN_LBRACKET, "["}, {TOKEN_NUMBER, "22"},
+		{TOKEN_COMMA, ","}, {TOKEN_NUMBER, "23"}, {TOKEN_RBRACKET, "]"},
+		{TOKEN_NEWLINE, "\n"}, {TOKEN_IDENT, "restrict"},
+		{TOKEN_DURATION, "7d"}, {TOKEN_IDENT, "if"}, {TOKEN_IDENT, "tag"},
+		{TOKEN_OPERATOR, "=="}, {TOKEN_STRING, "mi\"rai"}, {TOKEN_EOF, ""},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Errorf("lexer.tokens() returns unexpected amount of tokens. "+
+			"Expecting '%d', got '%d'", len(expected), len(tokens))
+
+		return
+	}
+
+	for idx, tok := range tokens {
+		if tok.kind == expected[idx].Kind && tok.text == expected[idx].Text {
+			continue
+		}
+
+		t.Errorf("lexer.tokens() returns unexpected token '%d'. "+
+			"Expecting '%s', got '%s'", idx, expected[idx].Text, tok.text)
+
+		return
+	}
+
+	if tokens[11].duration != 7*24*time.Hour {
+		t.Errorf("lexer.tokens() failed to parse duration. "+
+			"Expecting '%s', got '%s'", 7*24*time.Hour, tokens[11].duration)
+
+		return
+	}
+
+	if tokens[10].pos.Line != 2 || tokens[10].pos.Column != 1 ||
+		tokens[13].pos.Column != 16 {
+		t.Error("lexer.tokens() failed to track token positions")
+
+		return
+	}
+}
+
+func TestLexerInvalid(t *testing.T) {
+	tests := []string{
+		"mark if sample == \"unterminated",
+		"mark if port == 10x",
+		"mark if port ! 10",
+		"mark if port == @",
+	}
+
+	for _, test := range tests {
+		_, err := newLexer(test).tokens()
+
+		if err == nil || !err.Is(ErrSyntax) {
+			t.Errorf("lexer.tokens() failed to report error for '%s'", test)
+
+			return
+		}
+	}
+}
@@ -0,0 +1,797 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"regexp"
+	"strings"
+)
+
+type literalKind int
+
+const (
+	LITERAL_NUMBER literalKind = iota
+	LITERAL_STRING
+	LITERAL_BOOL
+	LITERAL_LIST
+)
+
+type literal struct {
+	kind    literalKind
+	number  int64
+	str     string
+	boolean bool
+	list    []literal
+	pos     Position
+}
+
+type condition func(*scope) bool
+
+var keywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "contains": true,
+	"matches": true, "if": true, "within": true, "mark": true,
+	"skip": true, "score": true, "restrict": true, "define": true,
+	"true": true, "false": true,
+}
+
+type parser struct {
+	tokens  []token
+	current int
+	fields  map[string]field
+	defines map[string]literal
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.current]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.current]
+
+	if tok.kind != TOKEN_EOF {
+		p.current += 1
+	}
+
+	return tok
+}
+
+func (p *parser) isKeyword(tok token, keyword string) bool {
+	return tok.kind == TOKEN_IDENT && tok.text == keyword
+}
+
+func (p *parser) expectKeyword(keyword string) *types.Throw {
+	tok := p.next()
+
+	if !p.isKeyword(tok, keyword) {
+		return syntaxError(tok.pos, "expecting '%s', got %s", keyword,
+			describe(tok))
+	}
+
+	return nil
+}
+
+func describe(tok token) string {
+	switch tok.kind {
+	case TOKEN_EOF:
+		return "end of file"
+
+	case TOKEN_NEWLINE:
+		return "end of line"
+
+	case TOKEN_STRING:
+		return "string \"" + tok.text + "\""
+	}
+
+	return "'" + tok.text + "'"
+}
+
+func (p *parser) parse() (*Policy, *types.Throw) {
+	policy := &Policy{
+		statements: []Statement{},
+	}
+
+	for {
+		tok := p.peek()
+
+		switch {
+		case tok.kind == TOKEN_EOF:
+			return policy, nil
+
+		case tok.kind == TOKEN_NEWLINE:
+			p.next()
+
+			continue
+
+		case p.isKeyword(tok, "define"):
+			err := p.define()
+
+			if err != nil {
+				return nil, err
+			}
+
+		default:
+			statement, err := p.statement()
+
+			if err != nil {
+				return nil, err
+			}
+
+			policy.statements = append(policy.statements, statement)
+		}
+
+		// Every definition and statement must end with a line break
+		end := p.next()
+
+		if end.kind != TOKEN_NEWLINE && end.kind != TOKEN_EOF {
+			return nil, syntaxError(end.pos, "expecting end of line, got %s",
+				describe(end))
+		}
+	}
+}
+
+func (p *parser) define() *types.Throw {
+	p.next()
+
+	name := p.next()
+
+	if name.kind != TOKEN_IDENT {
+		return syntaxError(name.pos, "expecting a name, got %s",
+			describe(name))
+	}
+
+	if _, isField := p.fields[name.text]; isField || keywords[name.text] {
+		return syntaxError(name.pos, "'%s' is reserved and can't be "+
+			"defined", name.text)
+	}
+
+	if _, defined := p.defines[name.text]; defined {
+		return syntaxError(name.pos, "'%s' has already been defined",
+			name.text)
+	}
+
+	assign := p.next()
+
+	if assign.kind != TOKEN_OPERATOR || assign.text != "=" {
+		return syntaxError(assign.pos, "expecting '=', got %s",
+			describe(assign))
+	}
+
+	value, err := p.literal()
+
+	if err != nil {
+		return err
+	}
+
+	p.defines[name.text] = value
+
+	return nil
+}
+
+func (p *parser) statement() (Statement, *types.Throw) {
+	statement := Statement{
+		Line: p.peek().pos.Line,
+	}
+
+	// Actions
+	for !p.isKeyword(p.peek(), "if") {
+		tok := p.next()
+
+		switch {
+		case p.isKeyword(tok, "mark"):
+			statement.Mark = true
+
+		case p.isKeyword(tok, "skip"):
+			statement.Skip = true
+
+		case p.isKeyword(tok, "score"):
+			score := p.next()
+
+			if score.kind != TOKEN_NUMBER ||
+				score.number > int64(types.UINT32_MAX_UINT32) {
+				return statement, syntaxError(score.pos, "expecting a "+
+					"score number, got %s", describe(score))
+			}
+
+			statement.Score += types.UInt32(score.number)
+
+		case p.isKeyword(tok, "restrict"):
+			restrict := p.next()
+
+			if restrict.kind != TOKEN_DURATION {
+				return statement, syntaxError(restrict.pos, "expecting a "+
+					"duration like '7d', got %s", describe(restrict))
+			}
+
+			statement.Restrict = restrict.duration
+
+		default:
+			return statement, syntaxError(tok.pos, "expecting an action "+
+				"(mark, skip, score or restrict), got %s", describe(tok))
+		}
+	}
+
+	p.next()
+
+	cond, err := p.or()
+
+	if err != nil {
+		return statement, err
+	}
+
+	statement.condition = cond
+
+	if p.isKeyword(p.peek(), "within") {
+		p.next()
+
+		within := p.next()
+
+		if within.kind != TOKEN_DURATION {
+			return statement, syntaxError(within.pos, "expecting a "+
+				"duration like '10m', got %s", describe(within))
+		}
+
+		statement.Within = within.duration
+	}
+
+	return statement, nil
+}
+
+func (p *parser) or() (condition, *types.Throw) {
+	left, err := p.and()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword(p.peek(), "or") {
+		p.next()
+
+		right, err := p.and()
+
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+
+		left = func(s *scope) bool {
+			return l(s) || right(s)
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (condition, *types.Throw) {
+	left, err := p.not()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword(p.peek(), "and") {
+		p.next()
+
+		right, err := p.not()
+
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+
+		left = func(s *scope) bool {
+			return l(s) && right(s)
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) not() (condition, *types.Throw) {
+	if !p.isKeyword(p.peek(), "not") {
+		return p.primary()
+	}
+
+	p.next()
+
+	cond, err := p.not()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(s *scope) bool {
+		return !cond(s)
+	}, nil
+}
+
+func (p *parser) primary() (condition, *types.Throw) {
+	tok := p.next()
+
+	if tok.kind == TOKEN_LPAREN {
+		cond, err := p.or()
+
+		if err != nil {
+			return nil, err
+		}
+
+		closing := p.next()
+
+		if closing.kind != TOKEN_RPAREN {
+			return nil, syntaxError(closing.pos, "expecting ')', got %s",
+				describe(closing))
+		}
+
+		return cond, nil
+	}
+
+	if tok.kind != TOKEN_IDENT {
+		return nil, syntaxError(tok.pos, "expecting a field, got %s",
+			describe(tok))
+	}
+
+	f, found := p.fields[tok.text]
+
+	if !found {
+		return nil, syntaxError(tok.pos, "unknown field '%s'", tok.text)
+	}
+
+	cond, err := p.comparison(tok, f)
+
+	if err != nil || f.present == nil {
+		return cond, err
+	}
+
+	return func(s *scope) bool {
+		return f.present(s) && cond(s)
+	}, nil
+}
+
+func (p *parser) comparison(tok token, f field) (condition, *types.Throw) {
+	opTok := p.peek()
+	op := ""
+
+	switch {
+	case opTok.kind == TOKEN_OPERATOR:
+		if opTok.text == "=" {
+			return nil, syntaxError(opTok.pos, "use '==' to compare")
+		}
+
+		op = opTok.text
+
+	case p.isKeyword(opTok, "in"), p.isKeyword(opTok, "contains"),
+		p.isKeyword(opTok, "matches"):
+		op = opTok.text
+
+	case p.isKeyword(opTok, "not"):
+		p.next()
+
+		if err := p.expectKeyword("in"); err != nil {
+			return nil, err
+		}
+
+		return p.compile(tok, f, "not in", opTok.pos)
+	}
+
+	if op == "" {
+		if f.kind != FIELD_BOOL {
+			return nil, syntaxError(opTok.pos, "field '%s' must be "+
+				"compared with a value", tok.text)
+		}
+
+		return f.boolean, nil
+	}
+
+	p.next()
+
+	return p.compile(tok, f, op, opTok.pos)
+}
+
+func (p *parser) literal() (literal, *types.Throw) {
+	tok := p.next()
+
+	switch {
+	case tok.kind == TOKEN_NUMBER:
+		return literal{kind: LITERAL_NUMBER, number: tok.number,
+			pos: tok.pos}, nil
+
+	case tok.kind == TOKEN_STRING:
+		return literal{kind: LITERAL_STRING, str: tok.text, pos: tok.pos}, nil
+
+	case p.isKeyword(tok, "true"), p.isKeyword(tok, "false"):
+		return literal{kind: LITERAL_BOOL, boolean: tok.text == "true",
+			pos: tok.pos}, nil
+
+	case tok.kind == TOKEN_LBRACKET:
+		list := literal{kind: LITERAL_LIST, list: []literal{}, pos: tok.pos}
+
+		if p.peek().kind == TOKEN_RBRACKET {
+			p.next()
+
+			return list, nil
+		}
+
+		for {
+			item, err := p.literal()
+
+			if err != nil {
+				return list, err
+			}
+
+			if item.kind == LITERAL_LIST {
+				return list, syntaxError(item.pos, "list can't be nested")
+			}
+
+			list.list = append(list.list, item)
+
+			sep := p.next()
+
+			if sep.kind == TOKEN_RBRACKET {
+				return list, nil
+			}
+
+			if sep.kind != TOKEN_COMMA {
+				return list, syntaxError(sep.pos, "expecting ',' or ']', "+
+					"got %s", describe(sep))
+			}
+		}
+
+	case tok.kind == TOKEN_IDENT && !keywords[tok.text]:
+		defined, found := p.defines[tok.text]
+
+		if !found {
+			return literal{}, syntaxError(tok.pos, "'%s' is not defined",
+				tok.text)
+		}
+
+		defined.pos = tok.pos
+
+		return defined, nil
+	}
+
+	return literal{}, syntaxError(tok.pos, "expecting a value, got %s",
+		describe(tok))
+}
+
+// Build the condition for the comparison, values are checked against the
+// type of the field here so nothing can fail during evaluation
+func (p *parser) compile(fieldTok token, f field, op string,
+	opPos Position) (condition, *types.Throw) {
+	value, err := p.literal()
+
+	if err != nil {
+		return nil, err
+	}
+
+	mismatch := func(expecting string) *types.Throw {
+		return syntaxError(value.pos, "operator '%s' of %s field '%s' "+
+			"expects %s", op, fieldKindNames[f.kind], fieldTok.text,
+			expecting)
+	}
+
+	invalidOp := func() *types.Throw {
+		return syntaxError(opPos, "operator '%s' can't be used on %s "+
+			"field '%s'", op, fieldKindNames[f.kind], fieldTok.text)
+	}
+
+	stringList := func() ([]string, *types.Throw) {
+		result := []string{}
+
+		if value.kind != LITERAL_LIST {
+			return nil, mismatch("a list of strings")
+		}
+
+		for _, item := range value.list {
+			if item.kind != LITERAL_STRING {
+				return nil, syntaxError(item.pos, "expecting a string")
+			}
+
+			result = append(result, item.str)
+		}
+
+		return result, nil
+	}
+
+	switch f.kind {
+	case FIELD_NUMBER:
+		return compileNumber(f, op, value, mismatch, invalidOp)
+
+	case FIELD_STRING:
+		return compileString(f, op, value, stringList, mismatch, invalidOp)
+
+	case FIELD_IP:
+		return compileIP(f, op, value, mismatch, invalidOp)
+
+	case FIELD_SET:
+		return compileSet(f, op, value, stringList, mismatch, invalidOp)
+
+	case FIELD_BOOL:
+		if op != "==" && op != "!=" {
+			return nil, invalidOp()
+		}
+
+		if value.kind != LITERAL_BOOL {
+			return nil, mismatch("true or false")
+		}
+
+		expected := value.boolean == (op == "==")
+
+		return func(s *scope) bool {
+			return f.boolean(s) == expected
+		}, nil
+	}
+
+	return nil, invalidOp()
+}
+
+func compileNumber(f field, op string, value literal,
+	mismatch func(string) *types.Throw,
+	invalidOp func() *types.Throw) (condition, *types.Throw) {
+	if op == "in" || op == "not in" {
+		if value.kind != LITERAL_LIST {
+			return nil, mismatch("a list of numbers")
+		}
+
+		numbers := map[int64]bool{}
+
+		for _, item := range value.list {
+			if item.kind != LITERAL_NUMBER {
+				return nil, syntaxError(item.pos, "expecting a number")
+			}
+
+			numbers[item.number] = true
+		}
+
+		expected := op == "in"
+
+		return func(s *scope) bool {
+			return numbers[f.number(s)] == expected
+		}, nil
+	}
+
+	if value.kind != LITERAL_NUMBER {
+		return nil, mismatch("a number")
+	}
+
+	n := value.number
+
+	switch op {
+	case "==":
+		return func(s *scope) bool { return f.number(s) == n }, nil
+
+	case "!=":
+		return func(s *scope) bool { return f.number(s) != n }, nil
+
+	case "<":
+		return func(s *scope) bool { return f.number(s) < n }, nil
+
+	case "<=":
+		return func(s *scope) bool { return f.number(s) <= n }, nil
+
+	case ">":
+		return func(s *scope) bool { return f.number(s) > n }, nil
+
+	case ">=":
+		return func(s *scope) bool { return f.number(s) >= n }, nil
+	}
+
+	return nil, invalidOp()
+}
+
+func compileString(f field, op string, value literal,
+	list func() ([]string, *types.Throw),
+	mismatch func(string) *types.Throw,
+	invalidOp func() *types.Throw) (condition, *types.Throw) {
+	if op == "in" || op == "not in" {
+		items, err := list()
+
+		if err != nil {
+			return nil, err
+		}
+
+		expected := op == "in"
+
+		return func(s *scope) bool {
+			str := f.str(s)
+
+			for _, item := range items {
+				if item == str {
+					return expected
+				}
+			}
+
+			return !expected
+		}, nil
+	}
+
+	if value.kind != LITERAL_STRING {
+		return nil, mismatch("a string")
+	}
+
+	str := value.str
+
+	switch op {
+	case "==":
+		return func(s *scope) bool { return f.str(s) == str }, nil
+
+	case "!=":
+		return func(s *scope) bool { return f.str(s) != str }, nil
+
+	case "contains":
+		return func(s *scope) bool {
+			return strings.Contains(f.str(s), str)
+		}, nil
+
+	case "matches":
+		regex, err := regexp.Compile(str)
+
+		if err != nil {
+			return nil, syntaxError(value.pos, "invalid regular "+
+				"expression: %s", err)
+		}
+
+		return func(s *scope) bool { return regex.MatchString(f.str(s)) }, nil
+	}
+
+	return nil, invalidOp()
+}
+
+func parseNetwork(value literal) (*net.IPNet, *types.Throw) {
+	if value.kind != LITERAL_STRING {
+		return nil, syntaxError(value.pos, "expecting an address or "+
+			"a network")
+	}
+
+	if strings.Contains(value.str, "/") {
+		_, network, err := net.ParseCIDR(value.str)
+
+		if err != nil {
+			return nil, syntaxError(value.pos, "invalid network '%s'",
+				value.str)
+		}
+
+		return network, nil
+	}
+
+	ip := net.ParseIP(value.str)
+
+	if ip == nil {
+		return nil, syntaxError(value.pos, "invalid address '%s'", value.str)
+	}
+
+	if ip.To4() != nil {
+		return &net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}, nil
+	}
+
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+}
+
+func compileIP(f field, op string, value literal,
+	mismatch func(string) *types.Throw,
+	invalidOp func() *types.Throw) (condition, *types.Throw) {
+	switch op {
+	case "==", "!=":
+		if value.kind != LITERAL_STRING {
+			return nil, mismatch("an address")
+		}
+
+		ip, err := types.ConvertIPFromString(types.String(value.str))
+
+		if err != nil {
+			return nil, syntaxError(value.pos, "invalid address '%s'",
+				value.str)
+		}
+
+		expected := op == "=="
+
+		return func(s *scope) bool { return (f.ip(s) == ip) == expected }, nil
+
+	case "in", "not in":
+		networks := []*net.IPNet{}
+
+		items := []literal{value}
+
+		if value.kind == LITERAL_LIST {
+			items = value.list
+		}
+
+		for _, item := range items {
+			network, err := parseNetwork(item)
+
+			if err != nil {
+				return nil, err
+			}
+
+			networks = append(networks, network)
+		}
+
+		expected := op == "in"
+
+		return func(s *scope) bool {
+			ip := f.ip(s).IP()
+
+			for _, network := range networks {
+				if network.Contains(ip) {
+					return expected
+				}
+			}
+
+			return !expected
+		}, nil
+	}
+
+	return nil, invalidOp()
+}
+
+func compileSet(f field, op string, value literal,
+	list func() ([]string, *types.Throw),
+	mismatch func(string) *types.Throw,
+	invalidOp func() *types.Throw) (condition, *types.Throw) {
+	has := func(set types.Strings, str string) bool {
+		for _, item := range set {
+			if strings.EqualFold(item.String(), str) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	switch op {
+	case "==", "contains", "!=":
+		if value.kind != LITERAL_STRING {
+			return nil, mismatch("a string")
+		}
+
+		str := value.str
+		expected := op != "!="
+
+		return func(s *scope) bool { return has(f.set(s), str) == expected }, nil
+
+	case "in", "not in":
+		items, err := list()
+
+		if err != nil {
+			return nil, err
+		}
+
+		expected := op == "in"
+
+		return func(s *scope) bool {
+			set := f.set(s)
+
+			for _, item := range items {
+				if has(set, item) {
+					return expected
+				}
+			}
+
+			return !expected
+		}, nil
+	}
+
+	return nil, invalidOp()
+}
@@ -0,0 +1,145 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"io/ioutil"
+	"time"
+)
+
+type Action int
+
+// Actions are ordered by their priority, when multiple statements
+// matched, the action with the highest priority will be taken
+const (
+	ACTION_NONE Action = iota
+	ACTION_SCORE
+	ACTION_MARK
+	ACTION_SKIP
+)
+
+type Statement struct {
+	Line     int
+	Mark     bool
+	Skip     bool
+	Score    types.UInt32
+	Restrict time.Duration
+	Within   time.Duration
+
+	condition condition
+}
+
+func (s Statement) Match(context *Context) bool {
+	return s.condition(&scope{
+		context: context,
+		within:  s.Within,
+	})
+}
+
+type Decision struct {
+	Action   Action
+	Score    types.UInt32
+	Restrict time.Duration
+	Lines    []int
+}
+
+func (d Decision) Matched() bool {
+	return len(d.Lines) > 0
+}
+
+type Policy struct {
+	statements []Statement
+}
+
+func Load(filePath string) (*Policy, *types.Throw) {
+	content, err := ioutil.ReadFile(filePath)
+
+	if err != nil {
+		return nil, types.ConvertError(err)
+	}
+
+	return Parse(string(content))
+}
+
+func Parse(source string) (*Policy, *types.Throw) {
+	tokens, err := newLexer(source).tokens()
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{
+		tokens:  tokens,
+		current: 0,
+		fields:  fields,
+		defines: map[string]literal{},
+	}
+
+	return p.parse()
+}
+
+func (p *Policy) Len() int {
+	return len(p.statements)
+}
+
+// Evaluate all statements against the context. A matched `skip`
+// statement stops the evaluation right away
+func (p *Policy) Evaluate(context Context) Decision {
+	decision := Decision{
+		Action: ACTION_NONE,
+		Lines:  []int{},
+	}
+
+	for _, statement := range p.statements {
+		if !statement.Match(&context) {
+			continue
+		}
+
+		decision.Lines = append(decision.Lines, statement.Line)
+
+		if statement.Skip {
+			decision.Action = ACTION_SKIP
+
+			return decision
+		}
+
+		if statement.Mark {
+			decision.Action = ACTION_MARK
+		}
+
+		if statement.Score > 0 {
+			if decision.Action < ACTION_SCORE {
+				decision.Action = ACTION_SCORE
+			}
+
+			decision.Score += statement.Score
+		}
+
+		if statement.Restrict > decision.Restrict {
+			decision.Restrict = statement.Restrict
+		}
+	}
+
+	return decision
+}
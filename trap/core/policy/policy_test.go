@@ -0,0 +1,266 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package policy
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+	"time"
+)
+
+const testPolicy = `
+# Monitoring servers are never counted
+define monitoring = ["10.0.0.0/8", "2001:db8::/32", "192.0.2.7"]
+
+skip if source in monitoring
+mark if port in [22, 23] and count >= 2 within 10m
+mark restrict 7d if tag == "mirai"
+score 5 if protocol == "tcp" and (sample contains "GET" or size > 512)
+score 3 if signature in ["zgrab", "masscan"] and not marked
+mark if score >= 20; skip if server == "192.0.2.1" and port == 8080
+`
+
+func getTestContext(source types.String) Context {
+	sourceIP, _ := types.ConvertIPFromString(source)
+	serverIP, _ := types.ConvertIPFromString("192.0.2.2")
+
+	return Context{
+		Source:     sourceIP,
+		Server:     serverIP,
+		Port:       80,
+		Protocol:   "TCP",
+		Count:      1,
+		Score:      0,
+		Marked:     false,
+		Sample:     []byte{},
+		Response:   []byte{},
+		Tags:       types.Strings{},
+		Signatures: types.Strings{},
+		HitsWithin: func(d time.Duration) types.UInt32 {
+			return 0
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	policy, err := Parse(testPolicy)
+
+	if err != nil {
+		t.Errorf("Parse() failed due to error: %s", err)
+
+		return
+	}
+
+	if policy.Len() != 7 {
+		t.Errorf("Parse() failed to parse all statements. "+
+			"Expecting '%d', got '%d'", 7, policy.Len())
+
+		return
+	}
+
+	if policy.statements[1].Line != 6 ||
+		policy.statements[1].Within != 10*time.Minute {
+		t.Error("Parse() failed to parse the `within` statement")
+
+		return
+	}
+
+	if !policy.statements[2].Mark ||
+		policy.statements[2].Restrict != 7*24*time.Hour {
+		t.Error("Parse() failed to parse the `mark restrict` statement")
+
+		return
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		Source string
+		Line   int
+		Column int
+	}{
+		{"mark port == 22", 1, 6},
+		{"drop if port == 22", 1, 1},
+		{"mark if port == 22\nmark if prot == \"tcp\"", 2, 9},
+		{"mark if port == \"22\"", 1, 17},
+		{"mark if port contains 22", 1, 14},
+		{"mark if port = 22", 1, 14},
+		{"mark if source in \"10.0.0.0/33\"", 1, 19},
+		{"mark if sample matches \"(\"", 1, 24},
+		{"mark if port in list", 1, 17},
+		{"mark if port == 22 within 10", 1, 27},
+		{"mark if (port == 22", 1, 20},
+		{"mark if port == 22 port", 1, 20},
+		{"score if port == 22", 1, 7},
+		{"restrict 10 if port == 22", 1, 10},
+		{"define port = 22", 1, 8},
+		{"define a = 1\ndefine a = 2", 2, 8},
+		{"mark if count", 1, 14},
+		{"mark if tag in [\"a\", 1]", 1, 22},
+	}
+
+	for _, test := range tests {
+		_, err := Parse(test.Source)
+
+		if err == nil {
+			t.Errorf("Parse() failed to report error for '%s'", test.Source)
+
+			return
+		}
+
+		expected := ErrSyntax.Throw(test.Line, test.Column, "")
+
+		if !err.Is(ErrSyntax) || len(err.Error()) <= len(expected.Error()) ||
+			err.Error()[:len(expected.Error())] != expected.Error() {
+			t.Errorf("Parse() reported unexpected error for '%s'. "+
+				"Expecting position '%d:%d', got '%s'", test.Source,
+				test.Line, test.Column, err)
+
+			return
+		}
+	}
+}
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy, err := Parse(testPolicy)
+
+	if err != nil {
+		t.Errorf("Parse() failed due to error: %s", err)
+
+		return
+	}
+
+	// Nothing matched
+	ctx := getTestContext("198.51.100.1")
+
+	ctx.Protocol = "udp"
+
+	decision := policy.Evaluate(ctx)
+
+	if decision.Matched() || decision.Action != ACTION_NONE {
+		t.Errorf("Policy.Evaluate() matched unexpected lines: %v",
+			decision.Lines)
+
+		return
+	}
+
+	// Monitoring sources are skipped
+	for _, source := range []types.String{"10.1.2.3", "2001:db8::1",
+		"192.0.2.7"} {
+		ctx = getTestContext(source)
+
+		ctx.Tags = types.Strings{"mirai"}
+
+		decision = policy.Evaluate(ctx)
+
+		if decision.Action != ACTION_SKIP {
+			t.Errorf("Policy.Evaluate() failed to skip '%s'", source)
+
+			return
+		}
+	}
+
+	// Count within the window
+	ctx = getTestContext("198.51.100.1")
+	ctx.Port = 22
+	ctx.Protocol = "udp"
+	ctx.Count = 100
+	ctx.HitsWithin = func(d time.Duration) types.UInt32 {
+		if d != 10*time.Minute {
+			return 0
+		}
+
+		return 1
+	}
+
+	if policy.Evaluate(ctx).Action != ACTION_NONE {
+		t.Error("Policy.Evaluate() failed to count hits within the window")
+
+		return
+	}
+
+	ctx.HitsWithin = func(d time.Duration) types.UInt32 {
+		return 2
+	}
+
+	if policy.Evaluate(ctx).Action != ACTION_MARK {
+		t.Error("Policy.Evaluate() failed to mark the client")
+
+		return
+	}
+
+	// Tags are case insensitive, restrict duration is returned
+	ctx = getTestContext("198.51.100.1")
+	ctx.Protocol = "udp"
+	ctx.Tags = types.Strings{"botnet", "Mirai"}
+
+	decision = policy.Evaluate(ctx)
+
+	if decision.Action != ACTION_MARK ||
+		decision.Restrict != 7*24*time.Hour {
+		t.Errorf("Policy.Evaluate() failed to restrict the client. "+
+			"Expecting '%s', got '%s'", 7*24*time.Hour, decision.Restrict)
+
+		return
+	}
+
+	// Scores are summed
+	ctx = getTestContext("198.51.100.1")
+	ctx.Sample = []byte("GET / HTTP/1.1")
+	ctx.Signatures = types.Strings{"zgrab"}
+
+	decision = policy.Evaluate(ctx)
+
+	if decision.Action != ACTION_SCORE || decision.Score != 8 ||
+		len(decision.Lines) != 2 || decision.Lines[0] != 8 ||
+		decision.Lines[1] != 9 {
+		t.Errorf("Policy.Evaluate() failed to score the client. "+
+			"Expecting '%d', got '%d'", 8, decision.Score)
+
+		return
+	}
+
+	ctx.Marked = true
+	ctx.Score = 20
+
+	decision = policy.Evaluate(ctx)
+
+	if decision.Action != ACTION_MARK || decision.Score != 5 {
+		t.Errorf("Policy.Evaluate() failed to mark the client by score. "+
+			"Expecting '%d', got '%d'", 5, decision.Score)
+
+		return
+	}
+
+	// Statements separated by `;`
+	ctx = getTestContext("198.51.100.1")
+	ctx.Protocol = "udp"
+	ctx.Server, _ = types.ConvertIPFromString("192.0.2.1")
+	ctx.Port = 8080
+
+	if policy.Evaluate(ctx).Action != ACTION_SKIP {
+		t.Error("Policy.Evaluate() failed to skip the client")
+
+		return
+	}
+}
@@ -49,4 +49,7 @@ var (
 
 	ErrInvalidConnectionType *types.Error = types.NewError(
 		"Connection Type for client '%s' is invalid")
+
+	ErrInvalidPrefix *types.Error = types.NewError(
+		"Prefix '%s' is invalid")
 )
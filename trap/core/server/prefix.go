@@ -0,0 +1,140 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+)
+
+var (
+	ErrPrefixInfoInvalidLength *types.Error = types.NewError(
+		"Invalid length for unserialization of the Prefix Info")
+
+	ErrPrefixInfoInvalidBits *types.Error = types.NewError(
+		"Invalid prefix length '%d' for the Prefix Info")
+)
+
+const (
+	PREFIX_INFO_SEGMENT_NETWORK_BEGIN = 0
+	PREFIX_INFO_SEGMENT_NETWORK_END   = types.IP_ADDR_SLICE_LEN
+
+	PREFIX_INFO_SEGMENT_BITS_BEGIN = PREFIX_INFO_SEGMENT_NETWORK_END
+	PREFIX_INFO_SEGMENT_BITS_END   = PREFIX_INFO_SEGMENT_BITS_BEGIN + 2
+
+	PREFIX_INFO_LEN = PREFIX_INFO_SEGMENT_BITS_END
+)
+
+type PrefixInfo struct {
+	Network types.IP
+	Bits    types.UInt16
+}
+
+func ConvertPrefixInfo(network net.IPNet) PrefixInfo {
+	bits, _ := network.Mask.Size()
+
+	return PrefixInfo{
+		Network: types.ConvertIP(network.IP),
+		Bits:    types.UInt16(bits),
+	}
+}
+
+// MaxBits returns the address length of the family of the network
+func (p *PrefixInfo) MaxBits() types.UInt16 {
+	if p.Network.IP().To4() != nil {
+		return net.IPv4len * 8
+	}
+
+	return net.IPv6len * 8
+}
+
+func (p *PrefixInfo) IPNet() net.IPNet {
+	ip := p.Network.IP()
+
+	if ip4 := ip.To4(); ip4 != nil {
+		mask := net.CIDRMask(int(p.Bits), net.IPv4len*8)
+
+		return net.IPNet{
+			IP:   ip4.Mask(mask),
+			Mask: mask,
+		}
+	}
+
+	mask := net.CIDRMask(int(p.Bits), net.IPv6len*8)
+
+	return net.IPNet{
+		IP:   ip.Mask(mask),
+		Mask: mask,
+	}
+}
+
+func (p *PrefixInfo) CIDR() types.String {
+	network := p.IPNet()
+
+	return types.String(network.String())
+}
+
+func (p *PrefixInfo) IsEmpty() bool {
+	if p.Network.IsEmpty() || p.Bits == 0 {
+		return true
+	}
+
+	return false
+}
+
+func (p *PrefixInfo) Serialize() ([]byte, *types.Throw) {
+	result := []byte{}
+
+	bitsByte, bitsErr := p.Bits.Serialize()
+
+	if bitsErr != nil {
+		return []byte{}, bitsErr
+	}
+
+	result = append(result, p.Network[:]...)
+	result = append(result, bitsByte...)
+
+	return result, nil
+}
+
+func (p *PrefixInfo) Unserialize(data []byte) *types.Throw {
+	if len(data) != PREFIX_INFO_LEN {
+		return ErrPrefixInfoInvalidLength.Throw()
+	}
+
+	copy(p.Network[:],
+		data[PREFIX_INFO_SEGMENT_NETWORK_BEGIN:PREFIX_INFO_SEGMENT_NETWORK_END])
+
+	bitsErr := p.Bits.Unserialize(
+		data[PREFIX_INFO_SEGMENT_BITS_BEGIN:PREFIX_INFO_SEGMENT_BITS_END])
+
+	if bitsErr != nil {
+		return bitsErr
+	}
+
+	if p.Bits > p.MaxBits() {
+		return ErrPrefixInfoInvalidBits.Throw(p.Bits)
+	}
+
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"testing"
+)
+
+func TestPrefixInfoIPNet(t *testing.T) {
+	ip4, _ := types.ConvertIPFromString("192.168.1.27")
+	ip6, _ := types.ConvertIPFromString("2001:db8:1:2:3:4:5:6")
+
+	prefix4 := PrefixInfo{Network: ip4, Bits: 24}
+	prefix6 := PrefixInfo{Network: ip6, Bits: 64}
+
+	if prefix4.CIDR() != "192.168.1.0/24" {
+		t.Errorf("PrefixInfo.CIDR() failed to build IPv4 network. "+
+			"Expecting '%s', got '%s'", "192.168.1.0/24", prefix4.CIDR())
+
+		return
+	}
+
+	if prefix6.CIDR() != "2001:db8:1:2::/64" {
+		t.Errorf("PrefixInfo.CIDR() failed to build IPv6 network. "+
+			"Expecting '%s', got '%s'", "2001:db8:1:2::/64", prefix6.CIDR())
+
+		return
+	}
+
+	_, network, _ := net.ParseCIDR("10.1.0.0/16")
+
+	converted := ConvertPrefixInfo(*network)
+
+	if converted.Bits != 16 || converted.CIDR() != "10.1.0.0/16" {
+		t.Errorf("ConvertPrefixInfo() failed to convert network. "+
+			"Expecting '%s', got '%s'", "10.1.0.0/16", converted.CIDR())
+
+		return
+	}
+}
+
+func TestPrefixInfoSerialize(t *testing.T) {
+	ip, _ := types.ConvertIPFromString("192.168.1.0")
+
+	prefix := PrefixInfo{Network: ip, Bits: 24}
+
+	result, serErr := prefix.Serialize()
+
+	if serErr != nil {
+		t.Errorf("PrefixInfo.Serialize() failed due to error: %s", serErr)
+
+		return
+	}
+
+	if len(result) != PREFIX_INFO_LEN {
+		t.Errorf("PrefixInfo.Serialize() failed to serialize data. "+
+			"Expecting '%d' bytes, got '%d'", PREFIX_INFO_LEN, len(result))
+
+		return
+	}
+
+	unserialized := PrefixInfo{}
+
+	unserErr := unserialized.Unserialize(result)
+
+	if unserErr != nil {
+		t.Errorf("PrefixInfo.Unserialize() failed due to error: %s",
+			unserErr)
+
+		return
+	}
+
+	if !unserialized.Network.IsEqual(&ip) || unserialized.Bits != 24 {
+		t.Errorf("PrefixInfo.Unserialize() failed to unserialize data. "+
+			"Expecting '%s', got '%s'", prefix.CIDR(), unserialized.CIDR())
+
+		return
+	}
+
+	if unserialized.Unserialize(result[1:]) == nil {
+		t.Error("PrefixInfo.Unserialize() accepted data with invalid length")
+
+		return
+	}
+}
+
+func TestPrefixInfoUnserializeInvalidBits(t *testing.T) {
+	ip4, _ := types.ConvertIPFromString("192.168.1.0")
+	ip6, _ := types.ConvertIPFromString("2001:db8::")
+
+	tests := []struct {
+		prefix PrefixInfo
+		valid  bool
+	}{
+		{PrefixInfo{Network: ip4, Bits: 32}, true},
+		{PrefixInfo{Network: ip4, Bits: 33}, false},
+		{PrefixInfo{Network: ip6, Bits: 128}, true},
+		{PrefixInfo{Network: ip6, Bits: 200}, false},
+	}
+
+	for _, test := range tests {
+		data, _ := test.prefix.Serialize()
+
+		unserialized := PrefixInfo{}
+
+		unserErr := unserialized.Unserialize(data)
+
+		if (unserErr == nil) != test.valid {
+			t.Errorf("PrefixInfo.Unserialize() failed to check prefix "+
+				"length '%d' of '%s'", test.prefix.Bits,
+				test.prefix.Network.IP())
+
+			return
+		}
+	}
+}
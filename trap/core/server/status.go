@@ -32,6 +32,19 @@ type Status struct {
 	TotalMarked  types.UInt64
 	TotalHit     types.UInt64
 	TotalClients types.UInt64
+	TotalEvicted types.UInt64
+
+	MarkedClients types.UInt64
+	MemoryUsage   types.UInt64
+
+	ListenerErrors   types.UInt64
+	ListenerFailures types.UInt64
+	ListenerBroken   types.UInt64
+
+	EventQueueDepth types.UInt64
+	EventHandled    types.UInt64
+	EventFailed     types.UInt64
+	EventDropped    types.UInt64
 
 	Uptime time.Duration
 
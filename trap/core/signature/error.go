@@ -0,0 +1,55 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+)
+
+var (
+	ErrRuleNameEmpty *types.Error = types.NewError(
+		"Signature rule must have a name")
+
+	ErrRuleNameDuplicated *types.Error = types.NewError(
+		"Signature rule '%s' has already been defined")
+
+	ErrRuleNoMatcher *types.Error = types.NewError(
+		"Signature rule '%s' must have at least one matcher")
+
+	ErrInvalidAction *types.Error = types.NewError(
+		"Invalid action '%s' for signature rule '%s'")
+
+	ErrInvalidSeverity *types.Error = types.NewError(
+		"Invalid severity '%s' for signature rule '%s'")
+
+	ErrInvalidMatcherType *types.Error = types.NewError(
+		"Invalid matcher type '%s' for signature rule '%s'")
+
+	ErrInvalidMatcherTarget *types.Error = types.NewError(
+		"Invalid matcher target '%s' for signature rule '%s'")
+
+	ErrInvalidMatcherField *types.Error = types.NewError(
+		"Invalid matcher field '%s' for signature rule '%s'")
+
+	ErrInvalidMatcherValue *types.Error = types.NewError(
+		"Invalid matcher value '%s' for signature rule '%s': %s")
+)
@@ -0,0 +1,105 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"github.com/raincious/trap/trap/core/listen"
+	"github.com/raincious/trap/trap/core/types"
+
+	"bytes"
+	"regexp"
+)
+
+const (
+	MATCH_TARGET_INBOUND  = "inbound"
+	MATCH_TARGET_OUTBOUND = "outbound"
+)
+
+const (
+	MATCH_FIELD_PROTOCOL  = "protocol"
+	MATCH_FIELD_PORT      = "port"
+	MATCH_FIELD_SERVER_IP = "server_ip"
+)
+
+type matcher interface {
+	Match(c listen.ConnectionInfo, r listen.RespondedResult) bool
+}
+
+func payload(target types.String, r listen.RespondedResult) []byte {
+	if target == MATCH_TARGET_OUTBOUND {
+		return r.RespondedData
+	}
+
+	return r.ReceivedSample
+}
+
+// Matches when the payload contains the specified bytes
+type bytesMatcher struct {
+	target types.String
+	value  []byte
+}
+
+func (b bytesMatcher) Match(c listen.ConnectionInfo,
+	r listen.RespondedResult) bool {
+	return bytes.Contains(payload(b.target, r), b.value)
+}
+
+// Matches when the payload matches the regular expression
+type regexMatcher struct {
+	target types.String
+	regex  *regexp.Regexp
+}
+
+func (m regexMatcher) Match(c listen.ConnectionInfo,
+	r listen.RespondedResult) bool {
+	return m.regex.Match(payload(m.target, r))
+}
+
+// Matches when the connection is using the specified protocol
+type protocolMatcher struct {
+	protocol types.String
+}
+
+func (p protocolMatcher) Match(c listen.ConnectionInfo,
+	r listen.RespondedResult) bool {
+	return c.Type.Lower() == p.protocol
+}
+
+// Matches when the connection is made to the specified server port
+type portMatcher struct {
+	port types.UInt16
+}
+
+func (p portMatcher) Match(c listen.ConnectionInfo,
+	r listen.RespondedResult) bool {
+	return c.ServerAddress.Port == p.port
+}
+
+// Matches when the connection is made to the specified server address
+type serverIPMatcher struct {
+	ip types.IP
+}
+
+func (s serverIPMatcher) Match(c listen.ConnectionInfo,
+	r listen.RespondedResult) bool {
+	return c.ServerAddress.IP == s.ip
+}
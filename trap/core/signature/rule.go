@@ -0,0 +1,131 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"github.com/raincious/trap/trap/core/listen"
+	"github.com/raincious/trap/trap/core/types"
+)
+
+type Action int
+
+// Actions are ordered by their priority, when multiple rules matched,
+// the action with the highest priority will be taken
+const (
+	ACTION_SCORE Action = iota
+	ACTION_MARK
+	ACTION_IGNORE
+)
+
+var actionNames = map[types.String]Action{
+	"score":  ACTION_SCORE,
+	"mark":   ACTION_MARK,
+	"ignore": ACTION_IGNORE,
+}
+
+type Severity int
+
+const (
+	SEVERITY_INFO Severity = iota
+	SEVERITY_LOW
+	SEVERITY_MEDIUM
+	SEVERITY_HIGH
+	SEVERITY_CRITICAL
+)
+
+var severityNames = []types.String{
+	SEVERITY_INFO:     "info",
+	SEVERITY_LOW:      "low",
+	SEVERITY_MEDIUM:   "medium",
+	SEVERITY_HIGH:     "high",
+	SEVERITY_CRITICAL: "critical",
+}
+
+func (s Severity) String() types.String {
+	if int(s) < 0 || int(s) >= len(severityNames) {
+		return ""
+	}
+
+	return severityNames[s]
+}
+
+type Rule struct {
+	Name     types.String
+	Tags     types.Strings
+	Severity Severity
+	Action   Action
+	Score    types.UInt32
+
+	matchers []matcher
+}
+
+// Rule only matches when all of it's matchers are matched
+func (r *Rule) Match(c listen.ConnectionInfo,
+	res listen.RespondedResult) bool {
+	for _, m := range r.matchers {
+		if !m.Match(c, res) {
+			return false
+		}
+	}
+
+	return true
+}
+
+type Result struct {
+	Rules    []*Rule
+	Action   Action
+	Severity Severity
+	Score    types.UInt32
+}
+
+func (r Result) Matched() bool {
+	return len(r.Rules) > 0
+}
+
+func (r Result) Names() types.Strings {
+	names := types.Strings{}
+
+	for _, rule := range r.Rules {
+		names = append(names, rule.Name)
+	}
+
+	return names
+}
+
+func (r Result) Tags() types.Strings {
+	tags := types.Strings{}
+	added := map[types.String]bool{}
+
+	for _, rule := range r.Rules {
+		for _, tag := range rule.Tags {
+			if added[tag] {
+				continue
+			}
+
+			added[tag] = true
+
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
@@ -0,0 +1,292 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"github.com/raincious/trap/trap/core/listen"
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+)
+
+type rawMatcher struct {
+	Type   types.String `json:"type"`
+	Target types.String `json:"target"`
+	Value  types.String `json:"value"`
+}
+
+type rawRule struct {
+	Name     types.String   `json:"name"`
+	Tags     []types.String `json:"tags"`
+	Severity types.String   `json:"severity"`
+	Action   types.String   `json:"action"`
+	Score    types.UInt32   `json:"score"`
+	Match    []rawMatcher   `json:"match"`
+}
+
+type rawRules struct {
+	ScoreThershold types.UInt32 `json:"score_thershold"`
+	Rules          []rawRule    `json:"rules"`
+}
+
+type Rules struct {
+	ScoreThershold types.UInt32
+
+	rules []*Rule
+}
+
+func Load(filePath string) (*Rules, *types.Throw) {
+	content, err := ioutil.ReadFile(filePath)
+
+	if err != nil {
+		return nil, types.ConvertError(err)
+	}
+
+	return Parse(content)
+}
+
+func Parse(rulesStr []byte) (*Rules, *types.Throw) {
+	raw := rawRules{}
+
+	err := json.Unmarshal(rulesStr, &raw)
+
+	if err != nil {
+		return nil, types.ConvertError(err)
+	}
+
+	rules := &Rules{
+		ScoreThershold: raw.ScoreThershold,
+		rules:          []*Rule{},
+	}
+
+	names := map[types.String]bool{}
+
+	for _, rawRule := range raw.Rules {
+		rule, ruleErr := parseRule(rawRule)
+
+		if ruleErr != nil {
+			return nil, ruleErr
+		}
+
+		if names[rule.Name] {
+			return nil, ErrRuleNameDuplicated.Throw(rule.Name)
+		}
+
+		names[rule.Name] = true
+
+		rules.rules = append(rules.rules, rule)
+	}
+
+	return rules, nil
+}
+
+func parseRule(raw rawRule) (*Rule, *types.Throw) {
+	rule := &Rule{
+		Name:     raw.Name.Trim(),
+		Tags:     types.Strings{},
+		Score:    raw.Score,
+		matchers: []matcher{},
+	}
+
+	if rule.Name == "" {
+		return nil, ErrRuleNameEmpty.Throw()
+	}
+
+	for _, tag := range raw.Tags {
+		if tag.Trim() == "" {
+			continue
+		}
+
+		rule.Tags = append(rule.Tags, tag.Trim().Lower())
+	}
+
+	// Parse `Action`, default to score
+	if raw.Action != "" {
+		action, found := actionNames[raw.Action.Lower()]
+
+		if !found {
+			return nil, ErrInvalidAction.Throw(raw.Action, rule.Name)
+		}
+
+		rule.Action = action
+	}
+
+	// Parse `Severity`, default to info
+	if raw.Severity != "" {
+		found := false
+
+		for severity, severityName := range severityNames {
+			if severityName != raw.Severity.Lower() {
+				continue
+			}
+
+			rule.Severity = Severity(severity)
+			found = true
+
+			break
+		}
+
+		if !found {
+			return nil, ErrInvalidSeverity.Throw(raw.Severity, rule.Name)
+		}
+	}
+
+	for _, rawM := range raw.Match {
+		m, mErr := parseMatcher(rule.Name, rawM)
+
+		if mErr != nil {
+			return nil, mErr
+		}
+
+		rule.matchers = append(rule.matchers, m)
+	}
+
+	if len(rule.matchers) <= 0 {
+		return nil, ErrRuleNoMatcher.Throw(rule.Name)
+	}
+
+	return rule, nil
+}
+
+func parseMatcher(name types.String, raw rawMatcher) (matcher, *types.Throw) {
+	target := raw.Target.Lower()
+
+	switch target {
+	case "":
+		target = MATCH_TARGET_INBOUND
+
+	case MATCH_TARGET_INBOUND:
+	case MATCH_TARGET_OUTBOUND:
+
+	default:
+		return nil, ErrInvalidMatcherTarget.Throw(raw.Target, name)
+	}
+
+	switch raw.Type.Lower() {
+	case "bytes":
+		if raw.Value == "" {
+			return nil, ErrInvalidMatcherValue.Throw(raw.Value, name,
+				"value can't be empty")
+		}
+
+		return bytesMatcher{
+			target: target,
+			value:  raw.Value.Bytes(),
+		}, nil
+
+	case "hex":
+		value, err := hex.DecodeString(raw.Value.String())
+
+		if err != nil {
+			return nil, ErrInvalidMatcherValue.Throw(raw.Value, name, err)
+		}
+
+		if len(value) <= 0 {
+			return nil, ErrInvalidMatcherValue.Throw(raw.Value, name,
+				"value can't be empty")
+		}
+
+		return bytesMatcher{
+			target: target,
+			value:  value,
+		}, nil
+
+	case "regex":
+		regex, err := regexp.Compile(raw.Value.String())
+
+		if err != nil {
+			return nil, ErrInvalidMatcherValue.Throw(raw.Value, name, err)
+		}
+
+		return regexMatcher{
+			target: target,
+			regex:  regex,
+		}, nil
+
+	case MATCH_FIELD_PROTOCOL:
+		return protocolMatcher{
+			protocol: raw.Value.Trim().Lower(),
+		}, nil
+
+	case MATCH_FIELD_PORT:
+		port := raw.Value.Trim().UInt16()
+
+		if port == 0 {
+			return nil, ErrInvalidMatcherValue.Throw(raw.Value, name,
+				"invalid port number")
+		}
+
+		return portMatcher{
+			port: port,
+		}, nil
+
+	case MATCH_FIELD_SERVER_IP:
+		ip, err := types.ConvertIPFromString(raw.Value.Trim())
+
+		if err != nil {
+			return nil, ErrInvalidMatcherValue.Throw(raw.Value, name, err)
+		}
+
+		return serverIPMatcher{
+			ip: ip,
+		}, nil
+	}
+
+	return nil, ErrInvalidMatcherType.Throw(raw.Type, name)
+}
+
+func (r *Rules) Len() int {
+	return len(r.rules)
+}
+
+// Evaluate all rules against the connection and it's responded result
+func (r *Rules) Match(c listen.ConnectionInfo,
+	res listen.RespondedResult) Result {
+	result := Result{
+		Rules: []*Rule{},
+	}
+
+	for _, rule := range r.rules {
+		if !rule.Match(c, res) {
+			continue
+		}
+
+		result.Rules = append(result.Rules, rule)
+
+		if rule.Action > result.Action {
+			result.Action = rule.Action
+		}
+
+		if rule.Severity > result.Severity {
+			result.Severity = rule.Severity
+		}
+
+		if rule.Action == ACTION_SCORE {
+			result.Score += rule.Score
+		}
+	}
+
+	return result
+}
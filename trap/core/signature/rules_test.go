@@ -0,0 +1,280 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package signature
+
+import (
+	"github.com/raincious/trap/trap/core/listen"
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+)
+
+var testRules = []byte(`{
+	"score_thershold": 10,
+	"rules": [
+		{
+			"name": "masscan",
+			"tags": ["Scanner", "masscan"],
+			"severity": "low",
+			"action": "mark",
+			"match": [
+				{"type": "bytes", "value": "masscan"}
+			]
+		},
+		{
+			"name": "log4shell",
+			"tags": ["exploit", "log4shell"],
+			"severity": "critical",
+			"action": "score",
+			"score": 6,
+			"match": [
+				{"type": "regex", "value": "(?i)\\$\\{jndi:(ldap|rmi|dns)"}
+			]
+		},
+		{
+			"name": "http-scanner",
+			"tags": ["scanner"],
+			"action": "score",
+			"score": 2,
+			"match": [
+				{"type": "protocol", "value": "TCP"},
+				{"type": "port", "value": "8080"},
+				{"type": "hex", "value": "474554"}
+			]
+		},
+		{
+			"name": "health-check",
+			"action": "ignore",
+			"match": [
+				{"type": "server_ip", "value": "127.0.0.1"},
+				{"type": "bytes", "target": "outbound", "value": "OK"}
+			]
+		}
+	]
+}`)
+
+func getTestConnection(port types.UInt16,
+	proto types.String) listen.ConnectionInfo {
+	serverIP, _ := types.ConvertIPFromString("192.0.2.1")
+
+	return listen.ConnectionInfo{
+		ServerAddress: types.IPAddress{
+			IP:   serverIP,
+			Port: port,
+		},
+		Type: proto,
+	}
+}
+
+func TestParse(t *testing.T) {
+	rules, err := Parse(testRules)
+
+	if err != nil {
+		t.Errorf("Parse() failed due to error: %s", err)
+
+		return
+	}
+
+	if rules.Len() != 4 {
+		t.Errorf("Parse() failed to parse all rules. Expecting '%d', got '%d'",
+			4, rules.Len())
+
+		return
+	}
+
+	if rules.ScoreThershold != 10 {
+		t.Errorf("Parse() failed to parse score thershold. "+
+			"Expecting '%d', got '%d'", 10, rules.ScoreThershold)
+
+		return
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		Rules []byte
+		Error *types.Error
+	}{
+		{
+			Rules: []byte(`{"rules": [{"match": [{"type": "bytes", ` +
+				`"value": "a"}]}]}`),
+			Error: ErrRuleNameEmpty,
+		},
+		{
+			Rules: []byte(`{"rules": [{"name": "a"}]}`),
+			Error: ErrRuleNoMatcher,
+		},
+		{
+			Rules: []byte(`{"rules": [{"name": "a", "action": "drop", ` +
+				`"match": [{"type": "bytes", "value": "a"}]}]}`),
+			Error: ErrInvalidAction,
+		},
+		{
+			Rules: []byte(`{"rules": [{"name": "a", "severity": "huge", ` +
+				`"match": [{"type": "bytes", "value": "a"}]}]}`),
+			Error: ErrInvalidSeverity,
+		},
+		{
+			Rules: []byte(`{"rules": [{"name": "a", ` +
+				`"match": [{"type": "glob", "value": "a"}]}]}`),
+			Error: ErrInvalidMatcherType,
+		},
+		{
+			Rules: []byte(`{"rules": [{"name": "a", ` +
+				`"match": [{"type": "regex", "value": "("}]}]}`),
+			Error: ErrInvalidMatcherValue,
+		},
+		{
+			Rules: []byte(`{"rules": [{"name": "a", "match": ` +
+				`[{"type": "bytes", "target": "body", "value": "a"}]}]}`),
+			Error: ErrInvalidMatcherTarget,
+		},
+		{
+			Rules: []byte(`{"rules": [` +
+				`{"name": "a", "match": [{"type": "bytes", "value": "a"}]},` +
+				`{"name": "a", "match": [{"type": "bytes", "value": "b"}]}` +
+				`]}`),
+			Error: ErrRuleNameDuplicated,
+		},
+	}
+
+	for testIdx, test := range tests {
+		_, err := Parse(test.Rules)
+
+		if err == nil || !err.Is(test.Error) {
+			t.Errorf("Parse() failed to report expected error for test "+
+				"'%d', got '%s'", testIdx, err)
+
+			return
+		}
+	}
+}
+
+func TestRulesMatch(t *testing.T) {
+	rules, err := Parse(testRules)
+
+	if err != nil {
+		t.Errorf("Parse() failed due to error: %s", err)
+
+		return
+	}
+
+	// Nothing matched
+	result := rules.Match(getTestConnection(80, "tcp"),
+		listen.RespondedResult{
+			ReceivedSample: []byte("Hello"),
+		})
+
+	if result.Matched() {
+		t.Errorf("Rules.Match() matched unexpected rules: %s",
+			result.Names())
+
+		return
+	}
+
+	// Only score rules matched
+	result = rules.Match(getTestConnection(8080, "tcp"),
+		listen.RespondedResult{
+			ReceivedSample: []byte("GET /?q=${JNDI:ldap://x/a} HTTP/1.1"),
+		})
+
+	if len(result.Rules) != 2 {
+		t.Errorf("Rules.Match() failed to match rules. "+
+			"Expecting '%d', got '%d'", 2, len(result.Rules))
+
+		return
+	}
+
+	if result.Action != ACTION_SCORE {
+		t.Errorf("Rules.Match() picked unexpected action. "+
+			"Expecting '%d', got '%d'", ACTION_SCORE, result.Action)
+
+		return
+	}
+
+	if result.Score != 8 {
+		t.Errorf("Rules.Match() failed to sum the score. "+
+			"Expecting '%d', got '%d'", 8, result.Score)
+
+		return
+	}
+
+	if result.Severity != SEVERITY_CRITICAL {
+		t.Errorf("Rules.Match() picked unexpected severity. "+
+			"Expecting '%s', got '%s'", SEVERITY_CRITICAL.String(),
+			result.Severity.String())
+
+		return
+	}
+
+	tags := result.Tags()
+
+	if len(tags) != 3 || tags[0] != "exploit" || tags[1] != "log4shell" ||
+		tags[2] != "scanner" {
+		t.Errorf("Result.Tags() returns unexpected tags: %s", tags)
+
+		return
+	}
+
+	// Mark overrides score
+	result = rules.Match(getTestConnection(8080, "tcp"),
+		listen.RespondedResult{
+			ReceivedSample: []byte("GET / HTTP/1.0\r\nUser-Agent: masscan"),
+		})
+
+	if result.Action != ACTION_MARK || len(result.Rules) != 2 {
+		t.Errorf("Rules.Match() picked unexpected action. "+
+			"Expecting '%d', got '%d'", ACTION_MARK, result.Action)
+
+		return
+	}
+
+	// Protocol must be matched as well
+	result = rules.Match(getTestConnection(8080, "udp"),
+		listen.RespondedResult{
+			ReceivedSample: []byte("GET / HTTP/1.0"),
+		})
+
+	if result.Matched() {
+		t.Errorf("Rules.Match() matched unexpected rules: %s",
+			result.Names())
+
+		return
+	}
+
+	// Ignore overrides everything
+	localConn := getTestConnection(80, "tcp")
+
+	localConn.ServerAddress.IP, _ = types.ConvertIPFromString("127.0.0.1")
+
+	result = rules.Match(localConn, listen.RespondedResult{
+		ReceivedSample: []byte("masscan"),
+		RespondedData:  []byte("OK"),
+	})
+
+	if result.Action != ACTION_IGNORE {
+		t.Errorf("Rules.Match() picked unexpected action. "+
+			"Expecting '%d', got '%d'", ACTION_IGNORE, result.Action)
+
+		return
+	}
+}
@@ -22,52 +22,186 @@
 package status
 
 import (
+	"github.com/raincious/trap/trap/core/status/password"
 	"github.com/raincious/trap/trap/core/types"
+
+	"crypto/subtle"
+	"strings"
+)
+
+const (
+	// Name of the accounts which only has a plaintext password
+	LEGACY_ACCOUNT_NAME = "legacy"
+
+	// Compared against when the user is not found, so the unknown users
+	// takes as long as the known ones to fail
+	dummyPasswordHash = "$2b$10$au6OaENnVOTRhSj0AyKoduanVXal4Vd3/Nkwvm5vrCqti2S6X3Zxu"
 )
 
 type Account struct {
+	name       types.String
+	secret     types.String
+	legacy     bool
 	permission Permission
 }
 
+func (a Account) Name() types.String {
+	return a.name
+}
+
+// Legacy returns true when the account is identified by a plaintext
+// password instead of an user name and a password hash
+func (a Account) Legacy() bool {
+	return a.legacy
+}
+
 func (a Account) Allowed(name types.String) bool {
 	return a.permission.Allowed(name)
 }
 
+func (a Account) Permission() Permission {
+	return a.permission
+}
+
 func (a Account) Permissions() map[types.String]bool {
 	return a.permission.All()
 }
 
-type Accounts map[types.String]*Account
+// Accounts holds the users of the status interface. Users are found by
+// their name, and legacy accounts are found by their plaintext password
+type Accounts struct {
+	users  map[types.String]*Account
+	legacy []*Account
+}
+
+func newAccount(name types.String, secret types.String, legacy bool,
+	permissions []types.String) (*Account, *types.Throw) {
+	newAccount := &Account{
+		name:       name,
+		secret:     secret,
+		legacy:     legacy,
+		permission: Permission{},
+	}
+
+	for _, permissionName := range permissions {
+		authErr := newAccount.permission.Authorize(permissionName)
+
+		if authErr != nil {
+			return nil, authErr
+		}
+	}
+
+	return newAccount, nil
+}
 
-func (a Accounts) Get(pass types.String) (*Account, *types.Throw) {
-	if _, ok := a[pass]; !ok {
-		return nil, ErrAccountNotFound.Throw(pass)
+func (a *Accounts) Get(name types.String) (*Account, *types.Throw) {
+	if _, ok := a.users[name]; !ok {
+		return nil, ErrAccountNotFound.Throw(name)
 	}
 
-	return a[pass], nil
+	return a.users[name], nil
 }
 
-func (a Accounts) Register(
+// Add an user with it's bcrypt password hash
+func (a *Accounts) Add(name types.String, hash types.String,
+	permissions []types.String) (*Account, *types.Throw) {
+	if name == "" || strings.Contains(name.String(), ":") {
+		return nil, ErrAccountInvalidName.Throw(name)
+	}
+
+	hashErr := password.Check(hash)
+
+	if hashErr != nil {
+		return nil, hashErr
+	}
+
+	if _, ok := a.users[name]; ok {
+		return nil, ErrAccountAlreadyExisted.Throw(name)
+	}
+
+	account, accountErr := newAccount(name, hash, false, permissions)
+
+	if accountErr != nil {
+		return nil, accountErr
+	}
+
+	if a.users == nil {
+		a.users = map[types.String]*Account{}
+	}
+
+	a.users[name] = account
+
+	return account, nil
+}
+
+// Register a legacy account which is identified by the plaintext password
+func (a *Accounts) Register(
 	pass types.String, permissions []types.String) (*Account, *types.Throw) {
-	testAccount, testErr := a.Get(pass)
+	if a.findLegacy(pass) != nil {
+		return nil, ErrAccountAlreadyExisted.Throw(LEGACY_ACCOUNT_NAME)
+	}
 
-	if testErr != nil && !testErr.Is(ErrAccountNotFound) {
-		return nil, testErr
+	newAccount, accountErr := newAccount(
+		LEGACY_ACCOUNT_NAME, pass, true, permissions)
+
+	if accountErr != nil {
+		return nil, accountErr
 	}
 
-	if testAccount != nil {
-		return nil, ErrAccountAlreadyExisted.Throw(pass)
+	a.legacy = append(a.legacy, newAccount)
+
+	return newAccount, nil
+}
+
+// Compare the password with every legacy account, always in constant time
+func (a *Accounts) findLegacy(pass types.String) *Account {
+	var found *Account = nil
+
+	for _, account := range a.legacy {
+		if subtle.ConstantTimeCompare(account.secret.Bytes(),
+			pass.Bytes()) != 1 {
+			continue
+		}
+
+		found = account
 	}
 
-	newAccount := &Account{
-		permission: Permission{},
+	return found
+}
+
+// Authenticate finds the account by the user name and password. When the
+// name is empty, the password is matched against the legacy accounts, then
+// treated as `name:password` so clients which can only send a password
+// can still login as an user
+func (a *Accounts) Authenticate(name types.String,
+	pass types.String) (*Account, *types.Throw) {
+	if name == "" {
+		legacy := a.findLegacy(pass)
+
+		if legacy != nil {
+			return legacy, nil
+		}
+
+		splitedName, splitedPass, splited := strings.Cut(pass.String(), ":")
+
+		if !splited {
+			return nil, ErrAccountAuthFailed.Throw(LEGACY_ACCOUNT_NAME)
+		}
+
+		name, pass = types.String(splitedName), types.String(splitedPass)
 	}
 
-	for _, permissionName := range permissions {
-		newAccount.permission.Authorize(permissionName)
+	account, found := a.users[name]
+
+	if !found {
+		password.Compare(dummyPasswordHash, pass)
+
+		return nil, ErrAccountAuthFailed.Throw(name)
 	}
 
-	a[pass] = newAccount
+	if password.Compare(account.secret, pass) != nil {
+		return nil, ErrAccountAuthFailed.Throw(name)
+	}
 
-	return a[pass], nil
+	return account, nil
 }
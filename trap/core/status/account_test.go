@@ -22,6 +22,8 @@
 package status
 
 import (
+	"github.com/raincious/trap/trap/core/status/password"
+	"github.com/raincious/trap/trap/core/status/permission"
 	"github.com/raincious/trap/trap/core/types"
 
 	"testing"
@@ -31,7 +33,7 @@ func TestAccountsRegister(t *testing.T) {
 	accounts := Accounts{}
 
 	acc, regErr := accounts.Register("The test pass",
-		[]types.String{"Test permission 1", "Test permission 2"})
+		[]types.String{"clients", "logs:read"})
 
 	if regErr != nil {
 		t.Errorf("Accounts.Register() failed register account due to error: %s",
@@ -41,8 +43,9 @@ func TestAccountsRegister(t *testing.T) {
 	}
 
 	// Let's by the way test the Account a little bit here
-	if !acc.Allowed("Test permission 1") ||
-		!acc.Allowed("Test permission 2") ||
+	if !acc.Allowed("clients:write") ||
+		!acc.Allowed("logs:read") ||
+		acc.Allowed("logs:write") ||
 		len(acc.Permissions()) == 0 {
 		t.Error("Accounts.Register() didn't succefully initialize the Account")
 
@@ -50,7 +53,7 @@ func TestAccountsRegister(t *testing.T) {
 	}
 
 	acc, regErr = accounts.Register("The test pass",
-		[]types.String{"Test permission 3", "Test permission 4"})
+		[]types.String{"sync:read"})
 
 	if regErr == nil || !regErr.Is(ErrAccountAlreadyExisted) {
 		t.Errorf("Accounts.Register() failed register account due to error: %s",
@@ -69,17 +72,19 @@ func TestAccountsRegister(t *testing.T) {
 func TestAccountsGet(t *testing.T) {
 	accounts := Accounts{}
 
-	regAcc, regErr := accounts.Register("The test pass",
-		[]types.String{"Test permission 1", "Test permission 2"})
+	hash, _ := password.Hash("The test pass", password.MIN_COST)
 
-	if regErr != nil {
-		t.Errorf("Accounts.Register() failed to register account due to error: %s",
-			regErr)
+	addAcc, addErr := accounts.Add("tester", hash,
+		[]types.String{"clients:read", "sessions:read"})
+
+	if addErr != nil {
+		t.Errorf("Accounts.Add() failed to add account due to error: %s",
+			addErr)
 
 		return
 	}
 
-	getAcc, getErr := accounts.Get("The test pass")
+	getAcc, getErr := accounts.Get("tester")
 
 	if getErr != nil {
 		t.Errorf("Accounts.Get() failed to get the account due to error: %s",
@@ -88,7 +93,7 @@ func TestAccountsGet(t *testing.T) {
 		return
 	}
 
-	if getAcc != regAcc {
+	if getAcc != addAcc || getAcc.Name() != "tester" {
 		t.Error("Accounts.Get() failed to get the correct Account")
 
 		return
@@ -108,4 +113,83 @@ func TestAccountsGet(t *testing.T) {
 
 		return
 	}
+
+	// Unknown permissions can't be granted
+	_, addErr = accounts.Add("unknown", hash,
+		[]types.String{"Test permission"})
+
+	if addErr == nil || !addErr.Is(permission.ErrUnknownPermission) {
+		t.Errorf("Accounts.Add() failed to reject unknown permission. "+
+			"Got '%s'", addErr)
+
+		return
+	}
+
+	// Plaintext passwords can't be used as the hash
+	_, addErr = accounts.Add("plain", "The test pass", []types.String{})
+
+	if addErr == nil || !addErr.Is(password.ErrInvalidHash) {
+		t.Errorf("Accounts.Add() failed to reject plaintext password. "+
+			"Got '%s'", addErr)
+
+		return
+	}
+}
+
+func TestAccountsAuthenticate(t *testing.T) {
+	accounts := Accounts{}
+
+	hash, _ := password.Hash("The test pass", password.MIN_COST)
+
+	userAcc, _ := accounts.Add("tester", hash, []types.String{})
+	legacyAcc, _ := accounts.Register("The legacy pass", []types.String{})
+
+	authAcc, authErr := accounts.Authenticate("tester", "The test pass")
+
+	if authErr != nil || authAcc != userAcc {
+		t.Errorf("Accounts.Authenticate() failed to authenticate user. "+
+			"Got '%s'", authErr)
+
+		return
+	}
+
+	authAcc, authErr = accounts.Authenticate("", "The legacy pass")
+
+	if authErr != nil || authAcc != legacyAcc || !authAcc.Legacy() {
+		t.Errorf("Accounts.Authenticate() failed to authenticate legacy "+
+			"account. Got '%s'", authErr)
+
+		return
+	}
+
+	authAcc, authErr = accounts.Authenticate("", "tester:The test pass")
+
+	if authErr != nil || authAcc != userAcc {
+		t.Errorf("Accounts.Authenticate() failed to authenticate user "+
+			"without name. Got '%s'", authErr)
+
+		return
+	}
+
+	for _, cred := range [][2]types.String{
+		{"tester", "The legacy pass"},
+		{"nobody", "The test pass"},
+		{"", "The test pass"},
+		{"", "nobody:The test pass"},
+	} {
+		authAcc, authErr = accounts.Authenticate(cred[0], cred[1])
+
+		if authErr == nil || !authErr.Is(ErrAccountAuthFailed) {
+			t.Errorf("Accounts.Authenticate() failed to reject user '%s'",
+				cred[0])
+
+			return
+		}
+
+		if authAcc != nil {
+			t.Error("Accounts.Authenticate() mistakenly filled the Account")
+
+			return
+		}
+	}
 }
@@ -22,5 +22,10 @@
 package status
 
 const (
-	STATUS_SERVER_SESSION_KEY_HEADER = "X-Trap-Token"
+	STATUS_SERVER_SESSION_KEY_HEADER  = "X-Trap-Token"
+	STATUS_SERVER_LOG_CURSOR_HEADER   = "X-Trap-Log-Cursor"
+	STATUS_SERVER_AUTH_HEADER         = "Authorization"
+	STATUS_SERVER_AUTH_BEARER         = "Bearer "
+	STATUS_SERVER_REQUEST_ID_HEADER   = "X-Trap-Request-ID"
+	STATUS_SERVER_AUDIT_CURSOR_HEADER = "X-Trap-Audit-Cursor"
 )
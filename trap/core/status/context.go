@@ -0,0 +1,108 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type contextKey struct{}
+
+// Context carries the state of a single request. Controllers are shared
+// by all requests of their route, so anything about the user must be kept
+// in here instead of the controller
+type Context struct {
+	ID      types.String
+	Started time.Time
+	Session *Session
+
+	// What the request did and to what, filled by the controller for the
+	// audit log
+	Action types.String
+	Target types.String
+
+	// The error responded to the client
+	Error *types.Throw
+}
+
+// Account returns the account of the session, nil when there is no
+// session or the session is created by an API token
+func (c *Context) Account() *Account {
+	if c.Session == nil {
+		return nil
+	}
+
+	return c.Session.Account()
+}
+
+func newRequestID() types.String {
+	rBytes := make([]byte, 8)
+
+	_, rErr := rand.Read(rBytes)
+
+	if rErr != nil {
+		return types.String(strconv.FormatInt(time.Now().UnixNano(), 16))
+	}
+
+	return types.String(hex.EncodeToString(rBytes))
+}
+
+// NewContext creates a new Context and attaches it to the request
+func NewContext(r *http.Request) (*Context, *http.Request) {
+	ctx := &Context{
+		ID:      newRequestID(),
+		Started: time.Now(),
+	}
+
+	return ctx, r.WithContext(
+		context.WithValue(r.Context(), contextKey{}, ctx))
+}
+
+// RequestContext returns the Context of the request, nil if the request
+// is not dispatched by the Mux
+func RequestContext(r *http.Request) *Context {
+	ctx, ok := r.Context().Value(contextKey{}).(*Context)
+
+	if !ok {
+		return nil
+	}
+
+	return ctx
+}
+
+// WithContext is the middleware which creates the Context for the request
+func WithContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, ctxRequest := NewContext(r)
+
+		w.Header().Set(STATUS_SERVER_REQUEST_ID_HEADER, ctx.ID.String())
+
+		next.ServeHTTP(w, ctxRequest)
+	})
+}
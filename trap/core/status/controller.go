@@ -36,6 +36,6 @@ type Controller interface {
 	Options(http.ResponseWriter, *http.Request)
 
 	Init() *types.Throw
-	Before(http.ResponseWriter, *http.Request) *types.Throw
+	Middlewares() []Middleware
 	Error(ErrorRespond, http.ResponseWriter, *http.Request)
 }
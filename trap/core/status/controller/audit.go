@@ -0,0 +1,117 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+type Audit struct {
+	SessionedJSON
+
+	QueryAudit func(audit.Query) audit.QueryResult
+}
+
+// ParseAuditQuery reads the query from the parameters of the request:
+// `actor`, `ip`, `action`, `target`, `result`, `since`, `until` (Unix
+// time or RFC 3339), `limit` and `cursor`
+func ParseAuditQuery(r *http.Request) (audit.Query, *types.Throw) {
+	var parseErr error = nil
+
+	params := r.URL.Query()
+	query := audit.Query{
+		Actor:  types.String(params.Get("actor")),
+		IP:     types.String(params.Get("ip")),
+		Action: types.String(params.Get("action")),
+		Target: types.String(params.Get("target")),
+		Result: types.String(params.Get("result")),
+	}
+
+	if since := params.Get("since"); since != "" {
+		query.Since, parseErr = parseLogTime(since)
+	}
+
+	if until := params.Get("until"); until != "" && parseErr == nil {
+		query.Until, parseErr = parseLogTime(until)
+	}
+
+	if limit := params.Get("limit"); limit != "" && parseErr == nil {
+		query.Limit, parseErr = strconv.Atoi(limit)
+	}
+
+	if cursor := params.Get("cursor"); cursor != "" && parseErr == nil {
+		query.Cursor, parseErr = strconv.ParseUint(cursor, 10, 64)
+	}
+
+	if parseErr != nil || query.Limit < 0 {
+		return query, status.ErrStatusControllerInvalidParameter.Throw()
+	}
+
+	return query, nil
+}
+
+// Get responds the matched audit entries, newest first. The cursor of
+// the next page will be set in the `X-Trap-Audit-Cursor` header when
+// there are more
+func (a *Audit) Get(w http.ResponseWriter, r *http.Request) {
+	if !a.Authorized(permission.AUDIT_READ, w, r) {
+		return
+	}
+
+	query, queryErr := ParseAuditQuery(r)
+
+	if queryErr != nil {
+		a.Error(status.ErrorRespond{
+			Code:  400,
+			Error: queryErr,
+		}, w, r)
+
+		return
+	}
+
+	result := a.QueryAudit(query)
+
+	jsonData, jsonErr := json.Marshal(result.Entries)
+
+	if jsonErr != nil {
+		a.Error(status.ErrorRespond{
+			Code:  500,
+			Error: types.ConvertError(jsonErr),
+		}, w, r)
+
+		return
+	}
+
+	if result.Cursor > 0 {
+		w.Header().Set(status.STATUS_SERVER_AUDIT_CURSOR_HEADER,
+			strconv.FormatUint(result.Cursor, 10))
+	}
+
+	a.Write(200, jsonData, w, r)
+}
@@ -34,7 +34,8 @@ type Auth struct {
 	JSON
 
 	Verify func(net.IP, types.String) (*status.Session, *types.Throw)
-	Auth   func(net.IP, types.String) (*status.Session, *types.Throw)
+	Auth   func(net.IP, types.String,
+		types.String) (*status.Session, *types.Throw)
 }
 
 func (a *Auth) Get(w http.ResponseWriter, r *http.Request) {
@@ -63,6 +64,7 @@ func (a *Auth) Get(w http.ResponseWriter, r *http.Request) {
 
 	responseData, jResErr := json.Marshal(status.AuthRespond{
 		Token:       sessionInfo.Key,
+		User:        sessionInfo.Account().Name(),
 		Permissions: sessionInfo.Account().Permissions(),
 	})
 
@@ -75,7 +77,7 @@ func (a *Auth) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.WriteGZIP(200, responseData, w, r)
+	a.Write(200, responseData, w, r)
 }
 
 func (a *Auth) Post(w http.ResponseWriter, r *http.Request) {
@@ -105,7 +107,8 @@ func (a *Auth) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	sessionData, vErr := a.Auth(userIP, authField.Password)
+	sessionData, vErr := a.Auth(userIP, authField.User,
+		authField.Password)
 
 	if vErr != nil {
 		a.Error(status.ErrorRespond{
@@ -118,6 +121,7 @@ func (a *Auth) Post(w http.ResponseWriter, r *http.Request) {
 
 	responseData, jResErr := json.Marshal(status.AuthRespond{
 		Token:       sessionData.Key,
+		User:        sessionData.Account().Name(),
 		Permissions: sessionData.Account().Permissions(),
 	})
 
@@ -130,5 +134,5 @@ func (a *Auth) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.WriteGZIP(200, responseData, w, r)
+	a.Write(200, responseData, w, r)
 }
@@ -0,0 +1,220 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/blocklist"
+	"github.com/raincious/trap/trap/core/client"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
+	"github.com/raincious/trap/trap/core/types"
+
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Blocklist renders marked clients in the formats of other firewalls.
+// Devices which can only fetch an URL can give the API token by the
+// `token` parameter
+type Blocklist struct {
+	Sessioned
+
+	// Serve the blocklist without authorization
+	Public bool
+
+	GetClients func() []client.ClientExport
+}
+
+// BlocklistQuery is the format and the filter of the blocklist
+type BlocklistQuery struct {
+	Format types.String
+	Name   types.String
+	Filter blocklist.Filter
+}
+
+// Use the `token` parameter as the API token when no credential is given
+func blocklistToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+
+		if r.Header.Get(status.STATUS_SERVER_AUTH_HEADER) == "" &&
+			r.Header.Get(status.STATUS_SERVER_SESSION_KEY_HEADER) == "" &&
+			token != "" {
+			r.Header.Set(status.STATUS_SERVER_AUTH_HEADER,
+				status.STATUS_SERVER_AUTH_BEARER+token)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (b *Blocklist) Middlewares() []status.Middleware {
+	if b.Public {
+		return b.Default.Middlewares()
+	}
+
+	return append([]status.Middleware{blocklistToken},
+		b.Sessioned.Middlewares()...)
+}
+
+// Values of the parameter, which can be given multiple times or be
+// separated by commas
+func listParam(r *http.Request, name string) types.Strings {
+	result := types.Strings{}
+
+	for _, value := range r.URL.Query()[name] {
+		for _, item := range types.String(value).ExplodeWith(",") {
+			if item.Trim() == "" {
+				continue
+			}
+
+			result = append(result, item.Trim())
+		}
+	}
+
+	return result
+}
+
+// ParseBlocklistQuery reads the query from the parameters of the
+// request: `format`, `name`, `tag`, `port` and `max_age` (a duration
+// like "24h", or seconds)
+func ParseBlocklistQuery(r *http.Request) (BlocklistQuery, *types.Throw) {
+	params := r.URL.Query()
+	query := BlocklistQuery{
+		Format: types.String(params.Get("format")),
+		Name:   types.String(params.Get("name")),
+		Filter: blocklist.Filter{
+			Tags:  listParam(r, "tag"),
+			Ports: []types.UInt16{},
+		},
+	}
+
+	if query.Format == "" {
+		query.Format = blocklist.FORMAT_PLAIN
+	}
+
+	if query.Name == "" {
+		query.Name = blocklist.DEFAULT_NAME
+	}
+
+	knownFormat := false
+
+	for _, format := range blocklist.Formats {
+		if format == query.Format {
+			knownFormat = true
+
+			break
+		}
+	}
+
+	if !knownFormat {
+		return query, blocklist.ErrUnknownFormat.Throw(query.Format)
+	}
+
+	nameErr := blocklist.CheckName(query.Name)
+
+	if nameErr != nil {
+		return query, nameErr
+	}
+
+	for _, port := range listParam(r, "port") {
+		portNum, portErr := strconv.ParseUint(port.String(), 10, 16)
+
+		if portErr != nil || portNum == 0 {
+			return query, status.ErrStatusControllerInvalidParameter.Throw()
+		}
+
+		query.Filter.Ports = append(query.Filter.Ports,
+			types.UInt16(portNum))
+	}
+
+	if maxAge := params.Get("max_age"); maxAge != "" {
+		seconds, secondsErr := strconv.ParseUint(maxAge, 10, 32)
+
+		if secondsErr == nil {
+			query.Filter.MaxAge = time.Duration(seconds) * time.Second
+		} else {
+			duration, durationErr := time.ParseDuration(maxAge)
+
+			if durationErr != nil || duration < 0 {
+				return query,
+					status.ErrStatusControllerInvalidParameter.Throw()
+			}
+
+			query.Filter.MaxAge = duration
+		}
+	}
+
+	return query, nil
+}
+
+// Get responds the blocklist. `304 Not Modified` will be responded when
+// the `If-None-Match` header has the `ETag` of it, so remote devices
+// can poll it cheaply
+func (b *Blocklist) Get(w http.ResponseWriter, r *http.Request) {
+	if !b.Public && !b.Authorized(permission.BLOCKLIST_READ, w, r) {
+		return
+	}
+
+	query, queryErr := ParseBlocklistQuery(r)
+
+	if queryErr != nil {
+		b.Error(status.ErrorRespond{
+			Code:  400,
+			Error: queryErr,
+		}, w, r)
+
+		return
+	}
+
+	data, renderErr := blocklist.Render(query.Format, query.Name,
+		blocklist.Build(b.GetClients(), query.Filter, time.Now()))
+
+	if renderErr != nil {
+		b.Error(status.ErrorRespond{
+			Code:  500,
+			Error: renderErr,
+		}, w, r)
+
+		return
+	}
+
+	etag := blocklist.ETag(data)
+
+	w.Header().Set("ETag", etag)
+
+	// Authorized responses are already set to not be cached by proxies
+	if b.Public {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	if b.IsNoneMatched(etag, r) {
+		w.WriteHeader(304)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", blocklist.ContentType(query.Format))
+
+	b.Write(200, data, w, r)
+}
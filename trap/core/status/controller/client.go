@@ -22,9 +22,11 @@
 package controller
 
 import (
+	"github.com/raincious/trap/trap/core/audit"
 	"github.com/raincious/trap/trap/core/client"
 	"github.com/raincious/trap/trap/core/server"
 	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
 	"github.com/raincious/trap/trap/core/types"
 
 	"encoding/json"
@@ -39,38 +41,11 @@ type Client struct {
 	DelClient func(addr types.IP) *types.Throw
 }
 
-func (c *Client) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	parentBefore := c.SessionedJSON.Before(w, r)
-
-	if parentBefore != nil {
-		return parentBefore
-	}
-
-	session := c.Session()
-
-	if session == nil {
-		c.Error(status.ErrorRespond{
-			Code:  401,
-			Error: status.ErrSessionLoginReqiured.Throw(),
-		}, w, r)
-
-		return status.ErrSessionLoginReqiured.Throw()
-	}
-
-	if !session.Account().Allowed("clients") {
-		c.Error(status.ErrorRespond{
-			Code:  403,
-			Error: status.ErrSessionNoPermission.Throw(),
-		}, w, r)
-
-		return status.ErrSessionNoPermission.Throw()
+func (c *Client) Get(w http.ResponseWriter, r *http.Request) {
+	if !c.Authorized(permission.CLIENTS_READ, w, r) {
+		return
 	}
 
-	return nil
-}
-
-func (c *Client) Get(w http.ResponseWriter, r *http.Request) {
 	clientIP, clientIPErr := types.ConvertIPFromString(
 		types.String(
 			r.URL.Query().Get("client")))
@@ -95,7 +70,7 @@ func (c *Client) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jsonData, jsonErr := json.Marshal(client)
+	jsonData, jsonErr := json.Marshal(client.Export())
 
 	if jsonErr != nil {
 		c.Error(status.ErrorRespond{
@@ -106,10 +81,17 @@ func (c *Client) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	c.WriteGZIP(200, jsonData, w, r)
+	c.Write(200, jsonData, w, r)
 }
 
 func (c *Client) Post(w http.ResponseWriter, r *http.Request) {
+	c.AuditAs(r, audit.ACTION_CLIENT_MARK,
+		types.String(r.URL.Query().Get("client")))
+
+	if !c.Authorized(permission.CLIENTS_WRITE, w, r) {
+		return
+	}
+
 	var clientConField server.ClientInfo
 
 	clientIP, clientIPErr := types.ConvertIPFromString(
@@ -165,7 +147,7 @@ func (c *Client) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jsonData, jsonErr := json.Marshal(clientInfo)
+	jsonData, jsonErr := json.Marshal(clientInfo.Export())
 
 	if jsonErr != nil {
 		c.Error(status.ErrorRespond{
@@ -176,10 +158,17 @@ func (c *Client) Post(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	c.WriteGZIP(201, jsonData, w, r)
+	c.Write(201, jsonData, w, r)
 }
 
 func (c *Client) Delete(w http.ResponseWriter, r *http.Request) {
+	c.AuditAs(r, audit.ACTION_CLIENT_UNMARK,
+		types.String(r.URL.Query().Get("client")))
+
+	if !c.Authorized(permission.CLIENTS_WRITE, w, r) {
+		return
+	}
+
 	clientIP, clientIPErr := types.ConvertIPFromString(
 		types.String(
 			r.URL.Query().Get("client")))
@@ -217,5 +206,5 @@ func (c *Client) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	c.WriteGZIP(200, jsonData, w, r)
+	c.Write(200, jsonData, w, r)
 }
@@ -24,6 +24,7 @@ package controller
 import (
 	"github.com/raincious/trap/trap/core/client"
 	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
 	"github.com/raincious/trap/trap/core/types"
 
 	"encoding/json"
@@ -37,6 +38,10 @@ type Clients struct {
 }
 
 func (c *Clients) Get(w http.ResponseWriter, r *http.Request) {
+	if !c.Authorized(permission.CLIENTS_READ, w, r) {
+		return
+	}
+
 	jsonData, jsonErr := json.Marshal(c.GetClients())
 
 	if jsonErr != nil {
@@ -48,5 +53,5 @@ func (c *Clients) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	c.WriteGZIP(200, jsonData, w, r)
+	c.Write(200, jsonData, w, r)
 }
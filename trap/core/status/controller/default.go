@@ -25,9 +25,9 @@ import (
 	"github.com/raincious/trap/trap/core/status"
 	"github.com/raincious/trap/trap/core/types"
 
-	"compress/gzip"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -80,64 +80,58 @@ func (d *Default) Options(w http.ResponseWriter, r *http.Request) {
 	}, w, r)
 }
 
-func (d *Default) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
-
-	return nil
-}
-
-func (d *Default) Error(err status.ErrorRespond, w http.ResponseWriter,
-	r *http.Request) {
-	d.WriteGZIP(err.Code, []byte(err.Error.Error()), w, r)
-}
-
-func (d *Default) GetIPFormString(addr string) (net.IP, *types.Throw) {
-	userHost, _, ipSplitErr := net.SplitHostPort(addr)
-
-	if ipSplitErr != nil {
-		return nil, types.ConvertError(ipSplitErr)
+func (d *Default) Middlewares() []status.Middleware {
+	return []status.Middleware{
+		ContentType("text/html; charset=UTF-8"),
+		GZIP,
 	}
+}
 
-	userIP := net.ParseIP(userHost)
+// AuditAs names the action and the target of the request in the audit log
+func (d *Default) AuditAs(r *http.Request, action types.String,
+	target types.String) {
+	ctx := status.RequestContext(r)
 
-	if userIP == nil {
-		return nil, status.ErrInvalidUserIPAddress.Throw(userHost)
+	if ctx == nil {
+		return
 	}
 
-	return userIP, nil
+	ctx.Action = action
+	ctx.Target = target
 }
 
-func (d *Default) IsGZIPSupported(r *http.Request) bool {
-	clientEncodings := types.String(r.Header.Get("Accept-Encoding")).Lower()
+// Keep the error in the Context of the request for the audit log
+func (d *Default) keepError(err status.ErrorRespond, r *http.Request) {
+	ctx := status.RequestContext(r)
 
-	if !clientEncodings.Contains("gzip") {
-		return false
+	if ctx == nil {
+		return
 	}
 
-	return true
+	ctx.Error = err.Error
 }
 
-func (d *Default) WriteGZIP(code int, data []byte, w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	if !d.IsGZIPSupported(r) || len(data) < 512 {
-		w.WriteHeader(code)
+func (d *Default) Error(err status.ErrorRespond, w http.ResponseWriter,
+	r *http.Request) {
+	d.keepError(err, r)
 
-		w.Write(data)
+	d.Write(err.Code, []byte(err.Error.Error()), w, r)
+}
 
-		return nil
-	}
+func (d *Default) GetIPFormString(addr string) (net.IP, *types.Throw) {
+	return parseRemoteIP(addr)
+}
 
-	w.Header().Set("Content-Encoding", "gzip")
-	w.Header().Set("Vary", " Accept-Encoding")
+func (d *Default) IsGZIPSupported(r *http.Request) bool {
+	return isGZIPSupported(r)
+}
 
+// Write the response, it will be compressed by the GZIP middleware
+func (d *Default) Write(code int, data []byte, w http.ResponseWriter,
+	r *http.Request) *types.Throw {
 	w.WriteHeader(code)
 
-	gzipWriter := gzip.NewWriter(w)
-
-	defer gzipWriter.Close()
-
-	_, wError := gzipWriter.Write(data)
+	_, wError := w.Write(data)
 
 	if wError != nil {
 		return types.ConvertError(wError)
@@ -171,3 +165,29 @@ func (d *Default) IsUnmodified(modifiedTime time.Time, r *http.Request) bool {
 
 	return true
 }
+
+// IsNoneMatched returns true when the `If-None-Match` header of the
+// request contains the entity tag, the cached copy is still fresh then
+func (d *Default) IsNoneMatched(etag string, r *http.Request) bool {
+	noneMatch := r.Header.Get("If-None-Match")
+
+	if noneMatch == "" {
+		return false
+	}
+
+	for _, tag := range types.String(noneMatch).ExplodeWith(",") {
+		tag = tag.Trim()
+
+		if tag == "*" {
+			return true
+		}
+
+		// Weak comparison, the `W/` prefix is ignored
+		if strings.TrimPrefix(tag.String(), "W/") ==
+			strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
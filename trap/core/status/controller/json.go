@@ -23,7 +23,6 @@ package controller
 
 import (
 	"github.com/raincious/trap/trap/core/status"
-	"github.com/raincious/trap/trap/core/types"
 
 	"encoding/json"
 	"net/http"
@@ -33,24 +32,25 @@ type JSON struct {
 	Default
 }
 
-func (j *JSON) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	w.Header().Set("Access-Control-Allow-Origin", "Origin")
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-
-	return nil
+func (j *JSON) Middlewares() []status.Middleware {
+	return []status.Middleware{
+		JSONHeaders,
+		GZIP,
+	}
 }
 
 func (j *JSON) Error(err status.ErrorRespond, w http.ResponseWriter,
 	r *http.Request) {
+	j.keepError(err, r)
+
 	jsonData, jsonErr := json.Marshal(err)
 
 	if jsonErr != nil {
-		j.WriteGZIP(500,
+		j.Write(500,
 			[]byte("{\"error\": \"Can't parse JSON data\"}"), w, r)
 
 		return
 	}
 
-	j.WriteGZIP(err.Code, jsonData, w, r)
+	j.Write(err.Code, jsonData, w, r)
 }
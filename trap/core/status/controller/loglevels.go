@@ -0,0 +1,105 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/json"
+	"net/http"
+)
+
+type LogLevels struct {
+	Logs
+
+	GetLevels func() logger.LevelSettings
+	SetLevel  func(status.LogLevelRequest) *types.Throw
+}
+
+func (l *LogLevels) writeLevels(code int, w http.ResponseWriter,
+	r *http.Request) {
+	jsonData, jsonErr := json.Marshal(l.GetLevels())
+
+	if jsonErr != nil {
+		l.Error(status.ErrorRespond{
+			Code:  500,
+			Error: types.ConvertError(jsonErr),
+		}, w, r)
+
+		return
+	}
+
+	l.Write(code, jsonData, w, r)
+}
+
+func (l *LogLevels) Get(w http.ResponseWriter, r *http.Request) {
+	if !l.Authorized(permission.LOGS_READ, w, r) {
+		return
+	}
+
+	l.writeLevels(200, w, r)
+}
+
+// Post sets the level of the `Context`, or the global level when
+// `Context` is empty. Leave `Level` empty to remove the context level
+func (l *LogLevels) Post(w http.ResponseWriter, r *http.Request) {
+	l.AuditAs(r, audit.ACTION_LOG_LEVEL, "")
+
+	if !l.Authorized(permission.LOGS_WRITE, w, r) {
+		return
+	}
+
+	var levelField status.LogLevelRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	decodeErr := decoder.Decode(&levelField)
+
+	if decodeErr != nil {
+		l.Error(status.ErrorRespond{
+			Code:  400,
+			Error: types.ConvertError(decodeErr),
+		}, w, r)
+
+		return
+	}
+
+	l.AuditAs(r, audit.ACTION_LOG_LEVEL,
+		levelField.Context+"="+levelField.Level)
+
+	setErr := l.SetLevel(levelField)
+
+	if setErr != nil {
+		l.Error(status.ErrorRespond{
+			Code:  400,
+			Error: setErr,
+		}, w, r)
+
+		return
+	}
+
+	l.writeLevels(200, w, r)
+}
@@ -24,51 +24,97 @@ package controller
 import (
 	"github.com/raincious/trap/trap/core/logger"
 	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
 	"github.com/raincious/trap/trap/core/types"
 
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type Logs struct {
 	SessionedJSON
 
-	GetLogs func() []logger.LogExport
+	QueryLogs func(logger.Query) logger.QueryResult
 }
 
-func (l *Logs) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	parentBefore := l.SessionedJSON.Before(w, r)
+func parseLogTime(value string) (time.Time, error) {
+	unix, unixErr := strconv.ParseInt(value, 10, 64)
 
-	if parentBefore != nil {
-		return parentBefore
+	if unixErr == nil {
+		return time.Unix(unix, 0), nil
 	}
 
-	session := l.Session()
+	return time.Parse(time.RFC3339, value)
+}
 
-	if session == nil {
-		l.Error(status.ErrorRespond{
-			Code:  401,
-			Error: status.ErrSessionLoginReqiured.Throw(),
-		}, w, r)
+// ParseLogQuery reads the query from the parameters of the request:
+// `level`, `context`, `since`, `until` (Unix time or RFC 3339),
+// `search`, `limit` and `cursor`
+func ParseLogQuery(r *http.Request) (logger.Query, *types.Throw) {
+	var parseErr error = nil
 
-		return status.ErrSessionLoginReqiured.Throw()
+	params := r.URL.Query()
+	query := logger.Query{
+		Context: types.String(params.Get("context")),
+		Search:  types.String(params.Get("search")),
 	}
 
-	if !session.Account().Allowed("logs") {
-		l.Error(status.ErrorRespond{
-			Code:  403,
-			Error: status.ErrSessionNoPermission.Throw(),
-		}, w, r)
+	if level := params.Get("level"); level != "" {
+		parsedLevel, levelErr := logger.ParseLevel(types.String(level))
+
+		if levelErr != nil {
+			return query, status.ErrStatusControllerInvalidParameter.Throw()
+		}
+
+		query.Level = parsedLevel
+	}
 
-		return status.ErrSessionNoPermission.Throw()
+	if since := params.Get("since"); since != "" {
+		query.Since, parseErr = parseLogTime(since)
 	}
 
-	return nil
+	if until := params.Get("until"); until != "" && parseErr == nil {
+		query.Until, parseErr = parseLogTime(until)
+	}
+
+	if limit := params.Get("limit"); limit != "" && parseErr == nil {
+		query.Limit, parseErr = strconv.Atoi(limit)
+	}
+
+	if cursor := params.Get("cursor"); cursor != "" && parseErr == nil {
+		query.Cursor, parseErr = strconv.ParseUint(cursor, 10, 64)
+	}
+
+	if parseErr != nil || query.Limit < 0 {
+		return query, status.ErrStatusControllerInvalidParameter.Throw()
+	}
+
+	return query, nil
 }
 
+// Get responds the matched logs, newest first. The cursor of the next
+// page will be set in the `X-Trap-Log-Cursor` header when there are more
 func (l *Logs) Get(w http.ResponseWriter, r *http.Request) {
-	jsonData, jsonErr := json.Marshal(l.GetLogs())
+	if !l.Authorized(permission.LOGS_READ, w, r) {
+		return
+	}
+
+	query, queryErr := ParseLogQuery(r)
+
+	if queryErr != nil {
+		l.Error(status.ErrorRespond{
+			Code:  400,
+			Error: queryErr,
+		}, w, r)
+
+		return
+	}
+
+	result := l.QueryLogs(query)
+
+	jsonData, jsonErr := json.Marshal(result.Logs)
 
 	if jsonErr != nil {
 		l.Error(status.ErrorRespond{
@@ -79,5 +125,10 @@ func (l *Logs) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	l.WriteGZIP(200, jsonData, w, r)
+	if result.Cursor > 0 {
+		w.Header().Set(status.STATUS_SERVER_LOG_CURSOR_HEADER,
+			strconv.FormatUint(result.Cursor, 10))
+	}
+
+	l.Write(200, jsonData, w, r)
 }
@@ -0,0 +1,267 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	LOG_STREAM_BUFFER    = 256
+	LOG_STREAM_KEEPALIVE = 15 * time.Second
+)
+
+// LogStream follows new logs with Server-Sent Events. As `EventSource`
+// can't set headers, a stream ticket can be requested by POST and then
+// given by the `ticket` parameter, so no credential ends up in the URL
+type LogStream struct {
+	Logs
+
+	Subscribe    func(logger.Query) *logger.Subscription
+	IssueTicket  func(net.IP, *status.Session) (types.String, *types.Throw)
+	RedeemTicket func(net.IP, types.String) (*status.Session, *types.Throw)
+}
+
+// Authenticate the request with the ticket in the `ticket` parameter, or
+// with the `authenticate` middleware when there is no ticket
+func (l *LogStream) ticketed(authenticate status.Middleware) status.Middleware {
+	return func(next http.Handler) http.Handler {
+		authenticated := authenticate(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ticket := r.URL.Query().Get("ticket")
+
+			if ticket == "" || r.Method != "GET" {
+				authenticated.ServeHTTP(w, r)
+
+				return
+			}
+
+			ctx := status.RequestContext(r)
+
+			if ctx == nil {
+				l.Error(status.ErrorRespond{
+					Code:  500,
+					Error: status.ErrRequestContextNotFound.Throw(),
+				}, w, r)
+
+				return
+			}
+
+			userIP, userIPErr := parseRemoteIP(r.RemoteAddr)
+
+			if userIPErr != nil {
+				l.Error(status.ErrorRespond{
+					Code:  400,
+					Error: userIPErr,
+				}, w, r)
+
+				return
+			}
+
+			session, redeemErr := l.RedeemTicket(userIP,
+				types.String(ticket))
+
+			if redeemErr != nil {
+				l.Error(status.ErrorRespond{
+					Code:  403,
+					Error: redeemErr,
+				}, w, r)
+
+				return
+			}
+
+			ctx.Session = session
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (l *LogStream) Middlewares() []status.Middleware {
+	return l.middlewares(l.JSON.Middlewares(),
+		l.ticketed(l.authenticate(l.Error)))
+}
+
+func (l *LogStream) send(w http.ResponseWriter, log logger.LogExport) error {
+	jsonData, jsonErr := json.Marshal(log)
+
+	if jsonErr != nil {
+		return jsonErr
+	}
+
+	_, writeErr := fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n",
+		log.ID, jsonData)
+
+	return writeErr
+}
+
+// Post issues a stream ticket, which can be used only once within
+// `status.STREAM_TICKET_EXPIRE`
+func (l *LogStream) Post(w http.ResponseWriter, r *http.Request) {
+	if !l.Authorized(permission.LOGS_READ, w, r) {
+		return
+	}
+
+	session := l.Session(r)
+
+	ticket, issueErr := l.IssueTicket(session.IP, session)
+
+	if issueErr != nil {
+		l.Error(status.ErrorRespond{
+			Code:  500,
+			Error: issueErr,
+		}, w, r)
+
+		return
+	}
+
+	jsonData, jsonErr := json.Marshal(status.StreamTicketRespond{
+		Ticket: ticket,
+		Expire: status.STREAM_TICKET_EXPIRE,
+	})
+
+	if jsonErr != nil {
+		l.Error(status.ErrorRespond{
+			Code:  500,
+			Error: types.ConvertError(jsonErr),
+		}, w, r)
+
+		return
+	}
+
+	l.Write(201, jsonData, w, r)
+}
+
+// Get streams the logs. Logs after the `Last-Event-ID`, or the `last_id`
+// parameter when a new ticket is used to reconnect, will be sent first
+func (l *LogStream) Get(w http.ResponseWriter, r *http.Request) {
+	if !l.Authorized(permission.LOGS_READ, w, r) {
+		return
+	}
+
+	query, queryErr := ParseLogQuery(r)
+
+	if queryErr != nil {
+		l.Error(status.ErrorRespond{
+			Code:  400,
+			Error: queryErr,
+		}, w, r)
+
+		return
+	}
+
+	flusher, flushable := w.(http.Flusher)
+
+	if !flushable {
+		l.Error(status.ErrorRespond{
+			Code:  500,
+			Error: status.ErrStreamingNotSupported.Throw(),
+		}, w, r)
+
+		return
+	}
+
+	// Subscribe before replaying, so no log will be missed in between
+	subscription := l.Subscribe(query)
+
+	defer subscription.Close()
+
+	// The stream lives longer than the write timeout of the server
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	w.WriteHeader(200)
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_id")
+	}
+
+	lastID, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+	if lastID > 0 {
+		replayQuery := query
+		replayQuery.After = lastID
+		replayQuery.Limit = 0
+		replayQuery.Cursor = 0
+
+		replay := l.QueryLogs(replayQuery).Logs
+
+		for i := len(replay) - 1; i >= 0; i-- {
+			if l.send(w, replay[i]) != nil {
+				return
+			}
+
+			lastID = replay[i].ID
+		}
+	}
+
+	flusher.Flush()
+
+	keepalive := time.NewTicker(LOG_STREAM_KEEPALIVE)
+
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case log, ok := <-subscription.C:
+			if !ok {
+				return
+			}
+
+			if log.ID <= lastID {
+				continue
+			}
+
+			if l.send(w, log) != nil {
+				return
+			}
+
+			lastID = log.ID
+
+		case <-keepalive.C:
+			if _, writeErr := fmt.Fprint(w, ": keepalive\n\n"); writeErr != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,120 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/types"
+
+	"context"
+	"encoding/json"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogStreamTicket(t *testing.T) {
+	users, verify := getTestUsers(t)
+	lock := sync.Mutex{}
+	tickets := status.StreamTickets{}
+	mux := status.NewMux()
+
+	mux.HandleController("/api/logs/stream", &LogStream{
+		Logs: Logs{
+			SessionedJSON: SessionedJSON{
+				Credentials: Credentials{
+					Verify: verify,
+				},
+			},
+		},
+		Subscribe: func(q logger.Query) *logger.Subscription {
+			return logger.NewLogger().Subscribe(q, LOG_STREAM_BUFFER)
+		},
+		IssueTicket: func(ip net.IP,
+			sess *status.Session) (types.String, *types.Throw) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			return tickets.Issue(ip, sess)
+		},
+		RedeemTicket: func(ip net.IP,
+			ticket types.String) (*status.Session, *types.Throw) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			return tickets.Redeem(ip, ticket)
+		},
+	})
+
+	for _, user := range users {
+		resp := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/logs/stream", nil)
+
+		req.Header.Set(status.STATUS_SERVER_SESSION_KEY_HEADER,
+			user.key.String())
+
+		mux.ServeHTTP(resp, req)
+
+		if user.name != "dave" {
+			if resp.Code != 403 {
+				t.Errorf("User '%s' is not rejected. Expecting '%d', "+
+					"got '%d'", user.name, 403, resp.Code)
+			}
+
+			continue
+		}
+
+		if resp.Code != 201 {
+			t.Errorf("User '%s' is rejected. Expecting '%d', got '%d'",
+				user.name, 201, resp.Code)
+
+			return
+		}
+
+		result := status.StreamTicketRespond{}
+
+		json.Unmarshal(resp.Body.Bytes(), &result)
+
+		for i, expected := range []int{200, 403} {
+			ctx, cancel := context.WithTimeout(context.Background(),
+				50*time.Millisecond)
+
+			resp = httptest.NewRecorder()
+			req = httptest.NewRequest("GET", "/api/logs/stream?ticket="+
+				result.Ticket.String(), nil).WithContext(ctx)
+
+			mux.ServeHTTP(resp, req)
+
+			cancel()
+
+			if resp.Code != expected {
+				t.Errorf("Ticket is not handled right on request '%d'. "+
+					"Expecting '%d', got '%d'", i+1, expected, resp.Code)
+
+				return
+			}
+		}
+	}
+}
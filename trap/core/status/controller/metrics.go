@@ -0,0 +1,63 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/metrics"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
+	"github.com/raincious/trap/trap/core/types"
+
+	"bytes"
+	"io"
+	"net/http"
+)
+
+type Metrics struct {
+	Sessioned
+
+	Collect func(io.Writer) *types.Throw
+}
+
+// Get responds the metrics in the Prometheus text exposition format
+func (m *Metrics) Get(w http.ResponseWriter, r *http.Request) {
+	if !m.Authorized(permission.METRICS_READ, w, r) {
+		return
+	}
+
+	buf := bytes.Buffer{}
+
+	collectErr := m.Collect(&buf)
+
+	if collectErr != nil {
+		m.Error(status.ErrorRespond{
+			Code:  500,
+			Error: collectErr,
+		}, w, r)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.CONTENT_TYPE)
+
+	m.Write(200, buf.Bytes(), w, r)
+}
@@ -0,0 +1,402 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/types"
+
+	"compress/gzip"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Responses smaller than this will not be compressed
+	GZIP_MIN_SIZE = 512
+)
+
+// ErrorResponder writes the error to the client
+type ErrorResponder func(status.ErrorRespond, http.ResponseWriter,
+	*http.Request)
+
+func isGZIPSupported(r *http.Request) bool {
+	clientEncodings := types.String(r.Header.Get("Accept-Encoding")).Lower()
+
+	if !clientEncodings.Contains("gzip") {
+		return false
+	}
+
+	return true
+}
+
+// The request will change the state of the server
+func isWriteRequest(r *http.Request) bool {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+
+	return true
+}
+
+func parseRemoteIP(addr string) (net.IP, *types.Throw) {
+	userHost, _, ipSplitErr := net.SplitHostPort(addr)
+
+	if ipSplitErr != nil {
+		return nil, types.ConvertError(ipSplitErr)
+	}
+
+	userIP := net.ParseIP(userHost)
+
+	if userIP == nil {
+		return nil, status.ErrInvalidUserIPAddress.Throw(userHost)
+	}
+
+	return userIP, nil
+}
+
+// gzipResponseWriter holds the response until it knows whether it's
+// large enough to be compressed
+type gzipResponseWriter struct {
+	http.ResponseWriter
+
+	code    int
+	buffer  []byte
+	started bool
+	gzip    *gzip.Writer
+}
+
+func (g *gzipResponseWriter) compressible() bool {
+	if g.Header().Get("Content-Encoding") != "" {
+		return false
+	}
+
+	if g.code < 200 || g.code == 204 || g.code == 304 {
+		return false
+	}
+
+	// Streams must reach the client as soon as they are written
+	if strings.HasPrefix(g.Header().Get("Content-Type"),
+		"text/event-stream") {
+		return false
+	}
+
+	return true
+}
+
+func (g *gzipResponseWriter) start(compress bool) {
+	g.started = true
+
+	if compress {
+		g.Header().Set("Content-Encoding", "gzip")
+		g.Header().Add("Vary", "Accept-Encoding")
+		g.Header().Del("Content-Length")
+
+		g.gzip = gzip.NewWriter(g.ResponseWriter)
+	}
+
+	g.ResponseWriter.WriteHeader(g.code)
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.code != 0 {
+		return
+	}
+
+	g.code = code
+
+	if !g.compressible() {
+		g.start(false)
+	}
+}
+
+func (g *gzipResponseWriter) Write(data []byte) (int, error) {
+	if g.code == 0 {
+		g.WriteHeader(200)
+	}
+
+	if g.started {
+		if g.gzip != nil {
+			return g.gzip.Write(data)
+		}
+
+		return g.ResponseWriter.Write(data)
+	}
+
+	g.buffer = append(g.buffer, data...)
+
+	if len(g.buffer) < GZIP_MIN_SIZE {
+		return len(data), nil
+	}
+
+	buffered := g.buffer
+	g.buffer = nil
+
+	g.start(true)
+
+	_, wErr := g.gzip.Write(buffered)
+
+	if wErr != nil {
+		return 0, wErr
+	}
+
+	return len(data), nil
+}
+
+// release sends the held response without compression
+func (g *gzipResponseWriter) release() error {
+	if g.code == 0 || g.started {
+		return nil
+	}
+
+	buffered := g.buffer
+	g.buffer = nil
+
+	g.start(false)
+
+	_, wErr := g.ResponseWriter.Write(buffered)
+
+	return wErr
+}
+
+func (g *gzipResponseWriter) Flush() {
+	g.release()
+
+	if g.gzip != nil {
+		g.gzip.Flush()
+	}
+
+	if flusher, ok := g.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) Close() error {
+	releaseErr := g.release()
+
+	if releaseErr != nil {
+		return releaseErr
+	}
+
+	if g.gzip != nil {
+		return g.gzip.Close()
+	}
+
+	return nil
+}
+
+// Unwrap allows http.ResponseController to reach the original writer
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// GZIP compresses the response when the client supports it
+func GZIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isGZIPSupported(r) {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		gzipWriter := &gzipResponseWriter{
+			ResponseWriter: w,
+		}
+
+		defer gzipWriter.Close()
+
+		next.ServeHTTP(gzipWriter, r)
+	})
+}
+
+// ContentType sets the `Content-Type` of the response
+func ContentType(contentType string) status.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", contentType)
+
+				next.ServeHTTP(w, r)
+			})
+	}
+}
+
+// JSONHeaders sets the headers of the JSON APIs
+func JSONHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "Origin")
+		w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authenticate verifies the credential of the request and puts the
+// session into the Context of the request
+func Authenticate(
+	verify func(net.IP, types.String) (*status.Session, *types.Throw),
+	verifyToken func(net.IP, types.String, bool) (*status.Session,
+		*types.Throw),
+	respond ErrorResponder,
+) status.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				ctx := status.RequestContext(r)
+
+				if ctx == nil {
+					respond(status.ErrorRespond{
+						Code:  500,
+						Error: status.ErrRequestContextNotFound.Throw(),
+					}, w, r)
+
+					return
+				}
+
+				userIP, userIPErr := parseRemoteIP(r.RemoteAddr)
+
+				if userIPErr != nil {
+					respond(status.ErrorRespond{
+						Code:  400,
+						Error: userIPErr,
+					}, w, r)
+
+					return
+				}
+
+				session, vErr := verifyCredential(r, userIP,
+					getSessionID(r), verify, verifyToken)
+
+				if vErr != nil {
+					respond(status.ErrorRespond{
+						Code:  403,
+						Error: vErr,
+					}, w, r)
+
+					return
+				}
+
+				ctx.Session = session
+
+				w.Header().Set("Cache-Control",
+					"private, max-age=0, no-cache")
+
+				next.ServeHTTP(w, r)
+			})
+	}
+}
+
+// codeRecorder remembers the status code of the response
+type codeRecorder struct {
+	http.ResponseWriter
+
+	code int
+}
+
+func (c *codeRecorder) WriteHeader(code int) {
+	if c.code == 0 {
+		c.code = code
+	}
+
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *codeRecorder) Write(data []byte) (int, error) {
+	if c.code == 0 {
+		c.code = 200
+	}
+
+	return c.ResponseWriter.Write(data)
+}
+
+func (c *codeRecorder) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (c *codeRecorder) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// Audited records requests which change the state of the server. It
+// must be placed before Authenticate so rejected requests are recorded
+// as well
+func Audited(record func(audit.Entry)) status.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if !isWriteRequest(r) {
+					next.ServeHTTP(w, r)
+
+					return
+				}
+
+				recorder := &codeRecorder{
+					ResponseWriter: w,
+				}
+
+				next.ServeHTTP(recorder, r)
+
+				entry := audit.Entry{
+					Actor:  audit.ACTOR_ANONYMOUS,
+					IP:     types.String(r.RemoteAddr),
+					Action: types.String(r.Method + " " + r.URL.Path),
+					Target: types.String(r.URL.RawQuery),
+					Result: audit.RESULT_SUCCESS,
+				}
+
+				if userIP, userIPErr := parseRemoteIP(
+					r.RemoteAddr); userIPErr == nil {
+					entry.IP = types.String(userIP.String())
+				}
+
+				if recorder.code >= 400 {
+					entry.Result = audit.RESULT_FAILURE
+					entry.Error = types.String(http.StatusText(recorder.code))
+				}
+
+				if ctx := status.RequestContext(r); ctx != nil {
+					entry.RequestID = ctx.ID
+
+					if ctx.Session != nil {
+						entry.Actor = ctx.Session.Actor()
+					}
+
+					if ctx.Action != "" {
+						entry.Action = ctx.Action
+						entry.Target = ctx.Target
+					}
+
+					if ctx.Error != nil {
+						entry.Result = audit.RESULT_FAILURE
+						entry.Error = types.String(ctx.Error.Error())
+					}
+				}
+
+				record(entry)
+			})
+	}
+}
@@ -22,128 +22,139 @@
 package controller
 
 import (
+	"github.com/raincious/trap/trap/core/audit"
 	"github.com/raincious/trap/trap/core/status"
 	"github.com/raincious/trap/trap/core/types"
 
 	"net"
 	"net/http"
+	"strings"
 )
 
-type Sessioned struct {
-	Default
-
-	Verify func(net.IP, types.String) (*status.Session, *types.Throw)
+// Verify the credential of the request, the API token in the
+// `Authorization: Bearer` header is checked by verifyToken when it's set,
+// otherwise the session key in the `X-Trap-Token` header is checked
+func verifyCredential(r *http.Request, ip net.IP, sessionID types.String,
+	verify func(net.IP, types.String) (*status.Session, *types.Throw),
+	verifyToken func(net.IP, types.String, bool) (*status.Session,
+		*types.Throw),
+) (*status.Session, *types.Throw) {
+	authHeader := r.Header.Get(status.STATUS_SERVER_AUTH_HEADER)
+
+	if verifyToken != nil && strings.HasPrefix(authHeader,
+		status.STATUS_SERVER_AUTH_BEARER) {
+		return verifyToken(ip, types.String(strings.TrimSpace(
+			authHeader[len(status.STATUS_SERVER_AUTH_BEARER):])),
+			isWriteRequest(r))
+	}
 
-	session *status.Session
+	return verify(ip, sessionID)
 }
 
-func (s *Sessioned) Session() *status.Session {
-	return s.session
+func getSessionID(r *http.Request) types.String {
+	return types.String(r.Header.Get(
+		status.STATUS_SERVER_SESSION_KEY_HEADER))
 }
 
-func (s *Sessioned) GetSessionID(r *http.Request) types.String {
-	headerID := r.Header.Get(
-		status.STATUS_SERVER_SESSION_KEY_HEADER)
+// Get the session of the request from it's Context
+func requestSession(r *http.Request) *status.Session {
+	ctx := status.RequestContext(r)
 
-	if headerID != "" {
-		return types.String(headerID)
+	if ctx == nil {
+		return nil
 	}
 
-	return types.String("")
+	return ctx.Session
 }
 
-func (s *Sessioned) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	userIP, userIPErr := s.GetIPFormString(r.RemoteAddr)
-
-	if userIPErr != nil {
-		s.Error(status.ErrorRespond{
-			Code:  400,
-			Error: userIPErr,
+// Check the permission of the session, and respond the error when it's
+// not allowed
+func authorized(session *status.Session, name types.String,
+	respond ErrorResponder, w http.ResponseWriter, r *http.Request) bool {
+	if session == nil {
+		respond(status.ErrorRespond{
+			Code:  401,
+			Error: status.ErrSessionLoginReqiured.Throw(),
 		}, w, r)
 
-		return userIPErr
+		return false
 	}
 
-	session, vErr := s.Verify(userIP, s.GetSessionID(r))
-
-	if vErr != nil {
-		s.Error(status.ErrorRespond{
+	if !session.Allowed(name) {
+		respond(status.ErrorRespond{
 			Code:  403,
-			Error: vErr,
+			Error: status.ErrSessionNoPermission.Throw(),
 		}, w, r)
 
-		return vErr
+		return false
 	}
 
-	s.session = session
-
-	w.Header().Set("Cache-Control", "private, max-age=0, no-cache")
-
-	return nil
+	return true
 }
 
-type SessionedJSON struct {
-	JSON
-
+// Credentials verifies the session of the requests to a sessioned
+// controller, and records the requests which change the state
+type Credentials struct {
 	Verify func(net.IP, types.String) (*status.Session, *types.Throw)
 
-	session *status.Session
-}
+	// Verifies the API token, and tells whether the request is going to
+	// change the state
+	VerifyToken func(net.IP, types.String, bool) (*status.Session,
+		*types.Throw)
 
-func (s *SessionedJSON) Session() *status.Session {
-	return s.session
+	// Requests which change the state will be recorded by it when it's set
+	Record func(audit.Entry)
 }
 
-func (s *SessionedJSON) GetSessionID(r *http.Request) types.String {
-	headerID := r.Header.Get(
-		status.STATUS_SERVER_SESSION_KEY_HEADER)
-
-	if headerID != "" {
-		return types.String(headerID)
-	}
-
-	return types.String("")
+// Session returns the session of the request
+func (c *Credentials) Session(r *http.Request) *status.Session {
+	return requestSession(r)
 }
 
-func (s *SessionedJSON) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	beforeErr := s.JSON.Before(w, r)
-
-	if beforeErr != nil {
-		s.Error(status.ErrorRespond{
-			Code:  500,
-			Error: beforeErr,
-		}, w, r)
+// Authenticate the request with the session key or the API token
+func (c *Credentials) authenticate(respond ErrorResponder) status.Middleware {
+	return Authenticate(c.Verify, c.VerifyToken, respond)
+}
 
-		return beforeErr
+// Append the audit and authentication middlewares to the ones of the
+// base controller
+func (c *Credentials) middlewares(middlewares []status.Middleware,
+	authenticate status.Middleware) []status.Middleware {
+	if c.Record != nil {
+		middlewares = append(middlewares, Audited(c.Record))
 	}
 
-	userIP, userIPErr := s.GetIPFormString(r.RemoteAddr)
-
-	if userIPErr != nil {
-		s.Error(status.ErrorRespond{
-			Code:  400,
-			Error: userIPErr,
-		}, w, r)
-
-		return userIPErr
-	}
+	return append(middlewares, authenticate)
+}
 
-	session, vErr := s.Verify(userIP, s.GetSessionID(r))
+type Sessioned struct {
+	Default
+	Credentials
+}
 
-	if vErr != nil {
-		s.Error(status.ErrorRespond{
-			Code:  403,
-			Error: vErr,
-		}, w, r)
+// Authorized returns false after responded the error when the session
+// doesn't have the permission
+func (s *Sessioned) Authorized(name types.String,
+	w http.ResponseWriter, r *http.Request) bool {
+	return authorized(s.Session(r), name, s.Error, w, r)
+}
 
-		return vErr
-	}
+func (s *Sessioned) Middlewares() []status.Middleware {
+	return s.middlewares(s.Default.Middlewares(), s.authenticate(s.Error))
+}
 
-	s.session = session
+type SessionedJSON struct {
+	JSON
+	Credentials
+}
 
-	w.Header().Set("Cache-Control", "private, max-age=0, no-cache")
+// Authorized returns false after responded the error when the session
+// doesn't have the permission
+func (s *SessionedJSON) Authorized(name types.String,
+	w http.ResponseWriter, r *http.Request) bool {
+	return authorized(s.Session(r), name, s.Error, w, r)
+}
 
-	return nil
+func (s *SessionedJSON) Middlewares() []status.Middleware {
+	return s.middlewares(s.JSON.Middlewares(), s.authenticate(s.Error))
 }
@@ -0,0 +1,234 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/password"
+	"github.com/raincious/trap/trap/core/types"
+
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testUser struct {
+	name    types.String
+	key     types.String
+	allowed bool
+}
+
+func getTestUsers(t *testing.T) ([]testUser, func(net.IP,
+	types.String) (*status.Session, *types.Throw)) {
+	lock := sync.Mutex{}
+	accounts := status.Accounts{}
+	sessions := status.Sessions{}
+	users := []testUser{}
+
+	hash, hashErr := password.Hash("The test pass", password.MIN_COST)
+
+	if hashErr != nil {
+		t.Fatalf("password.Hash() failed due to error: %s", hashErr)
+	}
+
+	for _, user := range []struct {
+		name        types.String
+		permissions []types.String
+		allowed     bool
+	}{
+		{"alice", []types.String{"tokens"}, true},
+		{"bob", []types.String{"tokens:write"}, true},
+		{"carol", []types.String{"clients:read"}, false},
+		{"dave", []types.String{"tokens", "logs"}, true},
+	} {
+		account, addErr := accounts.Add(user.name, hash, user.permissions)
+
+		if addErr != nil {
+			t.Fatalf("Accounts.Add() failed due to error: %s", addErr)
+		}
+
+		session, sessErr := sessions.Add(net.ParseIP("192.0.2.1"),
+			account, time.Hour)
+
+		if sessErr != nil {
+			t.Fatalf("Sessions.Add() failed due to error: %s", sessErr)
+		}
+
+		users = append(users, testUser{
+			name:    user.name,
+			key:     session.Key,
+			allowed: user.allowed,
+		})
+	}
+
+	return users, func(ip net.IP,
+		key types.String) (*status.Session, *types.Throw) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return sessions.Verify(ip, key)
+	}
+}
+
+func TestSessionedJSONParallelUsers(t *testing.T) {
+	users, verify := getTestUsers(t)
+	mux := status.NewMux()
+	wait := sync.WaitGroup{}
+
+	mux.HandleController("/api/tokens", &Tokens{
+		SessionedJSON: SessionedJSON{
+			Credentials: Credentials{
+				Verify: verify,
+			},
+		},
+		CreateToken: func(sess *status.Session,
+			req status.TokenRequest) (status.TokenRespond, *types.Throw) {
+			// Give other requests a chance to step in
+			time.Sleep(time.Millisecond)
+
+			return status.TokenRespond{
+				Info: status.TokenDump{
+					Name:    req.Name,
+					Creator: sess.Actor(),
+				},
+			}, nil
+		},
+	})
+
+	for i := 0; i < 32; i++ {
+		for _, user := range users {
+			wait.Add(1)
+
+			go func(user testUser) {
+				defer wait.Done()
+
+				resp := httptest.NewRecorder()
+				req := httptest.NewRequest("POST", "/api/tokens",
+					bytes.NewBufferString(`{"Name": "test"}`))
+
+				req.Header.Set(status.STATUS_SERVER_SESSION_KEY_HEADER,
+					user.key.String())
+
+				mux.ServeHTTP(resp, req)
+
+				if !user.allowed {
+					if resp.Code != 403 {
+						t.Errorf("User '%s' is not rejected. "+
+							"Expecting '%d', got '%d'", user.name, 403,
+							resp.Code)
+					}
+
+					return
+				}
+
+				if resp.Code != 201 {
+					t.Errorf("User '%s' is rejected. "+
+						"Expecting '%d', got '%d'", user.name, 201,
+						resp.Code)
+
+					return
+				}
+
+				result := status.TokenRespond{}
+
+				json.Unmarshal(resp.Body.Bytes(), &result)
+
+				if result.Info.Creator != user.name {
+					t.Errorf("Request served with another session. "+
+						"Expecting '%s', got '%s'", user.name,
+						result.Info.Creator)
+
+					return
+				}
+			}(user)
+		}
+	}
+
+	wait.Wait()
+}
+
+func TestGZIP(t *testing.T) {
+	large := strings.Repeat("Trap", GZIP_MIN_SIZE)
+
+	handler := status.Chain(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			data := large
+
+			if r.URL.Path == "/small" {
+				data = "Trap"
+			}
+
+			w.WriteHeader(200)
+			w.Write([]byte(data))
+		}), GZIP)
+
+	resp := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/large", nil)
+
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	handler.ServeHTTP(resp, req)
+
+	if resp.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("GZIP() failed to compress the large response")
+
+		return
+	}
+
+	reader, readerErr := gzip.NewReader(resp.Body)
+
+	if readerErr != nil {
+		t.Errorf("GZIP() responded invalid data. Got '%s'", readerErr)
+
+		return
+	}
+
+	data, _ := io.ReadAll(reader)
+
+	if string(data) != large {
+		t.Error("GZIP() responded invalid data")
+
+		return
+	}
+
+	resp = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/small", nil)
+
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	handler.ServeHTTP(resp, req)
+
+	if resp.Header().Get("Content-Encoding") != "" ||
+		resp.Body.String() != "Trap" {
+		t.Error("GZIP() compressed the small response")
+
+		return
+	}
+}
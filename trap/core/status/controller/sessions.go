@@ -23,6 +23,7 @@ package controller
 
 import (
 	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
 	"github.com/raincious/trap/trap/core/types"
 
 	"encoding/json"
@@ -35,38 +36,11 @@ type Sessions struct {
 	GetSessions func() []status.SessionDump
 }
 
-func (s *Sessions) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	parentBefore := s.SessionedJSON.Before(w, r)
-
-	if parentBefore != nil {
-		return parentBefore
-	}
-
-	session := s.Session()
-
-	if session == nil {
-		s.Error(status.ErrorRespond{
-			Code:  401,
-			Error: status.ErrSessionLoginReqiured.Throw(),
-		}, w, r)
-
-		return status.ErrSessionLoginReqiured.Throw()
-	}
-
-	if !session.Account().Allowed("sessions") {
-		s.Error(status.ErrorRespond{
-			Code:  403,
-			Error: status.ErrSessionNoPermission.Throw(),
-		}, w, r)
-
-		return status.ErrSessionNoPermission.Throw()
+func (s *Sessions) Get(w http.ResponseWriter, r *http.Request) {
+	if !s.Authorized(permission.SESSIONS_READ, w, r) {
+		return
 	}
 
-	return nil
-}
-
-func (s *Sessions) Get(w http.ResponseWriter, r *http.Request) {
 	jsonData, jsonErr := json.Marshal(s.GetSessions())
 
 	if jsonErr != nil {
@@ -78,5 +52,5 @@ func (s *Sessions) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.WriteGZIP(200, jsonData, w, r)
+	s.Write(200, jsonData, w, r)
 }
@@ -48,5 +48,5 @@ func (s *Status) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.WriteGZIP(200, jsonData, w, r)
+	s.Write(200, jsonData, w, r)
 }
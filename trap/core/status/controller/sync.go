@@ -26,6 +26,7 @@ import (
 	"net/http"
 
 	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
 	"github.com/raincious/trap/trap/core/sync"
 	"github.com/raincious/trap/trap/core/types"
 )
@@ -36,38 +37,11 @@ type Sync struct {
 	GetSyncInfo func() sync.Status
 }
 
-func (s *Sync) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	parentBefore := s.SessionedJSON.Before(w, r)
-
-	if parentBefore != nil {
-		return parentBefore
-	}
-
-	session := s.Session()
-
-	if session == nil {
-		s.Error(status.ErrorRespond{
-			Code:  401,
-			Error: status.ErrSessionLoginReqiured.Throw(),
-		}, w, r)
-
-		return status.ErrSessionLoginReqiured.Throw()
-	}
-
-	if !session.Account().Allowed("sync") {
-		s.Error(status.ErrorRespond{
-			Code:  403,
-			Error: status.ErrSessionNoPermission.Throw(),
-		}, w, r)
-
-		return status.ErrSessionNoPermission.Throw()
+func (s *Sync) Get(w http.ResponseWriter, r *http.Request) {
+	if !s.Authorized(permission.SYNC_READ, w, r) {
+		return
 	}
 
-	return nil
-}
-
-func (s *Sync) Get(w http.ResponseWriter, r *http.Request) {
 	jsonData, jsonErr := json.Marshal(s.GetSyncInfo())
 
 	if jsonErr != nil {
@@ -79,5 +53,5 @@ func (s *Sync) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.WriteGZIP(200, jsonData, w, r)
+	s.Write(200, jsonData, w, r)
 }
@@ -0,0 +1,164 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/status/permission"
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/json"
+	"net/http"
+)
+
+type Tokens struct {
+	SessionedJSON
+
+	GetTokens   func() []status.TokenDump
+	CreateToken func(*status.Session,
+		status.TokenRequest) (status.TokenRespond, *types.Throw)
+	RevokeToken func(*status.Session, types.String) *types.Throw
+}
+
+func (t *Tokens) Get(w http.ResponseWriter, r *http.Request) {
+	if !t.Authorized(permission.TOKENS_READ, w, r) {
+		return
+	}
+
+	jsonData, jsonErr := json.Marshal(t.GetTokens())
+
+	if jsonErr != nil {
+		t.Error(status.ErrorRespond{
+			Code:  500,
+			Error: types.ConvertError(jsonErr),
+		}, w, r)
+
+		return
+	}
+
+	t.Write(200, jsonData, w, r)
+}
+
+// Post creates a new token, the token itself is only responded once
+func (t *Tokens) Post(w http.ResponseWriter, r *http.Request) {
+	t.AuditAs(r, audit.ACTION_TOKEN_CREATE, "")
+
+	if !t.Authorized(permission.TOKENS_WRITE, w, r) {
+		return
+	}
+
+	var tokenReq status.TokenRequest
+
+	decoder := json.NewDecoder(r.Body)
+
+	decodeErr := decoder.Decode(&tokenReq)
+
+	if decodeErr != nil {
+		t.Error(status.ErrorRespond{
+			Code:  400,
+			Error: types.ConvertError(decodeErr),
+		}, w, r)
+
+		return
+	}
+
+	t.AuditAs(r, audit.ACTION_TOKEN_CREATE, tokenReq.Name)
+
+	tokenResp, createErr := t.CreateToken(t.Session(r), tokenReq)
+
+	if createErr != nil {
+		code := 400
+
+		if createErr.Is(status.ErrSessionNoPermission) {
+			code = 403
+		}
+
+		t.Error(status.ErrorRespond{
+			Code:  code,
+			Error: createErr,
+		}, w, r)
+
+		return
+	}
+
+	t.AuditAs(r, audit.ACTION_TOKEN_CREATE, tokenResp.Info.ID)
+
+	jsonData, jsonErr := json.Marshal(tokenResp)
+
+	if jsonErr != nil {
+		t.Error(status.ErrorRespond{
+			Code:  500,
+			Error: types.ConvertError(jsonErr),
+		}, w, r)
+
+		return
+	}
+
+	t.Write(201, jsonData, w, r)
+}
+
+func (t *Tokens) Delete(w http.ResponseWriter, r *http.Request) {
+	t.AuditAs(r, audit.ACTION_TOKEN_REVOKE,
+		types.String(r.URL.Query().Get("id")))
+
+	if !t.Authorized(permission.TOKENS_WRITE, w, r) {
+		return
+	}
+
+	tokenID := types.String(r.URL.Query().Get("id"))
+
+	if tokenID == "" {
+		t.Error(status.ErrorRespond{
+			Code:  400,
+			Error: status.ErrStatusControllerInvalidParameter.Throw(),
+		}, w, r)
+
+		return
+	}
+
+	revokeErr := t.RevokeToken(t.Session(r), tokenID)
+
+	if revokeErr != nil {
+		t.Error(status.ErrorRespond{
+			Code:  404,
+			Error: revokeErr,
+		}, w, r)
+
+		return
+	}
+
+	jsonData, jsonErr := json.Marshal(status.TokenRevokedRespond{
+		Result: true,
+	})
+
+	if jsonErr != nil {
+		t.Error(status.ErrorRespond{
+			Code:  500,
+			Error: types.ConvertError(jsonErr),
+		}, w, r)
+
+		return
+	}
+
+	t.Write(200, jsonData, w, r)
+}
@@ -56,6 +56,12 @@ var (
 	ErrAccountAlreadyExisted *types.Error = types.NewError(
 		"Account '%s' already existed")
 
+	ErrAccountInvalidName *types.Error = types.NewError(
+		"'%s' is not a valid user name")
+
+	ErrAccountAuthFailed *types.Error = types.NewError(
+		"Invalid user name or password for user '%s'")
+
 	ErrSessionNotFound *types.Error = types.NewError(
 		"Session key '%s' does not binded with user '%s'")
 
@@ -82,4 +88,43 @@ var (
 
 	ErrStatusControllerInvalidParameter *types.Error = types.NewError(
 		"Invalid parameter")
+
+	ErrStreamingNotSupported *types.Error = types.NewError(
+		"Streaming is not supported by current connection")
+
+	ErrFailedGenerateStreamTicket *types.Error = types.NewError(
+		"Can't generate stream ticket for '%s'")
+
+	ErrStreamTicketInvalid *types.Error = types.NewError(
+		"Stream ticket is invalid, expired or has been used")
+
+	ErrTokenInvalid *types.Error = types.NewError(
+		"API token is invalid")
+
+	ErrTokenNotFound *types.Error = types.NewError(
+		"API token '%s' is not found")
+
+	ErrTokenAlreadyExisted *types.Error = types.NewError(
+		"API token '%s' already existed")
+
+	ErrTokenExpired *types.Error = types.NewError(
+		"API token '%s' has expired")
+
+	ErrTokenNetworkNotAllowed *types.Error = types.NewError(
+		"API token '%s' can't be used from '%s'")
+
+	ErrTokenInvalidHash *types.Error = types.NewError(
+		"'%s' is not a valid SHA-256 hash of an API token")
+
+	ErrTokenRevoked *types.Error = types.NewError(
+		"API token '%s' has been revoked")
+
+	ErrTokenStateInvalid *types.Error = types.NewError(
+		"Can't load API tokens from '%s': %s")
+
+	ErrTokenStateSave *types.Error = types.NewError(
+		"Can't save API tokens to '%s': %s")
+
+	ErrRequestContextNotFound *types.Error = types.NewError(
+		"Request is not dispatched with a `Context`")
 )
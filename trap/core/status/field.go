@@ -23,6 +23,8 @@ package status
 
 import (
 	"github.com/raincious/trap/trap/core/types"
+
+	"time"
 )
 
 type RequestBase struct {
@@ -30,14 +32,30 @@ type RequestBase struct {
 }
 
 type AuthRequest struct {
+	User     types.String
 	Password types.String
 }
 
 type AuthRespond struct {
 	Token       types.String
+	User        types.String
 	Permissions map[types.String]bool
 }
 
 type ClientDeletedRespond struct {
 	Result bool
 }
+
+type TokenRevokedRespond struct {
+	Result bool
+}
+
+type StreamTicketRespond struct {
+	Ticket types.String
+	Expire time.Duration
+}
+
+type LogLevelRequest struct {
+	Context types.String
+	Level   types.String
+}
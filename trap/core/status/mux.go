@@ -27,6 +27,19 @@ import (
 	"net/http"
 )
 
+// Middleware wraps a handler to do something before or after it
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps the handler with the middlewares, the first middleware
+// will be the outermost one
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return handler
+}
+
 type Mux struct {
 	server *http.ServeMux
 }
@@ -46,6 +59,9 @@ func (mux *Mux) HandleFunc(pattern string,
 	mux.server.HandleFunc(pattern, handler)
 }
 
+// HandleController registers the controller for the pattern. The same
+// controller serves all requests of the pattern, and every request gets
+// it's own Context before going through the middlewares of the controller
 func (mux *Mux) HandleController(pattern string,
 	c Controller) *types.Throw {
 	err := c.Init()
@@ -54,23 +70,18 @@ func (mux *Mux) HandleController(pattern string,
 		return err
 	}
 
-	mux.server.HandleFunc(pattern,
+	middlewares := append([]Middleware{WithContext}, c.Middlewares()...)
+
+	mux.server.Handle(pattern, Chain(http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			mux.dispatchController(w, r, c)
-		})
+		}), middlewares...))
 
 	return nil
 }
 
 func (mux *Mux) dispatchController(w http.ResponseWriter, r *http.Request,
 	c Controller) {
-
-	err := c.Before(w, r)
-
-	if err != nil {
-		return
-	}
-
 	switch r.Method {
 	case "GET":
 		c.Get(w, r)
@@ -26,7 +26,11 @@ import (
 
 	"bytes"
 	"errors"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
 	"testing"
 )
 
@@ -94,11 +98,17 @@ func (d *dummyController) Options(w http.ResponseWriter, r *http.Request) {
 	d.optionsCalled = true
 }
 
-func (d *dummyController) Before(w http.ResponseWriter,
-	r *http.Request) *types.Throw {
-	d.beforeCalled = true
+func (d *dummyController) Middlewares() []Middleware {
+	return []Middleware{
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					d.beforeCalled = true
 
-	return nil
+					next.ServeHTTP(w, r)
+				})
+		},
+	}
 }
 
 func (d *dummyController) Error(err ErrorRespond, w http.ResponseWriter,
@@ -216,7 +226,8 @@ func TestMuxHandleController(t *testing.T) {
 		mux.ServeHTTP(resp, getNewHTTPRequest(method, "/handler"))
 	}
 
-	if !dummyCrtl.initCalled || !dummyCrtl.getCalled ||
+	if !dummyCrtl.initCalled || !dummyCrtl.beforeCalled ||
+		!dummyCrtl.getCalled ||
 		!dummyCrtl.postCalled || !dummyCrtl.putCalled ||
 		!dummyCrtl.deleteCalled || !dummyCrtl.headCalled ||
 		!dummyCrtl.optionsCalled {
@@ -225,3 +236,112 @@ func TestMuxHandleController(t *testing.T) {
 		return
 	}
 }
+
+func TestMuxChain(t *testing.T) {
+	called := ""
+
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					called += name
+
+					next.ServeHTTP(w, r)
+				})
+		}
+	}
+
+	handler := Chain(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			called += "h"
+		}), tag("a"), tag("b"), tag("c"))
+
+	handler.ServeHTTP(httptest.NewRecorder(), getNewHTTPRequest("GET", "/"))
+
+	if called != "abch" {
+		t.Errorf("Chain() failed to chain the middlewares in order. "+
+			"Expecting '%s', got '%s'", "abch", called)
+
+		return
+	}
+}
+
+// sessionController keeps nothing about the request, the session is
+// taken from the `X-Trap-Token` header and kept in the Context
+type sessionController struct {
+	dummyController
+}
+
+func (s *sessionController) Middlewares() []Middleware {
+	return []Middleware{
+		func(next http.Handler) http.Handler {
+			return http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					RequestContext(r).Session = &Session{
+						Key: types.String(r.Header.Get(
+							STATUS_SERVER_SESSION_KEY_HEADER)),
+						IP: net.ParseIP("127.0.0.1"),
+					}
+
+					next.ServeHTTP(w, r)
+				})
+		},
+	}
+}
+
+func (s *sessionController) Get(w http.ResponseWriter, r *http.Request) {
+	ctx := RequestContext(r)
+
+	w.Header().Set(STATUS_SERVER_SESSION_KEY_HEADER,
+		ctx.Session.Key.String())
+	w.Write([]byte(ctx.ID))
+}
+
+func TestMuxHandleControllerParallel(t *testing.T) {
+	mux := NewMux()
+	wait := sync.WaitGroup{}
+	requestIDs := sync.Map{}
+
+	mux.HandleController("/handler", &sessionController{})
+
+	for i := 0; i < 64; i++ {
+		wait.Add(1)
+
+		go func(user string) {
+			defer wait.Done()
+
+			resp := httptest.NewRecorder()
+			req := getNewHTTPRequest("GET", "/handler")
+
+			req.Header.Set(STATUS_SERVER_SESSION_KEY_HEADER, user)
+
+			mux.ServeHTTP(resp, req)
+
+			respUser := resp.Header().Get(STATUS_SERVER_SESSION_KEY_HEADER)
+
+			if respUser != user {
+				t.Errorf("Request served with another session. "+
+					"Expecting '%s', got '%s'", user, respUser)
+
+				return
+			}
+
+			requestID := resp.Header().Get(STATUS_SERVER_REQUEST_ID_HEADER)
+
+			if requestID == "" || requestID != resp.Body.String() {
+				t.Errorf("Invalid request ID. Expecting '%s', got '%s'",
+					resp.Body.String(), requestID)
+
+				return
+			}
+
+			if _, loaded := requestIDs.LoadOrStore(requestID, true); loaded {
+				t.Errorf("Request ID '%s' is not unique", requestID)
+
+				return
+			}
+		}("user" + strconv.Itoa(i))
+	}
+
+	wait.Wait()
+}
@@ -0,0 +1,40 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package password
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+)
+
+var (
+	ErrInvalidHash *types.Error = types.NewError(
+		"Invalid bcrypt password hash")
+
+	ErrInvalidCost *types.Error = types.NewError(
+		"bcrypt cost '%d' is out of range, it must between '%d' and '%d'")
+
+	ErrPasswordTooLong *types.Error = types.NewError(
+		"Password can't be longer than '%d' bytes")
+
+	ErrMismatched *types.Error = types.NewError(
+		"Password does not match")
+)
@@ -0,0 +1,130 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package password hashes and verifies passwords with bcrypt, which is
+// provided by golang.org/x/crypto/bcrypt
+package password
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"strings"
+)
+
+const (
+	MIN_COST     = bcrypt.MinCost
+	MAX_COST     = bcrypt.MaxCost
+	DEFAULT_COST = bcrypt.DefaultCost
+
+	// Only the first 72 bytes can be mixed into the key
+	MAX_LENGTH = 72
+
+	// $2b$10$ + 22 bytes of salt + 31 bytes of hash
+	hashLen = 60
+
+	encodingChars = "./ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+		"abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// Hash the password with a random salt, the result is in the modular
+// crypt format like `$2a$10$...` which can be verified by Compare
+func Hash(pass types.String, cost int) (types.String, *types.Throw) {
+	if len(pass) > MAX_LENGTH {
+		return "", ErrPasswordTooLong.Throw(MAX_LENGTH)
+	}
+
+	if cost < MIN_COST || cost > MAX_COST {
+		return "", ErrInvalidCost.Throw(cost, MIN_COST, MAX_COST)
+	}
+
+	hash, hashErr := bcrypt.GenerateFromPassword(pass.Bytes(), cost)
+
+	if hashErr != nil {
+		return "", types.ConvertError(hashErr)
+	}
+
+	return types.String(hash), nil
+}
+
+// Check whether the hash is a well formed bcrypt hash
+func Check(hash types.String) *types.Throw {
+	if len(hash) != hashLen {
+		return ErrInvalidHash.Throw()
+	}
+
+	switch hash[:4] {
+	case "$2a$", "$2b$", "$2y$":
+
+	default:
+		return ErrInvalidHash.Throw()
+	}
+
+	if hash[6] != '$' {
+		return ErrInvalidHash.Throw()
+	}
+
+	for _, c := range hash[7:] {
+		if !strings.ContainsRune(encodingChars, c) {
+			return ErrInvalidHash.Throw()
+		}
+	}
+
+	_, costErr := bcrypt.Cost(hash.Bytes())
+
+	if cost, ok := costErr.(bcrypt.InvalidCostError); ok {
+		return ErrInvalidCost.Throw(int(cost), MIN_COST, MAX_COST)
+	}
+
+	if costErr != nil {
+		return ErrInvalidHash.Throw()
+	}
+
+	return nil
+}
+
+// Compare the password with the hash in constant time, returns nil when
+// they match
+func Compare(hash types.String, pass types.String) *types.Throw {
+	checkErr := Check(hash)
+
+	if checkErr != nil {
+		return checkErr
+	}
+
+	// Bytes beyond the limit never made it into the hash
+	if len(pass) > MAX_LENGTH {
+		pass = pass[:MAX_LENGTH]
+	}
+
+	cmpErr := bcrypt.CompareHashAndPassword(hash.Bytes(), pass.Bytes())
+
+	if cmpErr == bcrypt.ErrMismatchedHashAndPassword {
+		return ErrMismatched.Throw()
+	}
+
+	if cmpErr != nil {
+		return ErrInvalidHash.Throw()
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package password
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	// Test vectors from the OpenBSD bcrypt implementation
+	vectors := map[types.String]types.String{
+		"":      "$2a$06$DCq7YPn5Rq63x1Lad4cll.TV4S6ytwfsfvkgY8jIucDrjc8deX1s.",
+		"U*U":   "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		"U*U*":  "$2a$05$CCCCCCCCCCCCCCCCCCCCC.VGOzA784oUp/Z0DY336zx7pLYAy0lwK",
+		"U*U*U": "$2a$05$XXXXXXXXXXXXXXXXXXXXXOAcXxm9kjPGEMsLznoKqmqw7tc8WCx4a",
+		"0123456789abcdefghijklmnopqrstuvwxyz" +
+			"ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789" +
+			"chars after 72 are ignored": "$2a$05$abcdefghijkl" +
+			"mnopqrstuu5s2v8.iXieOjg/.AySBTTZIIVFJeBui",
+	}
+
+	for pass, hash := range vectors {
+		err := Compare(hash, pass)
+
+		if err != nil {
+			t.Errorf("Compare() failed to verify password '%s'. Got '%s'",
+				pass, err)
+
+			return
+		}
+
+		if len(pass) > MAX_LENGTH {
+			continue
+		}
+
+		err = Compare(hash, pass+"?")
+
+		if err == nil || !err.Is(ErrMismatched) {
+			t.Errorf("Compare() failed to reject wrong password '%s'",
+				pass+"?")
+
+			return
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	hash, hashErr := Hash("The test password", MIN_COST)
+
+	if hashErr != nil {
+		t.Errorf("Hash() failed due to error: %s", hashErr)
+
+		return
+	}
+
+	if hash[:7] != "$2a$04$" {
+		t.Errorf("Hash() failed to generate expected prefix. "+
+			"Expecting '%s', got '%s'", "$2a$04$", hash[:7])
+
+		return
+	}
+
+	checkErr := Check(hash)
+
+	if checkErr != nil {
+		t.Errorf("Check() failed to parse the hash due to error: %s",
+			checkErr)
+
+		return
+	}
+
+	cmpErr := Compare(hash, "The test password")
+
+	if cmpErr != nil {
+		t.Errorf("Compare() failed to verify the hash due to error: %s",
+			cmpErr)
+
+		return
+	}
+
+	_, hashErr = Hash("The test password", MIN_COST-1)
+
+	if hashErr == nil || !hashErr.Is(ErrInvalidCost) {
+		t.Errorf("Hash() failed to reject invalid cost. Got '%s'", hashErr)
+
+		return
+	}
+}
+
+func TestCheck(t *testing.T) {
+	invalids := []types.String{
+		"",
+		"plaintext password",
+		"$1$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		"$2a$99$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW",
+		"$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOe",
+	}
+
+	for _, hash := range invalids {
+		if Check(hash) == nil {
+			t.Errorf("Check() failed to reject invalid hash '%s'", hash)
+
+			return
+		}
+	}
+}
@@ -27,21 +27,39 @@ import (
 )
 
 var (
-	permissionRegister = permission.NewRegister()
+	permissionRegister = permission.NewRegister(permission.Resources)
 )
 
+// ValidatePermission makes sure the permission can be granted
+func ValidatePermission(name types.String) *types.Throw {
+	_, err := permissionRegister.Grant(name)
+
+	return err
+}
+
 type Permission struct {
 	permission types.UInt64
 }
 
-func (p *Permission) Authorize(name types.String) {
-	permissionVal := permissionRegister.Get(name)
+// Authorize grants the permission and all permissions it implied
+func (p *Permission) Authorize(name types.String) *types.Throw {
+	permissionVal, err := permissionRegister.Grant(name)
+
+	if err != nil {
+		return err
+	}
 
 	p.permission = p.permission | permissionVal
+
+	return nil
 }
 
 func (p *Permission) Allowed(name types.String) bool {
-	permissionVal := permissionRegister.Get(name)
+	permissionVal, err := permissionRegister.Get(name)
+
+	if err != nil {
+		return false
+	}
 
 	if (p.permission & permissionVal) == 0 {
 		return false
@@ -50,6 +68,12 @@ func (p *Permission) Allowed(name types.String) bool {
 	return true
 }
 
+// Includes checks whether all permissions of another Permission is
+// granted by this one
+func (p *Permission) Includes(another Permission) bool {
+	return (p.permission & another.permission) == another.permission
+}
+
 func (p *Permission) All() map[types.String]bool {
 	permissions := map[types.String]bool{}
 
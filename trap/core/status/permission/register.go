@@ -23,37 +23,109 @@ package permission
 
 import (
 	"github.com/raincious/trap/trap/core/types"
+
+	"strings"
 )
 
 const (
 	registerFactor = types.UInt64(2)
+
+	READ  = "read"
+	WRITE = "write"
+
+	CLIENTS_READ    = "clients:read"
+	CLIENTS_WRITE   = "clients:write"
+	SESSIONS_READ   = "sessions:read"
+	SESSIONS_WRITE  = "sessions:write"
+	LOGS_READ       = "logs:read"
+	LOGS_WRITE      = "logs:write"
+	SYNC_READ       = "sync:read"
+	SYNC_WRITE      = "sync:write"
+	CONFIG_READ     = "config:read"
+	CONFIG_WRITE    = "config:write"
+	LISTENERS_READ  = "listeners:read"
+	LISTENERS_WRITE = "listeners:write"
+	METRICS_READ    = "metrics:read"
+	METRICS_WRITE   = "metrics:write"
+	TOKENS_READ     = "tokens:read"
+	TOKENS_WRITE    = "tokens:write"
+	AUDIT_READ      = "audit:read"
+	AUDIT_WRITE     = "audit:write"
+	BLOCKLIST_READ  = "blocklist:read"
+	BLOCKLIST_WRITE = "blocklist:write"
 )
 
+var (
+	// Resources which can be protected by a permission, every resource
+	// has a read and a write permission
+	Resources = []types.String{
+		"clients",
+		"sessions",
+		"logs",
+		"sync",
+		"config",
+		"listeners",
+		"metrics",
+		"tokens",
+		"audit",
+		"blocklist",
+	}
+)
+
+// Register is a fixed catalog of permissions, permissions which is not
+// in the catalog can't be granted
 type Register struct {
-	currentIdx types.UInt64
 	registered map[types.String]types.UInt64
 }
 
-func NewRegister() Register {
-	return Register{
-		currentIdx: 0,
+func NewRegister(resources []types.String) Register {
+	reg := Register{
 		registered: map[types.String]types.UInt64{},
 	}
+
+	currentVal := types.UInt64(1)
+
+	for _, resource := range resources {
+		for _, access := range []types.String{READ, WRITE} {
+			reg.registered[resource+":"+access] = currentVal
+
+			currentVal = currentVal * registerFactor
+		}
+	}
+
+	return reg
 }
 
-func (r *Register) Get(name types.String) types.UInt64 {
-	if _, ok := r.registered[name]; ok {
-		return r.registered[name]
+// Get returns the value of the permission in the catalog
+func (r *Register) Get(name types.String) (types.UInt64, *types.Throw) {
+	if _, ok := r.registered[name]; !ok {
+		return 0, ErrUnknownPermission.Throw(name)
 	}
 
-	if r.currentIdx < 1 {
-		r.currentIdx = 1
+	return r.registered[name], nil
+}
+
+// Grant returns the value of all permissions which implied by the name.
+// Write permission implies the read permission of the same resource, and
+// name of the resource itself (like `clients`) grants both of them
+func (r *Register) Grant(name types.String) (types.UInt64, *types.Throw) {
+	if readVal, ok := r.registered[name+":"+READ]; ok {
+		return readVal | r.registered[name+":"+WRITE], nil
 	}
 
-	r.currentIdx = r.currentIdx * registerFactor
-	r.registered[name] = r.currentIdx
+	val, err := r.Get(name)
+
+	if err != nil {
+		return 0, err
+	}
+
+	resource, access, _ := strings.Cut(name.String(), ":")
+
+	if access == WRITE {
+		val = val | r.registered[types.String(resource)+":"+READ]
+	}
 
-	return r.registered[name]
+	return val, nil
 }
 
 func (r *Register) All() map[types.String]types.UInt64 {
@@ -28,10 +28,11 @@ import (
 )
 
 func TestRegisterNewRegister(t *testing.T) {
-	reg := NewRegister()
+	reg := NewRegister([]types.String{"clients", "logs"})
 
-	if reg.currentIdx != 0 || reg.registered == nil {
-		t.Error("Inital value is incorrect")
+	if len(reg.registered) != 4 {
+		t.Errorf("NewRegister() failed to build the catalog. "+
+			"Expecting '%d' permissions, got '%d'", 4, len(reg.registered))
 
 		return
 	}
@@ -52,11 +53,19 @@ func noItemIsTheSame(arr []types.UInt64) bool {
 }
 
 func TestRegisterGet(t *testing.T) {
-	reg := NewRegister()
+	reg := NewRegister(Resources)
 	vecs := []types.UInt64{}
 
-	for i := types.UInt16(0); i < 64; i++ {
-		vecs = append(vecs, reg.Get(types.UInt16(i).String()))
+	for name := range reg.All() {
+		vec, err := reg.Get(name)
+
+		if err != nil {
+			t.Errorf("Register.Get() failed to get '%s'. Got '%s'", name, err)
+
+			return
+		}
+
+		vecs = append(vecs, vec)
 	}
 
 	if !noItemIsTheSame(vecs) {
@@ -64,48 +73,72 @@ func TestRegisterGet(t *testing.T) {
 
 		return
 	}
-}
 
-func TestRegisterAll(t *testing.T) {
-	reg := NewRegister()
-	vec := reg.Get("Name of the testing permission")
+	for _, name := range []types.String{"", "clients", "clients:delete",
+		"Name of the testing permission"} {
+		_, err := reg.Get(name)
 
-	if vec != reg.Get("Name of the testing permission") {
-		t.Error("Failed to get permission vector")
+		if err == nil || !err.Is(ErrUnknownPermission) {
+			t.Errorf("Register.Get() failed to reject '%s'", name)
 
-		return
+			return
+		}
 	}
+}
 
-	allPermissions := reg.All()
-
-	if len(allPermissions) != 1 {
-		t.Error("Permission register should only contain one item, but it's not")
+func TestRegisterGrant(t *testing.T) {
+	reg := NewRegister(Resources)
+	read, _ := reg.Get(CLIENTS_READ)
+	write, _ := reg.Get(CLIENTS_WRITE)
 
-		return
+	tests := map[types.String]types.UInt64{
+		"clients":     read | write,
+		CLIENTS_WRITE: read | write,
+		CLIENTS_READ:  read,
 	}
 
-	if allPermissions["Name of the testing permission"] != vec {
-		t.Error("Regisrer.All() exports an invalid value")
+	for name, expected := range tests {
+		vec, err := reg.Grant(name)
 
-		return
+		if err != nil {
+			t.Errorf("Register.Grant() failed to grant '%s'. Got '%s'",
+				name, err)
+
+			return
+		}
+
+		if vec != expected {
+			t.Errorf("Register.Grant() failed to grant '%s'. "+
+				"Expecting '%d', got '%d'", name, expected, vec)
+
+			return
+		}
 	}
 
-	vec2 := reg.Get("Another permission key name")
+	_, err := reg.Grant("everything")
 
-	if len(allPermissions) != 2 {
-		t.Error("Permission register should contain two items, but it's not")
+	if err == nil || !err.Is(ErrUnknownPermission) {
+		t.Error("Register.Grant() failed to reject unknown permission")
 
 		return
 	}
+}
 
-	if allPermissions["Name of the testing permission"] != vec {
-		t.Error("Regisrer.All() exports an invalid value")
+func TestRegisterAll(t *testing.T) {
+	reg := NewRegister(Resources)
+	allPermissions := reg.All()
+
+	if len(allPermissions) != len(Resources)*2 {
+		t.Errorf("Register.All() exports invalid amount of permissions. "+
+			"Expecting '%d', got '%d'", len(Resources)*2, len(allPermissions))
 
 		return
 	}
 
-	if allPermissions["Another permission key name"] != vec2 {
-		t.Error("Regisrer.All() exports an invalid value")
+	vec, _ := reg.Get(LOGS_WRITE)
+
+	if allPermissions[LOGS_WRITE] != vec {
+		t.Error("Register.All() exports an invalid value")
 
 		return
 	}
@@ -22,64 +22,96 @@
 package status
 
 import (
-	//"github.com/raincious/trap/trap/core/types"
+	"github.com/raincious/trap/trap/core/status/permission"
 
 	"testing"
 )
 
 func TestPermissionAuthorize(t *testing.T) {
-	permission := Permission{}
+	p := Permission{}
 
-	permission.Authorize("Test permission")
+	err := p.Authorize(permission.CLIENTS_WRITE)
 
-	if !permission.Allowed("Test permission") {
+	if err != nil {
+		t.Errorf("Permission.Authorize() failed to authorize. Got '%s'", err)
+
+		return
+	}
+
+	if !p.Allowed(permission.CLIENTS_WRITE) ||
+		!p.Allowed(permission.CLIENTS_READ) {
 		t.Error("Failed asserting an authorized permission is authorized")
 
 		return
 	}
+
+	err = p.Authorize("Test permission")
+
+	if err == nil || !err.Is(permission.ErrUnknownPermission) {
+		t.Error("Permission.Authorize() failed to reject unknown permission")
+
+		return
+	}
 }
 
 func TestPermissionAllowed(t *testing.T) {
-	permission := Permission{}
+	p := Permission{}
 
-	permission.Authorize("Test permission")
+	p.Authorize(permission.LOGS_READ)
 
-	if !permission.Allowed("Test permission") {
+	if !p.Allowed(permission.LOGS_READ) {
 		t.Error("Failed asserting an authorized permission is authorized")
 
 		return
 	}
 
-	if permission.Allowed("Another permission") {
+	if p.Allowed(permission.LOGS_WRITE) || p.Allowed("Another permission") {
 		t.Error("Failed asserting an unauthorized permission is unauthorized")
 
 		return
 	}
 }
 
+func TestPermissionIncludes(t *testing.T) {
+	p := Permission{}
+	another := Permission{}
+
+	p.Authorize("clients")
+	another.Authorize(permission.CLIENTS_READ)
+
+	if !p.Includes(another) {
+		t.Error("Permission.Includes() failed to include the permission")
+
+		return
+	}
+
+	another.Authorize(permission.LOGS_READ)
+
+	if p.Includes(another) {
+		t.Error("Permission.Includes() included an unauthorized permission")
+
+		return
+	}
+}
+
 func TestPermissionAll(t *testing.T) {
-	permission := Permission{}
+	p := Permission{}
 
-	permission.Authorize("Test permission 1")
-	permission.Authorize("Test permission 2")
-	permission.Authorize("Test permission 3")
-	permission.Authorize("Test permission 4")
+	p.Authorize(permission.SYNC_READ)
+	p.Authorize("sessions")
 
-	allPermissions := permission.All()
+	allPermissions := p.All()
 
-	// Why 6 permissions?
-	// Because we record all permissions in a global shared variable which
-	// share the same value across different structs.
-	// So the permission we actually have in this table is:
-	// Test permission, Another permission and Test permission 1 - 4
-	if len(allPermissions) != 6 {
-		t.Error("Invalid amount of permissions")
+	if len(allPermissions) != len(permission.Resources)*2 {
+		t.Errorf("Permission.All() exports invalid amount of permissions. "+
+			"Expecting '%d', got '%d'", len(permission.Resources)*2,
+			len(allPermissions))
 
 		return
 	}
 
 	for pKey, pVal := range allPermissions {
-		if permission.Allowed(pKey) == pVal {
+		if p.Allowed(pKey) == pVal {
 			continue
 		}
 
@@ -87,4 +119,11 @@ func TestPermissionAll(t *testing.T) {
 
 		return
 	}
+
+	if !allPermissions[permission.SESSIONS_WRITE] ||
+		allPermissions[permission.SYNC_WRITE] {
+		t.Error("Permission.All() exports an invalid permission")
+
+		return
+	}
 }
@@ -39,10 +39,12 @@ type Session struct {
 	Expire   time.Duration
 
 	account *Account
+	token   *Token
 }
 
 type SessionDump struct {
 	IP      net.IP
+	User    types.String
 	Created time.Time
 
 	LastSeen time.Time
@@ -59,6 +61,48 @@ func (s *Session) Account() *Account {
 	return s.account
 }
 
+// Token returns the API token of the session, nil when the session is
+// created by login
+func (s *Session) Token() *Token {
+	return s.token
+}
+
+// Actor returns who is acting through the session
+func (s *Session) Actor() types.String {
+	if s.token != nil {
+		return "token:" + s.token.ID
+	}
+
+	return s.account.Name()
+}
+
+// Allowed checks whether the account or the token of the session has
+// the permission
+func (s *Session) Allowed(name types.String) bool {
+	if s.token != nil {
+		return s.token.Allowed(name)
+	}
+
+	if s.account == nil {
+		return false
+	}
+
+	return s.account.Allowed(name)
+}
+
+// Permission returns all permissions of the session
+func (s *Session) Permission() Permission {
+	if s.token != nil {
+		return s.token.Permission()
+	}
+
+	if s.account == nil {
+		return Permission{}
+	}
+
+	return s.account.Permission()
+}
+
 func (s *Session) Expired() bool {
 	if s.LastSeen.Add(s.Expire).After(time.Now()) {
 		return false
@@ -67,9 +111,25 @@ func (s *Session) Expired() bool {
 	return true
 }
 
+// NewTokenSession creates a session for a single request which been
+// authorized by the API token
+func NewTokenSession(ip net.IP, token *Token) *Session {
+	return &Session{
+		IP:       ip,
+		Created:  time.Now(),
+		LastSeen: time.Now(),
+
+		token: token,
+	}
+}
+
 type Sessions map[types.String]*Session
 
 func (s Sessions) getRandomKey() types.String {
+	return getRandomKey()
+}
+
+func getRandomKey() types.String {
 	rBytes := make([]byte, 32)
 
 	_, rErr := rand.Read(rBytes)
@@ -175,6 +235,7 @@ func (s Sessions) Dump() []SessionDump {
 	for _, sess := range s {
 		result = append(result, SessionDump{
 			IP:      sess.IP,
+			User:    sess.Account().Name(),
 			Created: sess.Created,
 
 			LastSeen: sess.LastSeen,
@@ -0,0 +1,104 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"time"
+)
+
+// How long a stream ticket can wait to be used
+const (
+	STREAM_TICKET_EXPIRE = 30 * time.Second
+)
+
+type streamTicket struct {
+	ip      net.IP
+	expire  time.Time
+	session *Session
+}
+
+// StreamTickets are short-lived keys which can be used only once to open
+// a stream on behalf of a session. `EventSource` can't set headers, so a
+// ticket is given in the URL instead of the session key or the token
+type StreamTickets map[types.String]*streamTicket
+
+func (s StreamTickets) scanExpired() {
+	now := time.Now()
+
+	for key, ticket := range s {
+		if ticket.expire.After(now) {
+			continue
+		}
+
+		delete(s, key)
+	}
+}
+
+// Issue a ticket for the session, it can only be used from the same ip
+func (s StreamTickets) Issue(ip net.IP,
+	session *Session) (types.String, *types.Throw) {
+	s.scanExpired()
+
+	for retry := 0; retry < 3; retry++ {
+		key := getRandomKey()
+
+		if key == "" {
+			continue
+		}
+
+		if _, ok := s[key]; ok {
+			continue
+		}
+
+		s[key] = &streamTicket{
+			ip:      ip,
+			expire:  time.Now().Add(STREAM_TICKET_EXPIRE),
+			session: session,
+		}
+
+		return key, nil
+	}
+
+	return "", ErrFailedGenerateStreamTicket.Throw(ip)
+}
+
+// Redeem the ticket for it's session, the ticket will be removed even
+// when it fails
+func (s StreamTickets) Redeem(ip net.IP,
+	key types.String) (*Session, *types.Throw) {
+	ticket, ok := s[key]
+
+	if !ok {
+		return nil, ErrStreamTicketInvalid.Throw()
+	}
+
+	delete(s, key)
+
+	if !ticket.ip.Equal(ip) || !ticket.expire.After(time.Now()) {
+		return nil, ErrStreamTicketInvalid.Throw()
+	}
+
+	return ticket.session, nil
+}
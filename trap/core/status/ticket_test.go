@@ -0,0 +1,83 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStreamTicketsRedeem(t *testing.T) {
+	tickets := StreamTickets{}
+	ip := net.ParseIP("127.0.0.1")
+	session := &Session{IP: ip}
+
+	key, issueErr := tickets.Issue(ip, session)
+
+	if issueErr != nil {
+		t.Errorf("StreamTickets.Issue() failed due to error: %s", issueErr)
+
+		return
+	}
+
+	if _, redeemErr := tickets.Redeem(net.ParseIP("127.0.0.2"),
+		key); redeemErr == nil || !redeemErr.Is(ErrStreamTicketInvalid) {
+		t.Error("StreamTickets.Redeem() accepted a ticket from another IP")
+
+		return
+	}
+
+	key, _ = tickets.Issue(ip, session)
+
+	redeemed, redeemErr := tickets.Redeem(ip, key)
+
+	if redeemErr != nil || redeemed != session {
+		t.Errorf("StreamTickets.Redeem() failed to redeem the session: %s",
+			redeemErr)
+
+		return
+	}
+
+	if _, redeemErr = tickets.Redeem(ip, key); redeemErr == nil {
+		t.Error("StreamTickets.Redeem() accepted a used ticket")
+
+		return
+	}
+
+	key, _ = tickets.Issue(ip, session)
+
+	tickets[key].expire = time.Now().Add(-time.Second)
+
+	if _, redeemErr = tickets.Redeem(ip, key); redeemErr == nil {
+		t.Error("StreamTickets.Redeem() accepted an expired ticket")
+
+		return
+	}
+
+	if len(tickets) != 0 {
+		t.Errorf("StreamTickets.Redeem() failed to remove the tickets. "+
+			"Expecting '%d', got '%d'", 0, len(tickets))
+
+		return
+	}
+}
@@ -0,0 +1,293 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	TOKEN_PREFIX = "trap_"
+
+	// Length of the token hash prefix which been used as the token ID
+	tokenIDLen = 6
+)
+
+// Token is a long-lived credential for automation clients. Only the
+// SHA-256 hash of the token is kept
+type Token struct {
+	ID       types.String
+	Name     types.String
+	Scopes   []types.String
+	Networks []*net.IPNet
+	Expire   time.Time
+	Created  time.Time
+	Creator  types.String
+	Static   bool
+
+	hash       [sha256.Size]byte
+	permission Permission
+	lastUsed   time.Time
+}
+
+type TokenDump struct {
+	ID       types.String
+	Name     types.String
+	Scopes   []types.String
+	Networks []types.String
+	Expire   time.Time
+	Created  time.Time
+	Creator  types.String
+	Static   bool
+	LastUsed time.Time
+}
+
+// TokenRequest is the request to create a new API token, a zero Expire
+// means the token never expires
+type TokenRequest struct {
+	Name     types.String
+	Scopes   []types.String
+	Networks []types.String
+	Expire   time.Time
+}
+
+type TokenRespond struct {
+	Token types.String
+	Info  TokenDump
+}
+
+// ParseNetwork reads a CIDR, or an IP address as the network which only
+// contains itself
+func ParseNetwork(network types.String) (*net.IPNet, *types.Throw) {
+	if !strings.Contains(network.String(), "/") {
+		ip := net.ParseIP(network.String())
+
+		if ip == nil {
+			return nil, ErrInvalidUserIPAddress.Throw(network)
+		}
+
+		bits := 128
+
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+
+		return &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(bits, bits),
+		}, nil
+	}
+
+	_, ipNet, ipNetErr := net.ParseCIDR(network.String())
+
+	if ipNetErr != nil {
+		return nil, types.ConvertError(ipNetErr)
+	}
+
+	return ipNet, nil
+}
+
+// NewTokenSecret generates a random token and the hex encoded SHA-256
+// hash of it
+func NewTokenSecret() (types.String, types.String, *types.Throw) {
+	rBytes := make([]byte, 32)
+
+	_, rErr := rand.Read(rBytes)
+
+	if rErr != nil {
+		return "", "", types.ConvertError(rErr)
+	}
+
+	secret := types.String(TOKEN_PREFIX +
+		base64.RawURLEncoding.EncodeToString(rBytes))
+	hash := sha256.Sum256(secret.Bytes())
+
+	return secret, types.String(hex.EncodeToString(hash[:])), nil
+}
+
+// Allowed checks whether the token has the permission, scopes of the
+// token are granted the same way as the permissions of an account
+func (t *Token) Allowed(name types.String) bool {
+	return t.permission.Allowed(name)
+}
+
+func (t *Token) Permission() Permission {
+	return t.permission
+}
+
+func (t *Token) Expired(now time.Time) bool {
+	if t.Expire.IsZero() {
+		return false
+	}
+
+	return !now.Before(t.Expire)
+}
+
+// Permits checks whether the token can be used from the address, tokens
+// without networks can be used from anywhere
+func (t *Token) Permits(ip net.IP) bool {
+	if len(t.Networks) <= 0 {
+		return true
+	}
+
+	for _, network := range t.Networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t *Token) Dump() TokenDump {
+	networks := []types.String{}
+
+	for _, network := range t.Networks {
+		networks = append(networks, types.String(network.String()))
+	}
+
+	return TokenDump{
+		ID:       t.ID,
+		Name:     t.Name,
+		Scopes:   t.Scopes,
+		Networks: networks,
+		Expire:   t.Expire,
+		Created:  t.Created,
+		Creator:  t.Creator,
+		Static:   t.Static,
+		LastUsed: t.lastUsed,
+	}
+}
+
+type Tokens map[types.String]*Token
+
+// Add the token with the hex encoded SHA-256 hash of it's secret
+func (t Tokens) Add(token Token, hash types.String) (*Token, *types.Throw) {
+	hashBytes, hashErr := hex.DecodeString(hash.Lower().String())
+
+	if hashErr != nil || len(hashBytes) != sha256.Size {
+		return nil, ErrTokenInvalidHash.Throw(hash)
+	}
+
+	token.permission = Permission{}
+
+	for _, scope := range token.Scopes {
+		scopeErr := token.permission.Authorize(scope)
+
+		if scopeErr != nil {
+			return nil, scopeErr
+		}
+	}
+
+	copy(token.hash[:], hashBytes)
+
+	token.ID = types.String(hex.EncodeToString(token.hash[:tokenIDLen]))
+
+	if _, ok := t[token.ID]; ok {
+		return nil, ErrTokenAlreadyExisted.Throw(token.ID)
+	}
+
+	if token.Created.IsZero() {
+		token.Created = time.Now()
+	}
+
+	t[token.ID] = &token
+
+	return t[token.ID], nil
+}
+
+// Create a new token, the secret will be returned and can't be retrieved
+// again
+func (t Tokens) Create(token Token) (*Token, types.String, *types.Throw) {
+	secret, hash, secretErr := NewTokenSecret()
+
+	if secretErr != nil {
+		return nil, "", secretErr
+	}
+
+	created, addErr := t.Add(token, hash)
+
+	if addErr != nil {
+		return nil, "", addErr
+	}
+
+	return created, secret, nil
+}
+
+// Verify finds the token of the secret, and makes sure it's usable from
+// the address
+func (t Tokens) Verify(ip net.IP, secret types.String) (*Token, *types.Throw) {
+	hash := sha256.Sum256(secret.Bytes())
+	id := types.String(hex.EncodeToString(hash[:tokenIDLen]))
+
+	token, found := t[id]
+
+	if !found || subtle.ConstantTimeCompare(token.hash[:], hash[:]) != 1 {
+		return nil, ErrTokenInvalid.Throw()
+	}
+
+	now := time.Now()
+
+	if token.Expired(now) {
+		return nil, ErrTokenExpired.Throw(token.ID)
+	}
+
+	if !token.Permits(ip) {
+		return nil, ErrTokenNetworkNotAllowed.Throw(token.ID, ip)
+	}
+
+	token.lastUsed = now
+
+	return token, nil
+}
+
+func (t Tokens) Revoke(id types.String) (*Token, *types.Throw) {
+	token, found := t[id]
+
+	if !found {
+		return nil, ErrTokenNotFound.Throw(id)
+	}
+
+	delete(t, id)
+
+	return token, nil
+}
+
+func (t Tokens) Dump() []TokenDump {
+	result := []TokenDump{}
+
+	for _, token := range t {
+		result = append(result, token.Dump())
+	}
+
+	return result
+}
@@ -0,0 +1,181 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"github.com/raincious/trap/trap/core/status/permission"
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTokensVerify(t *testing.T) {
+	tokens := Tokens{}
+	network, _ := ParseNetwork("10.0.0.0/8")
+
+	token, secret, createErr := tokens.Create(Token{
+		Name:     "ci",
+		Scopes:   []types.String{"clients:write", "logs:read"},
+		Networks: []*net.IPNet{network},
+	})
+
+	if createErr != nil {
+		t.Errorf("Tokens.Create() failed due to error: %s", createErr)
+
+		return
+	}
+
+	verified, verifyErr := tokens.Verify(net.ParseIP("10.1.2.3"), secret)
+
+	if verifyErr != nil || verified != token {
+		t.Errorf("Tokens.Verify() failed to verify the token. Got '%s'",
+			verifyErr)
+
+		return
+	}
+
+	_, verifyErr = tokens.Verify(net.ParseIP("192.0.2.1"), secret)
+
+	if verifyErr == nil || !verifyErr.Is(ErrTokenNetworkNotAllowed) {
+		t.Errorf("Tokens.Verify() failed to reject the address. Got '%s'",
+			verifyErr)
+
+		return
+	}
+
+	_, verifyErr = tokens.Verify(net.ParseIP("10.1.2.3"), secret+"x")
+
+	if verifyErr == nil || !verifyErr.Is(ErrTokenInvalid) {
+		t.Errorf("Tokens.Verify() failed to reject wrong token. Got '%s'",
+			verifyErr)
+
+		return
+	}
+
+	_, revokeErr := tokens.Revoke(token.ID)
+
+	if revokeErr != nil {
+		t.Errorf("Tokens.Revoke() failed due to error: %s", revokeErr)
+
+		return
+	}
+
+	_, verifyErr = tokens.Verify(net.ParseIP("10.1.2.3"), secret)
+
+	if verifyErr == nil || !verifyErr.Is(ErrTokenInvalid) {
+		t.Errorf("Tokens.Verify() accepted revoked token. Got '%s'",
+			verifyErr)
+
+		return
+	}
+}
+
+func TestTokensExpire(t *testing.T) {
+	tokens := Tokens{}
+	secret, hash, _ := NewTokenSecret()
+
+	_, addErr := tokens.Add(Token{
+		Name:   "expired",
+		Scopes: []types.String{"logs:read"},
+		Expire: time.Now().Add(-time.Second),
+	}, hash.Upper())
+
+	if addErr != nil {
+		t.Errorf("Tokens.Add() failed due to error: %s", addErr)
+
+		return
+	}
+
+	_, verifyErr := tokens.Verify(net.ParseIP("127.0.0.1"), secret)
+
+	if verifyErr == nil || !verifyErr.Is(ErrTokenExpired) {
+		t.Errorf("Tokens.Verify() failed to reject expired token. "+
+			"Got '%s'", verifyErr)
+
+		return
+	}
+
+	_, addErr = tokens.Add(Token{Name: "bad"}, "not a hash")
+
+	if addErr == nil || !addErr.Is(ErrTokenInvalidHash) {
+		t.Errorf("Tokens.Add() failed to reject invalid hash. Got '%s'",
+			addErr)
+
+		return
+	}
+}
+
+func TestSessionAllowed(t *testing.T) {
+	tokens := Tokens{}
+
+	token, _, createErr := tokens.Create(Token{
+		Name:   "ci",
+		Scopes: []types.String{"clients:write", "logs:read"},
+	})
+
+	if createErr != nil {
+		t.Errorf("Tokens.Create() failed due to error: %s", createErr)
+
+		return
+	}
+
+	sess := NewTokenSession(net.ParseIP("127.0.0.1"), token)
+
+	if !sess.Allowed("clients:write") || !sess.Allowed("clients:read") ||
+		!sess.Allowed("logs:read") {
+		t.Error("Session.Allowed() failed to grant the scopes of the token")
+
+		return
+	}
+
+	if sess.Allowed("logs:write") || sess.Allowed("sync:read") {
+		t.Error("Session.Allowed() granted scopes which token doesn't have")
+
+		return
+	}
+
+	if sess.Actor() != "token:"+token.ID {
+		t.Errorf("Session.Actor() failed to name the token. "+
+			"Expecting '%s', got '%s'", "token:"+token.ID, sess.Actor())
+
+		return
+	}
+}
+
+func TestTokensAddUnknownScope(t *testing.T) {
+	tokens := Tokens{}
+	_, hash, _ := NewTokenSecret()
+
+	_, addErr := tokens.Add(Token{
+		Name:   "unknown",
+		Scopes: []types.String{"clients:delete"},
+	}, hash)
+
+	if addErr == nil || !addErr.Is(permission.ErrUnknownPermission) {
+		t.Errorf("Tokens.Add() failed to reject unknown scope. Got '%s'",
+			addErr)
+
+		return
+	}
+}
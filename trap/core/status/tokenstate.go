@@ -0,0 +1,141 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// TokenRecord is a token saved in the token state file, only the hash
+// of the token is saved
+type TokenRecord struct {
+	Name     types.String
+	Hash     types.String
+	Scopes   []types.String
+	Networks []types.String
+	Expire   time.Time
+	Created  time.Time
+	Creator  types.String
+}
+
+// TokenState is the content of the token state file. It keeps the
+// tokens created by the API, and the IDs of the revoked tokens so the
+// revoked tokens of the configuration will not come back
+type TokenState struct {
+	Tokens  []TokenRecord
+	Revoked []types.String
+}
+
+func (t *Token) Record() TokenRecord {
+	return TokenRecord{
+		Name:     t.Name,
+		Hash:     types.String(hex.EncodeToString(t.hash[:])),
+		Scopes:   t.Scopes,
+		Networks: t.Dump().Networks,
+		Expire:   t.Expire,
+		Created:  t.Created,
+		Creator:  t.Creator,
+	}
+}
+
+// Restore adds the saved token to the tokens
+func (t Tokens) Restore(record TokenRecord) (*Token, *types.Throw) {
+	token := Token{
+		Name:     record.Name,
+		Scopes:   record.Scopes,
+		Networks: nil,
+		Expire:   record.Expire,
+		Created:  record.Created,
+		Creator:  record.Creator,
+	}
+
+	for _, network := range record.Networks {
+		ipNet, ipNetErr := ParseNetwork(network)
+
+		if ipNetErr != nil {
+			return nil, ipNetErr
+		}
+
+		token.Networks = append(token.Networks, ipNet)
+	}
+
+	return t.Add(token, record.Hash)
+}
+
+// LoadTokenState reads the token state file, an empty state will be
+// returned when the file is not existed yet
+func LoadTokenState(path types.String) (TokenState, *types.Throw) {
+	state := TokenState{
+		Tokens:  []TokenRecord{},
+		Revoked: []types.String{},
+	}
+
+	data, readErr := ioutil.ReadFile(path.String())
+
+	if os.IsNotExist(readErr) {
+		return state, nil
+	}
+
+	if readErr != nil {
+		return state, ErrTokenStateInvalid.Throw(path, readErr)
+	}
+
+	jsonErr := json.Unmarshal(data, &state)
+
+	if jsonErr != nil {
+		return state, ErrTokenStateInvalid.Throw(path, jsonErr)
+	}
+
+	return state, nil
+}
+
+// Save replaces the token state file at once, so a crash will not leave
+// a partial file behind
+func (s TokenState) Save(path types.String) *types.Throw {
+	data, jsonErr := json.MarshalIndent(s, "", "    ")
+
+	if jsonErr != nil {
+		return ErrTokenStateSave.Throw(path, jsonErr)
+	}
+
+	tempFile := path.String() + ".tmp"
+
+	writeErr := ioutil.WriteFile(tempFile, data, 0600)
+
+	if writeErr == nil {
+		writeErr = os.Rename(tempFile, path.String())
+	}
+
+	if writeErr != nil {
+		os.Remove(tempFile)
+
+		return ErrTokenStateSave.Throw(path, writeErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenStateSaveNLoad(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-token-state-test-")
+
+	if dirErr != nil {
+		t.Error("Can't create temp dir:", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := types.String(filepath.Join(dir, "tokens.json"))
+
+	state, loadErr := LoadTokenState(path)
+
+	if loadErr != nil || len(state.Tokens) != 0 || len(state.Revoked) != 0 {
+		t.Errorf("LoadTokenState() failed to load a non-existed file. "+
+			"Got '%s'", loadErr)
+
+		return
+	}
+
+	tokens := Tokens{}
+	network, _ := ParseNetwork("10.0.0.0/8")
+
+	token, secret, createErr := tokens.Create(Token{
+		Name:     "ci",
+		Scopes:   []types.String{"clients:write"},
+		Networks: []*net.IPNet{network},
+	})
+
+	if createErr != nil {
+		t.Errorf("Tokens.Create() failed due to error: %s", createErr)
+
+		return
+	}
+
+	state.Tokens = append(state.Tokens, token.Record())
+	state.Revoked = append(state.Revoked, "abcdef")
+
+	saveErr := state.Save(path)
+
+	if saveErr != nil {
+		t.Errorf("TokenState.Save() failed due to error: %s", saveErr)
+
+		return
+	}
+
+	loaded, loadErr := LoadTokenState(path)
+
+	if loadErr != nil || len(loaded.Tokens) != 1 ||
+		len(loaded.Revoked) != 1 || loaded.Revoked[0] != "abcdef" {
+		t.Errorf("LoadTokenState() failed to load the saved state. "+
+			"Got '%s'", loadErr)
+
+		return
+	}
+
+	restoredTokens := Tokens{}
+
+	restored, restoreErr := restoredTokens.Restore(loaded.Tokens[0])
+
+	if restoreErr != nil || restored.ID != token.ID ||
+		!restored.Created.Equal(token.Created) {
+		t.Errorf("Tokens.Restore() failed to restore the token. Got '%s'",
+			restoreErr)
+
+		return
+	}
+
+	_, verifyErr := restoredTokens.Verify(net.ParseIP("10.1.2.3"), secret)
+
+	if verifyErr != nil {
+		t.Errorf("Tokens.Verify() failed to verify the restored token. "+
+			"Got '%s'", verifyErr)
+
+		return
+	}
+
+	_, verifyErr = restoredTokens.Verify(net.ParseIP("192.0.2.1"), secret)
+
+	if verifyErr == nil || !verifyErr.Is(ErrTokenNetworkNotAllowed) {
+		t.Errorf("Tokens.Verify() failed to restore the networks. "+
+			"Got '%s'", verifyErr)
+
+		return
+	}
+}
+
+func TestTokenStateInvalid(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-token-state-test-")
+
+	if dirErr != nil {
+		t.Error("Can't create temp dir:", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "tokens.json")
+
+	ioutil.WriteFile(path, []byte("{"), 0600)
+
+	_, loadErr := LoadTokenState(types.String(path))
+
+	if loadErr == nil || !loadErr.Is(ErrTokenStateInvalid) {
+		t.Errorf("LoadTokenState() failed to reject invalid file. "+
+			"Got '%s'", loadErr)
+
+		return
+	}
+}
@@ -45,6 +45,9 @@ type Common struct {
 	IsAuthed      func(net.Addr) bool
 	MarkClients   func(*conn.Conn, []server.ClientInfo) *types.Throw
 	UnmarkClients func(*conn.Conn, []types.IP) *types.Throw
+
+	MarkPrefixes   func(*conn.Conn, []server.PrefixInfo) *types.Throw
+	UnmarkPrefixes func(*conn.Conn, []server.PrefixInfo) *types.Throw
 }
 
 func (c *Common) ClientsMarked(req messager.Request) *types.Throw {
@@ -115,3 +118,72 @@ func (c *Common) ClientsUnmarked(req messager.Request) *types.Throw {
 	return req.Reply(messager.SYNC_SIGNAL_CLIENT_UNMARK_ACCEPT,
 		&data.Undefined{})
 }
+
+func (c *Common) PrefixesMarked(req messager.Request) *types.Throw {
+	marked := &data.PrefixMark{}
+
+	if !c.IsAuthed(req.RemoteAddr()) {
+		req.Reply(messager.SYNC_SIGNAL_PREFIX_MARK_DENIED, &data.Undefined{})
+
+		req.Close()
+
+		return ErrControllerServerClientNotLoggedIn.Throw(req.RemoteAddr())
+	}
+
+	parseErr := marked.Parse(req.Data())
+
+	if parseErr != nil {
+		req.Reply(messager.SYNC_SIGNAL_PREFIX_MARK_DENIED, &data.Undefined{})
+
+		req.Close()
+
+		return ErrControllerInvalidData.Throw(req.RemoteAddr(), parseErr)
+	}
+
+	markErr := c.MarkPrefixes(req.Conn(), marked.Prefixes)
+
+	if markErr != nil {
+		req.Reply(messager.SYNC_SIGNAL_PREFIX_MARK_DENIED, &data.Undefined{})
+
+		req.Close()
+
+		return markErr
+	}
+
+	return req.Reply(messager.SYNC_SIGNAL_PREFIX_MARK_ACCEPT, &data.Undefined{})
+}
+
+func (c *Common) PrefixesUnmarked(req messager.Request) *types.Throw {
+	unmarked := &data.PrefixUnmark{}
+
+	if !c.IsAuthed(req.RemoteAddr()) {
+		req.Reply(messager.SYNC_SIGNAL_PREFIX_UNMARK_DENIED, &data.Undefined{})
+
+		req.Close()
+
+		return ErrControllerServerClientNotLoggedIn.Throw(req.RemoteAddr())
+	}
+
+	parseErr := unmarked.Parse(req.Data())
+
+	if parseErr != nil {
+		req.Reply(messager.SYNC_SIGNAL_PREFIX_UNMARK_DENIED, &data.Undefined{})
+
+		req.Close()
+
+		return ErrControllerInvalidData.Throw(req.RemoteAddr(), parseErr)
+	}
+
+	unmarkErr := c.UnmarkPrefixes(req.Conn(), unmarked.Prefixes)
+
+	if unmarkErr != nil {
+		req.Reply(messager.SYNC_SIGNAL_PREFIX_UNMARK_DENIED, &data.Undefined{})
+
+		req.Close()
+
+		return unmarkErr
+	}
+
+	return req.Reply(messager.SYNC_SIGNAL_PREFIX_UNMARK_ACCEPT,
+		&data.Undefined{})
+}
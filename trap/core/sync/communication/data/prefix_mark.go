@@ -0,0 +1,75 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package data
+
+import (
+	"github.com/raincious/trap/trap/core/server"
+	"github.com/raincious/trap/trap/core/types"
+)
+
+type PrefixMark struct {
+	Base
+
+	Prefixes []server.PrefixInfo
+}
+
+func (d *PrefixMark) Parse(msg [][]byte) *types.Throw {
+	verifyErr := d.Verify(msg, 1)
+
+	if verifyErr != nil {
+		return verifyErr
+	}
+
+	for _, data := range msg {
+		prefixInfo := server.PrefixInfo{}
+
+		prefixSerErr := prefixInfo.Unserialize(data)
+
+		if prefixSerErr != nil {
+			return prefixSerErr
+		}
+
+		d.Prefixes = append(d.Prefixes, prefixInfo)
+	}
+
+	return nil
+}
+
+func (d *PrefixMark) Build() ([][]byte, *types.Throw) {
+	result := [][]byte{}
+
+	for _, prefix := range d.Prefixes {
+		prefixBy, pErr := prefix.Serialize()
+
+		if pErr != nil {
+			return [][]byte{}, pErr
+		}
+
+		result = append(result, prefixBy)
+	}
+
+	return result, nil
+}
+
+type PrefixUnmark struct {
+	PrefixMark
+}
@@ -46,6 +46,12 @@ const (
 	SYNC_SIGNAL_CLIENT_UNMARK         = byte(17)
 	SYNC_SIGNAL_CLIENT_UNMARK_ACCEPT  = byte(18)
 	SYNC_SIGNAL_CLIENT_UNMARK_DENIED  = byte(19)
+	SYNC_SIGNAL_PREFIX_MARK           = byte(20)
+	SYNC_SIGNAL_PREFIX_MARK_ACCEPT    = byte(21)
+	SYNC_SIGNAL_PREFIX_MARK_DENIED    = byte(22)
+	SYNC_SIGNAL_PREFIX_UNMARK         = byte(23)
+	SYNC_SIGNAL_PREFIX_UNMARK_ACCEPT  = byte(24)
+	SYNC_SIGNAL_PREFIX_UNMARK_DENIED  = byte(25)
 )
 
 const (
@@ -244,6 +244,40 @@ func (s *Server) BroadcastUnmarkClients(
 	return err
 }
 
+func (s *Server) BroadcastMarkPrefixes(
+	excludes []*conn.Conn,
+	prefixes []server.PrefixInfo,
+	retry uint16,
+) *types.Throw {
+	var err *types.Throw = nil
+
+	s.Broadcast(excludes,
+		func(key string, sess *Session) *types.Throw {
+			err = sess.MarkPrefixes(prefixes)
+
+			return nil
+		}, retry)
+
+	return err
+}
+
+func (s *Server) BroadcastUnmarkPrefixes(
+	excludes []*conn.Conn,
+	prefixes []server.PrefixInfo,
+	retry uint16,
+) *types.Throw {
+	var err *types.Throw = nil
+
+	s.Broadcast(excludes,
+		func(key string, sess *Session) *types.Throw {
+			err = sess.UnmarkPrefixes(prefixes)
+
+			return nil
+		}, retry)
+
+	return err
+}
+
 func (s *Server) Down() *types.Throw {
 	if s.server == nil {
 		return ErrServerNotUp.Throw()
@@ -59,6 +59,12 @@ var (
 
 	ErrSessionClientUnmarkDenied *types.Error = types.NewError(
 		"'%s' refused to handle 'Client Unmark' request")
+
+	ErrSessionPrefixMarkDenied *types.Error = types.NewError(
+		"'%s' refused to handle 'Prefix Mark' request")
+
+	ErrSessionPrefixUnmarkDenied *types.Error = types.NewError(
+		"'%s' refused to handle 'Prefix Unmark' request")
 )
 
 type Session struct {
@@ -391,3 +397,63 @@ func (s *Session) UnmarkClients(clients []types.IP) *types.Throw {
 
 	return nil
 }
+
+func (s *Session) MarkPrefixes(prefixes []server.PrefixInfo) *types.Throw {
+	handle := messager.Callbacks{}
+	mark := &data.PrefixMark{}
+
+	handle.Register(messager.SYNC_SIGNAL_PREFIX_MARK_ACCEPT,
+		func(req messager.Request) *types.Throw {
+			return nil
+		})
+
+	handle.Register(messager.SYNC_SIGNAL_PREFIX_MARK_DENIED,
+		func(req messager.Request) *types.Throw {
+			return ErrSessionPrefixMarkDenied.Throw(req.RemoteAddr())
+		})
+
+	mark.Prefixes = prefixes
+
+	reqErr := s.Request().Query(
+		messager.SYNC_SIGNAL_PREFIX_MARK,
+		mark,
+		handle,
+		s.requestTimeout,
+	)
+
+	if reqErr != nil {
+		return reqErr
+	}
+
+	return nil
+}
+
+func (s *Session) UnmarkPrefixes(prefixes []server.PrefixInfo) *types.Throw {
+	handle := messager.Callbacks{}
+	um := &data.PrefixUnmark{}
+
+	handle.Register(messager.SYNC_SIGNAL_PREFIX_UNMARK_ACCEPT,
+		func(req messager.Request) *types.Throw {
+			return nil
+		})
+
+	handle.Register(messager.SYNC_SIGNAL_PREFIX_UNMARK_DENIED,
+		func(req messager.Request) *types.Throw {
+			return ErrSessionPrefixUnmarkDenied.Throw(req.RemoteAddr())
+		})
+
+	um.Prefixes = prefixes
+
+	reqErr := s.Request().Query(
+		messager.SYNC_SIGNAL_PREFIX_UNMARK,
+		um,
+		handle,
+		s.requestTimeout,
+	)
+
+	if reqErr != nil {
+		return reqErr
+	}
+
+	return nil
+}
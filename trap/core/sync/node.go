@@ -190,6 +190,10 @@ func (n *Node) client() *communication.Client {
 		clientController.ClientsMarked)
 	handle.Register(messager.SYNC_SIGNAL_CLIENT_UNMARK,
 		clientController.ClientsUnmarked)
+	handle.Register(messager.SYNC_SIGNAL_PREFIX_MARK,
+		clientController.PrefixesMarked)
+	handle.Register(messager.SYNC_SIGNAL_PREFIX_UNMARK,
+		clientController.PrefixesUnmarked)
 
 	n.nclient = communication.NewClient(
 		n.sessions,
@@ -279,6 +279,40 @@ func (n *Nodes) BroadcastUnmarkClients(
 	return err
 }
 
+func (n *Nodes) BroadcastMarkPrefixes(
+	excludes []*conn.Conn,
+	prefixes []server.PrefixInfo,
+	retry uint16,
+) *types.Throw {
+	var err *types.Throw = nil
+
+	n.Broadcast(excludes,
+		func(key string, sess *communication.Session) *types.Throw {
+			err = sess.MarkPrefixes(prefixes)
+
+			return nil
+		}, retry)
+
+	return err
+}
+
+func (n *Nodes) BroadcastUnmarkPrefixes(
+	excludes []*conn.Conn,
+	prefixes []server.PrefixInfo,
+	retry uint16,
+) *types.Throw {
+	var err *types.Throw = nil
+
+	n.Broadcast(excludes,
+		func(key string, sess *communication.Session) *types.Throw {
+			err = sess.UnmarkPrefixes(prefixes)
+
+			return nil
+		}, retry)
+
+	return err
+}
+
 func (n *Nodes) Clear() *types.Throw {
 	var err *types.Throw = nil
 
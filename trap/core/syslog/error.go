@@ -0,0 +1,40 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package syslog
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+)
+
+var (
+	ErrUnsupportedNetwork *types.Error = types.NewError(
+		"Syslog network '%s' is not supported")
+
+	ErrUnknownFacility *types.Error = types.NewError(
+		"Unknown syslog facility '%s'")
+
+	ErrWriterClosed *types.Error = types.NewError(
+		"Syslog writer has been closed")
+
+	ErrQueueFull *types.Error = types.NewError(
+		"Syslog queue is full, message has been dropped")
+)
@@ -0,0 +1,382 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package syslog
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SEVERITY_EMERGENCY = iota
+	SEVERITY_ALERT
+	SEVERITY_CRITICAL
+	SEVERITY_ERROR
+	SEVERITY_WARNING
+	SEVERITY_NOTICE
+	SEVERITY_INFO
+	SEVERITY_DEBUG
+)
+
+const (
+	TIMESTAMP_FORMAT = "2006-01-02T15:04:05.000000Z07:00"
+
+	MAX_APP_NAME_LEN = 48
+	MAX_MSG_ID_LEN   = 32
+
+	WRITER_QUEUE_SIZE = 1024
+	WRITER_CLOSE_WAIT = 5 * time.Second
+)
+
+var facilities = map[types.String]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// ParseFacility converts the facility name like `daemon` or `local0`
+// into it's code
+func ParseFacility(name types.String) (int, *types.Throw) {
+	facility, ok := facilities[name.Trim().Lower()]
+
+	if !ok {
+		return 0, ErrUnknownFacility.Throw(name)
+	}
+
+	return facility, nil
+}
+
+// Writer sends RFC 5424 messages to a syslog server over `udp`, `tcp`
+// or an `unix` socket. Messages on stream connections are framed with
+// octet counting (RFC 6587).
+//
+// Messages are queued and sent from the Writer's own goroutine, so an
+// unreachable server will not block the caller. Messages will be dropped
+// when the queue is full. The connection is established when the first
+// message is sent, and will be re-established once when a write failed
+type Writer struct {
+	network types.String
+	address types.String
+
+	hostname types.String
+	appName  types.String
+	procID   types.String
+
+	queue     chan []byte
+	queueLock sync.RWMutex
+	done      chan struct{}
+	closing   types.UInt64
+
+	lock    sync.Mutex
+	onError func(error)
+	failed  bool
+
+	// Only accessed by the sending goroutine
+	conn   net.Conn
+	stream bool
+
+	stats WriterStats
+}
+
+type WriterStats struct {
+	Sent    types.UInt64
+	Failed  types.UInt64
+	Dropped types.UInt64
+}
+
+// Dial creates a Writer for the server, the connection will be made in
+// background so an unreachable server is not an error here
+func Dial(network types.String, address types.String,
+	appName types.String) (*Writer, *types.Throw) {
+	switch network {
+	case "udp", "tcp", "unix":
+
+	default:
+		return nil, ErrUnsupportedNetwork.Throw(network)
+	}
+
+	hostname, hostErr := os.Hostname()
+
+	if hostErr != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	w := &Writer{
+		network:  network,
+		address:  address,
+		hostname: header(types.String(hostname), 255),
+		appName:  header(appName, MAX_APP_NAME_LEN),
+		procID:   types.String(fmt.Sprintf("%d", os.Getpid())),
+		queue:    make(chan []byte, WRITER_QUEUE_SIZE),
+		done:     make(chan struct{}),
+	}
+
+	go w.work(w.queue)
+
+	return w, nil
+}
+
+// OnError sets the callback which will be called when the Writer starts
+// failing to send messages, it will not be called again until the
+// sending recovered
+func (w *Writer) OnError(callback func(error)) {
+	w.lock.Lock()
+
+	defer w.lock.Unlock()
+
+	w.onError = callback
+}
+
+func (w *Writer) Stats() WriterStats {
+	return WriterStats{
+		Sent:    w.stats.Sent.AtomicLoad(),
+		Failed:  w.stats.Failed.AtomicLoad(),
+		Dropped: w.stats.Dropped.AtomicLoad(),
+	}
+}
+
+// Header fields only allow printable US-ASCII without spaces
+func header(value types.String, maxLen int) types.String {
+	result := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+
+		return r
+	}, value.String())
+
+	if result == "" {
+		return "-"
+	}
+
+	if len(result) > maxLen {
+		result = result[:maxLen]
+	}
+
+	return types.String(result)
+}
+
+func (w *Writer) dial() error {
+	var conn net.Conn = nil
+	var dialErr error = nil
+
+	switch w.network {
+	case "unix":
+		// Most local syslog daemons listen on datagram sockets
+		conn, dialErr = net.Dial("unixgram", w.address.String())
+
+		if dialErr == nil {
+			w.stream = false
+
+			break
+		}
+
+		conn, dialErr = net.Dial("unix", w.address.String())
+		w.stream = true
+
+	default:
+		conn, dialErr = net.DialTimeout(w.network.String(),
+			w.address.String(), 10*time.Second)
+		w.stream = w.network == "tcp"
+	}
+
+	if dialErr != nil {
+		return dialErr
+	}
+
+	w.conn = conn
+
+	return nil
+}
+
+// Format builds the RFC 5424 message
+func (w *Writer) Format(facility int, severity int, t time.Time,
+	msgID types.String, msg types.String) []byte {
+	return []byte(fmt.Sprintf("<%d>1 %s %s %s %s %s - %s",
+		facility*8+severity, t.Format(TIMESTAMP_FORMAT), w.hostname,
+		w.appName, w.procID, header(msgID, MAX_MSG_ID_LEN), msg))
+}
+
+func (w *Writer) send(message []byte) error {
+	if w.conn == nil {
+		dialErr := w.dial()
+
+		if dialErr != nil {
+			return dialErr
+		}
+	}
+
+	if w.stream {
+		message = append([]byte(fmt.Sprintf("%d ", len(message))),
+			message...)
+	}
+
+	w.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+	_, writeErr := w.conn.Write(message)
+
+	return writeErr
+}
+
+func (w *Writer) deliver(message []byte) error {
+	sendErr := w.send(message)
+
+	if sendErr == nil {
+		return nil
+	}
+
+	// Reconnect and try again, the server may have been restarted
+	if w.conn != nil {
+		w.conn.Close()
+
+		w.conn = nil
+	}
+
+	return w.send(message)
+}
+
+func (w *Writer) work(queue chan []byte) {
+	defer close(w.done)
+
+	defer func() {
+		if w.conn != nil {
+			w.conn.Close()
+
+			w.conn = nil
+		}
+	}()
+
+	for message := range queue {
+		// Don't keep trying an unreachable server after closed
+		if w.closing.AtomicLoad() > 0 && w.failing() {
+			w.stats.Dropped.AtomicAdd(1)
+
+			continue
+		}
+
+		sendErr := w.deliver(message)
+
+		w.report(sendErr)
+	}
+}
+
+func (w *Writer) failing() bool {
+	w.lock.Lock()
+
+	defer w.lock.Unlock()
+
+	return w.failed
+}
+
+func (w *Writer) report(sendErr error) {
+	if sendErr == nil {
+		w.stats.Sent.AtomicAdd(1)
+	} else {
+		w.stats.Failed.AtomicAdd(1)
+	}
+
+	w.lock.Lock()
+
+	startFailing := sendErr != nil && !w.failed
+	onError := w.onError
+
+	w.failed = sendErr != nil
+
+	w.lock.Unlock()
+
+	if startFailing && onError != nil {
+		onError(fmt.Errorf("Can't send message to syslog '%s' due to "+
+			"error: %s", w.address, sendErr))
+	}
+}
+
+// Write queues the message to be sent to the server
+func (w *Writer) Write(facility int, severity int, t time.Time,
+	msgID types.String, msg types.String) *types.Throw {
+	w.queueLock.RLock()
+
+	defer w.queueLock.RUnlock()
+
+	if w.queue == nil {
+		return ErrWriterClosed.Throw()
+	}
+
+	select {
+	case w.queue <- w.Format(facility, severity, t, msgID, msg):
+		return nil
+
+	default:
+		w.stats.Dropped.AtomicAdd(1)
+
+		return ErrQueueFull.Throw()
+	}
+}
+
+// Close stops accepting messages and waits a while for the queued ones
+// to be sent
+func (w *Writer) Close() *types.Throw {
+	w.queueLock.Lock()
+
+	if w.queue == nil {
+		w.queueLock.Unlock()
+
+		return nil
+	}
+
+	close(w.queue)
+
+	w.queue = nil
+
+	w.queueLock.Unlock()
+
+	w.closing.AtomicStore(1)
+
+	select {
+	case <-w.done:
+	case <-time.After(WRITER_CLOSE_WAIT):
+	}
+
+	return nil
+}
@@ -0,0 +1,307 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package syslog
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var messagePattern = regexp.MustCompile(
+	`^<(\d+)>1 \S+ \S+ trap \d+ (\S+) - (.*)$`)
+
+func TestParseFacility(t *testing.T) {
+	facility, err := ParseFacility("Local0")
+
+	if err != nil || facility != 16 {
+		t.Errorf("ParseFacility() failed to parse facility. "+
+			"Expecting '%d', got '%d'", 16, facility)
+
+		return
+	}
+
+	_, err = ParseFacility("local9")
+
+	if err == nil || !err.Is(ErrUnknownFacility) {
+		t.Errorf("ParseFacility() failed to reject unknown facility. "+
+			"Got '%s'", err)
+
+		return
+	}
+}
+
+func TestWriterUDP(t *testing.T) {
+	listener, listenErr := net.ListenPacket("udp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Errorf("Can't listen due to error: %s", listenErr)
+
+		return
+	}
+
+	defer listener.Close()
+
+	w, dialErr := Dial("udp", types.String(listener.LocalAddr().String()),
+		"trap")
+
+	if dialErr != nil {
+		t.Errorf("Dial() failed due to error: %s", dialErr)
+
+		return
+	}
+
+	defer w.Close()
+
+	writeErr := w.Write(16, SEVERITY_WARNING, time.Now(),
+		"Trap:Sync Server", "Hello syslog")
+
+	if writeErr != nil {
+		t.Errorf("Writer.Write() failed due to error: %s", writeErr)
+
+		return
+	}
+
+	buf := make([]byte, 1024)
+
+	listener.SetReadDeadline(time.Now().Add(3 * time.Second))
+
+	n, _, readErr := listener.ReadFrom(buf)
+
+	if readErr != nil {
+		t.Errorf("Can't read message due to error: %s", readErr)
+
+		return
+	}
+
+	matched := messagePattern.FindStringSubmatch(string(buf[:n]))
+
+	if matched == nil {
+		t.Errorf("Writer.Write() sent malformed message '%s'", buf[:n])
+
+		return
+	}
+
+	if matched[1] != "132" || matched[2] != "Trap:Sync_Server" ||
+		matched[3] != "Hello syslog" {
+		t.Errorf("Writer.Write() sent unexpected message '%s'", buf[:n])
+
+		return
+	}
+}
+
+func TestWriterTCP(t *testing.T) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Errorf("Can't listen due to error: %s", listenErr)
+
+		return
+	}
+
+	defer listener.Close()
+
+	received := make(chan string, 2)
+
+	go func() {
+		conn, acceptErr := listener.Accept()
+
+		if acceptErr != nil {
+			return
+		}
+
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+
+		for i := 0; i < 2; i++ {
+			var length int
+
+			_, scanErr := fmt.Fscanf(reader, "%d ", &length)
+
+			if scanErr != nil {
+				return
+			}
+
+			msg := make([]byte, length)
+
+			_, readErr := io.ReadFull(reader, msg)
+
+			if readErr != nil {
+				return
+			}
+
+			received <- string(msg)
+		}
+	}()
+
+	w, dialErr := Dial("tcp", types.String(listener.Addr().String()),
+		"trap")
+
+	if dialErr != nil {
+		t.Errorf("Dial() failed due to error: %s", dialErr)
+
+		return
+	}
+
+	defer w.Close()
+
+	w.Write(3, SEVERITY_ERROR, time.Now(), "", "First")
+	w.Write(3, SEVERITY_INFO, time.Now(), "", "Second")
+
+	for _, expected := range []string{"First", "Second"} {
+		select {
+		case msg := <-received:
+			matched := messagePattern.FindStringSubmatch(msg)
+
+			if matched == nil || matched[3] != expected {
+				t.Errorf("Writer.Write() sent unexpected message. "+
+					"Expecting '%s', got '%s'", expected, msg)
+
+				return
+			}
+
+		case <-time.After(3 * time.Second):
+			t.Error("Writer.Write() failed to deliver the message")
+
+			return
+		}
+	}
+}
+
+func TestDialUnsupportedNetwork(t *testing.T) {
+	_, dialErr := Dial("http", "127.0.0.1:514", "trap")
+
+	if dialErr == nil || !dialErr.Is(ErrUnsupportedNetwork) {
+		t.Errorf("Dial() failed to reject unsupported network. "+
+			"Got '%s'", dialErr)
+
+		return
+	}
+}
+
+func TestWriterUnreachable(t *testing.T) {
+	listener, listenErr := net.Listen("tcp", "127.0.0.1:0")
+
+	if listenErr != nil {
+		t.Error("Can't listen:", listenErr)
+
+		return
+	}
+
+	address := listener.Addr().String()
+
+	listener.Close()
+
+	w, dialErr := Dial("tcp", types.String(address), "trap")
+
+	if dialErr != nil {
+		t.Errorf("Dial() failed to create a Writer for an unreachable "+
+			"server. Got '%s'", dialErr)
+
+		return
+	}
+
+	errs := make(chan error, 10)
+
+	w.OnError(func(err error) {
+		errs <- err
+	})
+
+	for i := 0; i < 3; i++ {
+		writeErr := w.Write(16, SEVERITY_WARNING, time.Now(), "-",
+			"Unreachable")
+
+		if writeErr != nil {
+			t.Errorf("Writer.Write() failed to queue the message. "+
+				"Got '%s'", writeErr)
+
+			return
+		}
+	}
+
+	select {
+	case <-errs:
+
+	case <-time.After(3 * time.Second):
+		t.Error("Writer.OnError() failed to report the failure")
+
+		return
+	}
+
+	w.Close()
+
+	if len(errs) != 0 {
+		t.Errorf("Writer.OnError() failed to report only once. "+
+			"Got '%d' more reports", len(errs))
+
+		return
+	}
+
+	// Messages left in the queue after closing are dropped instead
+	stats := w.Stats()
+
+	if stats.Failed+stats.Dropped != 3 || stats.Sent != 0 {
+		t.Errorf("Writer.Stats() failed to count failed messages. "+
+			"Expecting '%d', got '%d'", 3, stats.Failed+stats.Dropped)
+
+		return
+	}
+}
+
+func TestWriterQueueFull(t *testing.T) {
+	// No sending goroutine, so the queue will never be drained
+	w := &Writer{
+		queue: make(chan []byte, 1),
+	}
+
+	writeErr := w.Write(16, SEVERITY_WARNING, time.Now(), "-", "First")
+
+	if writeErr != nil {
+		t.Errorf("Writer.Write() failed to queue the message. Got '%s'",
+			writeErr)
+
+		return
+	}
+
+	writeErr = w.Write(16, SEVERITY_WARNING, time.Now(), "-", "Second")
+
+	if writeErr == nil || !writeErr.Is(ErrQueueFull) {
+		t.Errorf("Writer.Write() failed to reject message when queue is "+
+			"full. Got '%s'", writeErr)
+
+		return
+	}
+
+	if w.Stats().Dropped != 1 {
+		t.Errorf("Writer.Stats() failed to count dropped messages. "+
+			"Expecting '%d', got '%d'", 1, w.Stats().Dropped)
+
+		return
+	}
+}
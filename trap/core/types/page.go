@@ -0,0 +1,53 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+// Page walks through the items from the newest (the last one) to the
+// oldest, and takes the matched ones which are older than the cursor
+// until the limit is reached. A zero cursor or limit means no restriction.
+// It returns the cursor of the next page, or 0 when there is no more
+func Page(length int, id func(int) uint64, match func(int) bool,
+	take func(int), cursor uint64, limit int) uint64 {
+	taken := 0
+	lastID := uint64(0)
+
+	for i := length - 1; i >= 0; i-- {
+		if cursor > 0 && id(i) >= cursor {
+			continue
+		}
+
+		if !match(i) {
+			continue
+		}
+
+		if limit > 0 && taken >= limit {
+			return lastID
+		}
+
+		take(i)
+
+		lastID = id(i)
+		taken++
+	}
+
+	return 0
+}
@@ -0,0 +1,89 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package types
+
+import (
+	"testing"
+)
+
+func TestPage(t *testing.T) {
+	ids := []uint64{1, 2, 3, 4, 5, 6, 7}
+
+	// Only the odd IDs match
+	page := func(cursor uint64, limit int) ([]uint64, uint64) {
+		taken := []uint64{}
+
+		next := Page(len(ids), func(i int) uint64 {
+			return ids[i]
+		}, func(i int) bool {
+			return ids[i]%2 == 1
+		}, func(i int) {
+			taken = append(taken, ids[i])
+		}, cursor, limit)
+
+		return taken, next
+	}
+
+	expected := [][]uint64{{7, 5}, {3, 1}}
+	expectedNext := []uint64{5, 0}
+	cursor := uint64(0)
+
+	for pageIdx, expectedIDs := range expected {
+		taken, next := page(cursor, 2)
+
+		if len(taken) != len(expectedIDs) {
+			t.Errorf("Page() failed to take items of page '%d'. "+
+				"Expecting '%d', got '%d'", pageIdx, len(expectedIDs),
+				len(taken))
+
+			return
+		}
+
+		for idx := range taken {
+			if taken[idx] != expectedIDs[idx] {
+				t.Errorf("Page() failed to take items in order. "+
+					"Expecting '%d', got '%d'", expectedIDs[idx], taken[idx])
+
+				return
+			}
+		}
+
+		if next != expectedNext[pageIdx] {
+			t.Errorf("Page() failed to return the cursor of page '%d'. "+
+				"Expecting '%d', got '%d'", pageIdx, expectedNext[pageIdx],
+				next)
+
+			return
+		}
+
+		cursor = next
+	}
+
+	taken, next := page(0, 0)
+
+	if len(taken) != 4 || next != 0 {
+		t.Errorf("Page() failed to take all matched items without a "+
+			"limit. Expecting '%d', got '%d'", 4, len(taken))
+
+		return
+	}
+}
@@ -232,3 +232,15 @@ func (s String) Replace(find String, replace String) String {
 
 	return String(strings.Replace(string(s), f, t, -1))
 }
+
+type Strings []String
+
+func (s Strings) ImplodeWith(imploder string) String {
+	var stringSlice []string
+
+	for _, str := range s {
+		stringSlice = append(stringSlice, str.String())
+	}
+
+	return String(strings.Join(stringSlice, imploder))
+}
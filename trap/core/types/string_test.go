@@ -425,3 +425,19 @@ func TestStringReplace(t *testing.T) {
 		return
 	}
 }
+
+func TestStringsImplodeWith(t *testing.T) {
+	strs := Strings{"this", "Is", "", "TEST"}
+
+	if strs.ImplodeWith(",") != "this,Is,,TEST" {
+		t.Error("Strings.ImplodeWith() failed to implode the test strings")
+
+		return
+	}
+
+	if (Strings{}).ImplodeWith(",") != "" {
+		t.Error("Strings.ImplodeWith() failed to implode empty strings")
+
+		return
+	}
+}
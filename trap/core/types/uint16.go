@@ -77,3 +77,9 @@ func (i *UInt16) Unserialize(data []byte) *Throw {
 
 	return nil
 }
+
+type UInt16Slice []UInt16
+
+func (p UInt16Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p UInt16Slice) Len() int           { return len(p) }
+func (p UInt16Slice) Less(i, j int) bool { return p[i] < p[j] }
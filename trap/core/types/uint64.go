@@ -23,6 +23,7 @@ package types
 
 import (
 	"strconv"
+	"sync/atomic"
 )
 
 type UInt64 uint64
@@ -74,3 +75,15 @@ func (i UInt64) UInt32() UInt32 {
 func (i UInt64) UInt64() uint64 {
 	return uint64(i)
 }
+
+func (i *UInt64) AtomicAdd(delta UInt64) UInt64 {
+	return UInt64(atomic.AddUint64((*uint64)(i), uint64(delta)))
+}
+
+func (i *UInt64) AtomicLoad() UInt64 {
+	return UInt64(atomic.LoadUint64((*uint64)(i)))
+}
+
+func (i *UInt64) AtomicStore(val UInt64) {
+	atomic.StoreUint64((*uint64)(i), uint64(val))
+}
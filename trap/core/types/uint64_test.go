@@ -23,6 +23,7 @@ package types
 
 import (
 	"strconv"
+	"sync"
 	"testing"
 )
 
@@ -146,3 +147,34 @@ func TestUInt64ToRealUInt64(t *testing.T) {
 			"Excepting '%d', got '%d'", min, MIN_UINT64, min.UInt64())
 	}
 }
+
+func TestUInt64Atomic(t *testing.T) {
+	counter := UInt64(0)
+	wait := sync.WaitGroup{}
+
+	for i := 0; i < 64; i++ {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			for j := 0; j < 1000; j++ {
+				counter.AtomicAdd(1)
+			}
+		}()
+	}
+
+	wait.Wait()
+
+	if counter.AtomicLoad() != 64000 {
+		t.Errorf("UInt64.AtomicAdd() failed to count concurrently. "+
+			"Excepting '%d', got '%d'", 64000, counter.AtomicLoad())
+	}
+
+	counter.AtomicStore(0)
+
+	if counter.AtomicLoad() != 0 {
+		t.Errorf("UInt64.AtomicStore() failed to store number. "+
+			"Excepting '%d', got '%d'", 0, counter.AtomicLoad())
+	}
+}
@@ -0,0 +1,196 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trap
+
+import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/client"
+	"github.com/raincious/trap/trap/core/event"
+	"github.com/raincious/trap/trap/core/server"
+	"github.com/raincious/trap/trap/core/signature"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"strconv"
+)
+
+// Parameters of the triggered events. Every event is triggered with the
+// parameters built here, so they can be checked against the ones which
+// the conditions of the event handlers are allowed to compare
+
+func errorString(err *types.Throw) types.String {
+	if err == nil {
+		return ""
+	}
+
+	return types.String(err.Error())
+}
+
+// Parameters of `on.port.registered` and `on.port.unregistered`
+func portParameters(ip net.IP, port int, protocol string) event.Parameters {
+	return event.Parameters{}.
+		AddString("IP", types.String(ip.String())).
+		AddInt16("Port", types.Int16(port)).
+		AddString("Protocol", types.String(protocol).Lower())
+}
+
+func portFailedParameters(protocol types.String, setting types.String,
+	err *types.Throw) event.Parameters {
+	return event.Parameters{}.
+		AddString("Setting", setting).
+		AddString("Protocol", protocol.Lower()).
+		AddString("Error", errorString(err))
+}
+
+func portBrokenParameters(ip net.IP, port int, protocol string,
+	err *types.Throw) event.Parameters {
+	return portParameters(ip, port, protocol).
+		AddString("Error", errorString(err))
+}
+
+func clientEvictedParameters(ip net.IP, count types.UInt32,
+	marked bool) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddUInt32("Count", count).
+		AddString("Marked", types.String(strconv.FormatBool(marked)))
+}
+
+func clientMarkedParameters(ip net.IP, count types.UInt32,
+	tags types.Strings, markType types.String) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddUInt32("Count", count).
+		AddString("Tags", tags.ImplodeWith(",")).
+		AddString("MarkType", markType)
+}
+
+func clientUnmarkedParameters(ip net.IP, count types.UInt32,
+	markType types.String) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddUInt32("Count", count).
+		AddString("MarkType", markType)
+}
+
+func clientHittingParameters(ip net.IP,
+	data client.Record) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddString("ServerIP", types.String(data.Hitting.IP.String())).
+		AddUInt16("ServerPort", data.Hitting.Port).
+		AddString("Type", data.Hitting.Type).
+		AddBytes("ReceivedSample", data.Inbound).
+		AddBytes("RespondedData", data.Outbound).
+		AddString("Signatures", data.Signatures.ImplodeWith(",")).
+		AddString("Tags", data.Tags.ImplodeWith(","))
+}
+
+func clientSignatureParameters(ip net.IP, result signature.Result,
+	score types.UInt32, count types.UInt32) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddString("Signatures", result.Names().ImplodeWith(",")).
+		AddString("Tags", result.Tags().ImplodeWith(",")).
+		AddString("Severity", result.Severity.String()).
+		AddUInt32("Score", score).
+		AddUInt32("Count", count)
+}
+
+func clientScanningParameters(ip net.IP, ports client.Ports,
+	count types.UInt32) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddString("Ports", ports.String()).
+		AddUInt16("PortCount", types.Int32(ports.Len()).UInt16()).
+		AddUInt32("Count", count)
+}
+
+func clientImportedParameters(info server.ClientInfo) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(info.Client.String())).
+		AddString("ServerIP", types.String(info.Server.IP.String())).
+		AddUInt16("ServerPort", info.Server.Port).
+		AddString("Type", info.Type)
+}
+
+// Parameters of `on.prefix.marked` and `on.prefix.marked.out`
+func prefixParameters(cidr types.String, members types.UInt32,
+	markType types.String) event.Parameters {
+	return event.Parameters{}.
+		AddString("CIDR", cidr).
+		AddUInt32("Count", members).
+		AddString("MarkType", markType)
+}
+
+// Parameters of the `on.sync.node.*` events
+func nodeParameters(addr types.IPAddress,
+	err *types.Throw) event.Parameters {
+	return event.Parameters{}.
+		AddString("NodeIP", types.String(addr.IP.String())).
+		AddUInt16("NodePort", addr.Port).
+		AddString("Error", errorString(err))
+}
+
+func loginParameters(ip net.IP, user types.String) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddString("User", user)
+}
+
+func loginFailedParameters(ip net.IP, user types.String,
+	err *types.Throw) event.Parameters {
+	return loginParameters(ip, user).
+		AddString("Error", errorString(err))
+}
+
+func tokenUsedParameters(ip net.IP, token *status.Token) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddString("TokenID", token.ID).
+		AddString("TokenName", token.Name)
+}
+
+func tokenFailedParameters(ip net.IP, err *types.Throw) event.Parameters {
+	return event.Parameters{}.
+		AddString("ClientIP", types.String(ip.String())).
+		AddString("Error", errorString(err))
+}
+
+// Parameters of `on.status.token.created` and `on.status.token.revoked`
+func tokenChangedParameters(ip net.IP, user types.String,
+	token *status.Token) event.Parameters {
+	return tokenUsedParameters(ip, token).
+		AddString("User", user)
+}
+
+func auditParameters(entry audit.Entry) event.Parameters {
+	return event.Parameters{}.
+		AddString("Actor", entry.Actor).
+		AddString("ClientIP", entry.IP).
+		AddString("Action", entry.Action).
+		AddString("Target", entry.Target).
+		AddString("Result", entry.Result).
+		AddString("Error", entry.Error).
+		AddString("RequestID", entry.RequestID)
+}
@@ -0,0 +1,115 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trap
+
+import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/client"
+	"github.com/raincious/trap/trap/core/event"
+	"github.com/raincious/trap/trap/core/server"
+	"github.com/raincious/trap/trap/core/signature"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/types"
+
+	"net"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestEventParametersMatchConditions(t *testing.T) {
+	ip := net.ParseIP("127.0.0.1")
+	token := &status.Token{}
+
+	triggered := map[types.String]event.Parameters{
+		"on.server.up":              {},
+		"on.server.down":            {},
+		"on.port.registered":        portParameters(ip, 0, ""),
+		"on.port.unregistered":      portParameters(ip, 0, ""),
+		"on.port.failed":            portFailedParameters("", "", nil),
+		"on.port.broken":            portBrokenParameters(ip, 0, "", nil),
+		"on.client.hitting":         clientHittingParameters(ip, client.Record{}),
+		"on.client.marked":          clientMarkedParameters(ip, 0, nil, ""),
+		"on.client.marked.out":      clientUnmarkedParameters(ip, 0, ""),
+		"on.client.scanning":        clientScanningParameters(ip, nil, 0),
+		"on.client.evicted":         clientEvictedParameters(ip, 0, false),
+		"on.client.imported":        clientImportedParameters(server.ClientInfo{}),
+		"on.prefix.marked":          prefixParameters("", 0, ""),
+		"on.prefix.marked.out":      prefixParameters("", 0, ""),
+		"on.sync.node.connected":    nodeParameters(types.IPAddress{}, nil),
+		"on.sync.node.authed":       nodeParameters(types.IPAddress{}, nil),
+		"on.sync.node.disconnected": nodeParameters(types.IPAddress{}, nil),
+		"on.sync.node.conflicted":   nodeParameters(types.IPAddress{}, nil),
+		"on.status.login":           loginParameters(ip, ""),
+		"on.status.login.failed":    loginFailedParameters(ip, "", nil),
+		"on.status.token.used":      tokenUsedParameters(ip, token),
+		"on.status.token.failed":    tokenFailedParameters(ip, nil),
+		"on.status.token.created":   tokenChangedParameters(ip, "", token),
+		"on.status.token.revoked":   tokenChangedParameters(ip, "", token),
+		"on.audit":                  auditParameters(audit.Entry{}),
+		"on.client.signature": clientSignatureParameters(ip,
+			signature.Result{}, 0, 0),
+	}
+
+	conditions := event.ConditionParameters()
+
+	if len(conditions) != len(triggered) {
+		t.Errorf("event.ConditionParameters() doesn't cover the triggered "+
+			"events. Expecting '%d' events, got '%d'", len(triggered),
+			len(conditions))
+
+		return
+	}
+
+	for name, params := range triggered {
+		expected, found := conditions[name]
+
+		if !found {
+			t.Errorf("Event '%s' is not known by conditions", name)
+
+			return
+		}
+
+		keys := []string{}
+
+		for key := range params {
+			keys = append(keys, strings.TrimSuffix(
+				strings.TrimPrefix(key.String(), "$(("), "))"))
+		}
+
+		names := []string{}
+
+		for _, param := range expected {
+			names = append(names, param.String())
+		}
+
+		sort.Strings(keys)
+		sort.Strings(names)
+
+		if strings.Join(keys, ",") != strings.Join(names, ",") {
+			t.Errorf("Parameters of event '%s' don't match the conditions. "+
+				"Expecting '%s', got '%s'", name, names, keys)
+
+			return
+		}
+	}
+}
@@ -24,18 +24,22 @@ package logger
 import (
 	"github.com/raincious/trap/trap/core/types"
 
-	"bufio"
 	"fmt"
+	"io"
+	"os"
 	"time"
 )
 
+// FilePrinter writes every log as a line of text. Lines are written
+// straight into the writer, so one failed write will not affect the
+// following ones
 type FilePrinter struct {
-	writer *bufio.Writer
+	writer io.Writer
 
-	writeCounts uint16
+	failed bool
 }
 
-func NewFilePrinter(w *bufio.Writer) (*FilePrinter, *types.Throw) {
+func NewFilePrinter(w io.Writer) (*FilePrinter, *types.Throw) {
 	_, writeErr := w.Write([]byte(""))
 
 	if writeErr != nil {
@@ -50,20 +54,23 @@ func NewFilePrinter(w *bufio.Writer) (*FilePrinter, *types.Throw) {
 func (l *FilePrinter) save(w types.String, c types.String,
 	t time.Time, m types.String) {
 
-	_, err := l.writer.WriteString(fmt.Sprintf("<%s> %s [%s]: %s\r\n",
-		w, c, t.Format(time.StampMilli), m))
+	_, err := l.writer.Write([]byte(fmt.Sprintf("<%s> %s [%s]: %s\r\n",
+		w, c, t.Format(time.StampMilli), m)))
 
+	// Report the failure once instead of crashing, the log is not
+	// worth to take the whole server down
 	if err != nil {
-		panic(fmt.Errorf("Can't write log file due to error: %s", err))
-	}
-
-	l.writeCounts += 1
+		if !l.failed {
+			fmt.Fprintf(os.Stderr, "Can't write log file due to error: %s\n",
+				err)
+		}
 
-	if l.writeCounts > 10 {
-		l.writer.Flush()
+		l.failed = true
 
-		l.writeCounts = 0
+		return
 	}
+
+	l.failed = false
 }
 
 func (l *FilePrinter) Info(c types.String, t time.Time, m types.String) {
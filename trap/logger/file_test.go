@@ -0,0 +1,57 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilePrinterRecovers(t *testing.T) {
+	w := &testFailingWriter{}
+
+	p, err := NewFilePrinter(w)
+
+	if err != nil {
+		t.Errorf("NewFilePrinter() failed due to error: %s", err)
+
+		return
+	}
+
+	w.failing = true
+
+	p.Info("Trap:Test", time.Now(), "Lost")
+
+	w.failing = false
+
+	p.Info("Trap:Test", time.Now(), "Kept")
+
+	if strings.Contains(w.String(), "Lost") ||
+		!strings.Contains(w.String(), "<INF> Trap:Test") ||
+		!strings.Contains(w.String(), ": Kept\r\n") {
+		t.Errorf("FilePrinter failed to write after a failure, got '%s'",
+			w.String())
+
+		return
+	}
+}
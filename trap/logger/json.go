@@ -0,0 +1,112 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+type jsonLine struct {
+	Time    string       `json:"time"`
+	Level   types.String `json:"level"`
+	Context types.String `json:"context"`
+	Message types.String `json:"message"`
+}
+
+// JSONPrinter writes every log as a JSON object in it's own line
+type JSONPrinter struct {
+	writer  io.Writer
+	onError func(error)
+
+	failed bool
+}
+
+// NewJSONPrinter creates a JSONPrinter. `onError` will be called when
+// the printer starts failing to write, and it will not be called again
+// until the writing recovered
+func NewJSONPrinter(w io.Writer, onError func(error)) *JSONPrinter {
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	return &JSONPrinter{
+		writer:  w,
+		onError: onError,
+	}
+}
+
+func (l *JSONPrinter) save(w types.String, c types.String,
+	t time.Time, m types.String) {
+	line, encodeErr := json.Marshal(jsonLine{
+		Time:    t.Format(time.RFC3339Nano),
+		Level:   w,
+		Context: c,
+		Message: m,
+	})
+
+	if encodeErr != nil {
+		l.onError(fmt.Errorf("Can't encode log due to error: %s",
+			encodeErr))
+
+		return
+	}
+
+	_, writeErr := l.writer.Write(append(line, '\n'))
+
+	if writeErr != nil {
+		if !l.failed {
+			l.onError(fmt.Errorf("Can't write log due to error: %s",
+				writeErr))
+		}
+
+		l.failed = true
+
+		return
+	}
+
+	l.failed = false
+}
+
+func (l *JSONPrinter) Info(c types.String, t time.Time, m types.String) {
+	l.save("info", c, t, m)
+}
+
+func (l *JSONPrinter) Debug(c types.String, t time.Time, m types.String) {
+	l.save("debug", c, t, m)
+}
+
+func (l *JSONPrinter) Warning(c types.String, t time.Time, m types.String) {
+	l.save("warning", c, t, m)
+}
+
+func (l *JSONPrinter) Error(c types.String, t time.Time, m types.String) {
+	l.save("error", c, t, m)
+}
+
+func (l *JSONPrinter) Print(c types.String, t time.Time, m types.String) {
+	l.save("default", c, t, m)
+}
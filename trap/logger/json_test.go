@@ -0,0 +1,117 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Writer which fails the writes while `failing` is true
+type testFailingWriter struct {
+	bytes.Buffer
+
+	failing bool
+}
+
+func (w *testFailingWriter) Write(p []byte) (int, error) {
+	if w.failing {
+		return 0, errors.New("Fake failure")
+	}
+
+	return w.Buffer.Write(p)
+}
+
+func TestJSONPrinter(t *testing.T) {
+	buf := bytes.Buffer{}
+	now := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	p := NewJSONPrinter(&buf, nil)
+
+	p.Warning("Trap:Test", now, "Hello \"World\"")
+	p.Info("Trap:Test", now, "Second")
+
+	lines := bytes.Split(bytes.TrimSuffix(buf.Bytes(), []byte("\n")),
+		[]byte("\n"))
+
+	if len(lines) != 2 {
+		t.Errorf("JSONPrinter failed to write one line per log. "+
+			"Expecting '%d' lines, got '%d'", 2, len(lines))
+
+		return
+	}
+
+	line := jsonLine{}
+
+	decodeErr := json.Unmarshal(lines[0], &line)
+
+	if decodeErr != nil {
+		t.Errorf("JSONPrinter wrote invalid JSON due to error: %s",
+			decodeErr)
+
+		return
+	}
+
+	if line.Time != "2016-01-02T03:04:05Z" || line.Level != "warning" ||
+		line.Context != "Trap:Test" || line.Message != "Hello \"World\"" {
+		t.Errorf("JSONPrinter wrote unexpected log: %+v", line)
+
+		return
+	}
+}
+
+func TestJSONPrinterErrors(t *testing.T) {
+	w := &testFailingWriter{failing: true}
+	reported := 0
+
+	p := NewJSONPrinter(w, func(error) {
+		reported += 1
+	})
+
+	p.Info("Trap:Test", time.Now(), "1")
+	p.Info("Trap:Test", time.Now(), "2")
+
+	if reported != 1 {
+		t.Errorf("JSONPrinter must report the failure only once. "+
+			"Expecting '%d', got '%d'", 1, reported)
+
+		return
+	}
+
+	w.failing = false
+
+	p.Info("Trap:Test", time.Now(), "3")
+
+	w.failing = true
+
+	p.Info("Trap:Test", time.Now(), "4")
+
+	if reported != 2 {
+		t.Errorf("JSONPrinter must report the failure again after "+
+			"recovered. Expecting '%d', got '%d'", 2, reported)
+
+		return
+	}
+}
@@ -0,0 +1,314 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"github.com/raincious/trap/trap/core/types"
+
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ROTATE_TIME_FORMAT = "20060102-150405.000"
+)
+
+type RotateConfig struct {
+	Path string
+
+	MaxSize    int64         // Rotate when file reached the size, 0 to disable
+	MaxAge     time.Duration // Rotate when file is older than it, 0 to disable
+	MaxBackups int           // Rotated files to keep, 0 to keep all
+	Compress   bool          // Gzip rotated files
+
+	OnError func(error) // Called when rotated files can't be handled
+}
+
+// RotatingFile is an append only file writer which rotates the file by
+// size and age, and reopens the file when asked so external tools like
+// logrotate can move it away
+type RotatingFile struct {
+	config RotateConfig
+
+	lock     sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	compressing sync.WaitGroup
+}
+
+func NewRotatingFile(cfg RotateConfig) (*RotatingFile, *types.Throw) {
+	r := &RotatingFile{
+		config: cfg,
+	}
+
+	if r.config.OnError == nil {
+		r.config.OnError = func(error) {}
+	}
+
+	openErr := r.open()
+
+	if openErr != nil {
+		return nil, types.ConvertError(openErr)
+	}
+
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, openErr := os.OpenFile(r.config.Path,
+		os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+
+	if openErr != nil {
+		return openErr
+	}
+
+	info, statErr := file.Stat()
+
+	if statErr != nil {
+		file.Close()
+
+		return statErr
+	}
+
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = time.Now()
+
+	return nil
+}
+
+func (r *RotatingFile) shouldRotate(writing int) bool {
+	if r.size <= 0 {
+		return false
+	}
+
+	if r.config.MaxSize > 0 && r.size+int64(writing) > r.config.MaxSize {
+		return true
+	}
+
+	if r.config.MaxAge > 0 && time.Since(r.openedAt) >= r.config.MaxAge {
+		return true
+	}
+
+	return false
+}
+
+func (r *RotatingFile) rotate() error {
+	closeErr := r.file.Close()
+
+	r.file = nil
+
+	if closeErr != nil {
+		return closeErr
+	}
+
+	rotatedPath := r.config.Path + "." + time.Now().Format(ROTATE_TIME_FORMAT)
+
+	renameErr := os.Rename(r.config.Path, rotatedPath)
+
+	if renameErr != nil {
+		return renameErr
+	}
+
+	if r.config.Compress {
+		r.compressing.Add(1)
+
+		go func() {
+			defer r.compressing.Done()
+
+			compressErr := r.compress(rotatedPath)
+
+			if compressErr != nil {
+				r.config.OnError(compressErr)
+			}
+
+			r.prune()
+		}()
+	} else {
+		r.prune()
+	}
+
+	return r.open()
+}
+
+func (r *RotatingFile) compress(path string) error {
+	source, openErr := os.Open(path)
+
+	if openErr != nil {
+		return openErr
+	}
+
+	defer source.Close()
+
+	target, createErr := os.OpenFile(path+".gz",
+		os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+
+	if createErr != nil {
+		return createErr
+	}
+
+	gzipWriter := gzip.NewWriter(target)
+
+	_, copyErr := io.Copy(gzipWriter, source)
+
+	if copyErr == nil {
+		copyErr = gzipWriter.Close()
+	}
+
+	closeErr := target.Close()
+
+	if copyErr == nil {
+		copyErr = closeErr
+	}
+
+	if copyErr != nil {
+		os.Remove(path + ".gz")
+
+		return copyErr
+	}
+
+	return os.Remove(path)
+}
+
+// Remove the oldest rotated files that exceeded `MaxBackups`
+func (r *RotatingFile) prune() {
+	if r.config.MaxBackups <= 0 {
+		return
+	}
+
+	matches, globErr := filepath.Glob(r.config.Path + ".*")
+
+	if globErr != nil {
+		r.config.OnError(globErr)
+
+		return
+	}
+
+	backups := []string{}
+
+	for _, match := range matches {
+		suffix := strings.TrimSuffix(
+			strings.TrimPrefix(match, r.config.Path+"."), ".gz")
+
+		if _, parseErr := time.Parse(ROTATE_TIME_FORMAT,
+			suffix); parseErr != nil {
+			continue
+		}
+
+		backups = append(backups, match)
+	}
+
+	// The time format keeps the names sortable
+	sort.Strings(backups)
+
+	for len(backups) > r.config.MaxBackups {
+		removeErr := os.Remove(backups[0])
+
+		if removeErr != nil {
+			r.config.OnError(removeErr)
+		}
+
+		backups = backups[1:]
+	}
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.lock.Lock()
+
+	defer r.lock.Unlock()
+
+	if r.file != nil && r.shouldRotate(len(p)) {
+		rotateErr := r.rotate()
+
+		if rotateErr != nil {
+			r.config.OnError(fmt.Errorf("Can't rotate log file '%s' due "+
+				"to error: %s", r.config.Path, rotateErr))
+		}
+	}
+
+	// Try to recover when the file was lost in last rotation or reopen
+	if r.file == nil {
+		openErr := r.open()
+
+		if openErr != nil {
+			return 0, openErr
+		}
+	}
+
+	written, writeErr := r.file.Write(p)
+
+	r.size += int64(written)
+
+	return written, writeErr
+}
+
+// Reopen closes and reopens the file at the same path
+func (r *RotatingFile) Reopen() *types.Throw {
+	r.lock.Lock()
+
+	defer r.lock.Unlock()
+
+	if r.file != nil {
+		r.file.Close()
+
+		r.file = nil
+	}
+
+	openErr := r.open()
+
+	if openErr != nil {
+		return types.ConvertError(openErr)
+	}
+
+	return nil
+}
+
+func (r *RotatingFile) Close() *types.Throw {
+	r.lock.Lock()
+
+	defer r.lock.Unlock()
+
+	r.compressing.Wait()
+
+	if r.file == nil {
+		return nil
+	}
+
+	closeErr := r.file.Close()
+
+	r.file = nil
+
+	if closeErr != nil {
+		return types.ConvertError(closeErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func getTestRotatingFile(t *testing.T, dir string,
+	cfg RotateConfig) *RotatingFile {
+	cfg.Path = filepath.Join(dir, "trap.log")
+	cfg.OnError = func(err error) {
+		t.Errorf("RotatingFile reported an error: %s", err)
+	}
+
+	r, err := NewRotatingFile(cfg)
+
+	if err != nil {
+		t.Fatalf("NewRotatingFile() failed due to error: %s", err)
+	}
+
+	return r
+}
+
+// Rotated files are named by the time in milliseconds, wait a bit so two
+// rotations will not use the same name
+func writeTestRotatingFile(t *testing.T, r *RotatingFile, data string) {
+	time.Sleep(2 * time.Millisecond)
+
+	_, err := r.Write([]byte(data))
+
+	if err != nil {
+		t.Fatalf("RotatingFile.Write() failed due to error: %s", err)
+	}
+}
+
+func getTestBackups(t *testing.T, dir string) []string {
+	backups, err := filepath.Glob(filepath.Join(dir, "trap.log.*"))
+
+	if err != nil {
+		t.Fatalf("Can't list rotated files due to error: %s", err)
+	}
+
+	sort.Strings(backups)
+
+	return backups
+}
+
+func readTestFile(path string) string {
+	content, _ := ioutil.ReadFile(path)
+
+	return string(content)
+}
+
+func TestRotatingFileBySize(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-rotate-test-")
+
+	if dirErr != nil {
+		t.Errorf("Can't create test directory due to error: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	r := getTestRotatingFile(t, dir, RotateConfig{
+		MaxSize: 10,
+	})
+
+	defer r.Close()
+
+	writeTestRotatingFile(t, r, "first\n")
+	writeTestRotatingFile(t, r, "second\n")
+
+	backups := getTestBackups(t, dir)
+
+	if len(backups) != 1 || readTestFile(backups[0]) != "first\n" {
+		t.Errorf("RotatingFile failed to rotate by size. Expecting one "+
+			"rotated file, got '%v'", backups)
+
+		return
+	}
+
+	if readTestFile(filepath.Join(dir, "trap.log")) != "second\n" {
+		t.Errorf("RotatingFile failed to write into a new file, got '%s'",
+			readTestFile(filepath.Join(dir, "trap.log")))
+
+		return
+	}
+}
+
+func TestRotatingFileByAge(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-rotate-test-")
+
+	if dirErr != nil {
+		t.Errorf("Can't create test directory due to error: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	r := getTestRotatingFile(t, dir, RotateConfig{
+		MaxAge: 5 * time.Millisecond,
+	})
+
+	defer r.Close()
+
+	writeTestRotatingFile(t, r, "first\n")
+	writeTestRotatingFile(t, r, "second\n")
+
+	if len(getTestBackups(t, dir)) != 0 {
+		t.Error("RotatingFile rotated a file that is not old enough")
+
+		return
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	writeTestRotatingFile(t, r, "third\n")
+
+	backups := getTestBackups(t, dir)
+
+	if len(backups) != 1 || readTestFile(backups[0]) != "first\nsecond\n" {
+		t.Errorf("RotatingFile failed to rotate by age. Expecting one "+
+			"rotated file, got '%v'", backups)
+
+		return
+	}
+}
+
+func TestRotatingFilePrune(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-rotate-test-")
+
+	if dirErr != nil {
+		t.Errorf("Can't create test directory due to error: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	// Files that are not rotated by us must be left alone
+	ioutil.WriteFile(filepath.Join(dir, "trap.log.keep"), []byte{}, 0600)
+
+	r := getTestRotatingFile(t, dir, RotateConfig{
+		MaxSize:    1,
+		MaxBackups: 2,
+	})
+
+	defer r.Close()
+
+	for _, data := range []string{"1", "2", "3", "4", "5"} {
+		writeTestRotatingFile(t, r, data)
+	}
+
+	backups := getTestBackups(t, dir)
+
+	if len(backups) != 3 || readTestFile(backups[0]) != "3" ||
+		readTestFile(backups[1]) != "4" ||
+		filepath.Base(backups[2]) != "trap.log.keep" {
+		t.Errorf("RotatingFile failed to keep only the newest '%d' "+
+			"rotated files, got '%v'", 2, backups)
+
+		return
+	}
+}
+
+func TestRotatingFileCompress(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-rotate-test-")
+
+	if dirErr != nil {
+		t.Errorf("Can't create test directory due to error: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	r := getTestRotatingFile(t, dir, RotateConfig{
+		MaxSize:  1,
+		Compress: true,
+	})
+
+	writeTestRotatingFile(t, r, "first\n")
+	writeTestRotatingFile(t, r, "second\n")
+
+	// Wait for the compression
+	r.Close()
+
+	backups := getTestBackups(t, dir)
+
+	if len(backups) != 1 || filepath.Ext(backups[0]) != ".gz" {
+		t.Errorf("RotatingFile failed to compress the rotated file, "+
+			"got '%v'", backups)
+
+		return
+	}
+
+	file, openErr := os.Open(backups[0])
+
+	if openErr != nil {
+		t.Errorf("Can't open compressed file due to error: %s", openErr)
+
+		return
+	}
+
+	defer file.Close()
+
+	reader, readerErr := gzip.NewReader(file)
+
+	if readerErr != nil {
+		t.Errorf("Can't read compressed file due to error: %s", readerErr)
+
+		return
+	}
+
+	content, _ := ioutil.ReadAll(reader)
+
+	if string(content) != "first\n" {
+		t.Errorf("RotatingFile compressed unexpected content. Expecting "+
+			"'%s', got '%s'", "first\n", string(content))
+
+		return
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-rotate-test-")
+
+	if dirErr != nil {
+		t.Errorf("Can't create test directory due to error: %s", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	r := getTestRotatingFile(t, dir, RotateConfig{})
+
+	defer r.Close()
+
+	path := filepath.Join(dir, "trap.log")
+
+	writeTestRotatingFile(t, r, "first\n")
+
+	// Move the file away like logrotate does
+	os.Rename(path, path+".moved")
+
+	writeTestRotatingFile(t, r, "second\n")
+
+	reopenErr := r.Reopen()
+
+	if reopenErr != nil {
+		t.Errorf("RotatingFile.Reopen() failed due to error: %s", reopenErr)
+
+		return
+	}
+
+	writeTestRotatingFile(t, r, "third\n")
+
+	if readTestFile(path+".moved") != "first\nsecond\n" ||
+		readTestFile(path) != "third\n" {
+		t.Errorf("RotatingFile.Reopen() failed to write into the new "+
+			"file, got '%s' and '%s'", readTestFile(path+".moved"),
+			readTestFile(path))
+
+		return
+	}
+}
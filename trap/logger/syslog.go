@@ -0,0 +1,94 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logger
+
+import (
+	"github.com/raincious/trap/trap/core/syslog"
+	"github.com/raincious/trap/trap/core/types"
+
+	"fmt"
+	"time"
+)
+
+// SyslogPrinter sends logs to a syslog server, the context of the log
+// will be used as the MSGID. Logs are queued by the Writer, so a slow
+// server will not block the logging, logs will be dropped instead
+type SyslogPrinter struct {
+	writer   *syslog.Writer
+	facility int
+	onError  func(error)
+
+	failed bool
+}
+
+func NewSyslogPrinter(w *syslog.Writer, facility int,
+	onError func(error)) *SyslogPrinter {
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	w.OnError(onError)
+
+	return &SyslogPrinter{
+		writer:   w,
+		facility: facility,
+		onError:  onError,
+	}
+}
+
+func (l *SyslogPrinter) send(severity int, c types.String,
+	t time.Time, m types.String) {
+	writeErr := l.writer.Write(l.facility, severity, t, c, m)
+
+	if writeErr != nil {
+		if !l.failed {
+			l.onError(fmt.Errorf("Can't send log to syslog due to "+
+				"error: %s", writeErr))
+		}
+
+		l.failed = true
+
+		return
+	}
+
+	l.failed = false
+}
+
+func (l *SyslogPrinter) Info(c types.String, t time.Time, m types.String) {
+	l.send(syslog.SEVERITY_INFO, c, t, m)
+}
+
+func (l *SyslogPrinter) Debug(c types.String, t time.Time, m types.String) {
+	l.send(syslog.SEVERITY_DEBUG, c, t, m)
+}
+
+func (l *SyslogPrinter) Warning(c types.String, t time.Time, m types.String) {
+	l.send(syslog.SEVERITY_WARNING, c, t, m)
+}
+
+func (l *SyslogPrinter) Error(c types.String, t time.Time, m types.String) {
+	l.send(syslog.SEVERITY_ERROR, c, t, m)
+}
+
+func (l *SyslogPrinter) Print(c types.String, t time.Time, m types.String) {
+	l.send(syslog.SEVERITY_NOTICE, c, t, m)
+}
@@ -0,0 +1,359 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trap
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/metrics"
+	"github.com/raincious/trap/trap/core/types"
+
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Metrics exports the counters of the trap in the Prometheus format.
+// Names of the metrics are part of the interface, don't change them
+type Metrics struct {
+	listen          types.String
+	logger          *logger.Logger
+	server          *Server
+	sync            *Sync
+	status          *Status
+	metrics         *http.Server
+	serverRWLock    types.Mutex
+	metricsDownWait sync.WaitGroup
+	metricsListener net.Listener
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		serverRWLock:    types.Mutex{},
+		metricsDownWait: sync.WaitGroup{},
+	}
+}
+
+func (this *Metrics) SetLogger(l *logger.Logger) {
+	this.logger = l.NewContext("Metrics")
+}
+
+// The setters may be called on reload while the standalone metrics
+// server is collecting, so they are guarded by the server lock
+func (this *Metrics) SetServer(s *Server) {
+	this.serverRWLock.Exec(func() {
+		this.server = s
+	})
+}
+
+func (this *Metrics) SetSync(s *Sync) {
+	this.serverRWLock.Exec(func() {
+		this.sync = s
+	})
+}
+
+func (this *Metrics) SetStatus(s *Status) {
+	this.serverRWLock.Exec(func() {
+		this.status = s
+	})
+}
+
+// Listen sets the address of the standalone metrics server, which
+// serves the metrics without session authorization
+func (this *Metrics) Listen(addr types.String) {
+	this.serverRWLock.Exec(func() {
+		this.listen = addr
+	})
+}
+
+func (this *Metrics) Collect(w io.Writer) *types.Throw {
+	var server *Server = nil
+	var syncer *Sync = nil
+	var status *Status = nil
+
+	// Only hold the lock for taking the references, writing to a slow
+	// client must not block the setters
+	this.serverRWLock.Exec(func() {
+		server = this.server
+		syncer = this.sync
+		status = this.status
+	})
+
+	if server == nil {
+		return metrics.ErrServerNotSet.Throw()
+	}
+
+	m := metrics.NewWriter(w)
+
+	this.collectServer(m, server)
+	this.collectEvent(m, server)
+	this.collectSync(m, syncer)
+	this.collectStatus(m, status)
+
+	return m.Flush()
+}
+
+func (this *Metrics) collectServer(m *metrics.Writer, server *Server) {
+	s := server.Status()
+
+	m.Family("trap_inbound_total", metrics.COUNTER,
+		"Inbound connections received by the listeners")
+	m.Sample("trap_inbound_total", nil, float64(s.TotalInbound))
+
+	m.Family("trap_hits_total", metrics.COUNTER,
+		"Connections which hit the trap ports")
+	m.Sample("trap_hits_total", nil, float64(s.TotalHit))
+
+	m.Family("trap_marked_total", metrics.COUNTER,
+		"Clients which have been marked")
+	m.Sample("trap_marked_total", nil, float64(s.TotalMarked))
+
+	m.Family("trap_clients", metrics.GAUGE,
+		"Clients currently been recorded")
+	m.Sample("trap_clients", nil, float64(s.TotalClients))
+
+	m.Family("trap_marked_clients", metrics.GAUGE,
+		"Clients currently been marked")
+	m.Sample("trap_marked_clients", nil, float64(s.MarkedClients))
+
+	m.Family("trap_clients_evicted_total", metrics.COUNTER,
+		"Clients evicted to keep the client limits")
+	m.Sample("trap_clients_evicted_total", nil, float64(s.TotalEvicted))
+
+	m.Family("trap_clients_memory_bytes", metrics.GAUGE,
+		"Estimated memory used by the client records")
+	m.Sample("trap_clients_memory_bytes", nil, float64(s.MemoryUsage))
+
+	m.Family("trap_port_hits_total", metrics.COUNTER,
+		"Hits received on each port and protocol")
+
+	for _, d := range s.Distribution {
+		m.Sample("trap_port_hits_total", metrics.Labels{}.
+			Add("port", d.Port.String().String()).
+			Add("protocol", d.Type.Lower().String()), float64(d.Hit))
+	}
+
+	m.Family("trap_listener_errors_total", metrics.COUNTER,
+		"Errors reported by the listeners")
+	m.Sample("trap_listener_errors_total", metrics.Labels{}.
+		Add("kind", "connection"), float64(s.ListenerErrors))
+	m.Sample("trap_listener_errors_total", metrics.Labels{}.
+		Add("kind", "listen"), float64(s.ListenerFailures))
+	m.Sample("trap_listener_errors_total", metrics.Labels{}.
+		Add("kind", "broken"), float64(s.ListenerBroken))
+
+	m.Family("trap_event_queue_depth", metrics.GAUGE,
+		"Events waiting in the queues")
+	m.Sample("trap_event_queue_depth", nil, float64(s.EventQueueDepth))
+
+	m.Family("trap_events_handled_total", metrics.COUNTER,
+		"Event handlers which have been run")
+	m.Sample("trap_events_handled_total", nil, float64(s.EventHandled))
+
+	m.Family("trap_events_failed_total", metrics.COUNTER,
+		"Event handlers which have failed")
+	m.Sample("trap_events_failed_total", nil, float64(s.EventFailed))
+
+	m.Family("trap_events_dropped_total", metrics.COUNTER,
+		"Events dropped as the queues were full")
+	m.Sample("trap_events_dropped_total", nil, float64(s.EventDropped))
+}
+
+func (this *Metrics) collectEvent(m *metrics.Writer, server *Server) {
+	commands := server.Event().CommandStats()
+
+	m.Family("trap_event_command_duration_seconds", metrics.HISTOGRAM,
+		"Time spent on running the event commands")
+
+	for _, c := range commands {
+		m.Histogram("trap_event_command_duration_seconds",
+			metrics.Labels{}.
+				Add("event", c.Event.String()).
+				Add("command", c.Path.String()), c.Duration)
+	}
+
+	m.Family("trap_event_command_failures_total", metrics.COUNTER,
+		"Event commands which have failed after all retries")
+
+	for _, c := range commands {
+		m.Sample("trap_event_command_failures_total", metrics.Labels{}.
+			Add("event", c.Event.String()).
+			Add("command", c.Path.String()), float64(c.Failures))
+	}
+}
+
+func (this *Metrics) collectSync(m *metrics.Writer, syncer *Sync) {
+	if syncer == nil {
+		return
+	}
+
+	nodes := syncer.Status().Nodes
+
+	m.Family("trap_sync_node_connected", metrics.GAUGE,
+		"Whether the sync node is connected")
+
+	for _, n := range nodes {
+		connected := 0.0
+
+		if n.Connected {
+			connected = 1
+		}
+
+		m.Sample("trap_sync_node_connected", metrics.Labels{}.
+			Add("node", n.Address.String().String()), connected)
+	}
+
+	m.Family("trap_sync_node_delay_seconds", metrics.GAUGE,
+		"Delay of the sync node")
+
+	for _, n := range nodes {
+		m.Sample("trap_sync_node_delay_seconds", metrics.Labels{}.
+			Add("node", n.Address.String().String()), n.Delay.Seconds())
+	}
+
+	m.Family("trap_sync_node_tx_total", metrics.COUNTER,
+		"Messages transmitted to the sync node")
+
+	for _, n := range nodes {
+		m.Sample("trap_sync_node_tx_total", metrics.Labels{}.
+			Add("node", n.Address.String().String()), float64(n.Stats.TX))
+	}
+
+	m.Family("trap_sync_node_rx_total", metrics.COUNTER,
+		"Messages received from the sync node")
+
+	for _, n := range nodes {
+		m.Sample("trap_sync_node_rx_total", metrics.Labels{}.
+			Add("node", n.Address.String().String()), float64(n.Stats.RX))
+	}
+}
+
+func (this *Metrics) collectStatus(m *metrics.Writer, status *Status) {
+	if status == nil {
+		return
+	}
+
+	m.Family("trap_status_login_failures_total", metrics.COUNTER,
+		"Bad authorization attempts made to the status server")
+	m.Sample("trap_status_login_failures_total", nil,
+		float64(status.LoginFailures()))
+}
+
+func (this *Metrics) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.WriteHeader(405)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.CONTENT_TYPE)
+	w.Header().Set("Cache-Control", "private, max-age=0, no-cache")
+
+	collectErr := this.Collect(w)
+
+	if collectErr != nil {
+		this.logger.Warningf("Can't write metrics to '%s' due to error: %s",
+			r.RemoteAddr, collectErr)
+	}
+}
+
+func (this *Metrics) up() *types.Throw {
+	if this.metrics != nil || this.metricsListener != nil {
+		return metrics.ErrServerAlreadyUp.Throw()
+	}
+
+	if this.server == nil {
+		return metrics.ErrServerNotSet.Throw()
+	}
+
+	httpMux := http.NewServeMux()
+
+	httpMux.HandleFunc("/metrics", this.serveHTTP)
+
+	listener, lErr := net.Listen("tcp", this.listen.String())
+
+	if lErr != nil {
+		return types.ConvertError(lErr)
+	}
+
+	this.metrics = &http.Server{
+		Addr:         this.listen.String(),
+		Handler:      httpMux,
+		WriteTimeout: 32 * time.Second,
+		ReadTimeout:  16 * time.Second,
+	}
+	this.metricsListener = listener
+
+	this.metricsDownWait.Add(1)
+
+	go func(server *http.Server) {
+		defer this.metricsDownWait.Done()
+
+		this.logger.Infof("Serving `Metrics` server at: %s",
+			listener.Addr())
+
+		servErr := server.Serve(listener)
+
+		if servErr != nil {
+			this.logger.Infof("`Metrics` server closed due to error: %s",
+				servErr)
+		}
+	}(this.metrics)
+
+	return nil
+}
+
+func (this *Metrics) down() *types.Throw {
+	if this.metrics == nil || this.metricsListener == nil {
+		return metrics.ErrServerNotDownable.Throw()
+	}
+
+	this.metrics.Close()
+
+	this.metricsDownWait.Wait()
+
+	this.metrics = nil
+	this.metricsListener = nil
+
+	return nil
+}
+
+func (this *Metrics) Serv() *types.Throw {
+	var e *types.Throw = nil
+
+	this.serverRWLock.Exec(func() {
+		e = this.up()
+	})
+
+	return e
+}
+
+func (this *Metrics) Down() *types.Throw {
+	var e *types.Throw = nil
+
+	this.serverRWLock.Exec(func() {
+		e = this.down()
+	})
+
+	return e
+}
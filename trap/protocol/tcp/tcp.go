@@ -36,8 +36,9 @@ type TCP struct {
 
 	responders []Responder
 
-	onError func(listen.ConnectionInfo, *types.Throw)
-	onPick  func(listen.ConnectionInfo, listen.RespondedResult)
+	onError  func(listen.ConnectionInfo, *types.Throw)
+	onPick   func(listen.ConnectionInfo, listen.RespondedResult)
+	onBroken func(*listen.ListeningInfo, *types.Throw)
 
 	maxBytes types.UInt32
 
@@ -66,6 +67,7 @@ func (t *TCP) Init(c *listen.ProtocolConfig) *types.Throw {
 
 	t.onError = c.OnError
 	t.onPick = c.OnPick
+	t.onBroken = c.OnBroken
 
 	t.readTimeout = c.ReadTimeout
 	t.writeTimeout = c.WriteTimeout
@@ -119,8 +121,9 @@ func (t *TCP) Spawn(setting types.String) (listen.Listener, *types.Throw) {
 			Concurrent: t.concurrent,
 			MaxBytes:   t.maxBytes,
 
-			OnError: t.onError,
-			OnPick:  t.onPick,
+			OnError:  t.onError,
+			OnPick:   t.onPick,
+			OnBroken: t.onBroken,
 
 			ReadTimeout:  t.readTimeout,
 			WriteTimeout: t.writeTimeout,
@@ -45,8 +45,9 @@ type Listener struct {
 	timeoutWrite time.Duration
 	timeoutTotal time.Duration
 
-	onError func(listen.ConnectionInfo, *types.Throw)
-	onPick  func(listen.ConnectionInfo, listen.RespondedResult)
+	onError  func(listen.ConnectionInfo, *types.Throw)
+	onPick   func(listen.ConnectionInfo, listen.RespondedResult)
+	onBroken func(*listen.ListeningInfo, *types.Throw)
 
 	downChan   chan bool
 	upwaitChan chan bool
@@ -83,8 +84,9 @@ func (this *Listener) Init(cfg ListenerConfig) *types.Throw {
 
 	this.onError = cfg.OnError
 	this.onPick = cfg.OnPick
+	this.onBroken = cfg.OnBroken
 
-	this.downChan = make(chan bool, 1)
+	this.downChan = make(chan bool, 2)
 	this.upwaitChan = make(chan bool)
 
 	this.listenOn = &net.UDPAddr{
@@ -167,6 +169,10 @@ func (this *Listener) Up() (*listen.ListeningInfo, *types.Throw) {
 				length, srcAddr, conErr := this.listener.ReadFromUDP(totalbuffer)
 
 				if conErr != nil {
+					if this.isBroken(conErr) {
+						return
+					}
+
 					continue
 				}
 
@@ -203,6 +209,34 @@ func (this *Listener) Up() (*listen.ListeningInfo, *types.Throw) {
 	}, nil
 }
 
+// isBroken tells whether the error means the listener has stopped working
+// while nobody asked it to, and reports it when so
+func (this *Listener) isBroken(err error) bool {
+	if !this.upped {
+		return false
+	}
+
+	if netErr, ok := err.(net.Error); ok && (netErr.Temporary() ||
+		netErr.Timeout()) {
+		return false
+	}
+
+	brokenErr := types.ConvertError(err)
+
+	this.logger.Errorf("`Listener` has stopped unexpectedly due to "+
+		"error: %s", brokenErr)
+
+	if this.onBroken != nil {
+		this.onBroken(&listen.ListeningInfo{
+			Port:     this.listenOn.Port,
+			IP:       this.listenOn.IP,
+			Protocol: "udp",
+		}, brokenErr)
+	}
+
+	return true
+}
+
 func (this *Listener) Down() (*listen.ListeningInfo, *types.Throw) {
 	if !this.closeable {
 		return nil, listen.ErrListenerNotCloseable.Throw(this.listenOn)
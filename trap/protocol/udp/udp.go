@@ -33,8 +33,9 @@ import (
 type UDP struct {
 	net.Net
 
-	onError func(listen.ConnectionInfo, *types.Throw)
-	onPick  func(listen.ConnectionInfo, listen.RespondedResult)
+	onError  func(listen.ConnectionInfo, *types.Throw)
+	onPick   func(listen.ConnectionInfo, listen.RespondedResult)
+	onBroken func(*listen.ListeningInfo, *types.Throw)
 
 	maxBytes types.UInt32
 
@@ -61,6 +62,7 @@ func (t *UDP) Init(c *listen.ProtocolConfig) *types.Throw {
 
 	t.onError = c.OnError
 	t.onPick = c.OnPick
+	t.onBroken = c.OnBroken
 
 	t.readTimeout = c.ReadTimeout
 	t.writeTimeout = c.WriteTimeout
@@ -85,8 +87,9 @@ func (t *UDP) Spawn(setting types.String) (listen.Listener, *types.Throw) {
 			Concurrent: t.concurrent,
 			MaxBytes:   t.maxBytes,
 
-			OnError: t.onError,
-			OnPick:  t.onPick,
+			OnError:  t.onError,
+			OnPick:   t.onPick,
+			OnBroken: t.onBroken,
 
 			ReadTimeout:  t.readTimeout,
 			WriteTimeout: t.writeTimeout,
@@ -24,9 +24,12 @@ package trap
 import (
 	"github.com/raincious/trap/trap/core/client"
 	"github.com/raincious/trap/trap/core/event"
+	"github.com/raincious/trap/trap/core/firewall"
 	"github.com/raincious/trap/trap/core/listen"
 	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/policy"
 	"github.com/raincious/trap/trap/core/server"
+	"github.com/raincious/trap/trap/core/signature"
 	"github.com/raincious/trap/trap/core/types"
 
 	"sync"
@@ -34,14 +37,25 @@ import (
 )
 
 type Server struct {
+	// Counters are updated atomically, keep them at the top for alignment
+	totalInbound            types.UInt64
+	totalMarked             types.UInt64
+	totalHit                types.UInt64
+	listenerErrors          types.UInt64
+	listenerFailures        types.UInt64
+	listenerBroken          types.UInt64
 	logger                  *logger.Logger
 	listen                  *listen.Listen
 	event                   *event.Event
-	clientMaps              *client.Clients
-	clientRWLock            types.Mutex
+	clientMaps              *client.Store
+	prefixMaps              *client.Prefixes
+	prefixLock              types.Mutex
+	statsLock               types.Mutex
 	clientCronExitCh        chan bool
 	clientMaxRecords        types.UInt16
 	clientMaxRecordMaxBytes types.UInt32
+	clientMaxCount          types.UInt32
+	clientMaxMemory         types.UInt64
 	serverUpped             bool
 	serverUpping            bool
 	serverLock              types.Mutex
@@ -50,32 +64,51 @@ type Server struct {
 	tolerate                types.UInt32
 	tolerateExpire          time.Duration
 	tolerateRestrict        time.Duration
+	scanThershold           types.UInt16
+	scanWindow              time.Duration
+	prefixIPv4Bits          types.UInt16
+	prefixIPv6Bits          types.UInt16
+	prefixThershold         types.UInt32
 	concurrentLimit         types.UInt16
+	eventWorkers            types.UInt16
+	eventQueueSize          types.UInt32
+	signatures              *signature.Rules
+	policy                  *policy.Policy
+	firewall                *firewall.Manager
 	onUpCommands            types.Callbacks
 	onDownCommands          types.Callbacks
 	onUpDownCommands        []types.CallbackPair
 	onMarkCommands          []func(server.ClientInfo)
 	onUnmarkCommands        []func(types.IP)
+	onPrefixMarkCommands    []func(server.PrefixInfo)
+	onPrefixUnmarkCommands  []func(server.PrefixInfo)
 	bootTime                time.Time
-	totalInbound            types.UInt64
-	totalMarked             types.UInt64
-	totalHit                types.UInt64
 	history                 server.Histories
 	distribution            server.Distributions
 }
 
 func NewServer() *Server {
 	return &Server{
-		clientRWLock:            types.Mutex{},
+		prefixLock:              types.Mutex{},
+		statsLock:               types.Mutex{},
 		serverLock:              types.Mutex{},
 		serverDownWait:          sync.WaitGroup{},
 		timeout:                 1 * time.Second,
 		tolerate:                1,
 		tolerateExpire:          3600 * time.Second,
 		tolerateRestrict:        3600 * time.Second,
+		scanThershold:           0,
+		scanWindow:              60 * time.Second,
+		prefixIPv4Bits:          24,
+		prefixIPv6Bits:          64,
+		prefixThershold:         0,
 		concurrentLimit:         10,
+		eventWorkers:            event.EVENT_DEFAULT_WORKERS,
+		eventQueueSize:          event.EVENT_DEFAULT_QUEUE_SIZE,
 		clientMaxRecords:        16,
 		clientMaxRecordMaxBytes: 512,
+		clientMaxCount:          0,
+		clientMaxMemory:         0,
 		history:                 server.Histories{},
 		distribution:            server.Distributions{},
 	}
@@ -98,6 +131,42 @@ func (this *Server) SetTolerate(limit types.UInt32, expire time.Duration,
 		limit, expire, restrict)
 }
 
+func (this *Server) SetScanDetect(limit types.UInt16, window time.Duration) {
+	this.scanThershold = limit
+	this.scanWindow = window
+
+	this.logger.Debugf("Scan detect has been set to '%d' distinct ports "+
+		"within '%s'", limit, window)
+}
+
+func (this *Server) SetAggregate(ipv4Bits types.UInt16,
+	ipv6Bits types.UInt16, limit types.UInt32) {
+	this.prefixIPv4Bits = ipv4Bits
+	this.prefixIPv6Bits = ipv6Bits
+	this.prefixThershold = limit
+
+	this.logger.Debugf("Aggregate has been set to '/%d' for IPv4 and "+
+		"'/%d' for IPv6, mark thershold is '%d' clients",
+		ipv4Bits, ipv6Bits, limit)
+}
+
+func (this *Server) SetSignatures(rules *signature.Rules) {
+	this.signatures = rules
+
+	this.logger.Debugf("'%d' signature rules has been loaded, score "+
+		"thershold is '%d'", rules.Len(), rules.ScoreThershold)
+}
+
+func (this *Server) SetPolicy(p *policy.Policy) {
+	this.policy = p
+
+	this.logger.Debugf("'%d' policy statements has been loaded", p.Len())
+}
+
+func (this *Server) SetFirewall(backend firewall.Backend) {
+	this.firewall = firewall.NewManager(backend, this.logger)
+}
+
 func (this *Server) SetClientRecordLimit(l types.UInt16) {
 	this.clientMaxRecords = l
 
@@ -112,6 +181,15 @@ func (this *Server) SetClientRecordDataLimit(limit types.UInt32) {
 		this.clientMaxRecordMaxBytes)
 }
 
+func (this *Server) SetClientLimit(maxCount types.UInt32,
+	maxMemory types.UInt64) {
+	this.clientMaxCount = maxCount
+	this.clientMaxMemory = maxMemory
+
+	this.logger.Debugf("Client limit has been set to maximum '%d' clients "+
+		"and '%d' bytes of memory", maxCount, maxMemory)
+}
+
 func (this *Server) SetTimeout(t time.Duration) {
 	this.timeout = t
 
@@ -124,6 +202,14 @@ func (this *Server) SetConcurrentLimit(c types.UInt16) {
 	this.logger.Debugf("Concurrent Limit has been set to '%d'", c)
 }
 
+func (this *Server) SetEventQueue(workers types.UInt16, size types.UInt32) {
+	this.eventWorkers = workers
+	this.eventQueueSize = size
+
+	this.logger.Debugf("Events will be handled by '%d' workers with a "+
+		"queue of '%d' events", workers, size)
+}
+
 func (this *Server) OnUp(f types.Callback) {
 	this.onUpCommands = append(this.onUpCommands, f)
 }
@@ -147,6 +233,14 @@ func (this *Server) OnUnmark(f func(types.IP)) {
 	this.onUnmarkCommands = append(this.onUnmarkCommands, f)
 }
 
+func (this *Server) OnPrefixMark(f func(server.PrefixInfo)) {
+	this.onPrefixMarkCommands = append(this.onPrefixMarkCommands, f)
+}
+
+func (this *Server) OnPrefixUnmark(f func(server.PrefixInfo)) {
+	this.onPrefixUnmarkCommands = append(this.onPrefixUnmarkCommands, f)
+}
+
 func (this *Server) Listen() *listen.Listen {
 	if this.listen != nil {
 		return this.listen
@@ -160,22 +254,29 @@ func (this *Server) Listen() *listen.Listen {
 		Concurrent: this.concurrentLimit,
 		MaxBytes:   this.clientMaxRecordMaxBytes,
 		OnListened: func(lInfo *listen.ListeningInfo) {
-			p := event.Parameters{}
-
 			this.Event().Trigger("on.port.registered",
-				p.AddString("IP", types.String(lInfo.IP.String())).
-					AddInt16("Port", types.Int16(lInfo.Port)).
-					AddString("Protocol", types.String(lInfo.Protocol).Lower()))
+				portParameters(lInfo.IP, lInfo.Port, lInfo.Protocol))
 		},
 		OnUnListened: func(lInfo *listen.ListeningInfo) {
-			p := event.Parameters{}
-
 			this.Event().Trigger("on.port.unregistered",
-				p.AddString("IP", types.String(lInfo.IP.String())).
-					AddInt16("Port", types.Int16(lInfo.Port)).
-					AddString("Protocol", types.String(lInfo.Protocol).Lower()))
+				portParameters(lInfo.IP, lInfo.Port, lInfo.Protocol))
+		},
+		OnListenFailed: func(lSetting *listen.ListenerSetting, e *types.Throw) {
+			this.listenerFailures.AtomicAdd(1)
+
+			this.Event().Trigger("on.port.failed",
+				portFailedParameters(lSetting.Protocol, lSetting.Setting, e))
+		},
+		OnBroken: func(lInfo *listen.ListeningInfo, e *types.Throw) {
+			this.listenerBroken.AtomicAdd(1)
+
+			this.Event().Trigger("on.port.broken",
+				portBrokenParameters(lInfo.IP, lInfo.Port, lInfo.Protocol,
+					e))
 		},
 		OnError: func(c listen.ConnectionInfo, e *types.Throw) {
+			this.listenerErrors.AtomicAdd(1)
+
 			this.logger.Debugf("An error happened when '%s' "+
 				"connected to '%s': %s", c.ClientIP.String(),
 				c.ServerAddress.IP, e)
@@ -196,11 +297,19 @@ func (this *Server) Listen() *listen.Listen {
 
 			this.serverDownWait.Add(1)
 
-			this.clientRWLock.RoutineExec(func() {
-				defer this.serverDownWait.Done()
+			defer this.serverDownWait.Done()
 
-				this.bumpClient(c, r)
-			})
+			sigResult := this.matchSignatures(c, r)
+
+			if sigResult.Action == signature.ACTION_IGNORE {
+				this.logger.Debugf("Ignoring '%s' as it matched "+
+					"signature '%s'", c.ClientIP.IP(),
+					sigResult.Names().ImplodeWith(","))
+
+				return
+			}
+
+			this.bumpClient(c, r, sigResult)
 		},
 	})
 
@@ -217,7 +326,9 @@ func (this *Server) Event() *event.Event {
 	this.event = &event.Event{}
 
 	this.event.Init(&event.Config{
-		Logger: this.logger,
+		Logger:    this.logger,
+		Workers:   int(this.eventWorkers),
+		QueueSize: int(this.eventQueueSize),
 	})
 
 	this.logger.Debugf("`Event` module now initialized")
@@ -225,23 +336,41 @@ func (this *Server) Event() *event.Event {
 	return this.event
 }
 
-func (this *Server) clients() *client.Clients {
+func (this *Server) clients() *client.Store {
 	if this.clientMaps != nil {
 		return this.clientMaps
 	}
 
-	this.clientMaps = client.NewClients(client.Config{
+	this.clientMaps = client.NewStore(client.CLIENT_STORE_SHARDS, client.Config{
+		MaxClients: this.clientMaxCount,
+		MaxMemory:  this.clientMaxMemory,
+		OnEvict: func(c *client.Client) {
+			this.logger.Debugf("Client '%s' has been evicted to release "+
+				"space for new clients", c.Address())
+
+			this.Event().Trigger("on.client.evicted",
+				clientEvictedParameters(c.Address(), c.Count(), c.Marked()))
+		},
 		OnMark: func(c *client.Client, typ client.MarkType) {
-			p := event.Parameters{}
+			tags := types.Strings{}
+
+			if c.LastRecord() != nil {
+				tags = c.LastRecord().Tags
+			}
 
 			this.Event().Trigger("on.client.marked",
-				p.AddString("ClientIP", types.String(
-					c.Address().String())).
-					AddUInt32("Count", c.Count()))
+				clientMarkedParameters(c.Address(), c.Count(), tags,
+					typ.String()))
 
 			switch typ {
 			case client.CLIENT_MARK_MANUAL:
 				fallthrough
+			case client.CLIENT_MARK_SCAN:
+				fallthrough
+			case client.CLIENT_MARK_SIGNATURE:
+				fallthrough
+			case client.CLIENT_MARK_POLICY:
+				fallthrough
 			case client.CLIENT_MARK_PICK:
 				lRecord := c.LastRecord()
 
@@ -259,14 +388,17 @@ func (this *Server) clients() *client.Clients {
 					markCmd(clientInfo)
 				}
 			}
+
+			this.blockClient(c)
+
+			this.prefixLock.Exec(func() {
+				this.prefixes().Join(types.ConvertIP(c.Address()))
+			})
 		},
 		OnUnmark: func(c *client.Client, typ client.UnmarkType) {
-			p := event.Parameters{}
-
 			this.Event().Trigger("on.client.marked.out",
-				p.AddString("ClientIP", types.String(
-					c.Address().String())).
-					AddUInt32("Count", c.Count()))
+				clientUnmarkedParameters(c.Address(), c.Count(),
+					typ.String()))
 
 			switch typ {
 			case client.CLIENT_UNMARK_MANUAL:
@@ -274,52 +406,158 @@ func (this *Server) clients() *client.Clients {
 					unmarkCmd(types.ConvertIP(c.Address()))
 				}
 			}
+
+			if this.firewall != nil {
+				this.firewall.Remove(types.ConvertIP(c.Address()))
+			}
+
+			this.prefixLock.Exec(func() {
+				this.prefixes().Leave(types.ConvertIP(c.Address()))
+			})
 		},
 		OnRecord: func(client *client.Client, data client.Record) {
-			p := event.Parameters{}
-
 			this.Event().Trigger("on.client.hitting",
-				p.AddString("ClientIP", types.String(
-					client.Address().String())).
-					AddString("ServerIP", types.String(
-						data.Hitting.IP.String())).
-					AddUInt16("ServerPort", data.Hitting.Port).
-					AddString("Type", data.Hitting.Type).
-					AddBytes("ReceivedSample", data.Inbound).
-					AddBytes("RespondedData", data.Outbound))
+				clientHittingParameters(client.Address(), data))
 		},
 	})
 
 	return this.clientMaps
 }
 
-func (this *Server) insertClient(c listen.ConnectionInfo, mark bool,
+// Add the client to the firewall set until it's restriction expires
+func (this *Server) blockClient(c *client.Client) {
+	if this.firewall == nil {
+		return
+	}
+
+	this.firewall.Add(types.ConvertIP(c.Address()), this.firewallTTL(c))
+}
+
+func (this *Server) firewallTTL(c *client.Client) time.Duration {
+	ttl := c.RestrictedUntil().Sub(time.Now())
+
+	if ttl < time.Second {
+		return time.Second
+	}
+
+	return ttl
+}
+
+// Make the firewall set the same as marked clients in the client table
+func (this *Server) reconcileFirewall() {
+	if this.firewall == nil {
+		return
+	}
+
+	desired := map[types.IP]time.Duration{}
+
+	this.clients().Each(func(clients *client.Clients) *types.Throw {
+		return clients.Scan(func(clientID types.IP,
+			clientInfo *client.Client) *types.Throw {
+			if !clientInfo.Marked() {
+				return nil
+			}
+
+			desired[clientID] = this.firewallTTL(clientInfo)
+
+			return nil
+		})
+	})
+
+	this.firewall.Reconcile(desired)
+}
+
+func (this *Server) prefixes() *client.Prefixes {
+	if this.prefixMaps != nil {
+		return this.prefixMaps
+	}
+
+	this.prefixMaps = client.NewPrefixes(client.PrefixConfig{
+		IPv4Bits:  this.prefixIPv4Bits,
+		IPv6Bits:  this.prefixIPv6Bits,
+		Thershold: this.prefixThershold,
+		// Prefixes from other nodes are kept as long as a marked client
+		Expire: this.tolerateExpire + this.tolerateRestrict,
+		OnMark: func(p *client.Prefix, typ client.MarkType) {
+			this.Event().Trigger("on.prefix.marked",
+				prefixParameters(p.CIDR(), p.Members(), typ.String()))
+
+			this.logger.Infof("Prefix '%s' has been marked as it contains "+
+				"'%d' marked clients", p.CIDR(), p.Members())
+
+			switch typ {
+			case client.CLIENT_MARK_MANUAL:
+				fallthrough
+			case client.CLIENT_MARK_PICK:
+				for _, markCmd := range this.onPrefixMarkCommands {
+					markCmd(server.ConvertPrefixInfo(p.Network()))
+				}
+			}
+		},
+		OnUnmark: func(p *client.Prefix, typ client.UnmarkType) {
+			this.Event().Trigger("on.prefix.marked.out",
+				prefixParameters(p.CIDR(), p.Members(), typ.String()))
+
+			// Prefixes released by their members are passed on too, so
+			// other nodes don't have to wait for them to expire
+			switch typ {
+			case client.CLIENT_UNMARK_MANUAL:
+				fallthrough
+			case client.CLIENT_UNMARK_RELEASE:
+				for _, unmarkCmd := range this.onPrefixUnmarkCommands {
+					unmarkCmd(server.ConvertPrefixInfo(p.Network()))
+				}
+			}
+		},
+	})
+
+	return this.prefixMaps
+}
+
+func (this *Server) countInbound() {
+	this.totalInbound.AtomicAdd(1)
+
+	this.statsLock.Exec(func() {
+		this.history.GetSlot(this.bootTime).Inbound += 1
+	})
+}
+
+func (this *Server) countHit(c listen.ConnectionInfo) {
+	this.totalHit.AtomicAdd(1)
+
+	this.statsLock.Exec(func() {
+		this.history.GetSlot(this.bootTime).Hit += 1
+
+		// Update port distribution
+		this.distribution.GetSlot(c.ServerAddress.Port, c.Type).Hit += 1
+	})
+}
+
+func (this *Server) countMarked() {
+	this.totalMarked.AtomicAdd(1)
+
+	this.statsLock.Exec(func() {
+		this.history.GetSlot(this.bootTime).Marked += 1
+	})
+}
+
+func (this *Server) insertClient(clients *client.Clients,
+	c listen.ConnectionInfo, mark bool,
 	insertType client.MarkType) (*client.Client, *types.Throw) {
 	nowTime := time.Now()
 
-	clientRecord, newClientRec := this.clients().Get(c.ClientIP)
-
-	historyRecord := this.history.GetSlot(
-		this.bootTime)
+	clientRecord, newClientRec := clients.Get(c.ClientIP)
 
 	// If this is a new client, add inbound record
 	if newClientRec {
-		this.totalInbound += 1
-		historyRecord.Inbound += 1
+		this.countInbound()
 
 		clientRecord.Tolerate(this.tolerate, this.tolerateExpire,
 			this.tolerateRestrict)
 	}
 
 	// Don't plus hit as we don't have actual hit here
-	this.totalHit += 1
-	historyRecord.Hit += 1
-
-	// Update port distribution
-	portDis := this.distribution.GetSlot(
-		c.ServerAddress.Port, c.Type)
-
-	portDis.Hit += 1
+	this.countHit(c)
 
 	clientRecord.Record(client.Record{
 		Inbound:  []byte{},
@@ -336,8 +574,7 @@ func (this *Server) insertClient(c listen.ConnectionInfo, mark bool,
 	if mark {
 		clientRecord.Mark(insertType)
 
-		this.totalMarked += 1
-		historyRecord.Marked += 1
+		this.countMarked()
 	}
 
 	this.logger.Infof("Client '%s' has been manually added as "+
@@ -348,54 +585,138 @@ func (this *Server) insertClient(c listen.ConnectionInfo, mark bool,
 	return clientRecord, nil
 }
 
+func (this *Server) matchSignatures(c listen.ConnectionInfo,
+	r listen.RespondedResult) signature.Result {
+	if this.signatures == nil {
+		return signature.Result{}
+	}
+
+	return this.signatures.Match(c, r)
+}
+
 func (this *Server) bumpClient(c listen.ConnectionInfo,
-	r listen.RespondedResult) (*client.Client, *types.Throw) {
+	r listen.RespondedResult,
+	sigResult signature.Result) (*client.Client, *types.Throw) {
+	var clientRecord *client.Client = nil
+	var err *types.Throw = nil
+
+	// Listener may reuse it's buffer after we return, so keep a copy
+	recordData := func(data []byte) []byte {
+		if types.Int32(len(data)).UInt32() > this.clientMaxRecordMaxBytes {
+			data = data[:this.clientMaxRecordMaxBytes]
+		}
+
+		dataCopy := make([]byte, len(data))
+
+		copy(dataCopy, data)
+
+		return dataCopy
+	}
+
+	inbound := recordData(r.ReceivedSample)
+	outbound := recordData(r.RespondedData)
+
+	this.clients().Exec(c.ClientIP, func(clients *client.Clients) {
+		clientRecord, err = this.hitClient(clients, c, inbound, outbound,
+			sigResult)
+	})
+
+	return clientRecord, err
+}
+
+func (this *Server) scoreThershold() types.UInt32 {
+	if this.signatures == nil {
+		return 0
+	}
+
+	return this.signatures.ScoreThershold
+}
+
+// Evaluate the policy before the hit is recorded, so a skipped hit will
+// leave no trace in the client table
+func (this *Server) evaluatePolicy(clients *client.Clients,
+	c listen.ConnectionInfo, inbound []byte, outbound []byte,
+	sigResult signature.Result, nowTime time.Time) policy.Decision {
+	if this.policy == nil {
+		return policy.Decision{}
+	}
+
+	context := policy.Context{
+		Source:     c.ClientIP,
+		Server:     c.ServerAddress.IP,
+		Port:       c.ServerAddress.Port,
+		Protocol:   c.Type,
+		Count:      1,
+		Score:      sigResult.Score,
+		Marked:     false,
+		Sample:     inbound,
+		Response:   outbound,
+		Tags:       sigResult.Tags(),
+		Signatures: sigResult.Names(),
+		HitsWithin: func(d time.Duration) types.UInt32 {
+			return 1
+		},
+	}
+
+	// Count in the current hit as it's not yet recorded
+	if clients.Has(c.ClientIP) {
+		existed, _ := clients.Get(c.ClientIP)
+
+		if existed.Expired(nowTime) != client.CLIENT_EXPIRED_YES {
+			context.Count = existed.Count() + 1
+			context.Score = existed.Score() + sigResult.Score
+		}
+
+		context.Marked = existed.Marked()
+		context.HitsWithin = func(d time.Duration) types.UInt32 {
+			return existed.HitsWithin(nowTime.Add(-d)) + 1
+		}
+	}
+
+	return this.policy.Evaluate(context)
+}
+
+func (this *Server) hitClient(clients *client.Clients,
+	c listen.ConnectionInfo, inbound []byte, outbound []byte,
+	sigResult signature.Result) (*client.Client, *types.Throw) {
 	nowTime := time.Now()
 
-	clientRecord, newClientRec := this.clients().Get(c.ClientIP)
+	decision := this.evaluatePolicy(clients, c, inbound, outbound,
+		sigResult, nowTime)
 
-	historyRecord := this.history.GetSlot(
-		this.bootTime)
+	if decision.Action == policy.ACTION_SKIP {
+		this.logger.Debugf("Skipping '%s' as policy on line '%v' "+
+			"says so", c.ClientIP.IP(), decision.Lines)
+
+		return nil, nil
+	}
+
+	clientRecord, newClientRec := clients.Get(c.ClientIP)
 
 	if newClientRec {
-		this.totalInbound += 1
-		historyRecord.Inbound += 1
+		this.countInbound()
 
 		clientRecord.Tolerate(this.tolerate, this.tolerateExpire,
 			this.tolerateRestrict)
 	}
 
-	this.totalHit += 1
-	historyRecord.Hit += 1
-
-	portDis := this.distribution.GetSlot(
-		c.ServerAddress.Port,
-		c.Type)
+	if decision.Restrict > 0 {
+		clientRecord.Tolerate(this.tolerate, this.tolerateExpire,
+			decision.Restrict)
+	}
 
-	portDis.Hit += 1
+	this.countHit(c)
 
 	clientRecord.Record(client.Record{
-		Inbound: func(r *listen.RespondedResult) []byte {
-			if types.Int32(len(r.ReceivedSample)).UInt32() >
-				this.clientMaxRecordMaxBytes {
-				return r.ReceivedSample[:this.clientMaxRecordMaxBytes]
-			}
-
-			return r.ReceivedSample
-		}(&r),
-		Outbound: func(r *listen.RespondedResult) []byte {
-			if types.Int32(len(r.RespondedData)).UInt32() >
-				this.clientMaxRecordMaxBytes {
-				return r.RespondedData[:this.clientMaxRecordMaxBytes]
-			}
-
-			return r.RespondedData
-		}(&r),
+		Inbound:  inbound,
+		Outbound: outbound,
 		Hitting: client.Hitting{
 			IPAddress: c.ServerAddress,
 			Type:      c.Type,
 		},
-		Time: nowTime,
+		Signatures: sigResult.Names(),
+		Tags:       sigResult.Tags(),
+		Time:       nowTime,
 	}, this.clientMaxRecords)
 
 	// Check expiration here, allowing faster expire reset
@@ -405,6 +726,82 @@ func (this *Server) bumpClient(c listen.ConnectionInfo,
 		clientRecord.Bump() // Update count and last seen
 	}
 
+	// Restriction has been extended, so does the firewall entry once
+	// it's about to expire
+	if clientRecord.Marked() && this.firewall != nil {
+		this.firewall.Refresh(types.ConvertIP(clientRecord.Address()),
+			this.firewallTTL(clientRecord))
+	}
+
+	// Apply the matched signatures, they can mark the client right away
+	// or when the score reached thershold
+	if sigResult.Matched() {
+		totalScore := clientRecord.AddScore(sigResult.Score)
+
+		this.Event().Trigger("on.client.signature",
+			clientSignatureParameters(clientRecord.Address(), sigResult,
+				totalScore, clientRecord.Count()))
+
+		if !clientRecord.Marked() &&
+			(sigResult.Action == signature.ACTION_MARK ||
+				(this.scoreThershold() > 0 &&
+					totalScore >= this.scoreThershold())) {
+			clientRecord.Mark(client.CLIENT_MARK_SIGNATURE)
+
+			this.countMarked()
+
+			this.logger.Infof("Client '%s' has been marked as it matched "+
+				"signature '%s' with score '%d'", clientRecord.Address(),
+				sigResult.Names().ImplodeWith(","), totalScore)
+
+			return clientRecord, nil
+		}
+	}
+
+	// Apply the policy decision
+	if decision.Matched() {
+		totalScore := clientRecord.AddScore(decision.Score)
+
+		if !clientRecord.Marked() &&
+			(decision.Action == policy.ACTION_MARK ||
+				(this.scoreThershold() > 0 &&
+					totalScore >= this.scoreThershold())) {
+			clientRecord.Mark(client.CLIENT_MARK_POLICY)
+
+			this.countMarked()
+
+			this.logger.Infof("Client '%s' has been marked by policy on "+
+				"line '%v' with score '%d'", clientRecord.Address(),
+				decision.Lines, totalScore)
+
+			return clientRecord, nil
+		}
+	}
+
+	// Check how many distinct ports the client touched within the scan
+	// window, a scanner will be marked right away without tolerate
+	if this.scanThershold > 0 {
+		scannedPorts := clientRecord.HitPort(c.ServerAddress.Port, nowTime,
+			this.scanWindow)
+
+		if !clientRecord.Marked() &&
+			types.Int32(scannedPorts.Len()).UInt16() >= this.scanThershold {
+			this.Event().Trigger("on.client.scanning",
+				clientScanningParameters(clientRecord.Address(),
+					scannedPorts, clientRecord.Count()))
+
+			clientRecord.Mark(client.CLIENT_MARK_SCAN)
+
+			this.countMarked()
+
+			this.logger.Infof("Client '%s' has been marked as it scanned "+
+				"'%d' ports (%s) within '%s'", clientRecord.Address(),
+				scannedPorts.Len(), scannedPorts.String(), this.scanWindow)
+
+			return clientRecord, nil
+		}
+	}
+
 	// Check the connection tolerate limit
 	if clientRecord.Count() < this.tolerate {
 		this.logger.Infof("Client '%s' connected '%d'"+
@@ -423,8 +820,7 @@ func (this *Server) bumpClient(c listen.ConnectionInfo,
 
 	clientRecord.Mark(client.CLIENT_MARK_PICK)
 
-	this.totalMarked += 1
-	historyRecord.Marked += 1
+	this.countMarked()
 
 	this.logger.Infof("Client '%s' worth some notice as it "+
 		"connected us '%d' times within '%s'", clientRecord.Address(),
@@ -446,8 +842,10 @@ func (this *Server) clientCron() {
 			return
 
 		case <-time.After(64 * time.Second):
-			this.clientRWLock.Exec(func() {
-				this.clients().Scan(func(clientID types.IP,
+			// Shards are locked one by one, so hits to other shards
+			// will not be blocked by the scan
+			this.clients().Each(func(clients *client.Clients) *types.Throw {
+				return clients.Scan(func(clientID types.IP,
 					clientInfo *client.Client) *types.Throw {
 					switch clientInfo.Expired(nowTime) {
 					case client.CLIENT_EXPIRED_NO:
@@ -458,22 +856,28 @@ func (this *Server) clientCron() {
 						return nil
 
 					case client.CLIENT_EXPIRED_YES:
-						return this.clients().Delete(clientID,
+						return clients.Delete(clientID,
 							client.CLIENT_UNMARK_EXPIRE)
 					}
 
 					return nil
 				})
 			})
+
+			this.prefixLock.Exec(func() {
+				this.prefixes().Expire(nowTime)
+			})
+
+			this.reconcileFirewall()
 		}
 	}
 }
 
 func (this *Server) Clients() []client.ClientExport {
-	var clients []client.ClientExport
+	clients := this.clients().Export()
 
-	this.clientRWLock.Exec(func() {
-		clients = this.clients().Export()
+	this.prefixLock.Exec(func() {
+		clients = append(clients, this.prefixes().Export()...)
 	})
 
 	return clients
@@ -483,14 +887,20 @@ func (this *Server) Client(addr types.IP) (*client.Client, *types.Throw) {
 	var c *client.Client = nil
 	var e *types.Throw = nil
 
-	this.clientRWLock.Exec(func() {
-		if !this.clients().Has(addr) {
+	this.clients().Exec(addr, func(clients *client.Clients) {
+		if !clients.Has(addr) {
 			e = server.ErrClientNotFound.Throw(addr.IP())
 
 			return
 		}
 
-		c, _ = this.clients().Get(addr)
+		// Make a copy, all change to the client must go through
+		// manageable methods
+		clientRecord, _ := clients.Get(addr)
+
+		c = &client.Client{}
+
+		*c = *clientRecord
 	})
 
 	if e != nil {
@@ -501,12 +911,7 @@ func (this *Server) Client(addr types.IP) (*client.Client, *types.Throw) {
 		return nil, server.ErrClientNotFound.Throw(addr.IP())
 	}
 
-	// Make a copy, all change to the client must go through manageable methods
-	clientCopy := &client.Client{}
-
-	*clientCopy = *c
-
-	return clientCopy, nil
+	return c, nil
 }
 
 func (this *Server) addClient(clientData server.ClientInfo,
@@ -528,9 +933,9 @@ func (this *Server) addClient(clientData server.ClientInfo,
 			clientData.Client.IP())
 	}
 
-	this.clientRWLock.Exec(func() {
+	this.clients().Exec(clientData.Client, func(clients *client.Clients) {
 		// Check if it already existed
-		if this.clients().Has(clientData.Client) {
+		if clients.Has(clientData.Client) {
 			e = server.ErrClientAlreadyExisted.Throw(
 				clientData.Client.IP())
 
@@ -538,11 +943,12 @@ func (this *Server) addClient(clientData server.ClientInfo,
 		}
 
 		// Add client to data set
-		newClient, newClientErr := this.insertClient(listen.ConnectionInfo{
-			ClientIP:      clientData.Client,
-			ServerAddress: clientData.Server,
-			Type:          clientData.Type,
-		}, clientData.Marked, addType)
+		newClient, newClientErr := this.insertClient(clients,
+			listen.ConnectionInfo{
+				ClientIP:      clientData.Client,
+				ServerAddress: clientData.Server,
+				Type:          clientData.Type,
+			}, clientData.Marked, addType)
 
 		if newClientErr != nil {
 			e = newClientErr
@@ -563,20 +969,29 @@ func (this *Server) AddClient(
 
 func (this *Server) ImportClient(
 	clientData server.ClientInfo) (*client.Client, *types.Throw) {
-	return this.addClient(clientData, client.CLIENT_MARK_OTHER)
+	c, err := this.addClient(clientData, client.CLIENT_MARK_OTHER)
+
+	if err != nil {
+		return c, err
+	}
+
+	this.Event().Trigger("on.client.imported",
+		clientImportedParameters(clientData))
+
+	return c, nil
 }
 
 func (this *Server) RemoveClient(addr types.IP) *types.Throw {
 	var result *types.Throw = nil
 
-	this.clientRWLock.Exec(func() {
-		if !this.clients().Has(addr) {
+	this.clients().Exec(addr, func(clients *client.Clients) {
+		if !clients.Has(addr) {
 			result = server.ErrClientNotFound.Throw(addr)
 
 			return
 		}
 
-		result = this.clients().Delete(addr, client.CLIENT_UNMARK_MANUAL)
+		result = clients.Delete(addr, client.CLIENT_UNMARK_MANUAL)
 	})
 
 	if result != nil {
@@ -586,21 +1001,77 @@ func (this *Server) RemoveClient(addr types.IP) *types.Throw {
 	return result
 }
 
+// ImportPrefix marks the prefix from another node. Prefixes which are
+// wider than the aggregation, or of a family which is not aggregated
+// can't be imported
+func (this *Server) ImportPrefix(prefix server.PrefixInfo) *types.Throw {
+	var result *types.Throw = nil
+
+	if prefix.IsEmpty() || prefix.Bits > prefix.MaxBits() {
+		return server.ErrInvalidPrefix.Throw(prefix.CIDR())
+	}
+
+	minBits := this.prefixIPv6Bits
+
+	if prefix.Network.IP().To4() != nil {
+		minBits = this.prefixIPv4Bits
+	}
+
+	// Aggregation of the address family is disabled when it's 0
+	if minBits == 0 || prefix.Bits < minBits {
+		return server.ErrInvalidPrefix.Throw(prefix.CIDR())
+	}
+
+	this.prefixLock.Exec(func() {
+		_, result = this.prefixes().Mark(prefix.IPNet(),
+			client.CLIENT_MARK_OTHER)
+	})
+
+	return result
+}
+
+// RemovePrefix unmarks the prefix as requested by another node. The
+// removal will not be broadcasted again, the caller passes it on
+func (this *Server) RemovePrefix(prefix server.PrefixInfo) *types.Throw {
+	var result *types.Throw = nil
+
+	this.prefixLock.Exec(func() {
+		result = this.prefixes().Unmark(prefix.IPNet(),
+			client.CLIENT_UNMARK_SYNC)
+	})
+
+	return result
+}
+
 func (this *Server) Status() server.Status {
 	sInfo := server.Status{}
 
-	this.clientRWLock.Exec(func() {
-		sInfo.Uptime = time.Now().Sub(this.bootTime)
+	sInfo.Uptime = time.Now().Sub(this.bootTime)
 
+	this.statsLock.Exec(func() {
 		sInfo.History = this.history.Histories()
 		sInfo.Distribution = this.distribution.Distributions()
-
-		sInfo.TotalInbound = this.totalInbound
-		sInfo.TotalMarked = this.totalMarked
-		sInfo.TotalHit = this.totalHit
-		sInfo.TotalClients = types.UInt64(this.clients().Len())
 	})
 
+	sInfo.TotalInbound = this.totalInbound.AtomicLoad()
+	sInfo.TotalMarked = this.totalMarked.AtomicLoad()
+	sInfo.TotalHit = this.totalHit.AtomicLoad()
+	sInfo.TotalClients = types.UInt64(this.clients().Len())
+	sInfo.TotalEvicted = this.clients().Evicted()
+	sInfo.MarkedClients = types.UInt64(this.clients().Marked())
+	sInfo.MemoryUsage = this.clients().Memory()
+
+	sInfo.ListenerErrors = this.listenerErrors.AtomicLoad()
+	sInfo.ListenerFailures = this.listenerFailures.AtomicLoad()
+	sInfo.ListenerBroken = this.listenerBroken.AtomicLoad()
+
+	eventStats := this.Event().Stats()
+
+	sInfo.EventQueueDepth = eventStats.Depth
+	sInfo.EventHandled = eventStats.Handled
+	sInfo.EventFailed = eventStats.Failed
+	sInfo.EventDropped = eventStats.Dropped
+
 	return sInfo
 }
 
@@ -619,6 +1090,22 @@ func (this *Server) powerup() *types.Throw {
 	this.bootTime = time.Now()
 	this.clientCronExitCh = make(chan bool)
 
+	// Initialize shared modules before any hit could reach them
+	this.Event().Start()
+	this.clients()
+	this.prefixes()
+
+	if this.firewall != nil {
+		fwErr := this.firewall.Up()
+
+		if fwErr != nil {
+			this.logger.Errorf("Can't bring up firewall backend due to "+
+				"error: %s", fwErr)
+
+			this.firewall = nil
+		}
+	}
+
 	go this.clientCron()
 
 	lnErr := this.Listen().Serv()
@@ -671,9 +1158,22 @@ func (this *Server) shutdown() *types.Throw {
 
 	this.logger.Debugf("Shutting down")
 
+	// The whole set will be removed, so don't bother to remove clients
+	// from it one by one when they are unmarked below
+	if this.firewall != nil {
+		fwErr := this.firewall.Down()
+
+		if fwErr != nil {
+			this.logger.Errorf("Can't bring down firewall backend due to "+
+				"error: %s", fwErr)
+		}
+	}
+
 	// Unmark all clients before shutdown
-	this.clientRWLock.Exec(func() {
-		this.clients().Clear()
+	this.clients().Clear()
+
+	this.prefixLock.Exec(func() {
+		this.prefixes().Clear()
 	})
 
 	// Send down commands before actually down the server
@@ -709,11 +1209,17 @@ func (this *Server) shutdown() *types.Throw {
 	// Final wait
 	this.serverDownWait.Wait()
 
+	// Handle all queued events, so `on.server.down` will be the last one
+	this.Event().Flush()
+
 	// We already successfully shut server down once we get here
 	this.serverUpped = false
 
 	this.Event().Trigger("on.server.down", event.Parameters{})
 
+	// Co-processes should receive the `on.server.down` before stop
+	this.Event().Stop()
+
 	if lnErr != nil {
 		this.logger.Errorf("`Server` is down, but there is at least "+
 			"one problem: %s", lnErr)
@@ -762,17 +1268,26 @@ func (this *Server) Reload(
 		this.listen = nil
 		this.event = nil
 
-		this.clientMaps = &client.Clients{}
+		this.clientMaps = nil
+		this.prefixMaps = nil
+		this.signatures = nil
+		this.policy = nil
+		this.firewall = nil
 
 		this.onUpCommands = types.Callbacks{}
 		this.onDownCommands = types.Callbacks{}
 		this.onUpDownCommands = []types.CallbackPair{}
 		this.onMarkCommands = []func(server.ClientInfo){}
 		this.onUnmarkCommands = []func(types.IP){}
-
-		this.totalInbound = 0
-		this.totalMarked = 0
-		this.totalHit = 0
+		this.onPrefixMarkCommands = []func(server.PrefixInfo){}
+		this.onPrefixUnmarkCommands = []func(server.PrefixInfo){}
+
+		this.totalInbound.AtomicStore(0)
+		this.totalMarked.AtomicStore(0)
+		this.totalHit.AtomicStore(0)
+		this.listenerErrors.AtomicStore(0)
+		this.listenerFailures.AtomicStore(0)
+		this.listenerBroken.AtomicStore(0)
 		this.history = server.Histories{}
 		this.distribution = server.Distributions{}
 
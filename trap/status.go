@@ -22,6 +22,7 @@
 package trap
 
 import (
+	"github.com/raincious/trap/trap/core/audit"
 	"github.com/raincious/trap/trap/core/client"
 	"github.com/raincious/trap/trap/core/logger"
 	"github.com/raincious/trap/trap/core/server"
@@ -38,6 +39,9 @@ import (
 )
 
 type Status struct {
+	// Updated atomically, keep it at the top for alignment
+	loginFailures types.UInt64
+
 	ip             types.IP
 	host           types.String
 	port           types.UInt16
@@ -48,9 +52,17 @@ type Status struct {
 	status         *http.Server
 	server         *Server
 	sync           *Sync
+	metrics        *Metrics
+	audit          *Audit
+	blocklistOpen  bool
 	serverRWLock   types.Mutex
 	sessions       status.Sessions
+	streamTickets  status.StreamTickets
 	sessionRWLock  types.Mutex
+	tokens         status.Tokens
+	tokenFile      types.String
+	revokedTokens  map[types.String]bool
+	tokenRWLock    types.Mutex
 	statusListener net.Listener
 	statusDownWait sync.WaitGroup
 }
@@ -68,8 +80,12 @@ func NewStatus() *Status {
 		port:           1793,
 		accounts:       status.Accounts{},
 		sessions:       status.Sessions{},
+		streamTickets:  status.StreamTickets{},
 		serverRWLock:   types.Mutex{},
 		sessionRWLock:  types.Mutex{},
+		tokens:         status.Tokens{},
+		revokedTokens:  map[types.String]bool{},
+		tokenRWLock:    types.Mutex{},
 		statusDownWait: sync.WaitGroup{},
 	}
 }
@@ -86,6 +102,37 @@ func (this *Status) SetSync(s *Sync) {
 	this.sync = s
 }
 
+// LoginFailures returns how many bad authorization attempts have been made
+func (this *Status) LoginFailures() types.UInt64 {
+	return this.loginFailures.AtomicLoad()
+}
+
+func (this *Status) SetMetrics(m *Metrics) {
+	this.metrics = m
+}
+
+func (this *Status) SetAudit(a *Audit) {
+	this.audit = a
+}
+
+func (this *Status) record(entry audit.Entry) {
+	if this.audit == nil {
+		return
+	}
+
+	this.audit.Record(entry)
+}
+
+func (this *Status) queryAudit(q audit.Query) audit.QueryResult {
+	if this.audit == nil {
+		return audit.QueryResult{
+			Entries: []audit.Entry{},
+		}
+	}
+
+	return this.audit.Query(q)
+}
+
 func (this *Status) LoadCert(pem types.String, key types.String) {
 	this.tlsCertFile = pem
 	this.tlsKeyFile = key
@@ -103,11 +150,126 @@ func (this *Status) Port(port types.UInt16) {
 	this.port = port
 }
 
+// BlocklistPublic serves `/api/blocklist` without authorization
+func (this *Status) BlocklistPublic(public bool) {
+	this.blocklistOpen = public
+}
+
+// Account registers a legacy account which is identified by it's
+// plaintext password
 func (this *Status) Account(pass types.String,
 	permissions []types.String) (*status.Account, *types.Throw) {
 	return this.accounts.Register(pass, permissions)
 }
 
+// User adds an user with the bcrypt hash of it's password
+func (this *Status) User(name types.String, hash types.String,
+	permissions []types.String) (*status.Account, *types.Throw) {
+	return this.accounts.Add(name, hash, permissions)
+}
+
+// SetTokenFile sets the file which keeps the tokens created by the API
+// and the revoked tokens, and loads them from it. Call it before adding
+// tokens. Without the file, they are only kept until Trap exits
+func (this *Status) SetTokenFile(path types.String) *types.Throw {
+	var result *types.Throw = nil
+
+	this.tokenRWLock.Exec(func() {
+		this.tokenFile = path
+
+		if path == "" {
+			return
+		}
+
+		state, stateErr := status.LoadTokenState(path)
+
+		if stateErr != nil {
+			result = stateErr
+
+			return
+		}
+
+		// The file is saved on every change, so it's never older than
+		// the tokens in memory
+		for id, token := range this.tokens {
+			if token.Static {
+				continue
+			}
+
+			delete(this.tokens, id)
+		}
+
+		this.revokedTokens = map[types.String]bool{}
+
+		for _, id := range state.Revoked {
+			this.revokedTokens[id] = true
+		}
+
+		for _, record := range state.Tokens {
+			_, restoreErr := this.tokens.Restore(record)
+
+			if restoreErr != nil {
+				result = status.ErrTokenStateInvalid.Throw(path, restoreErr)
+
+				return
+			}
+		}
+	})
+
+	return result
+}
+
+// Save the tokens created by the API and the revoked tokens, call it
+// with the tokenRWLock held
+func (this *Status) saveTokens() *types.Throw {
+	if this.tokenFile == "" {
+		return nil
+	}
+
+	state := status.TokenState{
+		Tokens:  []status.TokenRecord{},
+		Revoked: []types.String{},
+	}
+
+	for _, token := range this.tokens {
+		if token.Static {
+			continue
+		}
+
+		state.Tokens = append(state.Tokens, token.Record())
+	}
+
+	for id := range this.revokedTokens {
+		state.Revoked = append(state.Revoked, id)
+	}
+
+	return state.Save(this.tokenFile)
+}
+
+// Token adds an API token with the SHA-256 hash of it. ErrTokenRevoked
+// will be returned when the token has been revoked through the API
+func (this *Status) Token(token status.Token,
+	hash types.String) (*status.Token, *types.Throw) {
+	var result *status.Token = nil
+	var resultErr *types.Throw = nil
+
+	token.Static = true
+
+	this.tokenRWLock.Exec(func() {
+		result, resultErr = this.tokens.Add(token, hash)
+
+		if resultErr != nil || !this.revokedTokens[result.ID] {
+			return
+		}
+
+		delete(this.tokens, result.ID)
+
+		result, resultErr = nil, status.ErrTokenRevoked.Throw(result.ID)
+	})
+
+	return result, resultErr
+}
+
 func (this *Status) verifyUser(ip net.IP,
 	sessionKey types.String) (*status.Session, *types.Throw) {
 	var sess *status.Session = nil
@@ -120,44 +282,327 @@ func (this *Status) verifyUser(ip net.IP,
 	return sess, err
 }
 
-func (this *Status) authUser(ip net.IP,
+func (this *Status) issueStreamTicket(ip net.IP,
+	sess *status.Session) (types.String, *types.Throw) {
+	var ticket types.String = ""
+	var err *types.Throw = nil
+
+	this.sessionRWLock.Exec(func() {
+		ticket, err = this.streamTickets.Issue(ip, sess)
+	})
+
+	return ticket, err
+}
+
+func (this *Status) redeemStreamTicket(ip net.IP,
+	ticket types.String) (*status.Session, *types.Throw) {
+	var sess *status.Session = nil
+	var err *types.Throw = nil
+
+	this.sessionRWLock.Exec(func() {
+		sess, err = this.streamTickets.Redeem(ip, ticket)
+	})
+
+	return sess, err
+}
+
+func (this *Status) markBadClient(ip net.IP) {
+	_, cliAddErr := this.server.AddClient(server.ClientInfo{
+		Client: types.ConvertIP(ip),
+		Server: types.IPAddress{
+			IP:   types.ConvertIP(net.ParseIP("0.0.0.0")),
+			Port: this.port,
+		},
+		Type:   "status_ui",
+		Marked: true,
+	})
+
+	if cliAddErr != nil {
+		this.logger.Warningf("Can't mark bad client '%s' due to error: %s",
+			ip, cliAddErr)
+	}
+}
+
+// Verify the API token of a request. Successful uses of the token are
+// audited by the `Audited` middleware for the requests which change the
+// state, so only the failures of other requests are recorded here
+func (this *Status) verifyToken(ip net.IP, secret types.String,
+	write bool) (*status.Session, *types.Throw) {
+	var token *status.Token = nil
+	var tokenErr *types.Throw = nil
+
+	this.tokenRWLock.Exec(func() {
+		token, tokenErr = this.tokens.Verify(ip, secret)
+	})
+
+	if tokenErr != nil {
+		// Only guessing tokens is punished, expired or misplaced tokens
+		// are likely to be mistakes of our own
+		if tokenErr.Is(status.ErrTokenInvalid) {
+			this.markBadClient(ip)
+		}
+
+		this.loginFailures.AtomicAdd(1)
+
+		this.logger.Warningf("Bad API token from '%s': %s", ip, tokenErr)
+
+		if !write {
+			this.record(audit.Entry{
+				Actor:  audit.ACTOR_ANONYMOUS,
+				IP:     types.String(ip.String()),
+				Action: audit.ACTION_TOKEN_USE,
+				Error:  types.String(tokenErr.Error()),
+			})
+		}
+
+		this.server.Event().Trigger("on.status.token.failed",
+			tokenFailedParameters(ip, tokenErr))
+
+		return nil, tokenErr
+	}
+
+	this.logger.Debugf("API token '%s' (%s) has been used by '%s'",
+		token.Name, token.ID, ip)
+
+	if !write {
+		return status.NewTokenSession(ip, token), nil
+	}
+
+	this.server.Event().Trigger("on.status.token.used",
+		tokenUsedParameters(ip, token))
+
+	return status.NewTokenSession(ip, token), nil
+}
+
+func (this *Status) createToken(sess *status.Session,
+	req status.TokenRequest) (status.TokenRespond, *types.Throw) {
+	var token *status.Token = nil
+	var secret types.String = ""
+	var createErr *types.Throw = nil
+
+	if req.Name == "" {
+		return status.TokenRespond{},
+			status.ErrStatusControllerInvalidParameter.Throw()
+	}
+
+	if !req.Expire.IsZero() && !req.Expire.After(time.Now()) {
+		return status.TokenRespond{},
+			status.ErrStatusControllerInvalidParameter.Throw()
+	}
+
+	// Nobody can grant the access which they don't have
+	requested := status.Permission{}
+	sessionPermission := sess.Permission()
+
+	for _, scope := range req.Scopes {
+		scopeErr := requested.Authorize(scope)
+
+		if scopeErr != nil {
+			return status.TokenRespond{}, scopeErr
+		}
+	}
+
+	if !sessionPermission.Includes(requested) {
+		return status.TokenRespond{},
+			status.ErrSessionNoPermission.Throw()
+	}
+
+	networks := []*net.IPNet{}
+
+	for _, network := range req.Networks {
+		ipNet, ipNetErr := status.ParseNetwork(network)
+
+		if ipNetErr != nil {
+			return status.TokenRespond{}, ipNetErr
+		}
+
+		networks = append(networks, ipNet)
+	}
+
+	this.tokenRWLock.Exec(func() {
+		token, secret, createErr = this.tokens.Create(status.Token{
+			Name:     req.Name,
+			Scopes:   req.Scopes,
+			Networks: networks,
+			Expire:   req.Expire,
+			Creator:  sess.Actor(),
+		})
+
+		if createErr != nil {
+			return
+		}
+
+		createErr = this.saveTokens()
+
+		if createErr != nil {
+			delete(this.tokens, token.ID)
+		}
+	})
+
+	if createErr != nil {
+		return status.TokenRespond{}, createErr
+	}
+
+	this.logger.Infof("API token '%s' (%s) with scopes '%s' has been "+
+		"created by '%s' from '%s'", token.Name, token.ID,
+		token.Scopes, sess.Actor(), sess.IP)
+
+	this.server.Event().Trigger("on.status.token.created",
+		tokenChangedParameters(sess.IP, sess.Actor(), token))
+
+	return status.TokenRespond{
+		Token: secret,
+		Info:  token.Dump(),
+	}, nil
+}
+
+func (this *Status) revokeToken(sess *status.Session,
+	id types.String) *types.Throw {
+	var token *status.Token = nil
+	var revokeErr *types.Throw = nil
+	var saveErr *types.Throw = nil
+
+	this.tokenRWLock.Exec(func() {
+		token, revokeErr = this.tokens.Revoke(id)
+
+		if revokeErr != nil {
+			return
+		}
+
+		// Tokens of the configuration will be added again on reload
+		if token.Static {
+			this.revokedTokens[token.ID] = true
+		}
+
+		saveErr = this.saveTokens()
+	})
+
+	if revokeErr != nil {
+		return revokeErr
+	}
+
+	this.logger.Infof("API token '%s' (%s) has been revoked by '%s' "+
+		"from '%s'", token.Name, token.ID, sess.Actor(), sess.IP)
+
+	this.server.Event().Trigger("on.status.token.revoked",
+		tokenChangedParameters(sess.IP, sess.Actor(), token))
+
+	if saveErr != nil {
+		this.logger.Errorf("API token '%s' (%s) will be back after restart: "+
+			"%s", token.Name, token.ID, saveErr)
+
+		return saveErr
+	}
+
+	return nil
+}
+
+func (this *Status) getAllTokens() []status.TokenDump {
+	var dump []status.TokenDump
+
+	this.tokenRWLock.Exec(func() {
+		dump = this.tokens.Dump()
+	})
+
+	return dump
+}
+
+func (this *Status) credentials() controller.Credentials {
+	return controller.Credentials{
+		Verify:      this.verifyUser,
+		VerifyToken: this.verifyToken,
+		Record:      this.record,
+	}
+}
+
+func (this *Status) sessioned() controller.Sessioned {
+	return controller.Sessioned{
+		Credentials: this.credentials(),
+	}
+}
+
+func (this *Status) sessionedJSON() controller.SessionedJSON {
+	return controller.SessionedJSON{
+		Credentials: this.credentials(),
+	}
+}
+
+func (this *Status) authUser(ip net.IP, name types.String,
 	pass types.String) (*status.Session, *types.Throw) {
 	var result *status.Session = nil
 	var resultErr *types.Throw = nil
 
-	account, accountErr := this.accounts.Get(pass)
+	account, accountErr := this.accounts.Authenticate(name, pass)
 
 	if accountErr != nil {
-		_, cliAddErr := this.server.AddClient(server.ClientInfo{
-			Client: types.ConvertIP(ip),
-			Server: types.IPAddress{
-				IP:   types.ConvertIP(net.ParseIP("0.0.0.0")),
-				Port: this.port,
-			},
-			Type:   "status_ui",
-			Marked: true,
-		})
+		this.markBadClient(ip)
 
-		if cliAddErr != nil {
-			this.logger.Warningf("Can't mark bad client '%s' due to error: %s",
-				ip, cliAddErr)
-		}
+		this.loginFailures.AtomicAdd(1)
+
+		this.logger.Warningf("Bad authorization attempt from '%s' for "+
+			"user '%s'", ip, name)
 
-		this.logger.Warningf("Bad authorization attempt from '%s'", ip)
+		this.server.Event().Trigger("on.status.login.failed",
+			loginFailedParameters(ip, name, accountErr))
 
 		return nil, accountErr
 	}
 
-	this.logger.Infof("A new session has been binded with '%s'", ip)
+	this.logger.Infof("A new session has been binded with '%s' for user "+
+		"'%s'", ip, account.Name())
 
 	this.sessionRWLock.Exec(func() {
 		result, resultErr = this.sessions.Add(ip, account,
 			12*time.Hour)
 	})
 
+	if resultErr == nil {
+		this.server.Event().Trigger("on.status.login",
+			loginParameters(ip, account.Name()))
+	}
+
 	return result, resultErr
 }
 
+func (this *Status) setLogLevel(req status.LogLevelRequest) *types.Throw {
+	levels := this.logger.Levels()
+
+	if req.Level == "" {
+		if req.Context == "" {
+			return status.ErrStatusControllerInvalidParameter.Throw()
+		}
+
+		levels.ClearContextLevel(req.Context)
+
+		this.logger.Infof("Log level of context '%s' has been removed",
+			req.Context)
+
+		return nil
+	}
+
+	level, levelErr := logger.ParseLevel(req.Level)
+
+	if levelErr != nil {
+		return levelErr
+	}
+
+	if req.Context == "" {
+		levels.SetLevel(level)
+
+		this.logger.Infof("Global log level has been set to '%s'",
+			logger.LevelName(level))
+
+		return nil
+	}
+
+	levels.SetContextLevel(req.Context, level)
+
+	this.logger.Infof("Log level of context '%s' has been set to '%s'",
+		req.Context, logger.LevelName(level))
+
+	return nil
+}
+
 func (this *Status) getAllSessions() []status.SessionDump {
 	var dump []status.SessionDump
 
@@ -183,26 +628,20 @@ func (this *Status) getNewServer(httpAddr string) (*http.Server, *types.Throw) {
 	})
 
 	httpMux.HandleController("/api/status", &controller.Status{
-		SessionedJSON: controller.SessionedJSON{
-			Verify: this.verifyUser,
-		},
+		SessionedJSON: this.sessionedJSON(),
 		GetStatus: func() server.Status {
 			return this.server.Status()
 		},
 	})
 
 	httpMux.HandleController("/api/clients", &controller.Clients{
-		SessionedJSON: controller.SessionedJSON{
-			Verify: this.verifyUser,
-		},
+		SessionedJSON: this.sessionedJSON(),
 		GetClients: func() []client.ClientExport {
 			return this.server.Clients()
 		},
 	})
 	httpMux.HandleController("/api/client", &controller.Client{
-		SessionedJSON: controller.SessionedJSON{
-			Verify: this.verifyUser,
-		},
+		SessionedJSON: this.sessionedJSON(),
 		GetClient: func(addr types.IP) (*client.Client, *types.Throw) {
 			return this.server.Client(addr)
 		},
@@ -216,32 +655,81 @@ func (this *Status) getNewServer(httpAddr string) (*http.Server, *types.Throw) {
 	})
 
 	httpMux.HandleController("/api/logs", &controller.Logs{
-		SessionedJSON: controller.SessionedJSON{
-			Verify: this.verifyUser,
+		SessionedJSON: this.sessionedJSON(),
+		QueryLogs: func(q logger.Query) logger.QueryResult {
+			return this.logger.Query(q)
+		},
+	})
+
+	httpMux.HandleController("/api/logs/stream", &controller.LogStream{
+		Logs: controller.Logs{
+			SessionedJSON: this.sessionedJSON(),
+			QueryLogs: func(q logger.Query) logger.QueryResult {
+				return this.logger.Query(q)
+			},
 		},
-		GetLogs: func() []logger.LogExport {
-			return this.logger.Dump()
+		Subscribe: func(q logger.Query) *logger.Subscription {
+			return this.logger.Subscribe(q, controller.LOG_STREAM_BUFFER)
 		},
+		IssueTicket:  this.issueStreamTicket,
+		RedeemTicket: this.redeemStreamTicket,
 	})
 
-	httpMux.HandleController("/api/sessions", &controller.Sessions{
-		SessionedJSON: controller.SessionedJSON{
-			Verify: this.verifyUser,
+	httpMux.HandleController("/api/logs/levels", &controller.LogLevels{
+		Logs: controller.Logs{
+			SessionedJSON: this.sessionedJSON(),
 		},
+		GetLevels: func() logger.LevelSettings {
+			return this.logger.Levels().Export()
+		},
+		SetLevel: func(req status.LogLevelRequest) *types.Throw {
+			return this.setLogLevel(req)
+		},
+	})
+
+	httpMux.HandleController("/api/sessions", &controller.Sessions{
+		SessionedJSON: this.sessionedJSON(),
 		GetSessions: func() []status.SessionDump {
 			return this.getAllSessions()
 		},
 	})
 
-	httpMux.HandleController("/api/sync", &controller.Sync{
-		SessionedJSON: controller.SessionedJSON{
-			Verify: this.verifyUser,
+	httpMux.HandleController("/api/tokens", &controller.Tokens{
+		SessionedJSON: this.sessionedJSON(),
+		GetTokens: func() []status.TokenDump {
+			return this.getAllTokens()
 		},
+		CreateToken: this.createToken,
+		RevokeToken: this.revokeToken,
+	})
+
+	httpMux.HandleController("/api/sync", &controller.Sync{
+		SessionedJSON: this.sessionedJSON(),
 		GetSyncInfo: func() synchronize.Status {
 			return this.getSyncInfo()
 		},
 	})
 
+	httpMux.HandleController("/api/audit", &controller.Audit{
+		SessionedJSON: this.sessionedJSON(),
+		QueryAudit:    this.queryAudit,
+	})
+
+	httpMux.HandleController("/api/blocklist", &controller.Blocklist{
+		Sessioned: this.sessioned(),
+		Public:    this.blocklistOpen,
+		GetClients: func() []client.ClientExport {
+			return this.server.Clients()
+		},
+	})
+
+	if this.metrics != nil {
+		httpMux.HandleController("/metrics", &controller.Metrics{
+			Sessioned: this.sessioned(),
+			Collect:   this.metrics.Collect,
+		})
+	}
+
 	return &http.Server{
 		Addr:         httpAddr,
 		Handler:      httpMux,
@@ -339,6 +827,10 @@ func (this *Status) down() *types.Throw {
 	}
 
 	this.statusListener.Close()
+
+	// Also close the connections which are still streaming
+	this.status.Close()
+
 	this.statusDownWait.Wait()
 
 	this.status = nil
@@ -360,10 +852,25 @@ func (this *Status) Reset() *types.Throw {
 
 		this.accounts = status.Accounts{}
 		this.sessions = status.Sessions{}
+		this.streamTickets = status.StreamTickets{}
+		this.blocklistOpen = false
+		this.loginFailures.AtomicStore(0)
 
 		// Needs manual up
 	})
 
+	// Tokens of the configuration will be added again, the ones created
+	// by the API are kept
+	this.tokenRWLock.Exec(func() {
+		for id, token := range this.tokens {
+			if !token.Static {
+				continue
+			}
+
+			delete(this.tokens, id)
+		}
+	})
+
 	return e
 }
 
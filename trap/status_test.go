@@ -0,0 +1,237 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trap
+
+import (
+	"github.com/raincious/trap/trap/core/audit"
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/status"
+	"github.com/raincious/trap/trap/core/types"
+
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testStatusWithTokenFile(t *testing.T, path types.String) *Status {
+	trapServer := NewServer()
+
+	trapServer.SetLogger(logger.NewLogger())
+
+	s := NewStatus()
+
+	s.SetLogger(logger.NewLogger())
+	s.SetServer(trapServer)
+
+	fileErr := s.SetTokenFile(path)
+
+	if fileErr != nil {
+		t.Errorf("Status.SetTokenFile() failed due to error: %s", fileErr)
+
+		return nil
+	}
+
+	return s
+}
+
+func TestStatusTokensPersist(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-status-token-test-")
+
+	if dirErr != nil {
+		t.Error("Can't create temp dir:", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	path := types.String(filepath.Join(dir, "tokens.json"))
+	ip := net.ParseIP("127.0.0.1")
+
+	_, adminHash, _ := status.NewTokenSecret()
+	admin := status.Token{
+		Name:   "admin",
+		Scopes: []types.String{"tokens:write", "clients:write"},
+	}
+
+	s := testStatusWithTokenFile(t, path)
+
+	if s == nil {
+		return
+	}
+
+	adminToken, adminErr := s.Token(admin, adminHash)
+
+	if adminErr != nil {
+		t.Errorf("Status.Token() failed due to error: %s", adminErr)
+
+		return
+	}
+
+	sess := status.NewTokenSession(ip, adminToken)
+
+	created, createErr := s.createToken(sess, status.TokenRequest{
+		Name:   "ci",
+		Scopes: []types.String{"clients:read"},
+	})
+
+	if createErr != nil {
+		t.Errorf("Status.createToken() failed due to error: %s", createErr)
+
+		return
+	}
+
+	revokeErr := s.revokeToken(sess, adminToken.ID)
+
+	if revokeErr != nil {
+		t.Errorf("Status.revokeToken() failed due to error: %s", revokeErr)
+
+		return
+	}
+
+	// Reload
+	s.Reset()
+
+	if s.SetTokenFile(path) != nil {
+		t.Error("Status.SetTokenFile() failed to load the file on reload")
+
+		return
+	}
+
+	_, adminErr = s.Token(admin, adminHash)
+
+	if adminErr == nil || !adminErr.Is(status.ErrTokenRevoked) {
+		t.Errorf("Status.Token() failed to keep the token revoked after "+
+			"reload. Got '%s'", adminErr)
+
+		return
+	}
+
+	if _, verifyErr := s.verifyToken(ip, created.Token, false); verifyErr != nil {
+		t.Errorf("Status.verifyToken() failed to keep the created token "+
+			"after reload. Got '%s'", verifyErr)
+
+		return
+	}
+
+	// Restart
+	restarted := testStatusWithTokenFile(t, path)
+
+	if restarted == nil {
+		return
+	}
+
+	_, adminErr = restarted.Token(admin, adminHash)
+
+	if adminErr == nil || !adminErr.Is(status.ErrTokenRevoked) {
+		t.Errorf("Status.Token() failed to keep the token revoked after "+
+			"restart. Got '%s'", adminErr)
+
+		return
+	}
+
+	if _, verifyErr := restarted.verifyToken(ip,
+		created.Token, false); verifyErr != nil {
+		t.Errorf("Status.verifyToken() failed to keep the created token "+
+			"after restart. Got '%s'", verifyErr)
+
+		return
+	}
+}
+
+func TestStatusTokenUseAudit(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("", "trap-status-token-test-")
+
+	if dirErr != nil {
+		t.Error("Can't create temp dir:", dirErr)
+
+		return
+	}
+
+	defer os.RemoveAll(dir)
+
+	ip := net.ParseIP("127.0.0.1")
+
+	s := testStatusWithTokenFile(t,
+		types.String(filepath.Join(dir, "tokens.json")))
+
+	if s == nil {
+		return
+	}
+
+	a := NewAudit()
+
+	a.SetLogger(logger.NewLogger())
+	a.SetServer(s.server)
+
+	s.SetAudit(a)
+
+	secret, hash, _ := status.NewTokenSecret()
+
+	_, tokenErr := s.Token(status.Token{
+		Name:   "feed",
+		Scopes: []types.String{"clients:read"},
+	}, hash)
+
+	if tokenErr != nil {
+		t.Errorf("Status.Token() failed due to error: %s", tokenErr)
+
+		return
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, verifyErr := s.verifyToken(ip, secret,
+			false); verifyErr != nil {
+			t.Errorf("Status.verifyToken() failed due to error: %s",
+				verifyErr)
+
+			return
+		}
+	}
+
+	// Requests which change the state are audited by the middleware
+	s.verifyToken(ip, secret, true)
+	s.verifyToken(ip, "bad", true)
+
+	entries := s.queryAudit(audit.Query{}).Entries
+
+	if len(entries) != 0 {
+		t.Errorf("Status.verifyToken() failed to leave the token use to "+
+			"the middleware. Expecting '%d' entries, got '%d'",
+			0, len(entries))
+
+		return
+	}
+
+	s.verifyToken(ip, "bad", false)
+
+	entries = s.queryAudit(audit.Query{}).Entries
+
+	if len(entries) != 1 || entries[0].Action != audit.ACTION_TOKEN_USE {
+		t.Errorf("Status.verifyToken() failed to record the bad token. "+
+			"Expecting '%d' entries, got '%d'", 1, len(entries))
+
+		return
+	}
+}
@@ -22,6 +22,7 @@
 package trap
 
 import (
+	"github.com/raincious/trap/trap/core/audit"
 	"github.com/raincious/trap/trap/core/logger"
 	"github.com/raincious/trap/trap/core/server"
 	"github.com/raincious/trap/trap/core/sync"
@@ -58,6 +59,7 @@ type Sync struct {
 	cronDownChan      chan bool
 	downing           bool
 	downingLock       types.Mutex
+	audit             *Audit
 }
 
 func NewSync() *Sync {
@@ -117,7 +119,10 @@ func (s *Sync) nodes() *sync.Nodes {
 						client.Server.IP = newIP.IP
 					}
 
-					s.trapServer.ImportClient(client)
+					_, importErr := s.trapServer.ImportClient(client)
+
+					s.record(c, audit.ACTION_SYNC_CLIENT_MARK,
+						types.String(client.Client.String()), importErr)
 
 					importedClients = append(importedClients, client)
 				}
@@ -135,7 +140,10 @@ func (s *Sync) nodes() *sync.Nodes {
 				clients []types.IP,
 			) *types.Throw {
 				for _, client := range clients {
-					s.trapServer.RemoveClient(client)
+					removeErr := s.trapServer.RemoveClient(client)
+
+					s.record(c, audit.ACTION_SYNC_CLIENT_UNMARK,
+						types.String(client.String()), removeErr)
 				}
 
 				go s.nodes().BroadcastUnmarkClients([]*conn.Conn{c},
@@ -146,6 +154,46 @@ func (s *Sync) nodes() *sync.Nodes {
 
 				return nil
 			},
+			MarkPrefixes: func(
+				c *conn.Conn,
+				prefixes []server.PrefixInfo,
+			) *types.Throw {
+				importedPrefixes := s.importPrefixes(c, prefixes)
+
+				// Stop here when all of them are already known, otherwise
+				// the prefixes will be passed between nodes forever
+				if len(importedPrefixes) <= 0 {
+					return nil
+				}
+
+				go s.nodes().BroadcastMarkPrefixes([]*conn.Conn{c},
+					importedPrefixes, s.syncRetry)
+
+				go s.server().BroadcastMarkPrefixes([]*conn.Conn{c},
+					importedPrefixes, s.syncRetry)
+
+				return nil
+			},
+			UnmarkPrefixes: func(
+				c *conn.Conn,
+				prefixes []server.PrefixInfo,
+			) *types.Throw {
+				removedPrefixes := s.removePrefixes(c, prefixes)
+
+				// Same as the marking, only pass on the prefixes which
+				// are removed here
+				if len(removedPrefixes) <= 0 {
+					return nil
+				}
+
+				go s.nodes().BroadcastUnmarkPrefixes([]*conn.Conn{c},
+					removedPrefixes, s.syncRetry)
+
+				go s.server().BroadcastUnmarkPrefixes([]*conn.Conn{c},
+					removedPrefixes, s.syncRetry)
+
+				return nil
+			},
 		},
 		AddPartners: func(
 			c *conn.Conn, ips types.SearchableIPAddresses) *types.Throw {
@@ -214,7 +262,10 @@ func (s *Sync) server() *communication.Server {
 						client.Server.IP = newIP.IP
 					}
 
-					s.trapServer.ImportClient(client)
+					_, importErr := s.trapServer.ImportClient(client)
+
+					s.record(c, audit.ACTION_SYNC_CLIENT_MARK,
+						types.String(client.Client.String()), importErr)
 
 					importedClients = append(importedClients, client)
 				}
@@ -232,7 +283,10 @@ func (s *Sync) server() *communication.Server {
 				clients []types.IP,
 			) *types.Throw {
 				for _, client := range clients {
-					s.trapServer.RemoveClient(client)
+					removeErr := s.trapServer.RemoveClient(client)
+
+					s.record(c, audit.ACTION_SYNC_CLIENT_UNMARK,
+						types.String(client.String()), removeErr)
 				}
 
 				go s.nodes().BroadcastUnmarkClients([]*conn.Conn{c},
@@ -243,6 +297,46 @@ func (s *Sync) server() *communication.Server {
 
 				return nil
 			},
+			MarkPrefixes: func(
+				c *conn.Conn,
+				prefixes []server.PrefixInfo,
+			) *types.Throw {
+				importedPrefixes := s.importPrefixes(c, prefixes)
+
+				// Stop here when all of them are already known, otherwise
+				// the prefixes will be passed between nodes forever
+				if len(importedPrefixes) <= 0 {
+					return nil
+				}
+
+				go s.nodes().BroadcastMarkPrefixes([]*conn.Conn{c},
+					importedPrefixes, s.syncRetry)
+
+				go s.server().BroadcastMarkPrefixes([]*conn.Conn{c},
+					importedPrefixes, s.syncRetry)
+
+				return nil
+			},
+			UnmarkPrefixes: func(
+				c *conn.Conn,
+				prefixes []server.PrefixInfo,
+			) *types.Throw {
+				removedPrefixes := s.removePrefixes(c, prefixes)
+
+				// Same as the marking, only pass on the prefixes which
+				// are removed here
+				if len(removedPrefixes) <= 0 {
+					return nil
+				}
+
+				go s.nodes().BroadcastUnmarkPrefixes([]*conn.Conn{c},
+					removedPrefixes, s.syncRetry)
+
+				go s.server().BroadcastUnmarkPrefixes([]*conn.Conn{c},
+					removedPrefixes, s.syncRetry)
+
+				return nil
+			},
 		},
 		OnAuthed: func(connection *conn.Conn) {
 			s.activeClients.Add(connection)
@@ -288,6 +382,8 @@ func (s *Sync) server() *communication.Server {
 	handle.Register(messager.SYNC_SIGNAL_HEATBEAT, contrl.Heatbeat)
 	handle.Register(messager.SYNC_SIGNAL_CLIENT_MARK, contrl.ClientsMarked)
 	handle.Register(messager.SYNC_SIGNAL_CLIENT_UNMARK, contrl.ClientsUnmarked)
+	handle.Register(messager.SYNC_SIGNAL_PREFIX_MARK, contrl.PrefixesMarked)
+	handle.Register(messager.SYNC_SIGNAL_PREFIX_UNMARK, contrl.PrefixesUnmarked)
 
 	comServer := &communication.Server{
 		OnConnected: func(conn *conn.Conn) {},
@@ -308,6 +404,76 @@ func (s *Sync) SetServer(srv *Server) {
 	s.trapServer = srv
 }
 
+func (s *Sync) SetAudit(a *Audit) {
+	s.audit = a
+}
+
+// Import prefixes from the node, returns the ones which are newly marked
+// and should be passed on to other nodes
+func (s *Sync) importPrefixes(c *conn.Conn,
+	prefixes []server.PrefixInfo) []server.PrefixInfo {
+	importedPrefixes := []server.PrefixInfo{}
+
+	for _, prefix := range prefixes {
+		importErr := s.trapServer.ImportPrefix(prefix)
+
+		s.record(c, audit.ACTION_SYNC_PREFIX_MARK, prefix.CIDR(), importErr)
+
+		if importErr != nil {
+			continue
+		}
+
+		importedPrefixes = append(importedPrefixes, prefix)
+	}
+
+	return importedPrefixes
+}
+
+func (s *Sync) removePrefixes(c *conn.Conn,
+	prefixes []server.PrefixInfo) []server.PrefixInfo {
+	removedPrefixes := []server.PrefixInfo{}
+
+	for _, prefix := range prefixes {
+		removeErr := s.trapServer.RemovePrefix(prefix)
+
+		s.record(c, audit.ACTION_SYNC_PREFIX_UNMARK, prefix.CIDR(), removeErr)
+
+		if removeErr != nil {
+			continue
+		}
+
+		removedPrefixes = append(removedPrefixes, prefix)
+	}
+
+	return removedPrefixes
+}
+
+// Record the action done by the node in the audit log
+func (s *Sync) record(c *conn.Conn, action types.String,
+	target types.String, err *types.Throw) {
+	if s.audit == nil {
+		return
+	}
+
+	entry := audit.Entry{
+		Actor: types.String(audit.ACTOR_SYNC_PREFIX +
+			c.RemoteAddr().String()),
+		Action: action,
+		Target: target,
+	}
+
+	if remoteAddr, remoteErr := types.ConvertIPAddress(
+		c.RemoteAddr()); remoteErr == nil {
+		entry.IP = types.String(remoteAddr.IP.String())
+	}
+
+	if err != nil {
+		entry.Error = types.String(err.Error())
+	}
+
+	s.audit.Record(entry)
+}
+
 func (s *Sync) SetLogger(l *logger.Logger) {
 	s.logger = l.NewContext("Sync")
 }
@@ -407,6 +573,8 @@ func (s *Sync) connectAllNodes() {
 			func(c *conn.Conn) {
 				s.logger.Debugf("Node '%s' is connected",
 					node.Address().String())
+
+				s.triggerNodeEvent("on.sync.node.connected", node, nil)
 			},
 			func(c *conn.Conn, ips types.IPAddresses) {
 				defer s.server().BroadcastNewPartners([]*conn.Conn{}, ips,
@@ -414,6 +582,8 @@ func (s *Sync) connectAllNodes() {
 
 				s.logger.Infof("Logged in to node '%s'",
 					node.Address().String())
+
+				s.triggerNodeEvent("on.sync.node.authed", node, nil)
 			},
 			func(rmPartners types.IPAddresses, c *conn.Conn, err *types.Throw) {
 				defer s.server().BroadcastDetachedPartners([]*conn.Conn{},
@@ -423,22 +593,36 @@ func (s *Sync) connectAllNodes() {
 					s.logger.Warningf("Node '%s' is dropped due to error: %s",
 						node.Address().String(), err)
 
+					s.triggerNodeEvent("on.sync.node.disconnected", node, err)
+
 					return
 				}
 
 				s.logger.Infof("Node '%s' is disconnected",
 					node.Address().String())
+
+				s.triggerNodeEvent("on.sync.node.disconnected", node, nil)
 			})
 
 		if connectErr != nil {
 			s.logger.Warningf("Can't connect to node '%s' due to error: %s",
 				node.Address().String(), connectErr)
+
+			if connectErr.Is(communication.ErrSessionAuthFailedConflicted) {
+				s.triggerNodeEvent("on.sync.node.conflicted", node,
+					connectErr)
+			}
 		}
 
 		return nil
 	})
 }
 
+func (s *Sync) triggerNodeEvent(name types.String, node *sync.Node,
+	err *types.Throw) {
+	s.trapServer.Event().Trigger(name, nodeParameters(node.Address(), err))
+}
+
 func (s *Sync) tryConnectToAllNodes() {
 	if s.isDowning() {
 		return
@@ -536,6 +720,26 @@ func (s *Sync) up() *types.Throw {
 			clients, s.syncRetry)
 	})
 
+	s.trapServer.OnPrefixMark(func(prefix server.PrefixInfo) {
+		prefixes := []server.PrefixInfo{prefix}
+
+		go s.nodes().BroadcastMarkPrefixes([]*conn.Conn{},
+			prefixes, s.syncRetry)
+
+		go s.server().BroadcastMarkPrefixes([]*conn.Conn{},
+			prefixes, s.syncRetry)
+	})
+
+	s.trapServer.OnPrefixUnmark(func(prefix server.PrefixInfo) {
+		prefixes := []server.PrefixInfo{prefix}
+
+		go s.nodes().BroadcastUnmarkPrefixes([]*conn.Conn{},
+			prefixes, s.syncRetry)
+
+		go s.server().BroadcastUnmarkPrefixes([]*conn.Conn{},
+			prefixes, s.syncRetry)
+	})
+
 	sErr := s.server().Listen(
 		s.listenOn,
 		s.tlsCert,
@@ -0,0 +1,205 @@
+/*
+ * Trap
+ * An anti-pryer server for better privacy
+ *
+ * This file is a part of Trap project
+ *
+ * Copyright 2016 Rain Lee <raincious@gmail.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package trap
+
+import (
+	"github.com/raincious/trap/trap/core/logger"
+	"github.com/raincious/trap/trap/core/server"
+	"github.com/raincious/trap/trap/core/types"
+
+	"testing"
+)
+
+func TestSyncMarkPrefixesBroadcastStops(t *testing.T) {
+	type message struct {
+		from     int
+		to       int
+		prefixes []server.PrefixInfo
+	}
+
+	nodes := []*Sync{}
+
+	for i := 0; i < 3; i++ {
+		trapServer := NewServer()
+
+		trapServer.SetLogger(logger.NewLogger())
+		trapServer.SetAggregate(24, 64, 2)
+
+		node := NewSync()
+
+		node.SetServer(trapServer)
+
+		nodes = append(nodes, node)
+	}
+
+	ip, _ := types.ConvertIPFromString("192.0.2.0")
+	prefixes := []server.PrefixInfo{{Network: ip, Bits: 24}}
+
+	// The first node marked the prefix, and sent it to the others
+	if nodes[0].trapServer.ImportPrefix(prefixes[0]) != nil {
+		t.Error("Server.ImportPrefix() failed to mark the prefix")
+
+		return
+	}
+
+	queue := []message{{0, 1, prefixes}, {0, 2, prefixes}}
+	delivered := 0
+
+	for len(queue) > 0 {
+		msg := queue[0]
+		queue = queue[1:]
+
+		delivered++
+
+		if delivered > 16 {
+			t.Errorf("Sync.importPrefixes() failed to stop the broadcast. "+
+				"Still broadcasting after '%d' messages", delivered)
+
+			return
+		}
+
+		imported := nodes[msg.to].importPrefixes(nil, msg.prefixes)
+
+		if len(imported) <= 0 {
+			continue
+		}
+
+		// Pass it on to every node except the sender
+		for next := range nodes {
+			if next == msg.from || next == msg.to {
+				continue
+			}
+
+			queue = append(queue, message{msg.to, next, imported})
+		}
+	}
+
+	if delivered != 4 {
+		t.Errorf("Sync.importPrefixes() failed to stop the broadcast. "+
+			"Expecting '%d' messages, got '%d'", 4, delivered)
+
+		return
+	}
+
+	for i, node := range nodes {
+		if len(node.trapServer.Clients()) != 1 {
+			t.Errorf("Sync.importPrefixes() failed to mark the prefix on "+
+				"node '%d'", i)
+
+			return
+		}
+	}
+}
+
+func TestSyncUnmarkPrefixesBroadcastStops(t *testing.T) {
+	type message struct {
+		from     int
+		to       int
+		prefixes []server.PrefixInfo
+	}
+
+	ip, _ := types.ConvertIPFromString("192.0.2.0")
+	prefixes := []server.PrefixInfo{{Network: ip, Bits: 24}}
+
+	nodes := []*Sync{}
+	rebroadcasted := 0
+
+	for i := 0; i < 3; i++ {
+		trapServer := NewServer()
+
+		trapServer.SetLogger(logger.NewLogger())
+		trapServer.SetAggregate(24, 64, 2)
+
+		trapServer.OnPrefixUnmark(func(server.PrefixInfo) {
+			rebroadcasted++
+		})
+
+		if trapServer.ImportPrefix(prefixes[0]) != nil {
+			t.Error("Server.ImportPrefix() failed to mark the prefix")
+
+			return
+		}
+
+		node := NewSync()
+
+		node.SetServer(trapServer)
+
+		nodes = append(nodes, node)
+	}
+
+	// The first node removed the prefix, and sent it to the others
+	nodes[0].removePrefixes(nil, prefixes)
+
+	queue := []message{{0, 1, prefixes}, {0, 2, prefixes}}
+	delivered := 0
+
+	for len(queue) > 0 {
+		msg := queue[0]
+		queue = queue[1:]
+
+		delivered++
+
+		if delivered > 16 {
+			t.Errorf("Sync.removePrefixes() failed to stop the broadcast. "+
+				"Still broadcasting after '%d' messages", delivered)
+
+			return
+		}
+
+		removed := nodes[msg.to].removePrefixes(nil, msg.prefixes)
+
+		if len(removed) <= 0 {
+			continue
+		}
+
+		for next := range nodes {
+			if next == msg.from || next == msg.to {
+				continue
+			}
+
+			queue = append(queue, message{msg.to, next, removed})
+		}
+	}
+
+	if delivered != 4 {
+		t.Errorf("Sync.removePrefixes() failed to stop the broadcast. "+
+			"Expecting '%d' messages, got '%d'", 4, delivered)
+
+		return
+	}
+
+	if rebroadcasted != 0 {
+		t.Errorf("Server.RemovePrefix() failed to skip the unmark hooks. "+
+			"Expecting '%d' calls, got '%d'", 0, rebroadcasted)
+
+		return
+	}
+
+	for i, node := range nodes {
+		if len(node.trapServer.Clients()) != 0 {
+			t.Errorf("Sync.removePrefixes() failed to unmark the prefix on "+
+				"node '%d'", i)
+
+			return
+		}
+	}
+}